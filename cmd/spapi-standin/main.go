@@ -0,0 +1,96 @@
+// cmd/spapi-standin - Local stand-in for the Amazon SP-API that serves recorded responses
+//
+// Point the app at it with:
+//
+//	SPAPI_ENDPOINT=http://localhost:8089 SPAPI_LWA_TOKEN_URL=http://localhost:8089/auth/o2/token
+//	SPAPI_LWA_CLIENT_ID=standin SPAPI_LWA_CLIENT_SECRET=standin
+//	SPAPI_REFRESH_TOKEN=standin SPAPI_REQUEST_INTERVAL_MS=0
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8089", "listen address")
+	dir := flag.String("data", "testdata/amazon", "directory with recorded responses")
+	flag.Parse()
+
+	baseURL := "http://" + *addr
+
+	// Recorded responses reference customization files as {{BASE_URL}}/customizations/...
+	serveFile := func(w http.ResponseWriter, path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, `{"errors":[{"code":"NotFound","message":"no recording"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bytes.ReplaceAll(data, []byte("{{BASE_URL}}"), []byte(baseURL)))
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /auth/o2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"Atza|standin","token_type":"bearer","expires_in":3600}`))
+	})
+
+	mux.HandleFunc("GET /orders/v0/orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-amz-access-token") == "" {
+			http.Error(w, `{"errors":[{"code":"Unauthorized","message":"missing token"}]}`, http.StatusForbidden)
+			return
+		}
+		page := "orders.json"
+		if token := r.URL.Query().Get("NextToken"); token != "" {
+			page = "orders_" + filepath.Base(token) + ".json"
+		}
+		serveFile(w, filepath.Join(*dir, page))
+	})
+
+	mux.HandleFunc("GET /orders/v0/orders/{id}/orderItems", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, filepath.Join(*dir, "orderItems", filepath.Base(r.PathValue("id"))+".json"))
+	})
+
+	// Amazon serves customizations as a zip holding a JSON document; zip the recording on the fly
+	mux.HandleFunc("GET /customizations/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.PathValue("name"))
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		data, err := os.ReadFile(filepath.Join(*dir, "customizations", base+".json"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if !strings.HasSuffix(name, ".zip") {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+			return
+		}
+
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		f, _ := archive.Create(base + ".json")
+		f.Write(data)
+		archive.Close()
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(buf.Bytes())
+	})
+
+	log.Printf("SP-API stand-in serving %s on %s", *dir, baseURL)
+	log.Fatal(http.ListenAndServe(*addr, logRequests(mux)))
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the server configuration. Load fills it from, in increasing
+// precedence: the defaults below, a YAML file, environment variables and
+// command-line flags.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Uploads  UploadConfig   `yaml:"uploads"`
+
+	// The services, in services.go
+	AI            AIConfig            `yaml:"ai"`
+	Transcription TranscriptionConfig `yaml:"transcription"`
+	OCR           OCRConfig           `yaml:"ocr"`
+	SLA           SLAConfig           `yaml:"sla"`
+	SMS           SMSConfig           `yaml:"sms"`
+	WhatsApp      WhatsAppConfig      `yaml:"whatsapp"`
+	Notifications NotificationConfig  `yaml:"notifications"`
+	Webhooks      WebhookConfig       `yaml:"webhooks"`
+	Marketplace   MarketplaceConfig   `yaml:"marketplace"`
+}
+
+type ServerConfig struct {
+	Port        int      `yaml:"port"`
+	Mode        string   `yaml:"mode"` // gin mode: debug, release or test
+	CORSOrigins []string `yaml:"cors_origins"`
+}
+
+type DatabaseConfig struct {
+	// DSN, as key=value pairs or a postgres:// URL, replaces every
+	// connection field below. It may hold a password so it is printed redacted.
+	DSN         string `yaml:"dsn"`
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"` // secret, never defaulted or printed
+	Name        string `yaml:"name"`
+	SSLMode     string `yaml:"sslmode"`     // disable, allow, prefer, require, verify-ca or verify-full
+	SSLRootCert string `yaml:"sslrootcert"` // CA file for verify-ca and verify-full
+
+	// Read replicas, as DSNs, for queries that can tolerate replication lag
+	Replicas []string `yaml:"replicas"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// Startup retries, waiting RetryBackoff, then twice as long, up to a minute
+	ConnectAttempts int           `yaml:"connect_attempts"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+}
+
+type UploadConfig struct {
+	Dir            string `yaml:"dir"`
+	RecordingsDir  string `yaml:"recordings_dir"`   // call recordings, outside Dir because Dir is served publicly
+	MaxRequestMB   int    `yaml:"max_request_mb"`   // whole multipart upload request
+	MaxFileMB      int    `yaml:"max_file_mb"`      // each uploaded image
+	MaxRecordingMB int    `yaml:"max_recording_mb"` // each call recording
+}
+
+// Defaults is the configuration before any file, environment or flags.
+// Secrets are deliberately left empty.
+func Defaults() Config {
+	cfg := Config{
+		Server: ServerConfig{
+			Port:        8080,
+			Mode:        "debug",
+			CORSOrigins: []string{"*"},
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			Name:            "customflow",
+			SSLMode:         "prefer",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnectAttempts: 10,
+			RetryBackoff:    time.Second,
+		},
+		Uploads: UploadConfig{
+			Dir:            "./uploads",
+			RecordingsDir:  "./recordings",
+			MaxRequestMB:   32,
+			MaxFileMB:      10,
+			MaxRecordingMB: 25,
+		},
+	}
+	serviceDefaults(&cfg)
+	return cfg
+}
+
+// setting is one value that can come from the environment and, unless it is
+// a secret, from a flag. Secrets have no flag so they never show up in ps.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = append([]setting{
+	{"PORT", "port", "HTTP port", func(c *Config, v string) error { return setInt(&c.Server.Port, v) }},
+	{"GIN_MODE", "mode", "gin mode: debug, release or test", func(c *Config, v string) error { c.Server.Mode = v; return nil }},
+	{"CORS_ORIGINS", "cors-origins", "comma separated allowed CORS origins", func(c *Config, v string) error { c.Server.CORSOrigins = splitList(v); return nil }},
+	{"DB_HOST", "db-host", "database host", func(c *Config, v string) error { c.Database.Host = v; return nil }},
+	{"DB_PORT", "db-port", "database port", func(c *Config, v string) error { return setInt(&c.Database.Port, v) }},
+	{"DB_USER", "db-user", "database user", func(c *Config, v string) error { c.Database.User = v; return nil }},
+	{"DB_PASSWORD", "", "", func(c *Config, v string) error { c.Database.Password = v; return nil }},
+	{"DB_NAME", "db-name", "database name", func(c *Config, v string) error { c.Database.Name = v; return nil }},
+	{"DATABASE_URL", "", "", func(c *Config, v string) error { c.Database.DSN = v; return nil }},
+	{"DB_SSLMODE", "db-sslmode", "database sslmode", func(c *Config, v string) error { c.Database.SSLMode = v; return nil }},
+	{"DB_SSLROOTCERT", "db-sslrootcert", "database CA certificate file", func(c *Config, v string) error { c.Database.SSLRootCert = v; return nil }},
+	{"DB_REPLICAS", "", "", func(c *Config, v string) error { c.Database.Replicas = splitList(v); return nil }},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "database pool size", func(c *Config, v string) error { return setInt(&c.Database.MaxOpenConns, v) }},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "idle connections kept in the pool", func(c *Config, v string) error { return setInt(&c.Database.MaxIdleConns, v) }},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "longest a connection is reused, e.g. 30m", func(c *Config, v string) error { return setDuration(&c.Database.ConnMaxLifetime, v) }},
+	{"DB_CONNECT_ATTEMPTS", "db-connect-attempts", "connection attempts at startup", func(c *Config, v string) error { return setInt(&c.Database.ConnectAttempts, v) }},
+	{"DB_RETRY_BACKOFF", "db-retry-backoff", "wait before the first retry, e.g. 1s", func(c *Config, v string) error { return setDuration(&c.Database.RetryBackoff, v) }},
+	{"UPLOADS_DIR", "uploads-dir", "directory for uploaded files", func(c *Config, v string) error { c.Uploads.Dir = v; return nil }},
+	{"RECORDINGS_DIR", "recordings-dir", "directory for call recordings, not served over HTTP", func(c *Config, v string) error { c.Uploads.RecordingsDir = v; return nil }},
+	{"UPLOAD_MAX_REQUEST_MB", "upload-max-request-mb", "largest upload request in MB", func(c *Config, v string) error { return setInt(&c.Uploads.MaxRequestMB, v) }},
+	{"UPLOAD_MAX_FILE_MB", "upload-max-file-mb", "largest uploaded image in MB", func(c *Config, v string) error { return setInt(&c.Uploads.MaxFileMB, v) }},
+	{"UPLOAD_MAX_RECORDING_MB", "upload-max-recording-mb", "largest call recording in MB", func(c *Config, v string) error { return setInt(&c.Uploads.MaxRecordingMB, v) }},
+}, serviceSettings...)
+
+// Load parses the command line and builds the configuration. The YAML file
+// is named by --config or CONFIG_FILE. printConfig reports --print-config.
+// The result is not validated; call Validate.
+func Load(args []string) (cfg Config, printConfig bool, err error) {
+	fs := flag.NewFlagSet("customflow", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, s := range settings {
+		if s.flag != "" {
+			fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+
+	cfg = Defaults()
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return cfg, printConfig, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return cfg, printConfig, fmt.Errorf("invalid %s: %v", s.env, err)
+			}
+		}
+	}
+
+	// Only flags given on the command line override
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				if setErr := s.set(&cfg, f.Value.String()); setErr != nil {
+					err = fmt.Errorf("invalid --%s: %v", s.flag, setErr)
+				}
+			}
+		}
+	})
+	return cfg, printConfig, err
+}
+
+// loadFile overlays a YAML file on cfg; unknown keys are an error so typos don't go unnoticed
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(contains([]string{"debug", "release", "test"}, c.Server.Mode), "server.mode must be debug, release or test, got %q", c.Server.Mode)
+	check(len(c.Server.CORSOrigins) > 0, "server.cors_origins must list at least one origin")
+
+	if c.Database.DSN == "" {
+		check(c.Database.Host != "", "database.host is required (DB_HOST), or set database.dsn (DATABASE_URL)")
+		check(c.Database.Port > 0 && c.Database.Port <= 65535, "database.port must be between 1 and 65535, got %d", c.Database.Port)
+		check(c.Database.User != "", "database.user is required (DB_USER)")
+		check(c.Database.Password != "", "database.password is required (DB_PASSWORD)")
+		check(c.Database.Name != "", "database.name is required (DB_NAME)")
+		check(contains(sslModes, c.Database.SSLMode), "database.sslmode must be one of %s, got %q", strings.Join(sslModes, ", "), c.Database.SSLMode)
+	}
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns must be positive")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns must be between 0 and database.max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.ConnectAttempts > 0, "database.connect_attempts must be positive")
+	check(c.Database.RetryBackoff > 0, "database.retry_backoff must be positive")
+
+	check(c.Uploads.Dir != "", "uploads.dir is required")
+	check(c.Uploads.RecordingsDir != "", "uploads.recordings_dir is required")
+	check(!insideDir(c.Uploads.RecordingsDir, c.Uploads.Dir), "uploads.recordings_dir must be outside uploads.dir, which is served without authentication")
+	check(c.Uploads.MaxFileMB > 0, "uploads.max_file_mb must be positive")
+	check(c.Uploads.MaxRecordingMB > 0, "uploads.max_recording_mb must be positive")
+	check(c.Uploads.MaxRequestMB >= c.Uploads.MaxFileMB, "uploads.max_request_mb must be at least uploads.max_file_mb")
+
+	c.validateServices(check)
+
+	return errors.Join(errs...)
+}
+
+// insideDir reports whether path is dir or below it
+func insideDir(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && (rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))))
+}
+
+// Redacted is a copy safe to print or log, with secrets masked
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+	c.Database.DSN = redactDSN(c.Database.DSN)
+	replicas := make([]string, len(c.Database.Replicas))
+	for i, dsn := range c.Database.Replicas {
+		replicas[i] = redactDSN(dsn)
+	}
+	c.Database.Replicas = replicas
+	c.redactServices()
+	return c
+}
+
+// Print writes the redacted configuration as YAML
+func (c Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func setDuration(dest *time.Duration, value string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("expected a duration such as 30s or 5m, got %q", value)
+	}
+	*dest = d
+	return nil
+}
+
+func setInt(dest *int, value string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("expected a whole number, got %q", value)
+	}
+	*dest = n
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
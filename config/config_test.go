@@ -0,0 +1,91 @@
+package config
+
+import "testing"
+
+func TestInsideDir(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"./uploads", "uploads", true},
+		{"./uploads/recordings", "./uploads", true},
+		{"uploads/../uploads/calls", "uploads", true},
+		{"./recordings", "./uploads", false},
+		{"./uploads-private", "./uploads", false},
+		{"../uploads", "uploads", false},
+		{"/var/lib/recordings", "/var/lib/uploads", false},
+	}
+	for _, tt := range tests {
+		if got := insideDir(tt.path, tt.dir); got != tt.want {
+			t.Errorf("insideDir(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestLoadExampleWithServiceEnv(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "secret")
+	t.Setenv("OCR_WORKERS", "8")
+	t.Setenv("SLA_DUE_DAYS_AMAZON", "1")
+	t.Setenv("AI_PRICES", "gpt-4o=2.50/10.00")
+	t.Setenv("TWILIO_AUTH_TOKEN", "token")
+
+	cfg, _, err := Load([]string{"--config", "../config.example.yaml", "--sla-weekend", "fri,sat"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if cfg.OCR.Workers != 8 {
+		t.Errorf("ocr.workers = %d, want 8 from OCR_WORKERS", cfg.OCR.Workers)
+	}
+	if due := cfg.SLA.Sources["amazon"].DueDays; due == nil || *due != 1 {
+		t.Errorf("sla.sources.amazon.due_days = %v, want 1 from SLA_DUE_DAYS_AMAZON", due)
+	}
+	if cfg.SLA.Sources["amazon"].PromiseDays != nil {
+		t.Error("SLA_DUE_DAYS_AMAZON also set the promise days")
+	}
+	if got := cfg.AI.Prices["gpt-4o"]; got != (AIPrice{Input: 2.50, Output: 10.00}) {
+		t.Errorf("ai.prices.gpt-4o = %+v, want 2.50/10.00", got)
+	}
+	if len(cfg.SLA.Weekend) != 2 || cfg.SLA.Weekend[0] != "fri" {
+		t.Errorf("sla.weekend = %v, want the --sla-weekend flag", cfg.SLA.Weekend)
+	}
+	if got := cfg.Redacted().SMS.Twilio.AuthToken; got != redacted {
+		t.Errorf("redacted sms.twilio.auth_token = %q", got)
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+	}{
+		{"full weekend", func(c *Config) { c.SLA.Weekend = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"} }},
+		{"unknown weekday", func(c *Config) { c.SLA.Weekend = []string{"caturday"} }},
+		{"bad holiday", func(c *Config) { c.SLA.Holidays = []string{"25/12/2026"} }},
+		{"unknown sla source", func(c *Config) { c.SLA.Sources = map[string]SLAOverride{"ebay": {}} }},
+		{"unknown sms provider", func(c *Config) { c.SMS.Provider = "nexmo" }},
+		{"twilio without token", func(c *Config) { c.SMS.Twilio.AccountSID, c.SMS.Twilio.FromNumber = "AC123", "+15550100" }},
+		{"openai transcriber without key", func(c *Config) { c.Transcription.Provider = "openai" }},
+		{"amazon without client", func(c *Config) { c.Marketplace.Amazon.RefreshToken = "token" }},
+		{"no webhook attempts", func(c *Config) { c.Webhooks.MaxAttempts = 0 }},
+	}
+
+	valid := Defaults()
+	valid.Database.User, valid.Database.Password = "customflow", "secret"
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("defaults with a password: Validate() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate() accepted the configuration")
+			}
+		})
+	}
+}
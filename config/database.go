@@ -3,40 +3,163 @@ package config
 import (
 	"fmt"
 	"log"
-	"os"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 var DB *gorm.DB
 
-func ConnectDatabase() {
-	dbHost := getEnv("DB_HOST", "16.171.147.121")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbUser := getEnv("DB_USER", "celentris")
-	dbPassword := getEnv("DB_PASSWORD", "Thara2224")
-	dbName := getEnv("DB_NAME", "customflow")
+// Accepted values of database.sslmode, as libpq defines them
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// Name of the dbresolver configuration holding the read replicas. Queries
+// only go to a replica when they ask for it through ReplicaDB.
+const replicaResolver = "read-replicas"
 
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+// Longest wait between startup connection attempts
+const maxRetryBackoff = time.Minute
+
+var replicasEnabled bool
+
+// ConnectDatabase opens DB with the pool limits and read replicas from cfg,
+// retrying with exponential backoff while the database is unreachable
+func ConnectDatabase(cfg DatabaseConfig) error {
+	wait := cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := openDatabase(cfg)
+		if err == nil {
+			DB = db
+			replicasEnabled = len(cfg.Replicas) > 0
+			break
+		}
+		if attempt >= cfg.ConnectAttempts {
+			return fmt.Errorf("giving up after %d attempts: %v", attempt, err)
+		}
+
+		log.Printf("Database connection attempt %d/%d failed: %v (retrying in %s)", attempt, cfg.ConnectAttempts, err, wait)
+		time.Sleep(wait)
+		wait = min(wait*2, maxRetryBackoff)
+	}
+
+	log.Printf("Database connection established (%d read replicas)", len(cfg.Replicas))
+	return nil
+}
+
+func openDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.ConnectionString()), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
+	if err != nil {
+		return nil, err
+	}
 
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	if len(cfg.Replicas) == 0 {
+		return db, nil
+	}
+
+	replicas := make([]gorm.Dialector, len(cfg.Replicas))
+	for i, dsn := range cfg.Replicas {
+		replicas[i] = postgres.Open(dsn)
+	}
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}, replicaResolver).
+		SetMaxOpenConns(cfg.MaxOpenConns).
+		SetMaxIdleConns(cfg.MaxIdleConns).
+		SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if err := db.Use(resolver); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to connect to read replicas: %v", err)
 	}
+	return db, nil
+}
+
+// ReplicaDB is DB with its queries sent to a read replica, or DB itself when
+// there are none. Only use it for reads that can tolerate replication lag:
+// a row written a moment ago may not be there yet.
+func ReplicaDB() *gorm.DB {
+	if !replicasEnabled {
+		return DB
+	}
+	return DB.Clauses(dbresolver.Use(replicaResolver)).Session(&gorm.Session{})
+}
 
-	log.Println("Database connection established")
+// ConnectionString is DSN when set, else a DSN built from the connection fields
+func (c DatabaseConfig) ConnectionString() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", fmt.Sprint(c.Port)},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.Name},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p.value != "" {
+			parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+// quoteDSNValue quotes a key=value DSN value when it is empty or has spaces,
+// quotes or backslashes
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
 		return value
 	}
-	return defaultValue
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
+}
+
+const redacted = "[redacted]"
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN masks the password in a key=value or URL DSN. A URL that does not
+// parse, or whose user info is lost to an unescaped '#' or '?', has everything
+// between "://" and the last '@' masked.
+func redactDSN(dsn string) string {
+	if scheme, rest, ok := strings.Cut(dsn, "://"); ok {
+		u, err := url.Parse(dsn)
+		if at := strings.LastIndex(rest, "@"); at >= 0 && (err != nil || u.User == nil) {
+			return scheme + "://" + redacted + rest[at:]
+		}
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), "redacted")
+			}
+		}
+		// Passwords can also be given as a query parameter
+		if query := u.Query(); query.Has("password") {
+			query.Set("password", "redacted")
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
 }
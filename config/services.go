@@ -0,0 +1,445 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"customflow/models"
+)
+
+// AIConfig is the OpenAI client, its retry policy and the AI spend settings
+type AIConfig struct {
+	APIKey          string             `yaml:"api_key"` // secret; without it AI features use fallbacks
+	Timeout         time.Duration      `yaml:"timeout"` // per attempt
+	MaxRetries      int                `yaml:"max_retries"`
+	RetryBase       time.Duration      `yaml:"retry_base"`     // first backoff, doubled per retry
+	MaxRetryWait    time.Duration      `yaml:"max_retry_wait"` // cap on backoff and on Retry-After
+	BreakerFailures int                `yaml:"breaker_failures"`
+	BreakerCooldown time.Duration      `yaml:"breaker_cooldown"`
+	Prices          map[string]AIPrice `yaml:"prices"`          // merged over the built-in price table
+	DowngradeModel  string             `yaml:"downgrade_model"` // for "downgrade" budgets without their own
+	ReplyExamples   int                `yaml:"reply_examples"`  // library replies sent as examples, 0 disables
+}
+
+// AIPrice is USD per million tokens
+type AIPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+type TranscriptionConfig struct {
+	Provider string `yaml:"provider"` // openai or stub; openai when ai.api_key is set
+	Model    string `yaml:"model"`
+	APIURL   string `yaml:"api_url"`
+}
+
+type OCRConfig struct {
+	Model         string `yaml:"model"`
+	Workers       int    `yaml:"workers"`         // images read in parallel per request
+	RatePerMinute int    `yaml:"rate_per_minute"` // provider requests across the server
+	Burst         int    `yaml:"burst"`           // 0 means workers
+	Cache         bool   `yaml:"cache"`
+}
+
+// SLAConfig is measured in business days from order creation
+type SLAConfig struct {
+	DueDays        int                    `yaml:"due_days"`     // internal dispatch deadline
+	PromiseDays    int                    `yaml:"promise_days"` // delivery date promised to the customer
+	Sources        map[string]SLAOverride `yaml:"sources"`      // by order source
+	AtRisk         time.Duration          `yaml:"at_risk"`      // warning window before the due date
+	Timezone       string                 `yaml:"timezone"`     // IANA zone for business days, empty for local time
+	Weekend        []string               `yaml:"weekend"`
+	Holidays       []string               `yaml:"holidays"` // YYYY-MM-DD
+	CheckInterval  time.Duration          `yaml:"check_interval"`
+	AlertRecipient string                 `yaml:"alert_recipient"` // defaults to notifications.staff_emails
+}
+
+// SLAOverride replaces the default due or promise days for one order source
+type SLAOverride struct {
+	DueDays     *int `yaml:"due_days,omitempty"`
+	PromiseDays *int `yaml:"promise_days,omitempty"`
+}
+
+type SMSConfig struct {
+	Provider           string       `yaml:"provider"`             // twilio or fake; twilio when twilio.account_sid is set
+	DefaultCountryCode string       `yaml:"default_country_code"` // for numbers written without one, e.g. 91
+	WebhookURL         string       `yaml:"webhook_url"`          // public URL of /api/v1/inbound/sms, for signatures behind proxies
+	Twilio             TwilioConfig `yaml:"twilio"`
+}
+
+type TwilioConfig struct {
+	APIURL     string `yaml:"api_url"`
+	AccountSID string `yaml:"account_sid"`
+	AuthToken  string `yaml:"auth_token"` // secret
+	FromNumber string `yaml:"from_number"`
+}
+
+// ProviderName is the SMS provider in use, "" when SMS is disabled
+func (c SMSConfig) ProviderName() string {
+	if c.Provider == "" && c.Twilio.AccountSID != "" {
+		return "twilio"
+	}
+	return c.Provider
+}
+
+type WhatsAppConfig struct {
+	APIURL           string `yaml:"api_url"`
+	AppSecret        string `yaml:"app_secret"`   // secret; checks X-Hub-Signature-256, webhooks are rejected without it
+	VerifyToken      string `yaml:"verify_token"` // secret; echoed back during the subscription handshake
+	AccessToken      string `yaml:"access_token"` // secret; downloads media and sends templates
+	PhoneNumberID    string `yaml:"phone_number_id"`
+	TemplateLanguage string `yaml:"template_language"`
+}
+
+type NotificationConfig struct {
+	Driver        string           `yaml:"driver"` // "log" to log every notification instead of sending it
+	StaffEmails   []string         `yaml:"staff_emails"`
+	TemplatesFile string           `yaml:"templates_file"` // JSON overriding the default message templates
+	SMTP          SMTPConfig       `yaml:"smtp"`
+	SMSGateway    SMSGatewayConfig `yaml:"sms_gateway"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"` // email is logged when empty
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"` // secret
+	From     string `yaml:"from"`
+}
+
+type SMSGatewayConfig struct {
+	URL    string `yaml:"url"`     // SMS notifications are logged when empty
+	APIKey string `yaml:"api_key"` // secret
+	Sender string `yaml:"sender"`
+}
+
+type WebhookConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"` // 0 disables the delivery worker
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+type MarketplaceConfig struct {
+	SyncInterval time.Duration `yaml:"sync_interval"` // 0 disables the scheduler
+	Lookback     time.Duration `yaml:"lookback"`      // how far back the first sync reaches
+	SyncUserID   int           `yaml:"sync_user_id"`  // created_by of imported orders
+	Amazon       AmazonConfig  `yaml:"amazon"`
+}
+
+// AmazonConfig is the Selling Partner API connector, enabled by a refresh token
+type AmazonConfig struct {
+	Endpoint        string        `yaml:"endpoint"`
+	TokenURL        string        `yaml:"token_url"`
+	ClientID        string        `yaml:"client_id"`     // secret
+	ClientSecret    string        `yaml:"client_secret"` // secret
+	RefreshToken    string        `yaml:"refresh_token"` // secret
+	MarketplaceIDs  []string      `yaml:"marketplace_ids"`
+	OrderStatuses   []string      `yaml:"order_statuses"`
+	RequestInterval time.Duration `yaml:"request_interval"` // pause between orderItems calls
+}
+
+func serviceDefaults(c *Config) {
+	c.AI = AIConfig{
+		Timeout:         time.Minute,
+		MaxRetries:      2,
+		RetryBase:       500 * time.Millisecond,
+		MaxRetryWait:    30 * time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+		Prices:          map[string]AIPrice{},
+		DowngradeModel:  "gpt-4o-mini",
+		ReplyExamples:   3,
+	}
+	c.Transcription = TranscriptionConfig{
+		Model:  "whisper-1",
+		APIURL: "https://api.openai.com/v1/audio/transcriptions",
+	}
+	c.OCR = OCRConfig{Model: "gpt-4o", Workers: 4, RatePerMinute: 60, Cache: true}
+	c.SLA = SLAConfig{
+		DueDays:       3,
+		PromiseDays:   5,
+		Sources:       map[string]SLAOverride{},
+		AtRisk:        24 * time.Hour,
+		Weekend:       []string{"sat", "sun"},
+		CheckInterval: 5 * time.Minute,
+	}
+	c.SMS = SMSConfig{Twilio: TwilioConfig{APIURL: "https://api.twilio.com"}}
+	c.WhatsApp = WhatsAppConfig{APIURL: "https://graph.facebook.com/v20.0", TemplateLanguage: "en"}
+	c.Notifications = NotificationConfig{
+		SMTP:       SMTPConfig{Port: 587, From: "orders@customflow.com"},
+		SMSGateway: SMSGatewayConfig{Sender: "CSTFLW"},
+	}
+	c.Webhooks = WebhookConfig{PollInterval: 5 * time.Second, MaxAttempts: 8, Timeout: 10 * time.Second}
+	c.Marketplace = MarketplaceConfig{
+		SyncInterval: 15 * time.Minute,
+		Lookback:     7 * 24 * time.Hour,
+		SyncUserID:   1,
+		Amazon: AmazonConfig{
+			Endpoint:        "https://sellingpartnerapi-na.amazon.com",
+			TokenURL:        "https://api.amazon.com/auth/o2/token",
+			MarketplaceIDs:  []string{"ATVPDKIKX0DER"},
+			OrderStatuses:   []string{"Unshipped", "PartiallyShipped"},
+			RequestInterval: 2 * time.Second,
+		},
+	}
+}
+
+// The services keep the environment variables they always read; the ones
+// counting seconds, minutes or days still do, while the file takes durations.
+var serviceSettings = append([]setting{
+	{"OPENAI_API_KEY", "", "", func(c *Config, v string) error { c.AI.APIKey = v; return nil }},
+	{"OPENAI_TIMEOUT_SECONDS", "ai-timeout-seconds", "AI request timeout per attempt", func(c *Config, v string) error { return setCount(&c.AI.Timeout, v, time.Second) }},
+	{"AI_MAX_RETRIES", "ai-max-retries", "AI retries on 429, 5xx and network errors", func(c *Config, v string) error { return setInt(&c.AI.MaxRetries, v) }},
+	{"AI_RETRY_BASE_MS", "ai-retry-base-ms", "first AI retry backoff", func(c *Config, v string) error { return setCount(&c.AI.RetryBase, v, time.Millisecond) }},
+	{"AI_RETRY_MAX_WAIT_SECONDS", "ai-retry-max-wait-seconds", "longest wait between AI attempts", func(c *Config, v string) error { return setCount(&c.AI.MaxRetryWait, v, time.Second) }},
+	{"AI_BREAKER_FAILURES", "ai-breaker-failures", "consecutive AI failures that open the circuit breaker", func(c *Config, v string) error { return setInt(&c.AI.BreakerFailures, v) }},
+	{"AI_BREAKER_COOLDOWN_SECONDS", "ai-breaker-cooldown-seconds", "how long the AI circuit breaker stays open", func(c *Config, v string) error { return setCount(&c.AI.BreakerCooldown, v, time.Second) }},
+	{"AI_PRICES", "ai-prices", "model=input/output USD per million tokens, comma separated", func(c *Config, v string) error { return setAIPrices(&c.AI.Prices, v) }},
+	{"AI_DOWNGRADE_MODEL", "ai-downgrade-model", "model used by downgrade budgets", func(c *Config, v string) error { c.AI.DowngradeModel = v; return nil }},
+	{"AI_REPLY_EXAMPLES", "ai-reply-examples", "library replies sent as examples, 0 disables", func(c *Config, v string) error { return setInt(&c.AI.ReplyExamples, v) }},
+	{"TRANSCRIBER", "transcriber", "call transcriber: openai or stub", func(c *Config, v string) error { c.Transcription.Provider = v; return nil }},
+	{"TRANSCRIPTION_MODEL", "transcription-model", "transcription model", func(c *Config, v string) error { c.Transcription.Model = v; return nil }},
+	{"TRANSCRIPTION_API_URL", "transcription-api-url", "transcription API URL", func(c *Config, v string) error { c.Transcription.APIURL = v; return nil }},
+	{"OCR_MODEL", "ocr-model", "OCR vision model", func(c *Config, v string) error { c.OCR.Model = v; return nil }},
+	{"OCR_WORKERS", "ocr-workers", "images read in parallel per request", func(c *Config, v string) error { return setInt(&c.OCR.Workers, v) }},
+	{"OCR_RATE_PER_MINUTE", "ocr-rate-per-minute", "OCR requests per minute across the server", func(c *Config, v string) error { return setInt(&c.OCR.RatePerMinute, v) }},
+	{"OCR_BURST", "ocr-burst", "OCR requests allowed at once, 0 for ocr-workers", func(c *Config, v string) error { return setInt(&c.OCR.Burst, v) }},
+	{"OCR_CACHE", "ocr-cache", "cache OCR results: true or false", func(c *Config, v string) error { return setBool(&c.OCR.Cache, v) }},
+	{"SLA_DUE_DAYS", "sla-due-days", "business days to dispatch", func(c *Config, v string) error { return setInt(&c.SLA.DueDays, v) }},
+	{"SLA_PROMISE_DAYS", "sla-promise-days", "business days to the promised delivery", func(c *Config, v string) error { return setInt(&c.SLA.PromiseDays, v) }},
+	{"SLA_AT_RISK_HOURS", "sla-at-risk-hours", "hours before the due date an order is at risk", func(c *Config, v string) error { return setCount(&c.SLA.AtRisk, v, time.Hour) }},
+	{"SLA_TIMEZONE", "sla-timezone", "IANA time zone for business days", func(c *Config, v string) error { c.SLA.Timezone = v; return nil }},
+	{"SLA_WEEKEND", "sla-weekend", "comma separated weekdays", func(c *Config, v string) error { c.SLA.Weekend = splitList(v); return nil }},
+	{"SLA_HOLIDAYS", "sla-holidays", "comma separated YYYY-MM-DD dates", func(c *Config, v string) error { c.SLA.Holidays = splitList(v); return nil }},
+	{"SLA_CHECK_INTERVAL_MINUTES", "sla-check-interval-minutes", "minutes between SLA checks, 0 disables alerts", func(c *Config, v string) error { return setCount(&c.SLA.CheckInterval, v, time.Minute) }},
+	{"SLA_ALERT_RECIPIENT", "sla-alert-recipient", "address for SLA alerts", func(c *Config, v string) error { c.SLA.AlertRecipient = v; return nil }},
+	{"SMS_PROVIDER", "sms-provider", "two-way SMS provider: twilio or fake", func(c *Config, v string) error { c.SMS.Provider = v; return nil }},
+	{"SMS_DEFAULT_COUNTRY_CODE", "sms-default-country-code", "calling code for numbers written without one", func(c *Config, v string) error { c.SMS.DefaultCountryCode = v; return nil }},
+	{"SMS_WEBHOOK_URL", "sms-webhook-url", "public URL of the inbound SMS webhook", func(c *Config, v string) error { c.SMS.WebhookURL = v; return nil }},
+	{"TWILIO_API_URL", "twilio-api-url", "Twilio API URL", func(c *Config, v string) error { c.SMS.Twilio.APIURL = v; return nil }},
+	{"TWILIO_ACCOUNT_SID", "twilio-account-sid", "Twilio account SID", func(c *Config, v string) error { c.SMS.Twilio.AccountSID = v; return nil }},
+	{"TWILIO_AUTH_TOKEN", "", "", func(c *Config, v string) error { c.SMS.Twilio.AuthToken = v; return nil }},
+	{"TWILIO_FROM_NUMBER", "twilio-from-number", "Twilio sending number", func(c *Config, v string) error { c.SMS.Twilio.FromNumber = v; return nil }},
+	{"WHATSAPP_API_URL", "whatsapp-api-url", "WhatsApp Graph API URL", func(c *Config, v string) error { c.WhatsApp.APIURL = v; return nil }},
+	{"WHATSAPP_APP_SECRET", "", "", func(c *Config, v string) error { c.WhatsApp.AppSecret = v; return nil }},
+	{"WHATSAPP_VERIFY_TOKEN", "", "", func(c *Config, v string) error { c.WhatsApp.VerifyToken = v; return nil }},
+	{"WHATSAPP_ACCESS_TOKEN", "", "", func(c *Config, v string) error { c.WhatsApp.AccessToken = v; return nil }},
+	{"WHATSAPP_PHONE_NUMBER_ID", "whatsapp-phone-number-id", "WhatsApp sending number ID", func(c *Config, v string) error { c.WhatsApp.PhoneNumberID = v; return nil }},
+	{"WHATSAPP_TEMPLATE_LANGUAGE", "whatsapp-template-language", "WhatsApp template language", func(c *Config, v string) error { c.WhatsApp.TemplateLanguage = v; return nil }},
+	{"NOTIFY_DRIVER", "notify-driver", "\"log\" to log notifications instead of sending them", func(c *Config, v string) error { c.Notifications.Driver = v; return nil }},
+	{"STAFF_NOTIFY_EMAILS", "staff-notify-emails", "comma separated staff addresses", func(c *Config, v string) error { c.Notifications.StaffEmails = splitList(v); return nil }},
+	{"NOTIFY_TEMPLATES_FILE", "notify-templates-file", "JSON file overriding the message templates", func(c *Config, v string) error { c.Notifications.TemplatesFile = v; return nil }},
+	{"SMTP_HOST", "smtp-host", "SMTP host", func(c *Config, v string) error { c.Notifications.SMTP.Host = v; return nil }},
+	{"SMTP_PORT", "smtp-port", "SMTP port", func(c *Config, v string) error { return setInt(&c.Notifications.SMTP.Port, v) }},
+	{"SMTP_USERNAME", "smtp-username", "SMTP user", func(c *Config, v string) error { c.Notifications.SMTP.Username = v; return nil }},
+	{"SMTP_PASSWORD", "", "", func(c *Config, v string) error { c.Notifications.SMTP.Password = v; return nil }},
+	{"SMTP_FROM", "smtp-from", "sender address", func(c *Config, v string) error { c.Notifications.SMTP.From = v; return nil }},
+	{"SMS_GATEWAY_URL", "sms-gateway-url", "SMS notification gateway URL", func(c *Config, v string) error { c.Notifications.SMSGateway.URL = v; return nil }},
+	{"SMS_GATEWAY_API_KEY", "", "", func(c *Config, v string) error { c.Notifications.SMSGateway.APIKey = v; return nil }},
+	{"SMS_SENDER_ID", "sms-sender-id", "SMS notification sender ID", func(c *Config, v string) error { c.Notifications.SMSGateway.Sender = v; return nil }},
+	{"WEBHOOK_POLL_SECONDS", "webhook-poll-seconds", "webhook queue poll interval, 0 disables delivery", func(c *Config, v string) error { return setCount(&c.Webhooks.PollInterval, v, time.Second) }},
+	{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts before a delivery fails", func(c *Config, v string) error { return setInt(&c.Webhooks.MaxAttempts, v) }},
+	{"WEBHOOK_TIMEOUT_SECONDS", "webhook-timeout-seconds", "webhook request timeout", func(c *Config, v string) error { return setCount(&c.Webhooks.Timeout, v, time.Second) }},
+	{"MARKETPLACE_SYNC_MINUTES", "marketplace-sync-minutes", "marketplace sync interval, 0 disables the scheduler", func(c *Config, v string) error { return setCount(&c.Marketplace.SyncInterval, v, time.Minute) }},
+	{"MARKETPLACE_LOOKBACK_DAYS", "marketplace-lookback-days", "days the first marketplace sync reaches back", func(c *Config, v string) error { return setCount(&c.Marketplace.Lookback, v, 24*time.Hour) }},
+	{"MARKETPLACE_SYNC_USER_ID", "marketplace-sync-user-id", "user recorded as creating imported orders", func(c *Config, v string) error { return setInt(&c.Marketplace.SyncUserID, v) }},
+	{"SPAPI_ENDPOINT", "spapi-endpoint", "Selling Partner API regional endpoint", func(c *Config, v string) error { c.Marketplace.Amazon.Endpoint = v; return nil }},
+	{"SPAPI_LWA_TOKEN_URL", "spapi-lwa-token-url", "Login with Amazon token URL", func(c *Config, v string) error { c.Marketplace.Amazon.TokenURL = v; return nil }},
+	{"SPAPI_LWA_CLIENT_ID", "", "", func(c *Config, v string) error { c.Marketplace.Amazon.ClientID = v; return nil }},
+	{"SPAPI_LWA_CLIENT_SECRET", "", "", func(c *Config, v string) error { c.Marketplace.Amazon.ClientSecret = v; return nil }},
+	{"SPAPI_REFRESH_TOKEN", "", "", func(c *Config, v string) error { c.Marketplace.Amazon.RefreshToken = v; return nil }},
+	{"SPAPI_MARKETPLACE_IDS", "spapi-marketplace-ids", "comma separated Amazon marketplace IDs", func(c *Config, v string) error { c.Marketplace.Amazon.MarketplaceIDs = splitList(v); return nil }},
+	{"SPAPI_ORDER_STATUSES", "spapi-order-statuses", "comma separated Amazon order statuses to import", func(c *Config, v string) error { c.Marketplace.Amazon.OrderStatuses = splitList(v); return nil }},
+	{"SPAPI_REQUEST_INTERVAL_MS", "spapi-request-interval-ms", "pause between Amazon orderItems calls", func(c *Config, v string) error {
+		return setCount(&c.Marketplace.Amazon.RequestInterval, v, time.Millisecond)
+	}},
+}, slaSourceSettings()...)
+
+// slaSourceSettings reads SLA_DUE_DAYS_<SOURCE> and SLA_PROMISE_DAYS_<SOURCE>,
+// e.g. SLA_DUE_DAYS_AMAZON
+func slaSourceSettings() []setting {
+	var list []setting
+	for _, source := range models.OrderSources {
+		source := source
+		suffix := strings.ToUpper(source)
+		list = append(list,
+			setting{"SLA_DUE_DAYS_" + suffix, "", "", func(c *Config, v string) error {
+				return setSLAOverride(c, source, v, func(o *SLAOverride, days *int) { o.DueDays = days })
+			}},
+			setting{"SLA_PROMISE_DAYS_" + suffix, "", "", func(c *Config, v string) error {
+				return setSLAOverride(c, source, v, func(o *SLAOverride, days *int) { o.PromiseDays = days })
+			}},
+		)
+	}
+	return list
+}
+
+func setSLAOverride(c *Config, source, value string, set func(o *SLAOverride, days *int)) error {
+	var days int
+	if err := setInt(&days, value); err != nil {
+		return err
+	}
+	if c.SLA.Sources == nil {
+		c.SLA.Sources = map[string]SLAOverride{}
+	}
+	override := c.SLA.Sources[source]
+	set(&override, &days)
+	c.SLA.Sources[source] = override
+	return nil
+}
+
+var (
+	smsProviders  = []string{"", "twilio", "fake"}
+	transcribers  = []string{"", "openai", "stub"}
+	notifyDrivers = []string{"", "log"}
+	weekdays      = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+// ParseWeekday reads a weekday from its English name or the first three letters of it
+func ParseWeekday(name string) (time.Weekday, bool) {
+	day, ok := weekdays[strings.ToLower(name[:min(3, len(name))])]
+	return day, ok
+}
+
+// validateServices adds the problems with the service sections to check
+func (c Config) validateServices(check func(ok bool, format string, args ...any)) {
+	ai := c.AI
+	check(ai.Timeout > 0, "ai.timeout must be positive")
+	check(ai.MaxRetries >= 0, "ai.max_retries must not be negative")
+	check(ai.RetryBase > 0, "ai.retry_base must be positive")
+	check(ai.MaxRetryWait >= ai.RetryBase, "ai.max_retry_wait must be at least ai.retry_base")
+	check(ai.BreakerFailures > 0, "ai.breaker_failures must be positive")
+	check(ai.BreakerCooldown > 0, "ai.breaker_cooldown must be positive")
+	for model, price := range ai.Prices {
+		check(price.Input >= 0 && price.Output >= 0, "ai.prices.%s must not be negative", model)
+	}
+	check(ai.DowngradeModel != "", "ai.downgrade_model is required")
+	check(ai.ReplyExamples >= 0, "ai.reply_examples must not be negative")
+
+	check(contains(transcribers, c.Transcription.Provider), "transcription.provider must be openai or stub, got %q", c.Transcription.Provider)
+	check(c.Transcription.Provider != "openai" || ai.APIKey != "", "transcription.provider openai needs ai.api_key (OPENAI_API_KEY)")
+
+	check(c.OCR.Workers > 0, "ocr.workers must be positive")
+	check(c.OCR.RatePerMinute > 0, "ocr.rate_per_minute must be positive")
+	check(c.OCR.Burst >= 0, "ocr.burst must not be negative")
+
+	sla := c.SLA
+	check(sla.DueDays >= 0 && sla.PromiseDays >= 0, "sla.due_days and sla.promise_days must not be negative")
+	for source, override := range sla.Sources {
+		check(contains(models.OrderSources, source), "sla.sources has unknown order source %q", source)
+		check((override.DueDays == nil || *override.DueDays >= 0) && (override.PromiseDays == nil || *override.PromiseDays >= 0),
+			"sla.sources.%s days must not be negative", source)
+	}
+	check(sla.AtRisk >= 0, "sla.at_risk must not be negative")
+	if sla.Timezone != "" {
+		_, err := time.LoadLocation(sla.Timezone)
+		check(err == nil, "sla.timezone %q is not a known time zone", sla.Timezone)
+	}
+	weekend := map[time.Weekday]bool{}
+	for _, name := range sla.Weekend {
+		day, ok := ParseWeekday(name)
+		check(ok, "sla.weekend has unknown day %q", name)
+		weekend[day] = ok
+	}
+	check(len(weekend) < len(weekdays), "sla.weekend leaves no business days in the week")
+	for _, date := range sla.Holidays {
+		_, err := time.Parse("2006-01-02", date)
+		check(err == nil, "sla.holidays date %q is not YYYY-MM-DD", date)
+	}
+	check(sla.CheckInterval >= 0, "sla.check_interval must not be negative")
+
+	sms := c.SMS
+	check(contains(smsProviders, sms.Provider), "sms.provider must be twilio or fake, got %q", sms.Provider)
+	if sms.ProviderName() == "twilio" {
+		check(sms.Twilio.AccountSID != "" && sms.Twilio.AuthToken != "" && sms.Twilio.FromNumber != "",
+			"sms.provider twilio needs sms.twilio.account_sid, auth_token and from_number (TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, TWILIO_FROM_NUMBER)")
+	}
+	if code := sms.DefaultCountryCode; code != "" {
+		_, err := strconv.Atoi(strings.TrimPrefix(code, "+"))
+		check(err == nil && len(strings.TrimPrefix(code, "+")) <= 3, "sms.default_country_code must be a calling code such as 91, got %q", code)
+	}
+
+	check(c.WhatsApp.APIURL != "", "whatsapp.api_url is required")
+
+	notify := c.Notifications
+	check(contains(notifyDrivers, notify.Driver), "notifications.driver must be empty or log, got %q", notify.Driver)
+	check(notify.SMTP.Port > 0 && notify.SMTP.Port <= 65535, "notifications.smtp.port must be between 1 and 65535, got %d", notify.SMTP.Port)
+
+	check(c.Webhooks.PollInterval >= 0, "webhooks.poll_interval must not be negative")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+
+	market := c.Marketplace
+	check(market.SyncInterval >= 0, "marketplace.sync_interval must not be negative")
+	check(market.Lookback > 0, "marketplace.lookback must be positive")
+	check(market.SyncUserID > 0, "marketplace.sync_user_id must be positive")
+	if amazon := market.Amazon; amazon.RefreshToken != "" {
+		check(amazon.ClientID != "" && amazon.ClientSecret != "",
+			"marketplace.amazon needs client_id and client_secret with refresh_token (SPAPI_LWA_CLIENT_ID, SPAPI_LWA_CLIENT_SECRET)")
+		check(len(amazon.MarketplaceIDs) > 0, "marketplace.amazon.marketplace_ids must list at least one marketplace")
+		check(amazon.RequestInterval >= 0, "marketplace.amazon.request_interval must not be negative")
+	}
+}
+
+// redactServices masks the service secrets
+func (c *Config) redactServices() {
+	for _, secret := range []*string{
+		&c.AI.APIKey,
+		&c.SMS.Twilio.AuthToken,
+		&c.WhatsApp.AppSecret,
+		&c.WhatsApp.VerifyToken,
+		&c.WhatsApp.AccessToken,
+		&c.Notifications.SMTP.Password,
+		&c.Notifications.SMSGateway.APIKey,
+		&c.Marketplace.Amazon.ClientID,
+		&c.Marketplace.Amazon.ClientSecret,
+		&c.Marketplace.Amazon.RefreshToken,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+}
+
+// setCount reads a whole number of units, for the environment variables
+// that predate durations
+func setCount(dest *time.Duration, value string, unit time.Duration) error {
+	var n int
+	if err := setInt(&n, value); err != nil {
+		return err
+	}
+	*dest = time.Duration(n) * unit
+	return nil
+}
+
+func setBool(dest *bool, value string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("expected true or false, got %q", value)
+	}
+	*dest = b
+	return nil
+}
+
+// setAIPrices merges "model=input/output" entries, comma separated, into prices
+func setAIPrices(prices *map[string]AIPrice, value string) error {
+	if *prices == nil {
+		*prices = map[string]AIPrice{}
+	}
+	for _, item := range splitList(value) {
+		model, pair, ok := strings.Cut(item, "=")
+		in, out, ok2 := strings.Cut(pair, "/")
+		input, err1 := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		output, err2 := strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if !ok || !ok2 || err1 != nil || err2 != nil || strings.TrimSpace(model) == "" {
+			return fmt.Errorf("expected model=input/output, got %q", item)
+		}
+		(*prices)[strings.TrimSpace(model)] = AIPrice{Input: input, Output: output}
+	}
+	return nil
+}
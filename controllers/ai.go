@@ -0,0 +1,223 @@
+// =================================================================
+// controllers/ai.go - AI token usage, cost and per-user budgets
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"customflow/models"
+	"customflow/repository"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AIBudgetRequest struct {
+	MonthlyLimitUSD float64 `json:"monthly_limit_usd" binding:"gte=0"`
+	Action          string  `json:"action"` // block (default) or downgrade
+	DowngradeModel  string  `json:"downgrade_model"`
+}
+
+// AIBudgetHandler serves the AI usage report and the per-user budget endpoints
+type AIBudgetHandler struct {
+	budgets repository.AIBudgetRepository
+	users   repository.UserRepository
+}
+
+// NewAIBudgetHandler builds the budget handlers, keeping budgets in budgets
+// and checking users against users
+func NewAIBudgetHandler(budgets repository.AIBudgetRepository, users repository.UserRepository) *AIBudgetHandler {
+	return &AIBudgetHandler{budgets: budgets, users: users}
+}
+
+// GetAIUsage returns daily and monthly token and cost aggregates.
+// ?days (default 30) and ?months (default 12) set how far back they go;
+// ?user_id, ?order_id and ?feature filter both.
+func (h *AIBudgetHandler) GetAIUsage(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days, expected 1-366"})
+		return
+	}
+	months, err := strconv.Atoi(c.DefaultQuery("months", "12"))
+	if err != nil || months < 1 || months > 36 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid months, expected 1-36"})
+		return
+	}
+
+	var filter models.AIUsageFilter
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		userID := uint(id)
+		filter.UserID = &userID
+	}
+	if value := c.Query("order_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order_id"})
+			return
+		}
+		orderID := uint(id)
+		filter.OrderID = &orderID
+	}
+	filter.Feature = c.Query("feature")
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	daily, err := h.budgets.Usage(ctx, filter, "day", today.AddDate(0, 0, 1-days))
+	if err != nil {
+		log.Printf("GetAIUsage: Daily aggregate failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI usage"})
+		return
+	}
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthly, err := h.budgets.Usage(ctx, filter, "month", thisMonth.AddDate(0, 1-months, 0))
+	if err != nil {
+		log.Printf("GetAIUsage: Monthly aggregate failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI usage"})
+		return
+	}
+
+	budgets, err := h.budgetStatuses(ctx, filter.UserID)
+	if err != nil {
+		log.Printf("GetAIUsage: Budget lookup failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI budgets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"daily":   daily,
+		"monthly": monthly,
+		"budgets": budgets,
+		"prices":  services.AIPrices(),
+	})
+}
+
+// GetAIBudgets lists the per-user monthly budgets with this month's spend
+func (h *AIBudgetHandler) GetAIBudgets(c *gin.Context) {
+	budgets, err := h.budgetStatuses(c.Request.Context(), nil)
+	if err != nil {
+		log.Printf("GetAIBudgets: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI budgets"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"budgets": budgets})
+}
+
+// SetAIBudget creates or replaces a user's monthly budget
+func (h *AIBudgetHandler) SetAIBudget(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req AIBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	if req.Action == "" {
+		req.Action = "block"
+	}
+	if !contains(models.AIBudgetActions, req.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action, expected block or downgrade"})
+		return
+	}
+
+	if _, err := h.users.Get(c.Request.Context(), uint(userID)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			log.Printf("SetAIBudget: Failed to load user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+
+	budget := models.AIBudget{
+		UserID:          uint(userID),
+		MonthlyLimitUSD: req.MonthlyLimitUSD,
+		Action:          req.Action,
+		DowngradeModel:  req.DowngradeModel,
+	}
+	if err := h.budgets.Save(c.Request.Context(), &budget); err != nil {
+		log.Printf("SetAIBudget: Failed to save budget for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save AI budget"})
+		return
+	}
+
+	statuses, err := h.budgetStatuses(c.Request.Context(), &budget.UserID)
+	if err != nil || len(statuses) == 0 {
+		c.JSON(http.StatusOK, gin.H{"budget": budget})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"budget": statuses[0]})
+}
+
+// DeleteAIBudget removes a user's budget, lifting any block
+func (h *AIBudgetHandler) DeleteAIBudget(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if err := h.budgets.Delete(c.Request.Context(), uint(userID)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "AI budget not found"})
+		} else {
+			log.Printf("DeleteAIBudget: Failed to delete budget for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AI budget"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "AI budget deleted"})
+}
+
+// budgetStatuses returns the budgets (of one user, if given) with this month's spend
+func (h *AIBudgetHandler) budgetStatuses(ctx context.Context, userID *uint) ([]services.AIBudgetStatus, error) {
+	budgets, err := h.budgets.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := services.AIBudgetPeriodStart(time.Now())
+	statuses := make([]services.AIBudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		spent, err := h.budgets.Spent(ctx, budget.UserID, since)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, services.NewAIBudgetStatus(budget, spent))
+	}
+	return statuses, nil
+}
+
+// aiContext is the request context with the AI usage accounted to the current user
+func aiContext(c *gin.Context) context.Context {
+	return services.WithAIUser(c.Request.Context(), currentUserID(c))
+}
+
+// aiErrorStatus maps an AI call error to the response status, or fallback
+// for errors that are not about the provider or the budget
+func aiErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrAIBudgetExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrAIUnavailable), errors.Is(err, services.ErrAIRetriesExhausted):
+		return http.StatusServiceUnavailable
+	}
+	return fallback
+}
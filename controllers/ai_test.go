@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"customflow/models"
+	"customflow/repository/memory"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newBudgetRouter(h *AIBudgetHandler) *gin.Engine {
+	router := gin.New()
+	router.GET("/ai/usage", h.GetAIUsage)
+	router.GET("/ai/budgets", h.GetAIBudgets)
+	router.PUT("/ai/budgets/:user_id", h.SetAIBudget)
+	router.DELETE("/ai/budgets/:user_id", h.DeleteAIBudget)
+	return router
+}
+
+func TestSetAIBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   gin.H
+		want   int
+	}{
+		{"block", "/ai/budgets/1", gin.H{"monthly_limit_usd": 10}, http.StatusOK},
+		{"downgrade", "/ai/budgets/1", gin.H{"monthly_limit_usd": 10, "action": "downgrade", "downgrade_model": "gpt-4o-mini"}, http.StatusOK},
+		{"zero limit", "/ai/budgets/1", gin.H{"monthly_limit_usd": 0}, http.StatusOK},
+		{"negative limit", "/ai/budgets/1", gin.H{"monthly_limit_usd": -1}, http.StatusBadRequest},
+		{"unknown action", "/ai/budgets/1", gin.H{"monthly_limit_usd": 10, "action": "warn"}, http.StatusBadRequest},
+		{"unknown user", "/ai/budgets/99", gin.H{"monthly_limit_usd": 10}, http.StatusNotFound},
+		{"malformed user ID", "/ai/budgets/me", gin.H{"monthly_limit_usd": 10}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			store.AddUser(models.User{ID: 1, Username: "staff"})
+			budgets := memory.NewAIBudgetRepository(store)
+			router := newBudgetRouter(NewAIBudgetHandler(budgets, memory.NewUserRepository(store)))
+
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if got := serveJSON(t, router, http.MethodPut, tt.target, tt.body, &resp); got != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", got, tt.want, resp.Error)
+			}
+
+			stored, err := budgets.List(benchContext, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved := len(stored) == 1; saved != (tt.want == http.StatusOK) {
+				t.Errorf("%d budgets stored after status %d", len(stored), tt.want)
+			}
+		})
+	}
+}
+
+func TestAIBudgetSpend(t *testing.T) {
+	store := memory.NewStore()
+	staff := store.AddUser(models.User{Username: "staff"})
+	other := store.AddUser(models.User{Username: "other"})
+	router := newBudgetRouter(NewAIBudgetHandler(memory.NewAIBudgetRepository(store), memory.NewUserRepository(store)))
+
+	monthStart := services.AIBudgetPeriodStart(time.Now())
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, CostUSD: 4.5})
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, CostUSD: 1.25})
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, CostUSD: 100, CreatedAt: monthStart.Add(-time.Second)})
+	store.AddAIUsage(models.AIUsage{UserID: &other.ID, CostUSD: 3})
+
+	var set struct {
+		Budget services.AIBudgetStatus `json:"budget"`
+	}
+	if got := serveJSON(t, router, http.MethodPut, "/ai/budgets/1", gin.H{"monthly_limit_usd": 5}, &set); got != http.StatusOK {
+		t.Fatalf("set: status %d", got)
+	}
+	if set.Budget.SpentUSD != 5.75 || set.Budget.RemainingUSD != 0 || !set.Budget.Exceeded || set.Budget.Action != "block" {
+		t.Errorf("budget = %+v, want 5.75 spent, none remaining, exceeded, block", set.Budget)
+	}
+
+	if got := serveJSON(t, router, http.MethodPut, "/ai/budgets/2", gin.H{"monthly_limit_usd": 10}, nil); got != http.StatusOK {
+		t.Fatalf("set other: status %d", got)
+	}
+
+	var list struct {
+		Budgets []services.AIBudgetStatus `json:"budgets"`
+	}
+	if got := serveJSON(t, router, http.MethodGet, "/ai/budgets", nil, &list); got != http.StatusOK {
+		t.Fatalf("list: status %d", got)
+	}
+	if len(list.Budgets) != 2 || list.Budgets[1].UserID != other.ID || list.Budgets[1].RemainingUSD != 7 || list.Budgets[1].Exceeded {
+		t.Errorf("budgets = %+v, want the other user's second with 7 remaining", list.Budgets)
+	}
+}
+
+func TestDeleteAIBudget(t *testing.T) {
+	store := memory.NewStore()
+	store.AddUser(models.User{ID: 1, Username: "staff"})
+	router := newBudgetRouter(NewAIBudgetHandler(memory.NewAIBudgetRepository(store), memory.NewUserRepository(store)))
+
+	if got := serveJSON(t, router, http.MethodPut, "/ai/budgets/1", gin.H{"monthly_limit_usd": 5}, nil); got != http.StatusOK {
+		t.Fatalf("set: status %d", got)
+	}
+
+	for _, step := range []struct {
+		target string
+		want   int
+	}{
+		{"/ai/budgets/me", http.StatusBadRequest},
+		{"/ai/budgets/1", http.StatusOK},
+		{"/ai/budgets/1", http.StatusNotFound},
+		{"/ai/budgets/2", http.StatusNotFound},
+	} {
+		if got := serveJSON(t, router, http.MethodDelete, step.target, nil, nil); got != step.want {
+			t.Errorf("DELETE %s: status %d, want %d", step.target, got, step.want)
+		}
+	}
+}
+
+func TestGetAIUsage(t *testing.T) {
+	store := memory.NewStore()
+	staff := store.AddUser(models.User{Username: "staff"})
+	other := store.AddUser(models.User{Username: "other"})
+	budgets := memory.NewAIBudgetRepository(store)
+	router := newBudgetRouter(NewAIBudgetHandler(budgets, memory.NewUserRepository(store)))
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	orderID := uint(7)
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, OrderID: &orderID, Feature: "reply", Model: "gpt-4o", TotalTokens: 100, CostUSD: 1, CreatedAt: today.Add(time.Minute)})
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, Feature: "reply", Model: "gpt-4o", TotalTokens: 50, CostUSD: 0.5, CreatedAt: today.Add(2 * time.Minute)})
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, Feature: "ocr", Model: "gpt-4o", TotalTokens: 10, CostUSD: 0.25, CreatedAt: today.AddDate(0, 0, -1)})
+	store.AddAIUsage(models.AIUsage{UserID: &other.ID, Feature: "reply", Model: "gpt-4o-mini", TotalTokens: 20, CostUSD: 0.1, CreatedAt: today.Add(time.Minute)})
+	store.AddAIUsage(models.AIUsage{UserID: &staff.ID, Feature: "reply", Model: "gpt-4o", CostUSD: 9, CreatedAt: today.AddDate(0, 0, -40)})
+	budgets.Save(benchContext, &models.AIBudget{UserID: staff.ID, MonthlyLimitUSD: 10, Action: "block"})
+
+	type usageResponse struct {
+		Daily   []models.AIUsageRow        `json:"daily"`
+		Monthly []models.AIUsageRow        `json:"monthly"`
+		Budgets []services.AIBudgetStatus  `json:"budgets"`
+		Prices  map[string]json.RawMessage `json:"prices"`
+		Error   string                     `json:"error"`
+	}
+
+	var resp usageResponse
+	if got := serveJSON(t, router, http.MethodGet, fmt.Sprintf("/ai/usage?days=7&user_id=%d", staff.ID), nil, &resp); got != http.StatusOK {
+		t.Fatalf("status %d (%s)", got, resp.Error)
+	}
+	// Newest day first, then by feature and model; the 40-day-old call is out of range
+	if len(resp.Daily) != 2 || resp.Daily[0].Feature != "reply" || resp.Daily[0].Calls != 2 || resp.Daily[0].TotalTokens != 150 ||
+		resp.Daily[1].Feature != "ocr" || !resp.Daily[1].Period.Equal(today.AddDate(0, 0, -1)) {
+		t.Errorf("daily = %+v, want today's 2 reply calls then yesterday's OCR call", resp.Daily)
+	}
+	if len(resp.Budgets) != 1 || resp.Budgets[0].UserID != staff.ID {
+		t.Errorf("budgets = %+v, want the staff budget", resp.Budgets)
+	}
+
+	resp = usageResponse{}
+	if got := serveJSON(t, router, http.MethodGet, "/ai/usage?order_id=7", nil, &resp); got != http.StatusOK {
+		t.Fatalf("by order: status %d (%s)", got, resp.Error)
+	}
+	if len(resp.Daily) != 1 || resp.Daily[0].CostUSD != 1 {
+		t.Errorf("daily for order 7 = %+v, want its one call", resp.Daily)
+	}
+
+	for _, query := range []string{"days=0", "months=99", "user_id=me", "order_id=x"} {
+		if got := serveJSON(t, router, http.MethodGet, "/ai/usage?"+query, nil, nil); got != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, got, http.StatusBadRequest)
+		}
+	}
+}
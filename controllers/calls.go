@@ -0,0 +1,280 @@
+// =================================================================
+// controllers/calls.go - Phone call log and transcript-to-order extraction
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CallLogRequest struct {
+	PhoneNumber     string     `json:"phone_number" binding:"required"`
+	CustomerName    string     `json:"customer_name"`
+	Direction       string     `json:"direction"`
+	DurationSeconds int        `json:"duration_seconds" binding:"min=0"`
+	StartedAt       *time.Time `json:"started_at"`
+	Notes           string     `json:"notes"`
+	OrderID         *uint      `json:"order_id"`
+	Transcript      string     `json:"transcript"`
+}
+
+type CallTranscriptRequest struct {
+	Transcript string `json:"transcript" binding:"required"`
+}
+
+// Audio formats accepted by the transcription API
+var callRecordingTypes = []string{".mp3", ".mp4", ".m4a", ".wav", ".webm", ".ogg", ".mpeg", ".mpga"}
+
+// GetCalls lists call logs, filtered by ?phone= or ?order_id=
+func GetCalls(c *gin.Context) {
+	query := config.DB.Order("started_at DESC, id DESC")
+
+	if phone := c.Query("phone"); phone != "" {
+		query = query.Where(services.PhoneKeySQL("phone_number")+" = ?", services.PhoneKey(phone))
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		id, err := strconv.Atoi(orderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+			return
+		}
+		query = query.Where("order_id = ?", id)
+	}
+
+	var calls []models.CallLog
+	if err := query.Limit(200).Find(&calls).Error; err != nil {
+		log.Printf("GetCalls: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calls"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"calls": calls, "count": len(calls)})
+}
+
+// GetCall returns one call log
+func GetCall(c *gin.Context) {
+	call, ok := findCall(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"call": call})
+}
+
+// CreateCall logs a call. A pasted transcript is extracted into a draft order straight away.
+func CreateCall(c *gin.Context) {
+	var req CallLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	call := models.CallLog{CreatedBy: currentUserID(c)}
+	if apiErr := applyCallLogRequest(&call, req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := config.DB.Create(&call).Error; err != nil {
+		log.Printf("CreateCall: Failed to create call log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create call log"})
+		return
+	}
+
+	if strings.TrimSpace(req.Transcript) == "" {
+		c.JSON(http.StatusCreated, gin.H{"call": call})
+		return
+	}
+
+	respondWithCallExtraction(c, http.StatusCreated, &call, req.Transcript, services.TranscriptPasted)
+}
+
+// UpdateCall edits the call details or links it to an order
+func UpdateCall(c *gin.Context) {
+	call, ok := findCall(c)
+	if !ok {
+		return
+	}
+
+	var req CallLogRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if apiErr := applyCallLogRequest(&call, req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := config.DB.Save(&call).Error; err != nil {
+		log.Printf("UpdateCall: Failed to update call log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update call log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"call": call})
+}
+
+// AddCallTranscript extracts order details from a pasted transcript
+func AddCallTranscript(c *gin.Context) {
+	call, ok := findCall(c)
+	if !ok {
+		return
+	}
+
+	var req CallTranscriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	respondWithCallExtraction(c, http.StatusOK, &call, req.Transcript, services.TranscriptPasted)
+}
+
+// UploadCallRecording stores a recording, transcribes it and extracts order details
+func UploadCallRecording(c *gin.Context) {
+	if !services.TranscriptionEnabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": services.ErrTranscriptionDisabled.Error()})
+		return
+	}
+
+	call, ok := findCall(c)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No recording uploaded"})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !contains(callRecordingTypes, ext) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported recording format", "valid_formats": callRecordingTypes})
+		return
+	}
+	if maxMB := services.UploadSettings().MaxRecordingMB; fileHeader.Size > int64(maxMB)<<20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Recording too large (max %dMB)", maxMB)})
+		return
+	}
+
+	filename := fmt.Sprintf("call_%d_%d%s", call.ID, time.Now().UnixNano(), ext)
+	if err := c.SaveUploadedFile(fileHeader, services.RecordingPath(filename)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recording"})
+		return
+	}
+
+	call.RecordingFile = filename
+	if err := config.DB.Save(&call).Error; err != nil {
+		log.Printf("UploadCallRecording: Failed to update call log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update call log"})
+		return
+	}
+
+	transcript, err := services.TranscribeRecording(c.Request.Context(), filename)
+	if err != nil {
+		log.Printf("UploadCallRecording: Transcription failed for call %d: %v", call.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Transcription failed: " + err.Error(), "call": call})
+		return
+	}
+
+	respondWithCallExtraction(c, http.StatusOK, &call, transcript, services.TranscriptTranscribed)
+}
+
+// DownloadCallRecording sends a call's recording. Recordings are not under
+// /uploads, so this authenticated route is the only way to fetch them.
+func DownloadCallRecording(c *gin.Context) {
+	call, ok := findCall(c)
+	if !ok {
+		return
+	}
+	if call.RecordingFile == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Call has no recording"})
+		return
+	}
+
+	path := services.RecordingPath(filepath.Base(call.RecordingFile))
+	if _, err := os.Stat(path); err != nil {
+		log.Printf("DownloadCallRecording: Recording for call %d missing: %v", call.ID, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording file not found"})
+		return
+	}
+	c.FileAttachment(path, call.RecordingFile)
+}
+
+// respondWithCallExtraction runs extraction and returns the call, its draft and a
+// prefilled CreateOrderRequest the client can review and POST to /orders
+func respondWithCallExtraction(c *gin.Context, status int, call *models.CallLog, transcript, source string) {
+	draft, extraction, err := services.ApplyCallTranscript(aiContext(c), call, transcript, source)
+	if err != nil {
+		log.Printf("Calls: Extraction failed for call %d: %v", call.ID, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "call": call})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"call":          call,
+		"draft":         draft,
+		"extraction":    extraction,
+		"order_request": draftOrderRequest(*draft),
+	})
+}
+
+func applyCallLogRequest(call *models.CallLog, req CallLogRequest) *apiError {
+	if req.Direction == "" {
+		req.Direction = "inbound"
+	}
+	if !contains(models.CallDirections, req.Direction) {
+		return newAPIError(http.StatusBadRequest, "Invalid direction")
+	}
+	if digitsOnly(req.PhoneNumber) == "" {
+		return newAPIError(http.StatusBadRequest, "Invalid phone number")
+	}
+
+	call.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	call.CustomerName = strings.TrimSpace(req.CustomerName)
+	call.Direction = req.Direction
+	call.DurationSeconds = req.DurationSeconds
+	call.Notes = req.Notes
+	call.OrderID = req.OrderID
+	if req.StartedAt != nil {
+		call.StartedAt = *req.StartedAt
+	} else if call.StartedAt.IsZero() {
+		call.StartedAt = time.Now()
+	}
+	return nil
+}
+
+func findCall(c *gin.Context) (models.CallLog, bool) {
+	var call models.CallLog
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid call ID format"})
+		return call, false
+	}
+
+	if err := config.DB.First(&call, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Call not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return call, false
+	}
+	return call, true
+}
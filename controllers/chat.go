@@ -0,0 +1,273 @@
+// =================================================================
+// controllers/chat.go - AI replies and staff chat, plain or streamed as Server-Sent Events
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AIReplyRequest struct {
+	Message     string   `json:"message" binding:"required"`
+	Tone        string   `json:"tone"`
+	OrderID     *uint    `json:"order_id"`     // ground the reply in this order
+	PhoneNumber string   `json:"phone_number"` // or in the customer's latest order, open ones first
+	Language    string   `json:"language"`     // language code to reply in; empty or "auto" for the customer's
+	Images      []string `json:"images"`       // photos or screenshots the customer sent, filenames returned by /upload
+}
+
+type ChatRequest struct {
+	SessionID string `json:"session_id"` // or the X-Session-ID header; empty starts a new session
+	Message   string `json:"message" binding:"required"`
+}
+
+// GenerateReply writes a reply to a customer message in one of the AI tones.
+// Given an order_id or phone_number, the reply uses that order's status and dates;
+// given images, it can answer from what they show, such as a measured table.
+func GenerateReply(c *gin.Context) {
+	req, ok := bindAIReplyRequest(c)
+	if !ok {
+		return
+	}
+	order, ok := replyOrder(c, req)
+	if !ok {
+		return
+	}
+
+	response, err := services.ReplyToMessage(aiContext(c), req.Message, req.replyOptions(order), nil)
+	if err != nil {
+		log.Printf("GenerateReply: %v", err)
+		c.JSON(aiErrorStatus(err, http.StatusBadGateway), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"response": response, "order": order})
+}
+
+// StreamReply is GenerateReply streamed as "delta" events, then "done" with the stored response
+func StreamReply(c *gin.Context) {
+	req, ok := bindAIReplyRequest(c)
+	if !ok {
+		return
+	}
+	order, ok := replyOrder(c, req)
+	if !ok {
+		return
+	}
+
+	ctx := aiContext(c)
+	streamSSE(c, func(onDelta services.AIDeltaFunc) (interface{}, error) {
+		response, err := services.ReplyToMessage(ctx, req.Message, req.replyOptions(order), onDelta)
+		return gin.H{"response": response, "order": order}, err
+	})
+}
+
+// Chat sends a staff message to the AI assistant within a session
+func Chat(c *gin.Context) {
+	req, ok := bindChatRequest(c)
+	if !ok {
+		return
+	}
+	session, ok := openChatSession(c, req.SessionID)
+	if !ok {
+		return
+	}
+
+	result, err := services.Chat(aiContext(c), session, req.Message, nil)
+	if err != nil {
+		log.Printf("Chat: Session %s: %v", session.SessionID, err)
+		c.JSON(aiErrorStatus(err, http.StatusBadGateway), gin.H{"error": err.Error(), "session_id": session.SessionID})
+		return
+	}
+
+	c.Header("X-Session-ID", result.SessionID)
+	c.JSON(http.StatusOK, result)
+}
+
+// StreamChat is Chat streamed as "delta" events, then "done" with the session and stored response
+func StreamChat(c *gin.Context) {
+	req, ok := bindChatRequest(c)
+	if !ok {
+		return
+	}
+	session, ok := openChatSession(c, req.SessionID)
+	if !ok {
+		return
+	}
+
+	c.Header("X-Session-ID", session.SessionID)
+	ctx := aiContext(c)
+	streamSSE(c, func(onDelta services.AIDeltaFunc) (interface{}, error) {
+		return services.Chat(ctx, session, req.Message, onDelta)
+	})
+}
+
+// GetChatSession returns a session's messages
+func GetChatSession(c *gin.Context) {
+	session, ok := openChatSession(c, c.Param("session_id"))
+	if !ok {
+		return
+	}
+
+	messages, err := services.ChatHistory(session.SessionID)
+	if err != nil {
+		log.Printf("GetChatSession: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chat history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session": session, "messages": messages})
+}
+
+// streamSSE answers with Server-Sent Events: a "delta" event per piece of
+// generated text, then "done" with run's result or "error". A client that
+// disconnects cancels the request context, which stops the provider stream.
+func streamSSE(c *gin.Context, run func(onDelta services.AIDeltaFunc) (interface{}, error)) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	onDelta := func(text string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.SSEvent("delta", gin.H{"text": text})
+		c.Writer.Flush()
+		return nil
+	}
+
+	result, err := run(onDelta)
+	if ctx.Err() != nil {
+		log.Printf("streamSSE: Client disconnected from %s", c.Request.URL.Path)
+		return
+	}
+	if err != nil {
+		log.Printf("streamSSE: %s: %v", c.Request.URL.Path, err)
+		c.SSEvent("error", gin.H{"error": err.Error(), "status": aiErrorStatus(err, http.StatusBadGateway)})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("done", result)
+	c.Writer.Flush()
+}
+
+func bindAIReplyRequest(c *gin.Context) (AIReplyRequest, bool) {
+	var req AIReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return req, false
+	}
+	if req.Tone == "" {
+		req.Tone = "friendly"
+	}
+	if !contains(services.ReplyTones, req.Tone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tone", "valid_tones": services.ReplyTones})
+		return req, false
+	}
+	if !validReplyLanguage(c, req.Language) {
+		return req, false
+	}
+	if len(req.Images) > services.MaxReplyImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d images can be sent with a reply", services.MaxReplyImages)})
+		return req, false
+	}
+	for _, image := range req.Images {
+		if !validUploadedImage(c, image) {
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// validUploadedImage checks that an image sent to the vision model is a
+// file in the uploads directory of a format the provider accepts
+func validUploadedImage(c *gin.Context, image string) bool {
+	if strings.ContainsAny(image, `/\`) || !services.IsReplyImage(image) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image filename: " + image})
+		return false
+	}
+	if _, err := os.Stat(services.UploadPath(image)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found: " + image})
+		return false
+	}
+	return true
+}
+
+func (req AIReplyRequest) replyOptions(order *models.Order) services.ReplyOptions {
+	return services.ReplyOptions{Tone: req.Tone, Order: order, Language: req.Language, Images: req.Images}
+}
+
+// replyOrder looks up the order a reply is about. An unknown order_id is an
+// error; a phone number without orders just gives an ungrounded reply.
+func replyOrder(c *gin.Context, req AIReplyRequest) (*models.Order, bool) {
+	if req.OrderID != nil {
+		var order models.Order
+		if err := config.DB.First(&order, *req.OrderID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return nil, false
+		}
+		return &order, true
+	}
+	if req.PhoneNumber == "" {
+		return nil, true
+	}
+
+	order, err := services.FindOrderByPhone(req.PhoneNumber)
+	if err != nil {
+		log.Printf("replyOrder: Order lookup failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	return order, true
+}
+
+func bindChatRequest(c *gin.Context) (ChatRequest, bool) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return req, false
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message cannot be empty"})
+		return req, false
+	}
+	if req.SessionID == "" {
+		req.SessionID = c.GetHeader("X-Session-ID")
+	}
+	return req, true
+}
+
+func openChatSession(c *gin.Context, sessionID string) (*models.ConversationSession, bool) {
+	session, err := services.OpenChatSession(currentUserID(c), sessionID)
+	if errors.Is(err, services.ErrChatSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("openChatSession: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open chat session"})
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,210 @@
+// =================================================================
+// controllers/drafts.go - Draft orders built from customer messages
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"customflow/models"
+	"customflow/repository"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConvertDraftRequest fills in or corrects draft fields before the order is created
+type ConvertDraftRequest struct {
+	OrderID      string   `json:"order_id"`
+	CustomerName *string  `json:"customer_name"`
+	PhoneNumber  *string  `json:"phone_number"`
+	Length       *float64 `json:"length"`
+	Width        *float64 `json:"width"`
+	Thickness    *string  `json:"thickness"`
+	CornerStyle  *string  `json:"corner_style"`
+	Notes        *string  `json:"notes"`
+	SpecialNotes string   `json:"special_notes"`
+	ImageFiles   []string `json:"image_files"`
+}
+
+// GetDrafts lists draft orders, pending ones by default (?status=all for everything)
+func (h *OrderHandler) GetDrafts(c *gin.Context) {
+	filter := repository.DraftFilter{Source: c.Query("source"), Limit: 200}
+
+	status := c.DefaultQuery("status", services.DraftPending)
+	if status != "all" {
+		if !contains([]string{services.DraftPending, services.DraftConverted, services.DraftDiscarded}, status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+			return
+		}
+		filter.Status = status
+	}
+
+	drafts, err := h.drafts.List(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("GetDrafts: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drafts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"drafts": drafts, "count": len(drafts)})
+}
+
+// GetDraft returns a draft with the messages it was built from
+func (h *OrderHandler) GetDraft(c *gin.Context) {
+	draft, ok := h.findDraft(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.drafts.Messages(c.Request.Context(), draft.ID)
+	if err != nil {
+		log.Printf("GetDraft: Failed to load messages of draft %d: %v", draft.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch draft messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"draft": draft, "messages": messages})
+}
+
+// ConvertDraft creates a real order from a pending draft
+func (h *OrderHandler) ConvertDraft(c *gin.Context) {
+	draft, ok := h.findDraft(c)
+	if !ok {
+		return
+	}
+	if draft.Status != services.DraftPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Draft is already " + draft.Status})
+		return
+	}
+
+	var req ConvertDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	orderReq := draftOrderRequest(draft)
+	if id := strings.TrimSpace(req.OrderID); id != "" {
+		orderReq.OrderID = id
+	}
+	orderReq.SpecialNotes = req.SpecialNotes
+	if req.CustomerName != nil {
+		orderReq.CustomerName = *req.CustomerName
+	}
+	if req.PhoneNumber != nil {
+		orderReq.PhoneNumber = *req.PhoneNumber
+	}
+	if req.Length != nil {
+		orderReq.Length = *req.Length
+	}
+	if req.Width != nil {
+		orderReq.Width = *req.Width
+	}
+	if req.Thickness != nil {
+		orderReq.Thickness = *req.Thickness
+	}
+	if req.CornerStyle != nil {
+		orderReq.CornerStyle = *req.CornerStyle
+	}
+	if req.Notes != nil {
+		orderReq.Notes = *req.Notes
+	}
+	if req.ImageFiles != nil {
+		orderReq.ImageFiles = req.ImageFiles
+	}
+
+	if orderReq.Length <= 0 || orderReq.Width <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Length and width are required to convert a draft"})
+		return
+	}
+
+	order, apiErr := h.createOrder(c, orderReq, currentUserID(c))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	draft.Status = services.DraftConverted
+	draft.OrderID = &order.ID
+	if err := h.drafts.SaveConverted(c.Request.Context(), &draft); err != nil {
+		log.Printf("ConvertDraft: Failed to mark draft %d converted: %v", draft.ID, err)
+	}
+
+	log.Printf("ConvertDraft: Draft %d converted to order %s", draft.ID, order.OrderID)
+	c.JSON(http.StatusCreated, gin.H{
+		"order":   order,
+		"draft":   draft,
+		"message": "Draft converted to order",
+	})
+}
+
+// DiscardDraft marks a pending draft as not an order
+func (h *OrderHandler) DiscardDraft(c *gin.Context) {
+	draft, ok := h.findDraft(c)
+	if !ok {
+		return
+	}
+	if draft.Status != services.DraftPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Draft is already " + draft.Status})
+		return
+	}
+
+	draft.Status = services.DraftDiscarded
+	if err := h.drafts.Save(c.Request.Context(), &draft); err != nil {
+		log.Printf("DiscardDraft: Failed to discard draft %d: %v", draft.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard draft"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"draft": draft, "message": "Draft discarded"})
+}
+
+// draftOrderRequest prefills a CreateOrderRequest from a draft. Length and
+// width are zero when the draft has no dimensions yet.
+func draftOrderRequest(draft models.DraftOrder) CreateOrderRequest {
+	req := CreateOrderRequest{
+		OrderID:      draft.ExternalID,
+		CustomerName: draft.CustomerName,
+		Source:       draft.Source,
+		PhoneNumber:  draft.PhoneNumber,
+		Thickness:    draft.Thickness,
+		CornerStyle:  draft.CornerStyle,
+		Notes:        draft.Notes,
+		ImageFiles:   draft.ImageFiles,
+	}
+	if req.OrderID == "" && draft.ID != 0 {
+		req.OrderID = fmt.Sprintf("%s-%d", strings.ToUpper(draft.Source[:2]), draft.ID)
+	}
+	if draft.Length != nil {
+		req.Length = *draft.Length
+	}
+	if draft.Width != nil {
+		req.Width = *draft.Width
+	}
+	return req
+}
+
+func (h *OrderHandler) findDraft(c *gin.Context) (models.DraftOrder, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID format"})
+		return models.DraftOrder{}, false
+	}
+
+	draft, err := h.drafts.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Draft not found"})
+		} else {
+			log.Printf("findDraft: Failed to load draft %d: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return draft, false
+	}
+	return draft, true
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"customflow/models"
+	"customflow/services"
+)
+
+func TestGetDrafts(t *testing.T) {
+	to := newTestOrders()
+	now := time.Now()
+	for i, draft := range []models.DraftOrder{
+		{Source: "whatsapp", Status: services.DraftPending},
+		{Source: "sms", Status: services.DraftPending},
+		{Source: "whatsapp", Status: services.DraftConverted},
+		{Source: "whatsapp", Status: services.DraftDiscarded},
+	} {
+		draft.CreatedAt = now.Add(time.Duration(i-4) * time.Hour)
+		draft.UpdatedAt = draft.CreatedAt
+		to.store.AddDraft(draft)
+	}
+
+	tests := []struct {
+		query string
+		want  string // draft IDs, most recently updated first
+		code  int
+	}{
+		{"", "[2 1]", http.StatusOK},
+		{"status=all", "[4 3 2 1]", http.StatusOK},
+		{"status=converted", "[3]", http.StatusOK},
+		{"source=whatsapp", "[1]", http.StatusOK},
+		{"status=all&source=whatsapp", "[4 3 1]", http.StatusOK},
+		{"status=lost", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Drafts []models.DraftOrder `json:"drafts"`
+			Count  int                 `json:"count"`
+		}
+		if got := serveJSON(t, to.router, http.MethodGet, "/drafts?"+tt.query, nil, &resp); got != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.query, got, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var ids []uint
+		for _, draft := range resp.Drafts {
+			ids = append(ids, draft.ID)
+		}
+		if fmt.Sprint(ids) != tt.want || resp.Count != len(ids) {
+			t.Errorf("%s: drafts = %v (count %d), want %s", tt.query, ids, resp.Count, tt.want)
+		}
+	}
+}
+
+func TestGetDraftMessages(t *testing.T) {
+	to := newTestOrders()
+	draft := to.store.AddDraft(models.DraftOrder{Source: "sms", Status: services.DraftPending})
+	other := to.store.AddDraft(models.DraftOrder{Source: "sms", Status: services.DraftPending})
+	now := time.Now()
+	to.store.AddMessage(models.ChannelMessage{DraftOrderID: &draft.ID, Body: "second", CreatedAt: now})
+	to.store.AddMessage(models.ChannelMessage{DraftOrderID: &other.ID, Body: "elsewhere", CreatedAt: now})
+	to.store.AddMessage(models.ChannelMessage{DraftOrderID: &draft.ID, Body: "first", CreatedAt: now.Add(-time.Minute)})
+
+	var resp struct {
+		Draft    models.DraftOrder       `json:"draft"`
+		Messages []models.ChannelMessage `json:"messages"`
+	}
+	if got := serveJSON(t, to.router, http.MethodGet, fmt.Sprintf("/drafts/%d", draft.ID), nil, &resp); got != http.StatusOK {
+		t.Fatalf("status %d", got)
+	}
+	if resp.Draft.ID != draft.ID || len(resp.Messages) != 2 || resp.Messages[0].Body != "first" || resp.Messages[1].Body != "second" {
+		t.Errorf("draft %d with messages %+v, want draft %d with first and second", resp.Draft.ID, resp.Messages, draft.ID)
+	}
+
+	for _, target := range []string{"/drafts/99", "/drafts/abc"} {
+		want := http.StatusNotFound
+		if target == "/drafts/abc" {
+			want = http.StatusBadRequest
+		}
+		if got := serveJSON(t, to.router, http.MethodGet, target, nil, nil); got != want {
+			t.Errorf("GET %s: status %d, want %d", target, got, want)
+		}
+	}
+}
+
+func TestDiscardDraft(t *testing.T) {
+	to := newTestOrders()
+	draft := to.store.AddDraft(models.DraftOrder{Source: "whatsapp", Status: services.DraftPending})
+	target := fmt.Sprintf("/drafts/%d/discard", draft.ID)
+
+	if got := serveJSON(t, to.router, http.MethodPost, target, nil, nil); got != http.StatusOK {
+		t.Fatalf("discard: status %d", got)
+	}
+	stored, err := to.drafts.Get(benchContext, draft.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != services.DraftDiscarded {
+		t.Errorf("draft status = %q, want %q", stored.Status, services.DraftDiscarded)
+	}
+
+	if got := serveJSON(t, to.router, http.MethodPost, target, nil, nil); got != http.StatusConflict {
+		t.Errorf("discard again: status %d, want %d", got, http.StatusConflict)
+	}
+}
@@ -0,0 +1,78 @@
+// =================================================================
+// controllers/inbound.go - Inbound messaging webhooks (WhatsApp Cloud API, SMS gateway)
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyWhatsAppWebhook answers Meta's subscription handshake
+func VerifyWhatsAppWebhook(c *gin.Context) {
+	if !services.VerifyWhatsAppChallenge(c.Query("hub.mode"), c.Query("hub.verify_token")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verification failed"})
+		return
+	}
+	c.String(http.StatusOK, c.Query("hub.challenge"))
+}
+
+// ReceiveWhatsAppWebhook stores inbound WhatsApp messages and turns them into draft orders.
+// It acknowledges quickly; media download, OCR and reply suggestions run in the background.
+func ReceiveWhatsAppWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	if !services.VerifyWhatsAppSignature(body, c.GetHeader("X-Hub-Signature-256")) {
+		log.Printf("ReceiveWhatsAppWebhook: Invalid signature from %s", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	stored, err := services.HandleWhatsAppWebhook(body)
+	if err != nil {
+		log.Printf("ReceiveWhatsAppWebhook: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": stored})
+}
+
+// ReceiveSMSWebhook stores an inbound SMS from the gateway and threads it onto the customer's order
+func ReceiveSMSWebhook(c *gin.Context) {
+	provider := services.SMS()
+	if provider == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": services.ErrSMSDisabled.Error()})
+		return
+	}
+
+	in, err := provider.ParseInbound(c.Request)
+	if err != nil {
+		log.Printf("ReceiveSMSWebhook: Rejected request from %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid request"})
+		return
+	}
+	if in.From == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing sender"})
+		return
+	}
+
+	if _, created, err := services.HandleInboundSMS(in); err != nil {
+		log.Printf("ReceiveSMSWebhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store message"})
+		return
+	} else if !created {
+		log.Printf("ReceiveSMSWebhook: Message %s already received", in.ExternalID)
+	}
+
+	contentType, body := provider.InboundResponse()
+	c.Data(http.StatusOK, contentType, []byte(body))
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testWhatsAppSecret = "test-app-secret"
+
+func signWhatsApp(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// useDryRunDB points config.DB at a Postgres handle that builds statements
+// without sending them, so handlers that store rows can run without a database.
+// Inserts report no affected rows.
+func useDryRunDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost dbname=test"), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = previous })
+}
+
+func TestReceiveWhatsAppWebhookSignature(t *testing.T) {
+	useDryRunDB(t)
+
+	router := gin.New()
+	router.POST("/inbound/whatsapp", ReceiveWhatsAppWebhook)
+
+	fixtures := []string{"image_message.json", "status_update.json", "text_message.json"}
+	tests := []struct {
+		name      string
+		secret    string // whatsapp.app_secret the server runs with
+		signature func(body []byte) string
+		want      int
+	}{
+		{"valid", testWhatsAppSecret, func(body []byte) string { return signWhatsApp(testWhatsAppSecret, body) }, http.StatusOK},
+		{"wrong secret", testWhatsAppSecret, func(body []byte) string { return signWhatsApp("other-secret", body) }, http.StatusUnauthorized},
+		{"tampered body", testWhatsAppSecret, func(body []byte) string { return signWhatsApp(testWhatsAppSecret, append(body, ' ')) }, http.StatusUnauthorized},
+		{"not hex", testWhatsAppSecret, func([]byte) string { return "sha256=zz" }, http.StatusUnauthorized},
+		{"missing prefix", testWhatsAppSecret, func(body []byte) string { return signWhatsApp(testWhatsAppSecret, body)[len("sha256="):] }, http.StatusUnauthorized},
+		{"missing", testWhatsAppSecret, func([]byte) string { return "" }, http.StatusUnauthorized},
+		{"no secret configured", "", func(body []byte) string { return signWhatsApp("", body) }, http.StatusUnauthorized},
+	}
+
+	for _, fixture := range fixtures {
+		body, err := os.ReadFile(filepath.Join("..", "testdata", "whatsapp", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range tests {
+			t.Run(fixture+"/"+tt.name, func(t *testing.T) {
+				cfg := config.Defaults().WhatsApp
+				cfg.AppSecret = tt.secret
+				services.InitWhatsAppInbound(cfg)
+
+				req := httptest.NewRequest(http.MethodPost, "/inbound/whatsapp", bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				if sig := tt.signature(body); sig != "" {
+					req.Header.Set("X-Hub-Signature-256", sig)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				if w.Code != tt.want {
+					t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestReceiveSMSWebhook(t *testing.T) {
+	useDryRunDB(t)
+	t.Cleanup(func() { services.InitSMS(config.SMSConfig{}) })
+
+	router := gin.New()
+	router.POST("/api/v1/inbound/sms", ReceiveSMSWebhook)
+
+	fixture, err := os.ReadFile(filepath.Join("..", "testdata", "sms", "inbound.form"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.TrimSpace(string(fixture))
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const webhookURL = "https://orders.example.com/api/v1/inbound/sms"
+	twilio := config.SMSConfig{
+		Provider:   "twilio",
+		WebhookURL: webhookURL,
+		Twilio:     config.TwilioConfig{AccountSID: "AC123", AuthToken: "twilio-token", FromNumber: "+15550783881"},
+	}
+
+	tests := []struct {
+		name      string
+		cfg       config.SMSConfig
+		body      string
+		signature string
+		want      int
+	}{
+		{"twilio signed", twilio, body, services.TwilioSignature("twilio-token", webhookURL, form), http.StatusOK},
+		{"twilio wrong token", twilio, body, services.TwilioSignature("other-token", webhookURL, form), http.StatusUnauthorized},
+		{"twilio other url", twilio, body, services.TwilioSignature("twilio-token", "https://evil.example.com/sms", form), http.StatusUnauthorized},
+		{"twilio tampered body", twilio, strings.Replace(body, "48x30", "96x30", 1), services.TwilioSignature("twilio-token", webhookURL, form), http.StatusUnauthorized},
+		{"twilio unsigned", twilio, body, "", http.StatusUnauthorized},
+		{"fake unsigned", config.SMSConfig{Provider: "fake"}, body, "", http.StatusOK},
+		{"fake without sender", config.SMSConfig{Provider: "fake"}, "Body=hello", "", http.StatusBadRequest},
+		{"disabled", config.SMSConfig{}, body, "", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := services.InitSMS(tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/inbound/sms", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.signature != "" {
+				req.Header.Set("X-Twilio-Signature", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && !strings.Contains(w.Body.String(), "<Response>") {
+				t.Errorf("body = %q, want the gateway's empty TwiML response", w.Body.String())
+			}
+		})
+	}
+}
+
+// TestReceiveWhatsAppWebhookDraft posts the text fixture and a follow-up
+// from the same customer and checks both land on one pending draft. Merging
+// takes an advisory lock and runs in the background, so it needs Postgres: set
+// TEST_DATABASE_URL to a migrated database. The test's messages and drafts
+// are deleted before and after.
+//
+//	TEST_DATABASE_URL=postgres://... go test ./controllers -run WhatsAppWebhookDraft
+func TestReceiveWhatsAppWebhookDraft(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = previous })
+
+	cfg := config.Defaults().WhatsApp
+	cfg.AppSecret = testWhatsAppSecret
+	services.InitWhatsAppInbound(cfg)
+	services.InitAIService(config.AIConfig{}) // fallback replies, no provider calls
+
+	first, err := os.ReadFile(filepath.Join("..", "testdata", "whatsapp", "text_message.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		phone    = "+919812345678"
+		firstID  = "wamid.HBgMOTE5ODEyMzQ1Njc4FQIAEhgUM0VCMDRBNzI5RkM2QjE0RDY1QTAA"
+		secondID = "wamid.test-follow-up"
+	)
+	second := bytes.Replace(first, []byte(firstID), []byte(secondID), 1)
+	second = bytes.Replace(second, []byte("Hi, I need a table cover 48 x 30 inches, 2mm, rounded corners please"), []byte("Also it is for the dining room"), 1)
+
+	cleanup := func() {
+		db.Where("channel = ? AND external_id IN ?", "whatsapp", []string{firstID, secondID}).Delete(&models.ChannelMessage{})
+		db.Where("source = ? AND phone_number = ?", "whatsapp", phone).Delete(&models.DraftOrder{})
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	router := gin.New()
+	router.POST("/inbound/whatsapp", ReceiveWhatsAppWebhook)
+
+	for _, post := range []struct {
+		id   string
+		body []byte
+	}{{firstID, first}, {secondID, second}} {
+		req := httptest.NewRequest(http.MethodPost, "/inbound/whatsapp", bytes.NewReader(post.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Hub-Signature-256", signWhatsApp(testWhatsAppSecret, post.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"received":1`) {
+			t.Fatalf("%s: status %d (%s), want the message received", post.id, w.Code, w.Body.String())
+		}
+
+		// Draft merging runs in the background; wait for the message to be processed
+		var message models.ChannelMessage
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			if err := db.Where("channel = ? AND external_id = ?", "whatsapp", post.id).First(&message).Error; err != nil {
+				t.Fatalf("%s: %v", post.id, err)
+			}
+			if message.Status != services.MessageReceived || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if message.Status != services.MessageProcessed || message.DraftOrderID == nil {
+			t.Fatalf("%s: status %q, draft %v (%s); want it processed onto a draft", post.id, message.Status, message.DraftOrderID, message.Error)
+		}
+	}
+
+	var drafts []models.DraftOrder
+	if err := db.Where("source = ? AND phone_number = ?", "whatsapp", phone).Find(&drafts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 1 {
+		t.Fatalf("got %d drafts for %s, want both messages merged into 1", len(drafts), phone)
+	}
+	draft := drafts[0]
+	if draft.Status != services.DraftPending || draft.CustomerName != "Priya Sharma" {
+		t.Errorf("draft status %q, customer %q; want pending for Priya Sharma", draft.Status, draft.CustomerName)
+	}
+	if !strings.Contains(draft.ExtractedText, "48 x 30 inches") || !strings.Contains(draft.ExtractedText, "dining room") {
+		t.Errorf("draft text = %q, want both messages", draft.ExtractedText)
+	}
+	if draft.Length == nil || *draft.Length != 48 || draft.Width == nil || *draft.Width != 30 {
+		t.Errorf("draft size = %v x %v, want 48 x 30 from the first message", draft.Length, draft.Width)
+	}
+}
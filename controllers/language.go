@@ -0,0 +1,80 @@
+// =================================================================
+// controllers/language.go - Language detection and translation for staff
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TranslateRequest struct {
+	Text   string `json:"text" binding:"required"`
+	Target string `json:"target"` // language code, default en
+}
+
+type DetectLanguageRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// GetLanguages lists the languages replies and translations can be written in
+func GetLanguages(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"languages": services.Languages})
+}
+
+// DetectLanguage reports the language of a text
+func DetectLanguage(c *gin.Context) {
+	var req DetectLanguageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"language": services.DetectLanguage(req.Text)})
+}
+
+// Translate translates what a customer wrote so staff can read it
+func Translate(c *gin.Context) {
+	var req TranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Text cannot be empty"})
+		return
+	}
+	if req.Target == "" {
+		req.Target = services.LanguageEnglish
+	}
+	if _, ok := services.LookupLanguage(req.Target); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target language", "languages": services.Languages})
+		return
+	}
+
+	translation, err := services.Translate(aiContext(c), req.Text, req.Target)
+	if err != nil {
+		log.Printf("Translate: %v", err)
+		c.JSON(aiErrorStatus(err, http.StatusBadGateway), gin.H{"error": err.Error(), "source": translation.Source})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"translation": translation})
+}
+
+// validReplyLanguage checks an optional reply language code; "auto" and
+// empty answer in the customer's language
+func validReplyLanguage(c *gin.Context, code string) bool {
+	if code == "" || code == "auto" {
+		return true
+	}
+	if _, ok := services.LookupLanguage(code); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid language", "languages": services.Languages})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"customflow/config"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var benchContext = context.Background()
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+	if err := services.InitSLA(config.Defaults().SLA); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newOrderRouter routes the order, saved view and draft endpoints to h the
+// way main does, without auth
+func newOrderRouter(h *OrderHandler) *gin.Engine {
+	router := gin.New()
+	orders := router.Group("/orders")
+	{
+		orders.GET("", h.GetOrders)
+		orders.GET("/summary", h.GetOrdersSummary)
+		orders.GET("/:id", h.GetOrder)
+		orders.POST("", h.CreateOrder)
+		orders.PUT("/:id", h.UpdateOrder)
+		orders.DELETE("/:id", h.DeleteOrder)
+		orders.PUT("/:id/status", h.UpdateOrderStatus)
+	}
+	views := router.Group("/views")
+	{
+		views.GET("", h.GetSavedViews)
+		views.POST("", h.CreateSavedView)
+		views.PUT("/:id", h.UpdateSavedView)
+		views.DELETE("/:id", h.DeleteSavedView)
+	}
+	drafts := router.Group("/drafts")
+	{
+		drafts.GET("", h.GetDrafts)
+		drafts.GET("/:id", h.GetDraft)
+		drafts.POST("/:id/convert", h.ConvertDraft)
+		drafts.POST("/:id/discard", h.DiscardDraft)
+	}
+	return router
+}
@@ -0,0 +1,47 @@
+// =================================================================
+// controllers/marketplaces.go - Marketplace connector status and manual sync
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMarketplaces lists the configured connectors with their sync state
+func GetMarketplaces(c *gin.Context) {
+	var states []models.MarketplaceSyncState
+	if err := config.DB.Order("connector").Find(&states).Error; err != nil {
+		log.Printf("GetMarketplaces: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync state"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"connectors": services.MarketplaceConnectorNames(),
+		"sync_state": states,
+	})
+}
+
+// SyncMarketplace runs a connector's sync now instead of waiting for the schedule
+func SyncMarketplace(c *gin.Context) {
+	name := c.Param("name")
+	if !contains(services.MarketplaceConnectorNames(), name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace connector not configured"})
+		return
+	}
+
+	result, err := services.SyncMarketplace(c.Request.Context(), name)
+	if err != nil {
+		log.Printf("SyncMarketplace: %s sync failed: %v", name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Sync failed: " + err.Error(), "result": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
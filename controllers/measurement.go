@@ -0,0 +1,176 @@
+// =================================================================
+// controllers/measurement.go - Table sizes estimated from customer photos
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MeasureTableRequest struct {
+	Image            string            `json:"image" binding:"required"`     // filename returned by /upload
+	Reference        string            `json:"reference" binding:"required"` // code from /ai/measurements/references
+	ReferenceCorners *models.ImageQuad `json:"reference_corners"`            // marked by staff; found by the vision model when empty
+	TableCorners     *models.ImageQuad `json:"table_corners"`
+}
+
+type AttachMeasurementRequest struct {
+	MeasurementID uint `json:"measurement_id" binding:"required"`
+}
+
+// GetReferenceObjects lists the objects a table can be measured against
+func GetReferenceObjects(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"references": services.ReferenceObjects})
+}
+
+// EstimateTableSize estimates the size of a table in a photo from a reference
+// object of known size lying on it, such as an A4 sheet or a credit card.
+// Corners are fractions of the image width and height; those not marked are
+// found by the vision model.
+func EstimateTableSize(c *gin.Context) {
+	var req MeasureTableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	if _, ok := services.LookupReferenceObject(req.Reference); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reference", "references": services.ReferenceObjects})
+		return
+	}
+	if !validUploadedImage(c, req.Image) {
+		return
+	}
+	for _, quad := range []*models.ImageQuad{req.ReferenceCorners, req.TableCorners} {
+		if quad != nil && !validImageQuad(*quad) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Corners must be fractions of the image width and height, from 0 to 1"})
+			return
+		}
+	}
+
+	estimate, err := services.EstimateTableSize(aiContext(c), services.MeasurementInput{
+		Image:            req.Image,
+		Reference:        req.Reference,
+		ReferenceCorners: req.ReferenceCorners,
+		TableCorners:     req.TableCorners,
+	})
+	switch {
+	case errors.Is(err, services.ErrReferenceNotFound), errors.Is(err, services.ErrTableNotFound),
+		errors.Is(err, services.ErrMeasurementGeometry):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "estimate": estimate})
+		return
+	case err != nil:
+		log.Printf("EstimateTableSize: %s: %v", req.Image, err)
+		c.JSON(aiErrorStatus(err, http.StatusBadGateway), gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := currentUserID(c)
+	estimate.CreatedBy = &userID
+	if err := config.DB.Create(&estimate).Error; err != nil {
+		log.Printf("EstimateTableSize: Failed to save estimate: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save measurement"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"estimate": estimate})
+}
+
+// GetMeasurements lists size estimates, newest first; ?image and ?draft_order_id filter them
+func GetMeasurements(c *gin.Context) {
+	query := config.DB.Order("created_at DESC, id DESC")
+	if image := c.Query("image"); image != "" {
+		query = query.Where("image_file = ?", image)
+	}
+	if draftID := c.Query("draft_order_id"); draftID != "" {
+		id, err := strconv.Atoi(draftID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID format"})
+			return
+		}
+		query = query.Where("draft_order_id = ?", id)
+	}
+
+	var estimates []models.MeasurementEstimate
+	if err := query.Limit(200).Find(&estimates).Error; err != nil {
+		log.Printf("GetMeasurements: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch measurements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"estimates": estimates, "count": len(estimates)})
+}
+
+// GetMeasurement returns one size estimate
+func GetMeasurement(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid measurement ID format"})
+		return
+	}
+	estimate, ok := findMeasurement(c, uint(id))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"estimate": estimate})
+}
+
+// AttachDraftMeasurement sets a pending draft's size to a photo estimate
+func (h *OrderHandler) AttachDraftMeasurement(c *gin.Context) {
+	draft, ok := h.findDraft(c)
+	if !ok {
+		return
+	}
+	if draft.Status != services.DraftPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Draft is already " + draft.Status})
+		return
+	}
+
+	var req AttachMeasurementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	estimate, ok := findMeasurement(c, req.MeasurementID)
+	if !ok {
+		return
+	}
+
+	if err := services.AttachMeasurementToDraft(&draft, &estimate); err != nil {
+		log.Printf("AttachDraftMeasurement: Failed to attach measurement %d to draft %d: %v", estimate.ID, draft.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach measurement"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"draft": draft, "estimate": estimate})
+}
+
+func validImageQuad(q models.ImageQuad) bool {
+	for _, p := range q.Corners() {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func findMeasurement(c *gin.Context, id uint) (models.MeasurementEstimate, bool) {
+	var estimate models.MeasurementEstimate
+	if err := config.DB.First(&estimate, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Measurement not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return estimate, false
+	}
+	return estimate, true
+}
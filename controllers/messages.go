@@ -0,0 +1,164 @@
+// =================================================================
+// controllers/messages.go - Customer conversation on an order
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SendOrderMessageRequest struct {
+	Body         string `json:"body"`
+	Tone         string `json:"tone"`           // generate the text with AI when body is empty
+	Language     string `json:"language"`       // of the generated text; default the customer's
+	AIResponseID *uint  `json:"ai_response_id"` // the suggested reply body was edited from
+}
+
+type SuggestReplyRequest struct {
+	Tone     string `json:"tone"`
+	Language string `json:"language"` // default the language of the customer's message
+}
+
+// GetOrderMessages returns the SMS/WhatsApp conversation with the order's customer
+func GetOrderMessages(c *gin.Context) {
+	order, ok := findOrder(c)
+	if !ok {
+		return
+	}
+
+	messages, err := services.OrderConversation(order)
+	if err != nil {
+		log.Printf("GetOrderMessages: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "count": len(messages)})
+}
+
+// SuggestOrderReply drafts a reply to the customer's latest message in one of the AI tones
+func SuggestOrderReply(c *gin.Context) {
+	order, ok := findOrder(c)
+	if !ok {
+		return
+	}
+
+	var req SuggestReplyRequest
+	c.ShouldBindJSON(&req)
+	if req.Tone == "" {
+		req.Tone = "friendly"
+	}
+	if !contains(services.ReplyTones, req.Tone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tone", "valid_tones": services.ReplyTones})
+		return
+	}
+	if !validReplyLanguage(c, req.Language) {
+		return
+	}
+
+	response, err := services.SuggestOrderReply(aiContext(c), order, req.Tone, req.Language)
+	if err != nil {
+		c.JSON(aiErrorStatus(err, http.StatusUnprocessableEntity), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reply": response.Response, "tone": req.Tone, "ai_response_id": response.ID})
+}
+
+// SendOrderMessage texts the order's customer, either the given body or an AI reply in the given tone.
+// The sent text is recorded as the final text of the AI reply it came from.
+func SendOrderMessage(c *gin.Context) {
+	order, ok := findOrder(c)
+	if !ok {
+		return
+	}
+
+	var req SendOrderMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if services.SMS() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": services.ErrSMSDisabled.Error()})
+		return
+	}
+
+	if strings.TrimSpace(order.PhoneNumber) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order has no phone number"})
+		return
+	}
+	if _, ok := services.NormalizePhone(order.PhoneNumber); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidPhone.Error()})
+		return
+	}
+
+	if strings.TrimSpace(req.Body) == "" {
+		if req.Tone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Either body or tone is required"})
+			return
+		}
+		if !contains(services.ReplyTones, req.Tone) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tone", "valid_tones": services.ReplyTones})
+			return
+		}
+		if !validReplyLanguage(c, req.Language) {
+			return
+		}
+
+		response, err := services.SuggestOrderReply(aiContext(c), order, req.Tone, req.Language)
+		if err != nil {
+			c.JSON(aiErrorStatus(err, http.StatusUnprocessableEntity), gin.H{"error": err.Error()})
+			return
+		}
+		req.Body = response.Response
+		if response.ID != 0 {
+			req.AIResponseID = &response.ID
+		}
+	}
+
+	message, err := services.SendOrderSMS(c.Request.Context(), order, req.Body)
+	if err != nil {
+		log.Printf("SendOrderMessage: Failed to send SMS for order %s: %v", order.OrderID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send message", "message": message})
+		return
+	}
+
+	// What was sent is the final text of the AI reply it came from
+	if req.AIResponseID != nil {
+		if _, err := services.RecordAIFeedback(*req.AIResponseID, currentUserID(c), services.AIFeedback{FinalText: &message.Body}); err != nil {
+			log.Printf("SendOrderMessage: Failed to record final text for AI response %d: %v", *req.AIResponseID, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": message})
+}
+
+func findOrder(c *gin.Context) (models.Order, bool) {
+	var order models.Order
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+		return order, false
+	}
+
+	if err := config.DB.First(&order, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return order, false
+	}
+	return order, true
+}
@@ -0,0 +1,94 @@
+// =================================================================
+// controllers/ocr.go - Text and structured order extraction from screenshots
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OCRRequest struct {
+	Images []string `json:"images"` // filenames returned by /upload
+	Text   string   `json:"text"`
+	Mode   string   `json:"mode"`   // "text" (default) or "structured"
+	Source string   `json:"source"` // order source to assume, e.g. whatsapp
+}
+
+// ExtractOrderText runs OCR on uploaded images. In structured mode it returns a
+// validated order instead of raw text, plus a prefilled CreateOrderRequest.
+func ExtractOrderText(c *gin.Context) {
+	var req OCRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	for _, image := range req.Images {
+		if strings.ContainsAny(image, `/\`) || !isValidImageType(image) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image filename: " + image})
+			return
+		}
+	}
+	if req.Source != "" && !contains(models.OrderSources, req.Source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source"})
+		return
+	}
+
+	switch req.Mode {
+	case "", "text":
+		if len(req.Images) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No images provided"})
+			return
+		}
+		// Images that fail are reported per image; only an all-failed batch is an error
+		batch, err := services.RunOCR(aiContext(c), req.Images)
+		if err != nil {
+			log.Printf("ExtractOrderText: OCR failed: %v", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "images": batch.Images})
+			return
+		}
+		c.JSON(http.StatusOK, batch)
+
+	case "structured":
+		result, err := services.ExtractStructuredOrder(aiContext(c), services.ExtractionInput{
+			Text:   req.Text,
+			Images: req.Images,
+			Source: req.Source,
+		})
+		if err != nil {
+			log.Printf("ExtractOrderText: Structured extraction failed: %v", err)
+			c.JSON(aiErrorStatus(err, http.StatusUnprocessableEntity), gin.H{"error": err.Error(), "extraction": result})
+			return
+		}
+
+		orderReq := CreateOrderRequest{
+			OrderID:      result.Order.OrderID,
+			CustomerName: result.Order.CustomerName,
+			Source:       result.Order.Source,
+			PhoneNumber:  result.Order.PhoneNumber,
+			LengthInput:  result.Order.LengthInput,
+			WidthInput:   result.Order.WidthInput,
+			Thickness:    result.Order.Thickness,
+			CornerStyle:  result.Order.CornerStyle,
+			Notes:        result.Order.Notes,
+			ImageFiles:   req.Images,
+		}
+		if result.Order.Length != nil {
+			orderReq.Length = *result.Order.Length
+		}
+		if result.Order.Width != nil {
+			orderReq.Width = *result.Order.Width
+		}
+
+		c.JSON(http.StatusOK, gin.H{"extraction": result, "order_request": orderReq})
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode, expected text or structured"})
+	}
+}
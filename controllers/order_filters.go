@@ -0,0 +1,201 @@
+// =================================================================
+// controllers/order_filters.go - Query parameter filters for GetOrders
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"customflow/models"
+	"customflow/repository"
+	"customflow/services"
+)
+
+// SLA states accepted by ?sla=
+var orderSLAStates = []string{"overdue", "at_risk", "on_track"}
+
+// Query parameters that make up a filter set; saved views store a subset of these
+var orderFilterParams = []string{
+	"status", "source", "thickness", "corner_style", "created_by",
+	"created_from", "created_to", "updated_from", "updated_to",
+	"min_length", "max_length", "min_width", "max_width", "min_area", "max_area",
+	"has_images", "search", "phone", "q", "sla",
+}
+
+// parseOrderFilter reads and validates the GetOrders query parameters
+func parseOrderFilter(params url.Values) (repository.OrderFilter, error) {
+	var f repository.OrderFilter
+	var err error
+
+	if f.Statuses, err = parseEnumList(params.Get("status"), models.OrderStatuses, "status"); err != nil {
+		return f, err
+	}
+	if f.Sources, err = parseEnumList(params.Get("source"), models.OrderSources, "source"); err != nil {
+		return f, err
+	}
+	if f.Thicknesses, err = parseEnumList(params.Get("thickness"), models.OrderThicknesses, "thickness"); err != nil {
+		return f, err
+	}
+	if f.CornerStyles, err = parseEnumList(params.Get("corner_style"), models.OrderCornerStyles, "corner_style"); err != nil {
+		return f, err
+	}
+
+	if v := strings.TrimSpace(params.Get("created_by")); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return f, fmt.Errorf("invalid created_by: %s", v)
+		}
+		createdBy := uint(id)
+		f.CreatedBy = &createdBy
+	}
+
+	dateParams := []struct {
+		name  string
+		dest  **time.Time
+		isEnd bool
+	}{
+		{"created_from", &f.CreatedFrom, false},
+		{"created_to", &f.CreatedTo, true},
+		{"updated_from", &f.UpdatedFrom, false},
+		{"updated_to", &f.UpdatedTo, true},
+	}
+	for _, p := range dateParams {
+		if *p.dest, err = parseDateParam(params.Get(p.name), p.isEnd); err != nil {
+			return f, fmt.Errorf("invalid %s: %v", p.name, err)
+		}
+	}
+
+	numberParams := []struct {
+		name string
+		dest **float64
+	}{
+		{"min_length", &f.MinLength},
+		{"max_length", &f.MaxLength},
+		{"min_width", &f.MinWidth},
+		{"max_width", &f.MaxWidth},
+		{"min_area", &f.MinArea},
+		{"max_area", &f.MaxArea},
+	}
+	for _, p := range numberParams {
+		if *p.dest, err = parseFloatParam(params.Get(p.name)); err != nil {
+			return f, fmt.Errorf("invalid %s: %v", p.name, err)
+		}
+	}
+
+	if v := strings.TrimSpace(params.Get("has_images")); v != "" {
+		hasImages, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid has_images: %s", v)
+		}
+		f.HasImages = &hasImages
+	}
+
+	f.Search = strings.TrimSpace(params.Get("search"))
+	f.Phone = digitsOnly(params.Get("phone"))
+	f.FullText = strings.TrimSpace(params.Get("q"))
+
+	if f.SLA = strings.TrimSpace(params.Get("sla")); f.SLA != "" && !contains(orderSLAStates, f.SLA) {
+		return f, fmt.Errorf("invalid sla filter: %s", f.SLA)
+	}
+	f.AtRiskWindow = services.AtRiskWindow()
+
+	if f.Sort, err = parseSort(params.Get("sort")); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// parseSort accepts "field" or "-field" (descending), comma separated, e.g. sort=-created_at,length
+func parseSort(raw string) ([]repository.SortField, error) {
+	var fields []repository.SortField
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := repository.SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = repository.SortField{Field: part[1:], Desc: true}
+		} else if name, dir, ok := strings.Cut(part, ":"); ok {
+			field = repository.SortField{Field: name, Desc: strings.EqualFold(dir, "desc")}
+		}
+
+		if _, ok := repository.OrderSortColumns[field.Field]; !ok {
+			return nil, fmt.Errorf("invalid sort field: %s", field.Field)
+		}
+		if seen[field.Field] {
+			continue
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// parseEnumList splits a comma separated parameter and checks each value against the allowed list
+func parseEnumList(raw string, allowed []string, name string) ([]string, error) {
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !contains(allowed, v) {
+			return nil, fmt.Errorf("invalid %s filter: %s", name, v)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// parseDateParam accepts RFC3339 timestamps or plain dates; a plain end date includes the whole day
+func parseDateParam(raw string, isEnd bool) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, fmt.Errorf("expected YYYY-MM-DD or RFC3339, got %s", raw)
+	}
+	if isEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func parseFloatParam(raw string) (*float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 {
+		return nil, fmt.Errorf("expected a non-negative number, got %s", raw)
+	}
+	return &v, nil
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,638 @@
+// =================================================================
+// controllers/orders.go - Order handlers on top of the order repositories
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"customflow/models"
+	"customflow/repository"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateOrderRequest struct {
+	OrderID      string   `json:"order_id" binding:"required,min=3,max=100"`
+	CustomerName string   `json:"customer_name"`
+	Source       string   `json:"source"`
+	PhoneNumber  string   `json:"phone_number"`
+	Length       float64  `json:"length"`       // in Unit, inches by default
+	Width        float64  `json:"width"`        // in Unit, inches by default
+	LengthInput  string   `json:"length_input"` // as written, e.g. "48 1/2 in", "4' 6\"" or "120cm"
+	WidthInput   string   `json:"width_input"`
+	Dimensions   string   `json:"dimensions"` // both at once, e.g. "4ft x 2.5ft" or "120 x 60 cm"
+	Unit         string   `json:"unit"`       // unit of bare numbers: in, cm, mm, m or ft
+	Thickness    string   `json:"thickness" binding:"required"`
+	CornerStyle  string   `json:"corner_style" binding:"required"`
+	Notes        string   `json:"notes"`
+	SpecialNotes string   `json:"special_notes"`
+	ImageFiles   []string `json:"image_files"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// OrderHandler serves the order endpoints, the dashboard summary, saved
+// views and the draft orders that become orders
+type OrderHandler struct {
+	orders repository.OrderRepository
+	images repository.ImageRepository
+	drafts repository.DraftRepository
+	views  repository.SavedViewRepository
+}
+
+// NewOrderHandler builds the order handlers on the given repositories
+func NewOrderHandler(orders repository.OrderRepository, images repository.ImageRepository, drafts repository.DraftRepository, views repository.SavedViewRepository) *OrderHandler {
+	return &OrderHandler{orders: orders, images: images, drafts: drafts, views: views}
+}
+
+// GetOrders - Fixed for Flyway schema
+func (h *OrderHandler) GetOrders(c *gin.Context) {
+	log.Println("GetOrders: Starting request")
+
+	params, ok := h.orderQueryParams(c)
+	if !ok {
+		return
+	}
+
+	filter, err := parseOrderFilter(params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	ctx := c.Request.Context()
+
+	// Cursor pagination: ?cursor= (empty for the first page) switches from
+	// OFFSET paging to keyset paging over (created_at, id) and skips the COUNT
+	if token, useCursor := c.GetQuery("cursor"); useCursor {
+		if len(filter.Sort) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor pagination only supports the default sort order"})
+			return
+		}
+
+		var cursor *repository.OrderCursor
+		if token != "" {
+			if cursor, err = decodeOrderCursor(token); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Fetch one extra row to know whether another page exists
+		orders, err := h.orders.List(ctx, repository.OrderQuery{Filter: filter, Cursor: cursor, Limit: limit + 1})
+		if err != nil {
+			log.Printf("GetOrders: Failed to fetch orders: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders: " + err.Error()})
+			return
+		}
+
+		hasNext := len(orders) > limit
+		if hasNext {
+			orders = orders[:limit]
+		}
+
+		if wantsImages(c) {
+			if err := h.loadOrderImages(c, orders); err != nil {
+				log.Printf("GetOrders: Failed to load images: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order images"})
+				return
+			}
+		}
+
+		nextCursor := ""
+		if hasNext {
+			nextCursor = encodeOrderCursor(orders[len(orders)-1])
+		}
+
+		annotateOrders(orders)
+
+		log.Printf("GetOrders: Successfully fetched %d orders (cursor)", len(orders))
+
+		c.JSON(http.StatusOK, gin.H{
+			"orders": orders,
+			"pagination": gin.H{
+				"limit":       limit,
+				"next_cursor": nextCursor,
+				"has_next":    hasNext,
+				"has_prev":    cursor != nil,
+			},
+		})
+		return
+	}
+
+	// Offset pagination
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	// Get total count
+	total, err := h.orders.Count(ctx, filter)
+	if err != nil {
+		log.Printf("GetOrders: Failed to count orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count orders"})
+		return
+	}
+
+	log.Printf("GetOrders: Found %d total orders", total)
+
+	orders, err := h.orders.List(ctx, repository.OrderQuery{Filter: filter, Offset: offset, Limit: limit})
+	if err != nil {
+		log.Printf("GetOrders: Failed to fetch orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders: " + err.Error()})
+		return
+	}
+
+	// Images for the whole page in one query
+	if wantsImages(c) {
+		if err := h.loadOrderImages(c, orders); err != nil {
+			log.Printf("GetOrders: Failed to load images: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order images"})
+			return
+		}
+	}
+
+	annotateOrders(orders)
+
+	log.Printf("GetOrders: Successfully fetched %d orders", len(orders))
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": orders,
+		"pagination": gin.H{
+			"total":    total,
+			"page":     page,
+			"limit":    limit,
+			"pages":    (total + int64(limit) - 1) / int64(limit),
+			"has_next": page < int((total+int64(limit)-1)/int64(limit)),
+			"has_prev": page > 1,
+		},
+	})
+}
+
+// GetOrder - Fixed for Flyway schema
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	// Load images separately
+	images, err := h.images.ListByOrder(c.Request.Context(), order.ID)
+	if err != nil {
+		log.Printf("GetOrder: Failed to load images for order %d: %v", order.ID, err)
+	}
+	order.Images = images
+
+	annotateOrder(&order, time.Now())
+
+	log.Printf("GetOrder: Successfully found order: %s", order.OrderID)
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// CreateOrder - Fixed for Flyway schema
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	log.Println("CreateOrder: Starting order creation")
+
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("CreateOrder: Validation error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	order, apiErr := h.createOrder(c, req, currentUserID(c))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"order":   order,
+		"message": "Order created successfully",
+	})
+}
+
+// apiError carries the status and JSON body of a failed request out of a helper
+type apiError struct {
+	Status int
+	Body   gin.H
+}
+
+func newAPIError(status int, message string) *apiError {
+	return &apiError{Status: status, Body: gin.H{"error": message}}
+}
+
+// createOrder validates the request against the schema constraints and stores
+// the order with its images. Shared by CreateOrder and draft conversion.
+func (h *OrderHandler) createOrder(c *gin.Context, req CreateOrderRequest, userID uint) (models.Order, *apiError) {
+	// Set defaults based on your Flyway schema
+	if req.Source == "" {
+		req.Source = "amazon"
+	}
+	if req.Thickness == "" {
+		req.Thickness = "3mm"
+	}
+	if req.CornerStyle == "" {
+		req.CornerStyle = "sharp"
+	}
+
+	// Validate against your Flyway schema constraints
+	if errs := validateOrderRequest(&req); len(errs) > 0 {
+		return models.Order{}, &apiError{Status: http.StatusBadRequest, Body: gin.H{"error": errs[0].Message, "errors": errs}}
+	}
+
+	// Normalize order ID
+	req.OrderID = strings.TrimSpace(req.OrderID)
+	if req.OrderID == "" {
+		return models.Order{}, newAPIError(http.StatusBadRequest, "Order ID cannot be empty")
+	}
+
+	ctx := c.Request.Context()
+
+	// Check for duplicate order ID
+	existingOrder, err := h.orders.FindByOrderID(ctx, req.OrderID)
+	if err == nil {
+		log.Printf("CreateOrder: Duplicate order ID found: %s", req.OrderID)
+		return models.Order{}, &apiError{Status: http.StatusConflict, Body: gin.H{
+			"error": fmt.Sprintf("Order ID '%s' already exists", req.OrderID),
+			"existing_order": gin.H{
+				"id":         existingOrder.ID,
+				"order_id":   existingOrder.OrderID,
+				"created_at": existingOrder.CreatedAt.Format("2006-01-02 15:04:05"),
+				"status":     existingOrder.Status,
+			},
+		}}
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		log.Printf("CreateOrder: Database error checking duplicate: %v", err)
+		return models.Order{}, newAPIError(http.StatusInternalServerError, "Database error checking order ID")
+	}
+
+	// Create order matching your Flyway schema exactly
+	order := models.Order{
+		OrderID:      req.OrderID,
+		CustomerName: strings.TrimSpace(req.CustomerName),
+		Source:       req.Source,
+		PhoneNumber:  strings.TrimSpace(req.PhoneNumber),
+		Length:       req.Length,
+		Width:        req.Width,
+		LengthInput:  req.LengthInput,
+		WidthInput:   req.WidthInput,
+		InputUnit:    req.Unit,
+		Thickness:    req.Thickness,
+		CornerStyle:  req.CornerStyle,
+		Notes:        strings.TrimSpace(req.Notes),
+		SpecialNotes: strings.TrimSpace(req.SpecialNotes),
+		Status:       "new", // Default status based on your schema
+		CreatedBy:    userID,
+		CreatedAt:    time.Now(),
+	}
+	services.ApplyDueDates(&order)
+
+	event := services.Event{Type: services.EventOrderCreated, UserID: userID, OccurredAt: time.Now()}
+	if err := h.orders.Create(ctx, &order, uploadedOrderImages(req.ImageFiles), services.WebhookOutbox(event)); err != nil {
+		log.Printf("CreateOrder: Failed to create order: %v", err)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return models.Order{}, newAPIError(http.StatusConflict, "Order ID already exists")
+		}
+		return models.Order{}, newAPIError(http.StatusInternalServerError, "Failed to create order: "+err.Error())
+	}
+
+	annotateOrder(&order, time.Now())
+
+	event.Order = order
+	services.PublishEvent(event)
+
+	log.Printf("CreateOrder: Successfully created order: %s (ID: %d)", order.OrderID, order.ID)
+	return order, nil
+}
+
+// UpdateOrder - Fixed for Flyway schema
+func (h *OrderHandler) UpdateOrder(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+	log.Printf("UpdateOrder: Updating order ID: %d", order.ID)
+
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if errs := validateOrderRequest(&req); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs[0].Message, "errors": errs})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Check for duplicate order ID if changed
+	if req.OrderID != order.OrderID {
+		if existing, err := h.orders.FindByOrderID(ctx, req.OrderID); err == nil && existing.ID != order.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order ID already exists: " + req.OrderID})
+			return
+		}
+	}
+
+	// Source decides the SLA, so a changed source moves the due dates
+	sourceChanged := req.Source != order.Source
+
+	// Update order fields
+	order.OrderID = strings.TrimSpace(req.OrderID)
+	order.CustomerName = strings.TrimSpace(req.CustomerName)
+	order.Source = req.Source
+	order.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	order.Length = req.Length
+	order.Width = req.Width
+	order.LengthInput = req.LengthInput
+	order.WidthInput = req.WidthInput
+	order.InputUnit = req.Unit
+	order.Thickness = req.Thickness
+	order.CornerStyle = req.CornerStyle
+	order.Notes = strings.TrimSpace(req.Notes)
+	order.SpecialNotes = strings.TrimSpace(req.SpecialNotes)
+
+	event := services.Event{Type: services.EventOrderUpdated, UserID: currentUserID(c), OccurredAt: time.Now()}
+	update := repository.OrderUpdate{ClearSLAAlerts: sourceChanged, Outbox: services.WebhookOutbox(event)}
+	if sourceChanged {
+		services.ApplyDueDates(&order)
+	}
+
+	// Replace images if provided
+	if len(req.ImageFiles) > 0 {
+		update.Images = append([]models.OrderImage{}, uploadedOrderImages(req.ImageFiles)...)
+	}
+
+	if err := h.orders.Update(ctx, &order, update); err != nil {
+		log.Printf("UpdateOrder: Failed to update order: %v", err)
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order ID already exists: " + req.OrderID})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+		}
+		return
+	}
+
+	// Reload with images
+	images, err := h.images.ListByOrder(ctx, order.ID)
+	if err != nil {
+		log.Printf("UpdateOrder: Failed to load images for order %d: %v", order.ID, err)
+	}
+	order.Images = images
+
+	annotateOrder(&order, time.Now())
+
+	event.Order = order
+	services.PublishEvent(event)
+
+	log.Printf("UpdateOrder: Successfully updated order: %s", order.OrderID)
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// UpdateOrderStatus - Fixed for Flyway schema
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate status against Flyway schema
+	if !contains(models.OrderStatuses, req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	oldStatus := order.Status
+	order.Status = req.Status
+
+	// Only a change of status is an event
+	var update repository.OrderUpdate
+	event := services.Event{Type: services.EventOrderStatusChanged, OldStatus: oldStatus, UserID: currentUserID(c), OccurredAt: time.Now()}
+	if oldStatus != order.Status {
+		update.Outbox = services.WebhookOutbox(event)
+	}
+
+	if err := h.orders.Update(c.Request.Context(), &order, update); err != nil {
+		log.Printf("UpdateOrderStatus: Failed to update status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		return
+	}
+
+	annotateOrder(&order, time.Now())
+
+	if update.Outbox != nil {
+		event.Order = order
+		services.PublishEvent(event)
+	}
+
+	log.Printf("UpdateOrderStatus: Status updated from %s to %s for order %s", oldStatus, req.Status, order.OrderID)
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// DeleteOrder - Fixed for Flyway schema
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	event := services.Event{Type: services.EventOrderDeleted, Order: order, UserID: currentUserID(c), OccurredAt: time.Now()}
+	if err := h.orders.Delete(c.Request.Context(), order, services.WebhookOutbox(event)); err != nil {
+		log.Printf("DeleteOrder: Failed to delete order: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order"})
+		return
+	}
+
+	services.PublishEvent(event)
+
+	log.Printf("DeleteOrder: Successfully deleted order: %s", order.OrderID)
+	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
+}
+
+// GetOrdersSummary returns queue counts for the dashboard. It accepts the
+// same filters as GetOrders (including ?view=) and ignores pagination.
+func (h *OrderHandler) GetOrdersSummary(c *gin.Context) {
+	params, ok := h.orderQueryParams(c)
+	if !ok {
+		return
+	}
+
+	filter, err := parseOrderFilter(params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.buildOrdersSummary(c, filter)
+	if err != nil {
+		log.Printf("GetOrdersSummary: Failed to count orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build order summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *OrderHandler) buildOrdersSummary(c *gin.Context, filter repository.OrderFilter) (gin.H, error) {
+	byStatus, err := h.countOrdersBy(c, filter, "status", models.OrderStatuses)
+	if err != nil {
+		return nil, err
+	}
+	bySource, err := h.countOrdersBy(c, filter, "source", models.OrderSources)
+	if err != nil {
+		return nil, err
+	}
+	byThickness, err := h.countOrdersBy(c, filter, "thickness", models.OrderThicknesses)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open orders due before now, and due within the at-risk window
+	now := time.Now()
+	riskCutoff := now.Add(services.AtRiskWindow())
+	open := filter
+	open.OpenOnly = true
+
+	overdueFilter := open
+	overdueFilter.DueTo = &now
+	overdue, err := h.countOrdersBy(c, overdueFilter, "status", models.OpenOrderStatuses)
+	if err != nil {
+		return nil, err
+	}
+	atRiskFilter := open
+	atRiskFilter.DueFrom, atRiskFilter.DueTo = &now, &riskCutoff
+	atRisk, err := h.countOrdersBy(c, atRiskFilter, "status", models.OpenOrderStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"total":        sumCounts(byStatus),
+		"by_status":    byStatus,
+		"by_source":    bySource,
+		"by_thickness": byThickness,
+		"overdue": gin.H{
+			"total":     sumCounts(overdue),
+			"by_status": overdue,
+		},
+		"at_risk": gin.H{
+			"total":     sumCounts(atRisk),
+			"by_status": atRisk,
+		},
+		"generated_at": time.Now(),
+	}, nil
+}
+
+// countOrdersBy groups the filtered orders by a column, zero-filling the known values
+func (h *OrderHandler) countOrdersBy(c *gin.Context, filter repository.OrderFilter, column string, known []string) (map[string]int64, error) {
+	rows, err := h.orders.CountBy(c.Request.Context(), filter, column)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(known))
+	for _, k := range known {
+		counts[k] = 0
+	}
+	for key, n := range rows {
+		counts[key] = n
+	}
+	return counts, nil
+}
+
+func sumCounts(counts map[string]int64) int64 {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+// findOrder loads the order named by the :id path parameter, writing the error response if it can't
+func (h *OrderHandler) findOrder(c *gin.Context) (models.Order, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+		return models.Order{}, false
+	}
+
+	order, err := h.orders.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			log.Printf("findOrder: Database error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return order, false
+	}
+	return order, true
+}
+
+// loadOrderImages fetches the images for all orders in a single query
+func (h *OrderHandler) loadOrderImages(c *gin.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].ID
+	}
+
+	byOrder, err := h.images.ListByOrders(c.Request.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range orders {
+		orders[i].Images = byOrder[orders[i].ID]
+		if orders[i].Images == nil {
+			orders[i].Images = []models.OrderImage{}
+		}
+	}
+	return nil
+}
+
+// uploadedOrderImages builds image records for the files that exist in the uploads directory, skipping the rest
+func uploadedOrderImages(filenames []string) []models.OrderImage {
+	var images []models.OrderImage
+	for _, filename := range filenames {
+		stat, err := os.Stat(services.UploadPath(filename))
+		if err != nil {
+			log.Printf("Order image file not found: %s", filename)
+			continue
+		}
+		images = append(images, models.OrderImage{
+			Filename: filename,
+			Path:     fmt.Sprintf("/uploads/%s", filename),
+			MimeType: getMimeType(filepath.Ext(filename)),
+			Size:     stat.Size(),
+		})
+	}
+	return images
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"customflow/models"
+	"customflow/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	benchOrders   = 50000
+	benchPageSize = 20
+)
+
+var benchPages = []int{1, 100, 1000, benchOrders / benchPageSize}
+
+// BenchmarkGetOrdersPaging compares OFFSET and keyset paging on increasingly
+// deep pages of a seeded Postgres order list. Set BENCH_DATABASE_URL to a
+// migrated database; the seed rows are written in a transaction that is
+// rolled back afterwards.
+//
+//	BENCH_DATABASE_URL=postgres://... go test ./controllers -run '^$' -bench GetOrdersPaging
+func BenchmarkGetOrdersPaging(b *testing.B) {
+	h, orders := seedBenchOrders(b)
+	router := newOrderRouter(h)
+
+	for _, page := range benchPages {
+		offset := fmt.Sprintf("/orders?limit=%d&page=%d", benchPageSize, page)
+		keyset := fmt.Sprintf("/orders?limit=%d&cursor=", benchPageSize)
+		if page > 1 {
+			// The cursor of the last order on the page before
+			keyset = fmt.Sprintf("/orders?limit=%d&cursor=%s", benchPageSize, encodeOrderCursor(orders[(page-1)*benchPageSize-1]))
+		}
+
+		// Both must return the same page for the timings to be comparable
+		if a, k := benchFirstID(b, router, offset), benchFirstID(b, router, keyset); a != k {
+			b.Fatalf("page %d: offset starts at order %d, keyset at %d", page, a, k)
+		}
+
+		b.Run(fmt.Sprintf("offset/page=%d", page), func(b *testing.B) { benchGet(b, router, offset) })
+		b.Run(fmt.Sprintf("keyset/page=%d", page), func(b *testing.B) { benchGet(b, router, keyset) })
+	}
+}
+
+func benchGet(b *testing.B, router *gin.Engine, target string) {
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			b.Fatalf("GET %s: %d %s", target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func benchFirstID(b *testing.B, router *gin.Engine, target string) uint {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp struct {
+		Orders []models.Order `json:"orders"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil || len(resp.Orders) == 0 {
+		b.Fatalf("GET %s: %d %s", target, w.Code, w.Body.String())
+	}
+	return resp.Orders[0].ID
+}
+
+// seedBenchOrders returns an order handler on a transaction holding
+// benchOrders orders with two images each, and the orders newest first as
+// the list sorts them by default
+func seedBenchOrders(b *testing.B) (*OrderHandler, []models.Order) {
+	b.Helper()
+
+	dsn := os.Getenv("BENCH_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("BENCH_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+
+	tx := db.Begin()
+	b.Cleanup(func() { tx.Rollback() })
+
+	user := models.User{Username: "bench", Email: "bench@example.com", Password: "x", Role: "editor"}
+	if err := tx.Create(&user).Error; err != nil {
+		b.Fatalf("seed user: %v", err)
+	}
+
+	// Dated after any real orders so they fill the first pages
+	start := time.Now().AddDate(1, 0, 0)
+	orders := make([]models.Order, benchOrders)
+	for i := range orders {
+		orders[i] = models.Order{
+			OrderID:     fmt.Sprintf("BENCH-%06d", i),
+			Source:      models.OrderSources[i%len(models.OrderSources)],
+			Length:      float64(24 + i%48),
+			Width:       float64(12 + i%24),
+			Thickness:   "3mm",
+			CornerStyle: "sharp",
+			Status:      models.OrderStatuses[i%len(models.OrderStatuses)],
+			CreatedBy:   user.ID,
+			CreatedAt:   start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	if err := tx.Omit("Images").CreateInBatches(&orders, 1000).Error; err != nil {
+		b.Fatalf("seed orders: %v", err)
+	}
+
+	images := make([]models.OrderImage, 0, 2*len(orders))
+	for _, order := range orders {
+		for i := 0; i < 2; i++ {
+			name := fmt.Sprintf("bench_%d_%d.jpg", order.ID, i)
+			images = append(images, models.OrderImage{OrderID: order.ID, Filename: name, Path: "/uploads/" + name, MimeType: "image/jpeg", Size: 1024})
+		}
+	}
+	if err := tx.CreateInBatches(&images, 1000).Error; err != nil {
+		b.Fatalf("seed images: %v", err)
+	}
+	if err := tx.Exec("ANALYZE orders").Error; err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+
+	newest := make([]models.Order, len(orders))
+	for i, order := range orders {
+		newest[len(orders)-1-i] = order
+	}
+	return NewOrderHandler(repository.NewGormOrderRepository(tx, tx), repository.NewGormImageRepository(tx), repository.NewGormDraftRepository(tx), repository.NewGormSavedViewRepository(tx)), newest
+}
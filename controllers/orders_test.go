@@ -0,0 +1,543 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"customflow/models"
+	"customflow/repository"
+	"customflow/repository/memory"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testOrders is an order handler on an empty in-memory store
+type testOrders struct {
+	store  *memory.Store
+	orders repository.OrderRepository
+	drafts repository.DraftRepository
+	views  repository.SavedViewRepository
+	router *gin.Engine
+}
+
+func newTestOrders() *testOrders {
+	store := memory.NewStore()
+	orders := memory.NewOrderRepository(store)
+	drafts := memory.NewDraftRepository(store)
+	views := memory.NewSavedViewRepository(store)
+	h := NewOrderHandler(orders, memory.NewImageRepository(store), drafts, views)
+	return &testOrders{store: store, orders: orders, drafts: drafts, views: views, router: newOrderRouter(h)}
+}
+
+// seed stores an order, with valid values for the fields order leaves unset
+func (to *testOrders) seed(t *testing.T, order models.Order) models.Order {
+	t.Helper()
+	if order.Source == "" {
+		order.Source = "amazon"
+	}
+	if order.Length == 0 {
+		order.Length, order.Width = 48, 24
+	}
+	if order.Thickness == "" {
+		order.Thickness = "3mm"
+	}
+	if order.CornerStyle == "" {
+		order.CornerStyle = "sharp"
+	}
+	if order.Status == "" {
+		order.Status = "new"
+	}
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = time.Now()
+	}
+	if err := to.orders.Create(benchContext, &order, nil, nil); err != nil {
+		t.Fatalf("seed %s: %v", order.OrderID, err)
+	}
+	return order
+}
+
+// serveJSON sends body as JSON and decodes the response into out, if given
+func serveJSON(t *testing.T, router http.Handler, method, target string, body, out any) int {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, target, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+type orderResponse struct {
+	Order  models.Order        `json:"order"`
+	Error  string              `json:"error"`
+	Errors []models.FieldError `json:"errors"`
+}
+
+type ordersResponse struct {
+	Orders     []models.Order `json:"orders"`
+	Pagination struct {
+		Total      int64  `json:"total"`
+		NextCursor string `json:"next_cursor"`
+		HasNext    bool   `json:"has_next"`
+		HasPrev    bool   `json:"has_prev"`
+	} `json:"pagination"`
+	Error string `json:"error"`
+}
+
+func TestCreateOrder(t *testing.T) {
+	valid := func() gin.H {
+		return gin.H{"order_id": "AMZ-1001", "source": "amazon", "length": 48, "width": 24, "thickness": "3mm", "corner_style": "sharp"}
+	}
+	with := func(key string, value any) gin.H {
+		body := valid()
+		if value == nil {
+			delete(body, key)
+		} else {
+			body[key] = value
+		}
+		return body
+	}
+
+	tests := []struct {
+		name       string
+		body       gin.H
+		want       int
+		wantField  string  // the field the first validation error is about
+		wantLength float64 // stored length in inches, for created orders
+	}{
+		{"valid", valid(), http.StatusCreated, "", 48},
+		{"metric dimensions", with("dimensions", "120 x 60 cm"), http.StatusCreated, "", 47.24},
+		{"source defaults to amazon", with("source", nil), http.StatusCreated, "", 48},
+		{"missing order ID", with("order_id", nil), http.StatusBadRequest, "", 0},
+		{"order ID too short", with("order_id", "A1"), http.StatusBadRequest, "", 0},
+		{"missing thickness", with("thickness", nil), http.StatusBadRequest, "", 0},
+		{"unknown source", with("source", "ebay"), http.StatusBadRequest, "source", 0},
+		{"zero length", with("length", 0), http.StatusBadRequest, "length", 0},
+		{"negative width", with("width", -3), http.StatusBadRequest, "width", 0},
+		{"unknown thickness", with("thickness", "4mm"), http.StatusBadRequest, "thickness", 0},
+		{"unknown corner style", with("corner_style", "bevelled"), http.StatusBadRequest, "corner_style", 0},
+		{"unknown unit", with("unit", "yd"), http.StatusBadRequest, "unit", 0},
+		{"unreadable dimensions", with("dimensions", "big"), http.StatusBadRequest, "dimensions", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := newTestOrders()
+
+			var resp orderResponse
+			if got := serveJSON(t, to.router, http.MethodPost, "/orders", tt.body, &resp); got != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", got, tt.want, resp.Error)
+			}
+			if tt.wantField != "" && (len(resp.Errors) == 0 || resp.Errors[0].Field != tt.wantField) {
+				t.Errorf("errors = %+v, want one about %s", resp.Errors, tt.wantField)
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+
+			stored, err := to.orders.Get(benchContext, resp.Order.ID)
+			if err != nil {
+				t.Fatalf("created order not stored: %v", err)
+			}
+			if stored.Status != "new" || stored.Length != tt.wantLength {
+				t.Errorf("stored status %q length %v, want new and %v", stored.Status, stored.Length, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestCreateOrderDuplicate(t *testing.T) {
+	to := newTestOrders()
+	to.seed(t, models.Order{OrderID: "AMZ-1001"})
+
+	body := gin.H{"order_id": " AMZ-1001 ", "length": 48, "width": 24, "thickness": "3mm", "corner_style": "sharp"}
+	var resp orderResponse
+	if got := serveJSON(t, to.router, http.MethodPost, "/orders", body, &resp); got != http.StatusConflict {
+		t.Errorf("status = %d, want %d (%s)", got, http.StatusConflict, resp.Error)
+	}
+}
+
+func TestUpdateOrder(t *testing.T) {
+	valid := func() gin.H {
+		return gin.H{"order_id": "AMZ-1001", "source": "amazon", "length": 60, "width": 30, "thickness": "5mm", "corner_style": "rounded"}
+	}
+	with := func(key string, value any) gin.H {
+		body := valid()
+		body[key] = value
+		return body
+	}
+
+	tests := []struct {
+		name      string
+		target    string // defaults to the first seeded order
+		body      gin.H
+		want      int
+		wantField string
+	}{
+		{"valid", "", valid(), http.StatusOK, ""},
+		{"new order ID", "", with("order_id", "AMZ-2002"), http.StatusOK, ""},
+		{"order ID of another order", "", with("order_id", "WA-1"), http.StatusConflict, ""},
+		{"unknown source", "", with("source", "ebay"), http.StatusBadRequest, "source"},
+		{"zero width", "", with("width", 0), http.StatusBadRequest, "width"},
+		{"unknown corner style", "", with("corner_style", "bevelled"), http.StatusBadRequest, "corner_style"},
+		{"missing corner style", "", with("corner_style", ""), http.StatusBadRequest, ""},
+		{"unknown order", "/orders/999", valid(), http.StatusNotFound, ""},
+		{"malformed ID", "/orders/abc", valid(), http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := newTestOrders()
+			order := to.seed(t, models.Order{OrderID: "AMZ-1001"})
+			to.seed(t, models.Order{OrderID: "WA-1", Source: "whatsapp"})
+
+			target := tt.target
+			if target == "" {
+				target = fmt.Sprintf("/orders/%d", order.ID)
+			}
+			var resp orderResponse
+			if got := serveJSON(t, to.router, http.MethodPut, target, tt.body, &resp); got != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", got, tt.want, resp.Error)
+			}
+			if tt.wantField != "" && (len(resp.Errors) == 0 || resp.Errors[0].Field != tt.wantField) {
+				t.Errorf("errors = %+v, want one about %s", resp.Errors, tt.wantField)
+			}
+
+			stored, err := to.orders.Get(benchContext, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated := stored.Length == 60; updated != (tt.want == http.StatusOK) {
+				t.Errorf("stored length = %v after status %d", stored.Length, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOrdersFilters(t *testing.T) {
+	to := newTestOrders()
+	now := time.Now()
+	seeded := []models.Order{
+		{OrderID: "AMZ-1", Source: "amazon", Status: "new", Length: 30, Width: 20, CustomerName: "Ann Lee"},
+		{OrderID: "AMZ-2", Source: "amazon", Status: "done", Length: 72, Width: 36, Thickness: "5mm"},
+		{OrderID: "WA-1", Source: "whatsapp", Status: "new", Length: 48, Width: 24, PhoneNumber: "+1 (555) 010-2030"},
+		{OrderID: "SMS-1", Source: "sms", Status: "in-progress", Length: 96, Width: 48, CornerStyle: "rounded"},
+	}
+	for i, order := range seeded {
+		order.CreatedAt = now.Add(time.Duration(i-len(seeded)) * time.Hour)
+		to.seed(t, order)
+	}
+
+	tests := []struct {
+		query string
+		want  []string // order IDs, newest first
+	}{
+		{"", []string{"SMS-1", "WA-1", "AMZ-2", "AMZ-1"}},
+		{"status=new", []string{"WA-1", "AMZ-1"}},
+		{"status=new,in-progress", []string{"SMS-1", "WA-1", "AMZ-1"}},
+		{"source=amazon", []string{"AMZ-2", "AMZ-1"}},
+		{"source=amazon&status=done", []string{"AMZ-2"}},
+		{"thickness=5mm", []string{"AMZ-2"}},
+		{"corner_style=rounded", []string{"SMS-1"}},
+		{"min_length=48&max_length=72", []string{"WA-1", "AMZ-2"}},
+		{"search=ann", []string{"AMZ-1"}},
+		{"phone=5550102030", []string{"WA-1"}},
+		{"sort=length", []string{"AMZ-1", "WA-1", "AMZ-2", "SMS-1"}},
+		{"sort=-width", []string{"SMS-1", "AMZ-2", "WA-1", "AMZ-1"}},
+		{"status=new&limit=1&page=2", []string{"AMZ-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var resp ordersResponse
+			if got := serveJSON(t, to.router, http.MethodGet, "/orders?"+tt.query, nil, &resp); got != http.StatusOK {
+				t.Fatalf("status = %d (%s)", got, resp.Error)
+			}
+			var ids []string
+			for _, order := range resp.Orders {
+				ids = append(ids, order.OrderID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("orders = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOrdersInvalidFilters(t *testing.T) {
+	to := newTestOrders()
+	to.seed(t, models.Order{OrderID: "AMZ-1"})
+
+	for _, query := range []string{
+		"status=lost",
+		"source=ebay",
+		"thickness=4mm",
+		"created_by=me",
+		"created_from=yesterday",
+		"min_length=long",
+		"has_images=maybe",
+		"sla=late",
+		"sort=colour",
+		"cursor=not-a-cursor",
+		"cursor=&sort=length",
+		"view=abc",
+		"view=-1",
+	} {
+		t.Run(query, func(t *testing.T) {
+			var resp ordersResponse
+			if got := serveJSON(t, to.router, http.MethodGet, "/orders?"+query, nil, &resp); got != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", got, http.StatusBadRequest)
+			}
+			if resp.Error == "" {
+				t.Error("no error message")
+			}
+		})
+	}
+}
+
+func TestGetOrdersCursorPaging(t *testing.T) {
+	to := newTestOrders()
+	created := time.Now().Add(-time.Hour)
+	var want []string
+	for i := 0; i < 25; i++ {
+		// Orders 10 and 11 share a timestamp, so the page boundary between
+		// them has to fall back on the ID
+		at := created.Add(time.Duration(i) * time.Minute)
+		if i == 11 {
+			at = created.Add(10 * time.Minute)
+		}
+		status := "new"
+		if i%5 == 0 {
+			status = "done"
+		}
+		order := to.seed(t, models.Order{OrderID: fmt.Sprintf("AMZ-%03d", i), Status: status, CreatedAt: at})
+		if status == "new" {
+			want = append([]string{order.OrderID}, want...)
+		}
+	}
+
+	var got []string
+	target := "/orders?status=new&limit=7&cursor="
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor paging does not end")
+		}
+		var resp ordersResponse
+		if code := serveJSON(t, to.router, http.MethodGet, target, nil, &resp); code != http.StatusOK {
+			t.Fatalf("GET %s: status %d (%s)", target, code, resp.Error)
+		}
+		if resp.Pagination.HasPrev != (pages > 0) {
+			t.Errorf("page %d: has_prev = %v", pages+1, resp.Pagination.HasPrev)
+		}
+		for _, order := range resp.Orders {
+			got = append(got, order.OrderID)
+		}
+		if !resp.Pagination.HasNext {
+			if resp.Pagination.NextCursor != "" {
+				t.Errorf("last page has next_cursor %q", resp.Pagination.NextCursor)
+			}
+			break
+		}
+		if len(resp.Orders) != 7 {
+			t.Errorf("page %d has %d orders, want 7", pages+1, len(resp.Orders))
+		}
+		target = "/orders?status=new&limit=7&cursor=" + resp.Pagination.NextCursor
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("paged through %v, want %v", got, want)
+	}
+}
+
+func TestOrderNotFound(t *testing.T) {
+	to := newTestOrders()
+	to.seed(t, models.Order{OrderID: "AMZ-1"})
+
+	tests := []struct {
+		method, target string
+		body           any
+	}{
+		{http.MethodGet, "/orders/999", nil},
+		{http.MethodPut, "/orders/999", gin.H{"order_id": "AMZ-999", "source": "amazon", "length": 1, "width": 1, "thickness": "3mm", "corner_style": "sharp"}},
+		{http.MethodPut, "/orders/999/status", gin.H{"status": "done"}},
+		{http.MethodDelete, "/orders/999", nil},
+		{http.MethodPost, "/drafts/999/convert", gin.H{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			var resp orderResponse
+			if got := serveJSON(t, to.router, tt.method, tt.target, tt.body, &resp); got != http.StatusNotFound {
+				t.Errorf("status = %d, want %d (%s)", got, http.StatusNotFound, resp.Error)
+			}
+		})
+	}
+}
+
+func TestUpdateOrderStatusAndDelete(t *testing.T) {
+	to := newTestOrders()
+	order := to.seed(t, models.Order{OrderID: "AMZ-1"})
+	target := fmt.Sprintf("/orders/%d", order.ID)
+
+	if got := serveJSON(t, to.router, http.MethodPut, target+"/status", gin.H{"status": "lost"}, nil); got != http.StatusBadRequest {
+		t.Errorf("unknown status: %d, want %d", got, http.StatusBadRequest)
+	}
+	var resp orderResponse
+	if got := serveJSON(t, to.router, http.MethodPut, target+"/status", gin.H{"status": "done"}, &resp); got != http.StatusOK {
+		t.Fatalf("status update: %d (%s)", got, resp.Error)
+	}
+	if resp.Order.Status != "done" {
+		t.Errorf("status = %q, want done", resp.Order.Status)
+	}
+
+	if got := serveJSON(t, to.router, http.MethodDelete, target, nil, nil); got != http.StatusOK {
+		t.Fatalf("delete: %d", got)
+	}
+	if got := serveJSON(t, to.router, http.MethodGet, target, nil, nil); got != http.StatusNotFound {
+		t.Errorf("get after delete: %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestOrderChangesQueueWebhookDeliveries(t *testing.T) {
+	to := newTestOrders()
+	all := to.store.AddWebhookSubscription(models.WebhookSubscription{URL: "https://example.com/all", Events: models.StringList{services.AllEvents}, Active: true})
+	statuses := to.store.AddWebhookSubscription(models.WebhookSubscription{URL: "https://example.com/status", Events: models.StringList{services.EventOrderStatusChanged}, Active: true})
+	to.store.AddWebhookSubscription(models.WebhookSubscription{URL: "https://example.com/off", Events: models.StringList{services.AllEvents}})
+
+	body := gin.H{"order_id": "AMZ-1001", "source": "amazon", "length": 48, "width": 24, "thickness": "3mm", "corner_style": "sharp"}
+	var resp orderResponse
+	if got := serveJSON(t, to.router, http.MethodPost, "/orders", body, &resp); got != http.StatusCreated {
+		t.Fatalf("create: %d (%s)", got, resp.Error)
+	}
+	target := fmt.Sprintf("/orders/%d", resp.Order.ID)
+
+	// A rejected change queues nothing
+	if got := serveJSON(t, to.router, http.MethodPost, "/orders", body, nil); got != http.StatusConflict {
+		t.Fatalf("duplicate create: %d, want %d", got, http.StatusConflict)
+	}
+	body["length"] = 60
+	if got := serveJSON(t, to.router, http.MethodPut, target, body, nil); got != http.StatusOK {
+		t.Fatalf("update: %d", got)
+	}
+	for _, status := range []string{"new", "done"} {
+		if got := serveJSON(t, to.router, http.MethodPut, target+"/status", gin.H{"status": status}, nil); got != http.StatusOK {
+			t.Fatalf("status %s: %d", status, got)
+		}
+	}
+	if got := serveJSON(t, to.router, http.MethodDelete, target, nil, nil); got != http.StatusOK {
+		t.Fatalf("delete: %d", got)
+	}
+
+	want := []struct {
+		event        string
+		subscription uint
+	}{
+		{services.EventOrderCreated, all.ID},
+		{services.EventOrderUpdated, all.ID},
+		{services.EventOrderStatusChanged, all.ID},
+		{services.EventOrderStatusChanged, statuses.ID},
+		{services.EventOrderDeleted, all.ID},
+	}
+	deliveries := to.store.WebhookDeliveries()
+	if len(deliveries) != len(want) {
+		t.Fatalf("queued %d deliveries, want %d: %+v", len(deliveries), len(want), deliveries)
+	}
+	for i, w := range want {
+		d := deliveries[i]
+		if d.EventType != w.event || d.SubscriptionID != w.subscription || d.Status != services.WebhookPending {
+			t.Errorf("delivery %d = %s to %d (%s), want pending %s to %d", i, d.EventType, d.SubscriptionID, d.Status, w.event, w.subscription)
+		}
+		var payload services.WebhookPayload
+		if err := json.Unmarshal(d.Payload, &payload); err != nil || payload.Data.Order.ID != resp.Order.ID || payload.Type != w.event {
+			t.Errorf("delivery %d payload = %s (%v), want order %d", i, d.Payload, err, resp.Order.ID)
+		}
+	}
+	if old := deliveries[2]; !strings.Contains(string(old.Payload), `"old_status":"new"`) {
+		t.Errorf("status change payload = %s, want the old status", old.Payload)
+	}
+}
+
+func TestConvertDraft(t *testing.T) {
+	length, width := 48.0, 24.0
+
+	tests := []struct {
+		name  string
+		draft models.DraftOrder
+		body  gin.H
+		want  int
+	}{
+		{"complete draft", models.DraftOrder{Length: &length, Width: &width, Thickness: "3mm", CornerStyle: "sharp"}, gin.H{}, http.StatusCreated},
+		{"dimensions given on conversion", models.DraftOrder{Thickness: "3mm", CornerStyle: "sharp"}, gin.H{"length": 30, "width": 20}, http.StatusCreated},
+		{"no dimensions", models.DraftOrder{Thickness: "3mm", CornerStyle: "sharp"}, gin.H{}, http.StatusBadRequest},
+		{"invalid thickness", models.DraftOrder{Length: &length, Width: &width, Thickness: "3mm", CornerStyle: "sharp"}, gin.H{"thickness": "4mm"}, http.StatusBadRequest},
+		{"taken order ID", models.DraftOrder{Length: &length, Width: &width, Thickness: "3mm", CornerStyle: "sharp"}, gin.H{"order_id": "AMZ-1"}, http.StatusConflict},
+		{"already converted", models.DraftOrder{Status: services.DraftConverted, Length: &length, Width: &width}, gin.H{}, http.StatusConflict},
+		{"discarded", models.DraftOrder{Status: services.DraftDiscarded, Length: &length, Width: &width}, gin.H{}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := newTestOrders()
+			to.seed(t, models.Order{OrderID: "AMZ-1"})
+			draft := tt.draft
+			draft.Source = "whatsapp"
+			draft.PhoneNumber = "15550102030"
+			if draft.Status == "" {
+				draft.Status = services.DraftPending
+			}
+			draft = to.store.AddDraft(draft)
+
+			var resp struct {
+				Order models.Order      `json:"order"`
+				Draft models.DraftOrder `json:"draft"`
+				Error string            `json:"error"`
+			}
+			target := fmt.Sprintf("/drafts/%d/convert", draft.ID)
+			if got := serveJSON(t, to.router, http.MethodPost, target, tt.body, &resp); got != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", got, tt.want, resp.Error)
+			}
+
+			stored, err := to.drafts.Get(benchContext, draft.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != http.StatusCreated {
+				if stored.Status != draft.Status {
+					t.Errorf("draft status = %q after a failed conversion, want %q", stored.Status, draft.Status)
+				}
+				return
+			}
+
+			if stored.Status != services.DraftConverted || stored.OrderID == nil || *stored.OrderID != resp.Order.ID {
+				t.Errorf("draft saved as %q for order %v, want converted for order %d", stored.Status, stored.OrderID, resp.Order.ID)
+			}
+			order, err := to.orders.Get(benchContext, resp.Order.ID)
+			if err != nil {
+				t.Fatalf("order not stored: %v", err)
+			}
+			if order.Source != "whatsapp" || order.OrderID != fmt.Sprintf("WH-%d", draft.ID) {
+				t.Errorf("order %s from %s, want WH-%d from whatsapp", order.OrderID, order.Source, draft.ID)
+			}
+		})
+	}
+}
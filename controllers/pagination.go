@@ -0,0 +1,55 @@
+// =================================================================
+// controllers/pagination.go - Keyset pagination cursors and ?include= parsing
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"customflow/models"
+	"customflow/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Order cursors are handed to clients as opaque base64 tokens so the
+// encoding can change freely
+func encodeOrderCursor(order models.Order) string {
+	data, _ := json.Marshal(repository.OrderCursor{CreatedAt: order.CreatedAt, ID: order.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeOrderCursor(token string) (*repository.OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor repository.OrderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// wantsImages reports whether the order list should carry images. They are
+// included by default, as they always were; ?include= without images (e.g. an
+// empty include=) leaves them out for a lighter response.
+func wantsImages(c *gin.Context) bool {
+	if _, given := c.GetQuery("include"); !given {
+		return true
+	}
+	return includes(c, "images")
+}
+
+// includes reports whether ?include= lists the given relation, e.g. include=images
+func includes(c *gin.Context, relation string) bool {
+	for _, part := range strings.Split(c.Query("include"), ",") {
+		if strings.TrimSpace(part) == relation {
+			return true
+		}
+	}
+	return false
+}
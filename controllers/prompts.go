@@ -0,0 +1,214 @@
+// =================================================================
+// controllers/prompts.go - Versioned AI prompt templates and A/B comparison
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreatePromptRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Body        string `json:"body" binding:"required"`
+	Description string `json:"description" binding:"max=255"`
+	Active      *bool  `json:"active"` // default true
+	Weight      *int   `json:"weight" binding:"omitempty,gte=0"`
+	ABTest      bool   `json:"ab_test"` // serve alongside the active versions instead of replacing them
+}
+
+type UpdatePromptRequest struct {
+	Active      *bool   `json:"active"`
+	Weight      *int    `json:"weight" binding:"omitempty,gte=0"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
+}
+
+type PreviewPromptRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Body     string `json:"body"` // empty previews the version currently served
+	Message  string `json:"message"`
+	Tone     string `json:"tone"`
+	Language string `json:"language"` // reply language; default the message's
+	OrderID  *uint  `json:"order_id"` // order facts to render; sample facts when empty
+}
+
+// GetPrompts lists the template versions, newest first; ?name narrows to one template
+func GetPrompts(c *gin.Context) {
+	query := config.DB.Order("name, version DESC")
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("active")
+	}
+
+	var templates []models.PromptTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		log.Printf("GetPrompts: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prompt templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prompts": templates, "names": services.PromptNames})
+}
+
+// GetPrompt returns one template version
+func GetPrompt(c *gin.Context) {
+	template, ok := findPromptTemplate(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"prompt": template})
+}
+
+// CreatePrompt saves a new version of a template. Versions are never edited
+// in place, so every stored response can be traced to the text that produced it.
+func CreatePrompt(c *gin.Context) {
+	var req CreatePromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	userID := currentUserID(c)
+	template := models.PromptTemplate{
+		Name:        req.Name,
+		Body:        req.Body,
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+		Weight:      100,
+		CreatedBy:   &userID,
+	}
+	if req.Weight != nil {
+		template.Weight = *req.Weight
+	}
+
+	if err := services.ValidatePrompt(template.Name, template.Body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt template: " + err.Error()})
+		return
+	}
+	if err := services.CreatePromptVersion(&template, req.ABTest); err != nil {
+		log.Printf("CreatePrompt: Failed to save %s: %v", template.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save prompt template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"prompt": template})
+}
+
+// UpdatePrompt activates or deactivates a version, or changes its A/B weight or description
+func UpdatePrompt(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt template ID format"})
+		return
+	}
+
+	var req UpdatePromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	template, err := services.UpdatePromptTemplate(uint(id), services.PromptTemplateUpdate{
+		Active:      req.Active,
+		Weight:      req.Weight,
+		Description: req.Description,
+	})
+	if errors.Is(err, services.ErrPromptTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("UpdatePrompt: Failed to update template %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prompt template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prompt": template})
+}
+
+// PreviewPrompt renders a template body, or the served version, with a sample message
+func PreviewPrompt(c *gin.Context) {
+	var req PreviewPromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	if req.Tone == "" {
+		req.Tone = "friendly"
+	}
+	if !contains(services.PromptNames, req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt name", "valid_names": services.PromptNames})
+		return
+	}
+	if !validReplyLanguage(c, req.Language) {
+		return
+	}
+
+	var order *models.Order
+	if req.OrderID != nil {
+		order = &models.Order{}
+		if err := config.DB.First(order, *req.OrderID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+	}
+
+	rendered, err := services.PreviewPrompt(req.Name, req.Body, req.Message, req.Tone, req.Language, order)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt template: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"name": rendered.Name, "version": rendered.Version, "text": rendered.Text})
+}
+
+// ComparePrompts compares the responses produced by each version of a
+// template over the last ?days (default 30)
+func ComparePrompts(c *gin.Context) {
+	name := c.Param("name")
+	if !contains(services.PromptNames, name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt name", "valid_names": services.PromptNames})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days, expected 1-366"})
+		return
+	}
+
+	stats, err := services.ComparePromptVersions(name, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("ComparePrompts: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare prompt versions"})
+		return
+	}
+
+	var active []models.PromptTemplate
+	config.DB.Where("name = ? AND active", name).Order("version").Find(&active)
+
+	c.JSON(http.StatusOK, gin.H{"name": name, "days": days, "versions": stats, "active": active})
+}
+
+func findPromptTemplate(c *gin.Context) (models.PromptTemplate, bool) {
+	var template models.PromptTemplate
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt template ID format"})
+		return template, false
+	}
+	if err := config.DB.First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+		return template, false
+	}
+	return template, true
+}
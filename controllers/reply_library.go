@@ -0,0 +1,324 @@
+// =================================================================
+// controllers/reply_library.go - Staff feedback on AI responses and the canned reply library
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AIFeedbackRequest struct {
+	FinalText *string `json:"final_text" binding:"omitempty,max=4000"`       // the text staff actually sent
+	Rating    *string `json:"rating" binding:"omitempty,oneof=up down none"` // none clears the rating
+}
+
+type PromoteReplyRequest struct {
+	Title string   `json:"title" binding:"max=200"` // default the start of the customer message
+	Tags  []string `json:"tags"`
+}
+
+type ReplyLibraryRequest struct {
+	Title        string   `json:"title" binding:"required,max=200"`
+	InputMessage string   `json:"input_message"`
+	Body         string   `json:"body" binding:"required"`
+	Tone         string   `json:"tone"`
+	Language     string   `json:"language"` // detected from the body when empty
+	Tags         []string `json:"tags"`
+}
+
+type UpdateReplyLibraryRequest struct {
+	Title        *string  `json:"title" binding:"omitempty,max=200"`
+	InputMessage *string  `json:"input_message"`
+	Body         *string  `json:"body"`
+	Tone         *string  `json:"tone"`
+	Language     *string  `json:"language"`
+	Tags         []string `json:"tags"`
+	Active       *bool    `json:"active"`
+}
+
+var feedbackRatings = map[string]int{"up": services.RatingUp, "down": services.RatingDown, "none": 0}
+
+// GetAIResponses lists stored AI responses, newest first.
+// ?feature, ?order_id and ?rating (up, down, none) filter them.
+func GetAIResponses(c *gin.Context) {
+	query := config.DB.Order("created_at DESC, id DESC")
+
+	if feature := c.Query("feature"); feature != "" {
+		query = query.Where("feature = ?", feature)
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		id, err := strconv.Atoi(orderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+			return
+		}
+		query = query.Where("order_id = ?", id)
+	}
+	switch rating := c.Query("rating"); rating {
+	case "":
+	case "none":
+		query = query.Where("rating IS NULL")
+	case "up", "down":
+		query = query.Where("rating = ?", feedbackRatings[rating])
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rating, expected up, down or none"})
+		return
+	}
+
+	var responses []models.AIResponse
+	if err := query.Limit(200).Find(&responses).Error; err != nil {
+		log.Printf("GetAIResponses: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI responses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"responses": responses, "count": len(responses)})
+}
+
+// RecordAIFeedback stores the text staff sent for a response and their rating
+func RecordAIFeedback(c *gin.Context) {
+	id, ok := aiResponseID(c)
+	if !ok {
+		return
+	}
+
+	var req AIFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	if req.FinalText == nil && req.Rating == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either final_text or rating is required"})
+		return
+	}
+
+	feedback := services.AIFeedback{FinalText: req.FinalText}
+	if req.Rating != nil {
+		rating := feedbackRatings[*req.Rating]
+		feedback.Rating = &rating
+	}
+
+	response, err := services.RecordAIFeedback(id, currentUserID(c), feedback)
+	if errors.Is(err, services.ErrAIResponseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "AI response not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("RecordAIFeedback: Failed to save feedback for response %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save feedback"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"response": response})
+}
+
+// PromoteAIResponse adds a reply to the canned reply library
+func PromoteAIResponse(c *gin.Context) {
+	id, ok := aiResponseID(c)
+	if !ok {
+		return
+	}
+
+	var req PromoteReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	entry, err := services.PromoteAIResponse(id, currentUserID(c), strings.TrimSpace(req.Title), req.Tags)
+	switch {
+	case errors.Is(err, services.ErrAIResponseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "AI response not found"})
+	case errors.Is(err, services.ErrNotPromotable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyPromoted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("PromoteAIResponse: Failed to promote response %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reply to the library"})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"entry": entry})
+	}
+}
+
+// GetReplyLibrary searches the canned replies. ?q uses web search syntax;
+// ?tone, ?language and ?tag filter; ?include_inactive=true shows retired replies.
+func GetReplyLibrary(c *gin.Context) {
+	entries, err := services.SearchReplyLibrary(services.ReplyLibraryFilter{
+		Query:           c.Query("q"),
+		Tone:            c.Query("tone"),
+		Language:        c.Query("language"),
+		Tag:             c.Query("tag"),
+		IncludeInactive: c.Query("include_inactive") == "true",
+	}, 100)
+	if err != nil {
+		log.Printf("GetReplyLibrary: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search the reply library"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "count": len(entries)})
+}
+
+// CreateReplyLibraryEntry adds a reply written by staff to the library
+func CreateReplyLibraryEntry(c *gin.Context) {
+	var req ReplyLibraryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+	if req.Tone == "" {
+		req.Tone = "friendly"
+	}
+	if req.Language == "" {
+		req.Language = services.DetectLanguage(req.Body).Code
+	}
+	if !validLibraryReply(c, req.Tone, req.Language) {
+		return
+	}
+
+	userID := currentUserID(c)
+	entry := models.ReplyLibraryEntry{
+		Title:        strings.TrimSpace(req.Title),
+		InputMessage: strings.TrimSpace(req.InputMessage),
+		Body:         strings.TrimSpace(req.Body),
+		Tone:         req.Tone,
+		Language:     req.Language,
+		Tags:         services.NormalizeTags(req.Tags),
+		Active:       true,
+		CreatedBy:    &userID,
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("CreateReplyLibraryEntry: Failed to save entry: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reply"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"entry": entry})
+}
+
+// UpdateReplyLibraryEntry edits a canned reply, or retires it with active=false
+func UpdateReplyLibraryEntry(c *gin.Context) {
+	entry, ok := findReplyLibraryEntry(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateReplyLibraryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		updates["title"] = strings.TrimSpace(*req.Title)
+	}
+	if req.InputMessage != nil {
+		updates["input_message"] = strings.TrimSpace(*req.InputMessage)
+	}
+	if req.Body != nil {
+		if strings.TrimSpace(*req.Body) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Body cannot be empty"})
+			return
+		}
+		updates["body"] = strings.TrimSpace(*req.Body)
+	}
+	tone, language := entry.Tone, entry.Language
+	if req.Tone != nil {
+		tone = *req.Tone
+		updates["tone"] = tone
+	}
+	if req.Language != nil {
+		language = *req.Language
+		updates["language"] = language
+	}
+	if !validLibraryReply(c, tone, language) {
+		return
+	}
+	if req.Tags != nil {
+		updates["tags"] = services.NormalizeTags(req.Tags)
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, gin.H{"entry": entry})
+		return
+	}
+
+	if err := config.DB.Model(&entry).Updates(updates).Error; err != nil {
+		log.Printf("UpdateReplyLibraryEntry: Failed to update entry %d: %v", entry.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reply"})
+		return
+	}
+	config.DB.First(&entry, entry.ID)
+
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// DeleteReplyLibraryEntry removes a canned reply
+func DeleteReplyLibraryEntry(c *gin.Context) {
+	entry, ok := findReplyLibraryEntry(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&entry).Error; err != nil {
+		log.Printf("DeleteReplyLibraryEntry: Failed to delete entry %d: %v", entry.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reply"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reply deleted from the library"})
+}
+
+func validLibraryReply(c *gin.Context, tone, language string) bool {
+	if !contains(services.ReplyTones, tone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tone", "valid_tones": services.ReplyTones})
+		return false
+	}
+	if _, ok := services.LookupLanguage(language); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid language", "languages": services.Languages})
+		return false
+	}
+	return true
+}
+
+func aiResponseID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid AI response ID format"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func findReplyLibraryEntry(c *gin.Context) (models.ReplyLibraryEntry, bool) {
+	var entry models.ReplyLibraryEntry
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reply ID format"})
+		return entry, false
+	}
+	if err := config.DB.First(&entry, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return entry, false
+	}
+	return entry, true
+}
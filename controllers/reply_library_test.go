@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecordAIFeedbackValidation(t *testing.T) {
+	router := gin.New()
+	router.POST("/ai/responses/:id/feedback", RecordAIFeedback)
+
+	tests := []struct {
+		name string
+		body gin.H
+	}{
+		{"nothing to record", gin.H{}},
+		{"unknown rating", gin.H{"rating": "meh"}},
+		// Edit distance is quadratic in the text length, so long texts are refused up front
+		{"final text too long", gin.H{"final_text": strings.Repeat("a", 4001)}},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if got := serveJSON(t, router, http.MethodPost, "/ai/responses/1/feedback", tt.body, &resp); got != http.StatusBadRequest {
+			t.Errorf("%s: status %d (%s), want %d", tt.name, got, resp.Error, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// =================================================================
+// controllers/views.go - Saved order list views and dashboard summary
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"customflow/models"
+	"customflow/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SavedViewRequest struct {
+	Name    string            `json:"name" binding:"required,max=100"`
+	Filters map[string]string `json:"filters"`
+	Sort    string            `json:"sort"`
+}
+
+// currentUserID returns the user set by AuthMiddleware, falling back to the default user
+func currentUserID(c *gin.Context) uint {
+	value, _ := c.Get("user_id")
+	switch v := value.(type) {
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	}
+	return 1
+}
+
+// orderQueryParams returns the request query, with the filters of ?view=<id>
+// filled in for any parameter the request does not set itself. It writes the
+// error response when the view can't be loaded.
+func (h *OrderHandler) orderQueryParams(c *gin.Context) (url.Values, bool) {
+	params := c.Request.URL.Query()
+
+	value := strings.TrimSpace(params.Get("view"))
+	if value == "" {
+		return params, true
+	}
+	viewID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid view ID: %s", value)})
+		return nil, false
+	}
+
+	view, err := h.views.Get(c.Request.Context(), uint(viewID), currentUserID(c))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("saved view %d not found", viewID)})
+		} else {
+			log.Printf("orderQueryParams: Failed to load saved view %d: %v", viewID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load saved view"})
+		}
+		return nil, false
+	}
+
+	for key, value := range view.Filters {
+		if _, set := params[key]; !set {
+			params.Set(key, value)
+		}
+	}
+	if _, set := params["sort"]; !set && view.Sort != "" {
+		params.Set("sort", view.Sort)
+	}
+	return params, true
+}
+
+// validateSavedView checks the filter keys and values the same way GetOrders would
+func validateSavedView(req *SavedViewRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+
+	params := url.Values{}
+	for key, value := range req.Filters {
+		if !contains(orderFilterParams, key) {
+			return fmt.Errorf("unknown filter: %s", key)
+		}
+		params.Set(key, value)
+	}
+	params.Set("sort", req.Sort)
+
+	_, err := parseOrderFilter(params)
+	return err
+}
+
+// GetSavedViews lists the current user's saved views
+func (h *OrderHandler) GetSavedViews(c *gin.Context) {
+	views, err := h.views.List(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		log.Printf("GetSavedViews: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch saved views"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"views": views})
+}
+
+// CreateSavedView stores a named filter set for the current user
+func (h *OrderHandler) CreateSavedView(c *gin.Context) {
+	var req SavedViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := validateSavedView(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view := models.SavedView{
+		UserID:  currentUserID(c),
+		Name:    req.Name,
+		Filters: models.JSONMap(req.Filters),
+		Sort:    strings.TrimSpace(req.Sort),
+	}
+
+	if err := h.views.Create(c.Request.Context(), &view); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A view named '%s' already exists", req.Name)})
+			return
+		}
+		log.Printf("CreateSavedView: Failed to create view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save view"})
+		return
+	}
+
+	log.Printf("CreateSavedView: Saved view '%s' for user %d", view.Name, view.UserID)
+	c.JSON(http.StatusCreated, gin.H{"view": view})
+}
+
+// UpdateSavedView replaces the name, filters and sort of a saved view
+func (h *OrderHandler) UpdateSavedView(c *gin.Context) {
+	view, ok := h.findSavedView(c)
+	if !ok {
+		return
+	}
+
+	var req SavedViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := validateSavedView(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view.Name = req.Name
+	view.Filters = models.JSONMap(req.Filters)
+	view.Sort = strings.TrimSpace(req.Sort)
+
+	if err := h.views.Save(c.Request.Context(), &view); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A view named '%s' already exists", req.Name)})
+			return
+		}
+		log.Printf("UpdateSavedView: Failed to update view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update view"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"view": view})
+}
+
+// DeleteSavedView removes one of the current user's saved views
+func (h *OrderHandler) DeleteSavedView(c *gin.Context) {
+	view, ok := h.findSavedView(c)
+	if !ok {
+		return
+	}
+
+	if err := h.views.Delete(c.Request.Context(), view); err != nil {
+		log.Printf("DeleteSavedView: Failed to delete view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete view"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "View deleted successfully"})
+}
+
+func (h *OrderHandler) findSavedView(c *gin.Context) (models.SavedView, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid view ID format"})
+		return models.SavedView{}, false
+	}
+
+	view, err := h.views.Get(c.Request.Context(), uint(id), currentUserID(c))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return view, false
+	}
+	return view, true
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"customflow/models"
+	"customflow/repository"
+	"customflow/repository/memory"
+
+	"github.com/gin-gonic/gin"
+)
+
+type viewResponse struct {
+	View  models.SavedView `json:"view"`
+	Error string           `json:"error"`
+}
+
+func TestSavedViews(t *testing.T) {
+	to := newTestOrders()
+	to.seed(t, models.Order{OrderID: "AMZ-1", Source: "amazon", Status: "new"})
+	to.seed(t, models.Order{OrderID: "AMZ-2", Source: "amazon", Status: "done"})
+	to.seed(t, models.Order{OrderID: "WA-1", Source: "whatsapp", Status: "new"})
+
+	var created viewResponse
+	body := gin.H{"name": "New Amazon", "filters": gin.H{"source": "amazon", "status": "new"}, "sort": "-created_at"}
+	if got := serveJSON(t, to.router, http.MethodPost, "/views", body, &created); got != http.StatusCreated {
+		t.Fatalf("create: status %d (%s)", got, created.Error)
+	}
+	if created.View.ID == 0 || created.View.UserID != 1 {
+		t.Fatalf("created view = %+v, want an ID for the default user", created.View)
+	}
+
+	for _, tt := range []struct {
+		name string
+		body gin.H
+		want int
+	}{
+		{"duplicate name", gin.H{"name": "New Amazon"}, http.StatusConflict},
+		{"unknown filter", gin.H{"name": "Colour", "filters": gin.H{"colour": "red"}}, http.StatusBadRequest},
+		{"invalid value", gin.H{"name": "Lost", "filters": gin.H{"status": "lost"}}, http.StatusBadRequest},
+		{"blank name", gin.H{"name": "  "}, http.StatusBadRequest},
+	} {
+		if got := serveJSON(t, to.router, http.MethodPost, "/views", tt.body, nil); got != tt.want {
+			t.Errorf("create %s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// The view fills in the filters the request leaves out
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{fmt.Sprintf("view=%d", created.View.ID), "[AMZ-1]"},
+		{fmt.Sprintf("view=%d&status=done", created.View.ID), "[AMZ-2]"},
+	} {
+		var resp ordersResponse
+		if got := serveJSON(t, to.router, http.MethodGet, "/orders?"+tt.query, nil, &resp); got != http.StatusOK {
+			t.Fatalf("%s: status %d (%s)", tt.query, got, resp.Error)
+		}
+		var ids []string
+		for _, order := range resp.Orders {
+			ids = append(ids, order.OrderID)
+		}
+		if fmt.Sprint(ids) != tt.want {
+			t.Errorf("%s: orders = %v, want %s", tt.query, ids, tt.want)
+		}
+	}
+
+	// Other users' views are not found
+	other := models.SavedView{UserID: 2, Name: "Theirs", Filters: models.JSONMap{"source": "sms"}}
+	if err := to.views.Create(benchContext, &other); err != nil {
+		t.Fatal(err)
+	}
+	if got := serveJSON(t, to.router, http.MethodGet, fmt.Sprintf("/orders?view=%d", other.ID), nil, nil); got != http.StatusBadRequest {
+		t.Errorf("orders with another user's view: status %d, want %d", got, http.StatusBadRequest)
+	}
+	if got := serveJSON(t, to.router, http.MethodDelete, fmt.Sprintf("/views/%d", other.ID), nil, nil); got != http.StatusNotFound {
+		t.Errorf("delete another user's view: status %d, want %d", got, http.StatusNotFound)
+	}
+
+	var updated viewResponse
+	target := fmt.Sprintf("/views/%d", created.View.ID)
+	if got := serveJSON(t, to.router, http.MethodPut, target, gin.H{"name": "WhatsApp", "filters": gin.H{"source": "whatsapp"}}, &updated); got != http.StatusOK {
+		t.Fatalf("update: status %d (%s)", got, updated.Error)
+	}
+
+	var list struct {
+		Views []models.SavedView `json:"views"`
+	}
+	if got := serveJSON(t, to.router, http.MethodGet, "/views", nil, &list); got != http.StatusOK {
+		t.Fatalf("list: status %d", got)
+	}
+	if len(list.Views) != 1 || list.Views[0].Name != "WhatsApp" || list.Views[0].Filters["source"] != "whatsapp" {
+		t.Errorf("views = %+v, want only the updated view", list.Views)
+	}
+
+	if got := serveJSON(t, to.router, http.MethodDelete, target, nil, nil); got != http.StatusOK {
+		t.Fatalf("delete: status %d", got)
+	}
+	if got := serveJSON(t, to.router, http.MethodGet, "/orders?"+fmt.Sprintf("view=%d", created.View.ID), nil, nil); got != http.StatusBadRequest {
+		t.Errorf("orders with a deleted view: status %d, want %d", got, http.StatusBadRequest)
+	}
+}
+
+// brokenViews fails every lookup the way an unreachable database would
+type brokenViews struct {
+	repository.SavedViewRepository
+}
+
+func (brokenViews) Get(ctx context.Context, id, userID uint) (models.SavedView, error) {
+	return models.SavedView{}, errors.New("connection refused")
+}
+
+func TestOrdersViewErrors(t *testing.T) {
+	to := newTestOrders()
+	broken := newOrderRouter(NewOrderHandler(to.orders, memory.NewImageRepository(to.store), to.drafts, brokenViews{to.views}))
+
+	for _, tt := range []struct {
+		name   string
+		router *gin.Engine
+		target string
+		want   int
+	}{
+		{"bad ID", to.router, "/orders?view=abc", http.StatusBadRequest},
+		{"unknown view", to.router, "/orders?view=99", http.StatusBadRequest},
+		{"summary with unknown view", to.router, "/orders/summary?view=99", http.StatusBadRequest},
+		{"storage error", broken, "/orders?view=1", http.StatusInternalServerError},
+		{"summary storage error", broken, "/orders/summary?view=1", http.StatusInternalServerError},
+	} {
+		var resp viewResponse
+		if got := serveJSON(t, tt.router, http.MethodGet, tt.target, nil, &resp); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+		if resp.Error == "" {
+			t.Errorf("%s: no error message", tt.name)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// =================================================================
+// controllers/webhooks.go - Outbound webhook subscriptions and delivery log
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+	"customflow/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookSubscriptionRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	Secret      string   `json:"secret"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// GetWebhookSubscriptions lists all webhook subscriptions
+func GetWebhookSubscriptions(c *gin.Context) {
+	var subscriptions []models.WebhookSubscription
+	if err := config.DB.Order("id").Find(&subscriptions).Error; err != nil {
+		log.Printf("GetWebhookSubscriptions: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subscriptions})
+}
+
+// CreateWebhookSubscription registers an endpoint. The signing secret is only returned here.
+func CreateWebhookSubscription(c *gin.Context) {
+	var req WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := validateWebhookRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Secret == "" {
+		req.Secret = services.NewWebhookSecret()
+	}
+
+	subscription := models.WebhookSubscription{
+		URL:         req.URL,
+		Events:      models.StringList(req.Events),
+		Secret:      req.Secret,
+		Description: strings.TrimSpace(req.Description),
+		Active:      req.Active == nil || *req.Active,
+		CreatedBy:   currentUserID(c),
+	}
+
+	if err := config.DB.Create(&subscription).Error; err != nil {
+		log.Printf("CreateWebhookSubscription: Failed to create subscription: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook subscription"})
+		return
+	}
+
+	log.Printf("CreateWebhookSubscription: Subscribed %s to %v", subscription.URL, req.Events)
+	c.JSON(http.StatusCreated, gin.H{
+		"subscription": subscription,
+		"secret":       subscription.Secret,
+		"message":      "Store the secret now - it is not shown again",
+	})
+}
+
+// UpdateWebhookSubscription changes the URL, events, description, active flag or secret
+func UpdateWebhookSubscription(c *gin.Context) {
+	subscription, ok := findWebhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	var req WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := validateWebhookRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription.URL = req.URL
+	subscription.Events = models.StringList(req.Events)
+	subscription.Description = strings.TrimSpace(req.Description)
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+	if req.Secret != "" {
+		subscription.Secret = req.Secret
+	}
+
+	if err := config.DB.Save(&subscription).Error; err != nil {
+		log.Printf("UpdateWebhookSubscription: Failed to update subscription: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscription": subscription})
+}
+
+// DeleteWebhookSubscription removes a subscription and its delivery log
+func DeleteWebhookSubscription(c *gin.Context) {
+	subscription, ok := findWebhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&subscription).Error; err != nil {
+		log.Printf("DeleteWebhookSubscription: Failed to delete subscription: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook subscription deleted successfully"})
+}
+
+// GetWebhookDeliveries lists recent deliveries for a subscription, optionally by ?status=
+func GetWebhookDeliveries(c *gin.Context) {
+	subscription, ok := findWebhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := config.DB.Where("subscription_id = ?", subscription.ID)
+	if status := c.Query("status"); status != "" {
+		if !contains([]string{services.WebhookPending, services.WebhookSucceeded, services.WebhookFailed}, status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		log.Printf("GetWebhookDeliveries: Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// GetWebhookDelivery returns one delivery with its attempt log
+func GetWebhookDelivery(c *gin.Context) {
+	delivery, ok := findWebhookDelivery(c)
+	if !ok {
+		return
+	}
+
+	config.DB.Where("delivery_id = ?", delivery.ID).Order("attempt_number").Find(&delivery.AttemptLog)
+
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+// ReplayWebhookDelivery queues a delivery again as a new log entry
+func ReplayWebhookDelivery(c *gin.Context) {
+	delivery, ok := findWebhookDelivery(c)
+	if !ok {
+		return
+	}
+
+	replay, err := services.ReplayWebhookDelivery(delivery)
+	if err != nil {
+		log.Printf("ReplayWebhookDelivery: Failed to queue replay: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue replay"})
+		return
+	}
+
+	log.Printf("ReplayWebhookDelivery: Delivery %d queued again as %d", delivery.ID, replay.ID)
+	c.JSON(http.StatusAccepted, gin.H{"delivery": replay})
+}
+
+func validateWebhookRequest(req *WebhookSubscriptionRequest) error {
+	req.URL = strings.TrimSpace(req.URL)
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+
+	for _, event := range req.Events {
+		if !contains(services.WebhookEventTypes, event) {
+			return fmt.Errorf("unknown event type: %s", event)
+		}
+	}
+	return nil
+}
+
+func findWebhookSubscription(c *gin.Context) (models.WebhookSubscription, bool) {
+	var subscription models.WebhookSubscription
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID format"})
+		return subscription, false
+	}
+
+	if err := config.DB.First(&subscription, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return subscription, false
+	}
+	return subscription, true
+}
+
+func findWebhookDelivery(c *gin.Context) (models.WebhookDelivery, bool) {
+	var delivery models.WebhookDelivery
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID format"})
+		return delivery, false
+	}
+
+	if err := config.DB.First(&delivery, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return delivery, false
+	}
+	return delivery, true
+}
@@ -0,0 +1,62 @@
+-- =================================================================
+-- V10__Create_channel_messages_and_draft_orders.sql
+-- Migration: Inbound customer messages and the draft orders built from them
+-- =================================================================
+
+CREATE TABLE draft_orders (
+    id SERIAL PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,
+    phone_number VARCHAR(20),
+    customer_name VARCHAR(255),
+    length DECIMAL(10,2),
+    width DECIMAL(10,2),
+    thickness VARCHAR(10),
+    corner_style VARCHAR(20),
+    notes TEXT,
+    extracted_text TEXT,
+    suggested_reply TEXT,
+    image_files JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    order_id INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_draft_orders_source CHECK (source IN ('amazon', 'whatsapp', 'sms', 'call')),
+    CONSTRAINT chk_draft_orders_status CHECK (status IN ('pending', 'converted', 'discarded')),
+    CONSTRAINT fk_draft_orders_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_draft_orders_status ON draft_orders(status, created_at DESC);
+CREATE INDEX idx_draft_orders_phone_number ON draft_orders(phone_number);
+
+CREATE TRIGGER update_draft_orders_updated_at
+    BEFORE UPDATE ON draft_orders
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE channel_messages (
+    id SERIAL PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL,
+    direction VARCHAR(10) NOT NULL,
+    external_id VARCHAR(255) NOT NULL DEFAULT '',
+    phone_number VARCHAR(20) NOT NULL,
+    customer_name VARCHAR(255),
+    body TEXT,
+    media_files JSONB NOT NULL DEFAULT '[]',
+    order_id INTEGER,
+    draft_order_id INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'received',
+    error TEXT,
+    raw_payload JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_channel_messages_channel CHECK (channel IN ('whatsapp', 'sms')),
+    CONSTRAINT chk_channel_messages_direction CHECK (direction IN ('inbound', 'outbound')),
+    CONSTRAINT chk_channel_messages_status CHECK (status IN ('received', 'processed', 'failed', 'sent')),
+    CONSTRAINT fk_channel_messages_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL,
+    CONSTRAINT fk_channel_messages_draft_order_id FOREIGN KEY (draft_order_id) REFERENCES draft_orders(id) ON DELETE SET NULL
+);
+
+-- Providers retry webhooks, so the same message can arrive twice
+CREATE UNIQUE INDEX uq_channel_messages_external_id ON channel_messages(channel, external_id) WHERE external_id <> '';
+CREATE INDEX idx_channel_messages_phone_number ON channel_messages(phone_number, created_at DESC);
+CREATE INDEX idx_channel_messages_order_id ON channel_messages(order_id);
+CREATE INDEX idx_channel_messages_draft_order_id ON channel_messages(draft_order_id);
@@ -0,0 +1,11 @@
+-- =================================================================
+-- V11__Add_phone_thread_indexes.sql
+-- Migration: Match customer messages to orders on the last 10 phone digits
+-- =================================================================
+
+-- Must stay in sync with services.PhoneKeySQL
+CREATE INDEX idx_orders_phone_key
+    ON orders (right(regexp_replace(phone_number, '[^0-9]', '', 'g'), 10));
+
+CREATE INDEX idx_channel_messages_phone_key
+    ON channel_messages (right(regexp_replace(phone_number, '[^0-9]', '', 'g'), 10), created_at DESC);
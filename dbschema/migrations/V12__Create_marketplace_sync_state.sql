@@ -0,0 +1,26 @@
+-- =================================================================
+-- V12__Create_marketplace_sync_state.sql
+-- Migration: Marketplace order sync cursor and marketplace drafts
+-- =================================================================
+
+CREATE TABLE marketplace_sync_state (
+    connector VARCHAR(50) PRIMARY KEY,
+    cursor TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_success_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    orders_created INTEGER NOT NULL DEFAULT 0,
+    orders_updated INTEGER NOT NULL DEFAULT 0,
+    drafts_created INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_marketplace_sync_state_updated_at
+    BEFORE UPDATE ON marketplace_sync_state
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Marketplace orders without usable dimensions become drafts, keyed by the marketplace order ID
+ALTER TABLE draft_orders ADD COLUMN external_id VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX uq_draft_orders_external_id ON draft_orders(source, external_id) WHERE external_id <> '';
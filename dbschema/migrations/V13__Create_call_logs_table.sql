@@ -0,0 +1,39 @@
+-- =================================================================
+-- V13__Create_call_logs_table.sql
+-- Migration: Phone calls with customers, recordings and transcripts
+-- =================================================================
+
+CREATE TABLE call_logs (
+    id SERIAL PRIMARY KEY,
+    phone_number VARCHAR(20) NOT NULL,
+    customer_name VARCHAR(255),
+    direction VARCHAR(10) NOT NULL DEFAULT 'inbound',
+    duration_seconds INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    recording_file VARCHAR(255),
+    transcript TEXT,
+    transcript_source VARCHAR(20) NOT NULL DEFAULT '',
+    extraction JSONB,
+    order_id INTEGER,
+    draft_order_id INTEGER,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_call_logs_direction CHECK (direction IN ('inbound', 'outbound')),
+    CONSTRAINT chk_call_logs_duration CHECK (duration_seconds >= 0),
+    CONSTRAINT chk_call_logs_transcript_source CHECK (transcript_source IN ('', 'pasted', 'transcribed')),
+    CONSTRAINT fk_call_logs_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL,
+    CONSTRAINT fk_call_logs_draft_order_id FOREIGN KEY (draft_order_id) REFERENCES draft_orders(id) ON DELETE SET NULL,
+    CONSTRAINT fk_call_logs_created_by FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Same phone key as orders and channel_messages (see V11)
+CREATE INDEX idx_call_logs_phone_key
+    ON call_logs (right(regexp_replace(phone_number, '[^0-9]', '', 'g'), 10), started_at DESC);
+CREATE INDEX idx_call_logs_order_id ON call_logs(order_id);
+
+CREATE TRIGGER update_call_logs_updated_at
+    BEFORE UPDATE ON call_logs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,22 @@
+-- =================================================================
+-- V14__Add_order_dimension_inputs.sql
+-- Migration: Keep the dimensions as the customer wrote them next to
+-- the canonical inches in length / width
+-- =================================================================
+
+ALTER TABLE orders ADD COLUMN length_input VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN width_input VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN input_unit VARCHAR(10) NOT NULL DEFAULT 'in';
+
+ALTER TABLE orders ADD CONSTRAINT chk_orders_input_unit
+    CHECK (input_unit IN ('in', 'cm', 'mm', 'm', 'ft', 'ft-in', 'mixed'));
+
+-- Existing orders were entered in inches; backfill without touching updated_at
+ALTER TABLE orders DISABLE TRIGGER update_orders_updated_at;
+
+UPDATE orders
+SET length_input = TRIM(TRAILING '.' FROM TRIM(TRAILING '0' FROM length::text)),
+    width_input = TRIM(TRAILING '.' FROM TRIM(TRAILING '0' FROM width::text))
+WHERE length_input = '';
+
+ALTER TABLE orders ENABLE TRIGGER update_orders_updated_at;
@@ -0,0 +1,17 @@
+-- =================================================================
+-- V15__Create_ocr_cache_table.sql
+-- Migration: OCR results cached by image content hash
+-- =================================================================
+
+CREATE TABLE ocr_cache (
+    content_hash CHAR(64) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    text TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (content_hash, model)
+);
+
+-- Old entries can be pruned by last use
+CREATE INDEX idx_ocr_cache_last_used_at ON ocr_cache(last_used_at);
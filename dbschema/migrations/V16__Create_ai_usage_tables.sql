@@ -0,0 +1,43 @@
+-- =================================================================
+-- V16__Create_ai_usage_tables.sql
+-- Migration: Token usage and cost of every LLM call, and per-user
+-- monthly AI budgets
+-- =================================================================
+
+CREATE TABLE ai_usage (
+    id BIGSERIAL PRIMARY KEY,
+    feature VARCHAR(30) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    requested_model VARCHAR(100) NOT NULL DEFAULT '',
+    user_id INTEGER,
+    order_id INTEGER,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(12,6) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_ai_usage_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_ai_usage_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_ai_usage_created_at ON ai_usage(created_at);
+-- Monthly spend per user, checked before every call of a user with a budget
+CREATE INDEX idx_ai_usage_user_created_at ON ai_usage(user_id, created_at) WHERE user_id IS NOT NULL;
+CREATE INDEX idx_ai_usage_order_id ON ai_usage(order_id) WHERE order_id IS NOT NULL;
+
+CREATE TABLE ai_budgets (
+    user_id INTEGER PRIMARY KEY,
+    monthly_limit_usd DECIMAL(10,2) NOT NULL,
+    action VARCHAR(20) NOT NULL DEFAULT 'block',
+    downgrade_model VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_ai_budgets_limit CHECK (monthly_limit_usd >= 0),
+    CONSTRAINT chk_ai_budgets_action CHECK (action IN ('block', 'downgrade')),
+    CONSTRAINT fk_ai_budgets_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER update_ai_budgets_updated_at
+    BEFORE UPDATE ON ai_budgets
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,49 @@
+-- =================================================================
+-- V17__Add_ai_chat_and_response_details.sql
+-- Migration: Staff AI chat sessions, and what produced each stored
+-- AI response (feature, model, order, streaming outcome)
+-- =================================================================
+
+ALTER TABLE ai_responses ADD COLUMN has_images BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE ai_responses ADD COLUMN feature VARCHAR(30) NOT NULL DEFAULT 'reply';
+ALTER TABLE ai_responses ADD COLUMN model VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE ai_responses ADD COLUMN session_id VARCHAR(64);
+ALTER TABLE ai_responses ADD COLUMN order_id INTEGER;
+-- A streamed response the client disconnected from is kept as far as it got
+ALTER TABLE ai_responses ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'completed';
+
+ALTER TABLE ai_responses ADD CONSTRAINT chk_ai_responses_status
+    CHECK (status IN ('completed', 'cancelled', 'failed'));
+ALTER TABLE ai_responses ADD CONSTRAINT fk_ai_responses_order_id
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_ai_responses_session_id ON ai_responses(session_id) WHERE session_id IS NOT NULL;
+
+CREATE TABLE conversation_sessions (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_conversation_sessions_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER update_conversation_sessions_updated_at
+    BEFORE UPDATE ON conversation_sessions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE conversation_messages (
+    id SERIAL PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    content TEXT NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    token_count INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT chk_conversation_messages_role CHECK (role IN ('user', 'assistant')),
+    CONSTRAINT fk_conversation_messages_session_id FOREIGN KEY (session_id) REFERENCES conversation_sessions(session_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_messages_session_id ON conversation_messages(session_id, timestamp);
@@ -0,0 +1,64 @@
+-- =================================================================
+-- V18__Create_prompt_templates_table.sql
+-- Migration: Versioned AI prompt templates, rendered with Go
+-- text/template, and the versions used by each stored AI response
+-- =================================================================
+
+CREATE TABLE prompt_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    version INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    -- Several active versions of a name are picked at random in
+    -- proportion to their weight, for A/B comparison
+    active BOOLEAN NOT NULL DEFAULT false,
+    weight INTEGER NOT NULL DEFAULT 100,
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_prompt_templates_name_version UNIQUE (name, version),
+    CONSTRAINT chk_prompt_templates_version CHECK (version > 0),
+    CONSTRAINT chk_prompt_templates_weight CHECK (weight >= 0),
+    CONSTRAINT fk_prompt_templates_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_prompt_templates_active ON prompt_templates(name) WHERE active;
+
+CREATE TRIGGER update_prompt_templates_updated_at
+    BEFORE UPDATE ON prompt_templates
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Template name -> version, e.g. {"system": 2, "reply": 1}
+ALTER TABLE ai_responses ADD COLUMN prompt_versions JSONB NOT NULL DEFAULT '{}';
+
+-- Version 1 of each prompt; thicknesses, corner styles and delivery
+-- times now come from the running configuration
+INSERT INTO prompt_templates (name, version, body, description, active) VALUES
+('system', 1, 'You are a professional customer service assistant for CustomFlow, a premium custom table cover manufacturing business.
+
+Your role:
+- Provide helpful, accurate information about custom table covers
+- Maintain a professional yet approachable tone
+- Focus on dimensions, materials, delivery timelines, and customization options
+- Always prioritize customer satisfaction
+- Keep responses concise but informative
+
+Key information about our business:
+- We specialize in custom table covers for dining tables, office tables, conference tables
+- Materials: thicknesses of {{join .Thicknesses ", "}} and corner styles {{join .CornerStyles ", "}}; we do not make other thicknesses
+- Standard delivery: {{.PromiseDays}} business days{{range $source, $days := .PromiseDaysBySource}}{{if ne $days $.PromiseDays}} ({{$days}} for {{$source}} orders){{end}}{{end}}
+- We serve customers through Amazon, WhatsApp, SMS, and phone orders
+- Premium quality and precise measurements are our specialties
+- We work in inches but accept sizes in cm, mm or feet and inches
+
+Always be helpful and ensure customers have the information they need to place their order.', 'Initial system prompt', true),
+('reply', 1, 'Customer message: "{{.Message}}"
+
+{{if eq .Tone "formal"}}Generate a formal, professional response for business correspondence. Use proper business language while addressing table cover requirements.{{else if eq .Tone "short"}}Generate a brief, concise response under 50 words. Focus on essential information - dimensions, material, and delivery.{{else}}Generate a warm, friendly response while remaining professional. Show enthusiasm for helping with custom table cover needs.{{end}}', 'Initial reply prompt', true),
+('chat', 1, '{{.System}}
+
+You are now talking with a CustomFlow staff member, not a customer. Help them with
+order questions, drafting customer messages and working out table cover specifications.
+Keep answers short and practical.', 'Initial staff chat prompt', true);
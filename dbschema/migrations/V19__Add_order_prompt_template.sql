@@ -0,0 +1,21 @@
+-- =================================================================
+-- V19__Add_order_prompt_template.sql
+-- Migration: Prompt that grounds AI replies in the customer's order
+-- (status, size, promised delivery date)
+-- =================================================================
+
+INSERT INTO prompt_templates (name, version, body, description, active) VALUES
+('order', 1, 'The customer has this order with us:
+- Order: {{.Order.Reference}} for {{.Order.CustomerName}}, placed via {{.Order.Source}} on {{.Order.OrderedOn}}
+- Status: {{.Order.StatusText}}
+- Size: {{.Order.Size}}, {{.Order.Thickness}} thick, {{.Order.CornerStyle}} corners
+{{- if .Order.PromisedBy}}
+- Promised delivery by: {{.Order.PromisedBy}}
+{{- end}}
+{{- if .Order.IsOverdue}}
+- The order is behind schedule: apologise for the delay and say the team is prioritising it
+{{- end}}
+
+Answer questions about the order from these facts only. Do not invent tracking numbers, carriers
+or dates that are not listed; if the customer asks for something not listed, say a team member
+will follow up.', 'Initial order facts prompt', true);
@@ -0,0 +1,21 @@
+-- =================================================================
+-- V20__Add_message_languages.sql
+-- Migration: Detected language of customer messages, staff chat and AI
+-- replies, and the prompts for replying in and translating from it
+-- =================================================================
+
+-- Language codes such as en, ta, hi or ta-Latn (Tamil typed in English
+-- letters); empty for rows stored before detection was added
+ALTER TABLE channel_messages ADD COLUMN language VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE conversation_messages ADD COLUMN language VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE ai_responses ADD COLUMN language VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE ai_responses ADD COLUMN reply_language VARCHAR(10) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_channel_messages_language ON channel_messages(language) WHERE language NOT IN ('', 'en');
+
+INSERT INTO prompt_templates (name, version, body, description, active) VALUES
+('language', 1, '{{if ne .CustomerLanguage.Code "und"}}The customer wrote in {{.CustomerLanguage.Name}}. {{end}}Write the whole reply in {{.Language.Name}}.
+Keep order references, sizes, thicknesses and dates exactly as written above.', 'Initial reply language prompt', true),
+('translate', 1, 'Translate the customer''s message{{if ne .CustomerLanguage.Code "und"}} from {{.CustomerLanguage.Name}}{{end}} into {{.Language.Name}}
+for CustomFlow staff. Keep numbers, sizes, units, names and order references exactly as written.
+Reply with the translation only.', 'Initial translation prompt', true);
@@ -0,0 +1,55 @@
+-- =================================================================
+-- V21__Add_ai_feedback_and_reply_library.sql
+-- Migration: Staff feedback on AI responses (final sent text, rating,
+-- edit distance) and the canned reply library used as few-shot examples
+-- =================================================================
+
+ALTER TABLE ai_responses ADD COLUMN final_text TEXT;
+-- 1 thumbs up, -1 thumbs down
+ALTER TABLE ai_responses ADD COLUMN rating SMALLINT;
+-- Levenshtein distance in characters from response to final_text
+ALTER TABLE ai_responses ADD COLUMN edit_distance INTEGER;
+ALTER TABLE ai_responses ADD COLUMN feedback_by INTEGER;
+ALTER TABLE ai_responses ADD COLUMN feedback_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE ai_responses ADD CONSTRAINT chk_ai_responses_rating CHECK (rating IN (-1, 1));
+ALTER TABLE ai_responses ADD CONSTRAINT fk_ai_responses_feedback_by
+    FOREIGN KEY (feedback_by) REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_ai_responses_rating ON ai_responses(rating, created_at DESC) WHERE rating IS NOT NULL;
+
+CREATE TABLE reply_library (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(200) NOT NULL,
+    -- The customer message the reply answers, shown to the model with the reply
+    input_message TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    tone VARCHAR(20) NOT NULL DEFAULT 'friendly',
+    language VARCHAR(10) NOT NULL DEFAULT 'en',
+    tags JSONB NOT NULL DEFAULT '[]',
+    source_response_id INTEGER,
+    active BOOLEAN NOT NULL DEFAULT true,
+    -- Times used as a few-shot example
+    use_count INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- 'simple' keeps Tamil, Hindi and romanized words as they are typed
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', title), 'A') ||
+        setweight(to_tsvector('simple', input_message), 'B') ||
+        setweight(to_tsvector('simple', body), 'C')
+    ) STORED,
+    CONSTRAINT chk_reply_library_tone CHECK (tone IN ('friendly', 'formal', 'short')),
+    CONSTRAINT fk_reply_library_source_response_id FOREIGN KEY (source_response_id) REFERENCES ai_responses(id) ON DELETE SET NULL,
+    CONSTRAINT fk_reply_library_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_reply_library_search ON reply_library USING GIN (search_vector);
+CREATE INDEX idx_reply_library_tags ON reply_library USING GIN (tags);
+CREATE UNIQUE INDEX idx_reply_library_source_response_id ON reply_library(source_response_id) WHERE source_response_id IS NOT NULL;
+
+CREATE TRIGGER update_reply_library_updated_at
+    BEFORE UPDATE ON reply_library
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,12 @@
+-- =================================================================
+-- V22__Add_images_prompt_template.sql
+-- Migration: Prompt for AI replies to messages with photos or
+-- screenshots attached (e.g. a table measured with a tape measure)
+-- =================================================================
+
+INSERT INTO prompt_templates (name, version, body, description, active) VALUES
+('images', 1, 'The customer attached {{if eq .Images 1}}a photo or screenshot{{else}}{{.Images}} photos or screenshots{{end}} to their message.
+Use what you can see in {{if eq .Images 1}}it{{else}}them{{end}} to answer. If a photo shows a table or surface with a tape measure
+or ruler, read the measurement and confirm the length and width you can see, with the unit, and ask
+the customer to check any reading that is unclear.{{if .Order}} Say whether it matches the order size above.{{end}}
+Do not guess at anything you cannot see.', 'Initial attached images prompt', true);
@@ -0,0 +1,37 @@
+-- =================================================================
+-- V23__Create_measurement_estimates_table.sql
+-- Migration: Table sizes estimated from customer photos with a
+-- reference object of known size (A4 sheet, credit card) on the table
+-- =================================================================
+
+CREATE TABLE measurement_estimates (
+    id SERIAL PRIMARY KEY,
+    image_file VARCHAR(255) NOT NULL,
+    reference VARCHAR(20) NOT NULL,
+    -- Corners as fractions of the image width and height:
+    -- {"top_left": {"x": 0.1, "y": 0.2}, "top_right": ..., "bottom_right": ..., "bottom_left": ...}
+    reference_corners JSONB NOT NULL,
+    table_corners JSONB NOT NULL,
+    -- Marked by staff; false when detected by the vision model
+    reference_marked BOOLEAN NOT NULL DEFAULT false,
+    table_marked BOOLEAN NOT NULL DEFAULT false,
+    -- Inches, with the range the size is likely to lie in
+    length DECIMAL(10,2) NOT NULL,
+    width DECIMAL(10,2) NOT NULL,
+    length_min DECIMAL(10,2) NOT NULL,
+    length_max DECIMAL(10,2) NOT NULL,
+    width_min DECIMAL(10,2) NOT NULL,
+    width_max DECIMAL(10,2) NOT NULL,
+    warnings JSONB NOT NULL DEFAULT '[]',
+    model VARCHAR(50) NOT NULL DEFAULT '',
+    draft_order_id INTEGER,
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_measurement_estimates_reference CHECK (reference IN ('a4', 'a3', 'letter', 'credit-card')),
+    CONSTRAINT fk_measurement_estimates_draft_order_id FOREIGN KEY (draft_order_id) REFERENCES draft_orders(id) ON DELETE SET NULL,
+    CONSTRAINT fk_measurement_estimates_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_measurement_estimates_draft_order_id ON measurement_estimates(draft_order_id) WHERE draft_order_id IS NOT NULL;
+CREATE INDEX idx_measurement_estimates_image_file ON measurement_estimates(image_file);
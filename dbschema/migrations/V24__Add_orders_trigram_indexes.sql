@@ -0,0 +1,20 @@
+-- =================================================================
+-- V24__Add_orders_trigram_indexes.sql
+-- Migration: Trigram indexes for the substring filters of GetOrders
+-- =================================================================
+
+-- ?search= and ?phone= match anywhere in the value (ILIKE/LIKE '%...%'),
+-- which a btree index cannot serve
+DROP INDEX IF EXISTS idx_orders_phone_number;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- ?search= on order ID, customer name and phone number
+CREATE INDEX idx_orders_order_id_trgm ON orders USING GIN (order_id gin_trgm_ops);
+CREATE INDEX idx_orders_customer_name_trgm ON orders USING GIN (customer_name gin_trgm_ops);
+CREATE INDEX idx_orders_phone_number_trgm ON orders USING GIN (phone_number gin_trgm_ops);
+
+-- ?phone= on the digits only
+-- NOTE: the expression must match orderPhoneDigits in repository/order_filter.go
+CREATE INDEX idx_orders_phone_digits_trgm ON orders
+    USING GIN ((regexp_replace(phone_number, '[^0-9]', '', 'g')) gin_trgm_ops);
@@ -0,0 +1,15 @@
+-- =================================================================
+-- V5__Add_orders_search_indexes.sql
+-- Migration: Indexes backing the GetOrders filters and full-text search
+-- =================================================================
+
+-- Full-text search over notes and special_notes
+-- NOTE: the expression must match orderNotesTSVector in controllers/order_filters.go
+CREATE INDEX idx_orders_notes_fts ON orders
+    USING GIN (to_tsvector('simple', coalesce(notes, '') || ' ' || coalesce(special_notes, '')));
+
+-- Filter columns not covered by V2
+CREATE INDEX idx_orders_thickness ON orders(thickness);
+CREATE INDEX idx_orders_corner_style ON orders(corner_style);
+CREATE INDEX idx_orders_phone_number ON orders(phone_number);
+CREATE INDEX idx_orders_updated_at ON orders(updated_at DESC);
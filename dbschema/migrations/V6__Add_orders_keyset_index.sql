@@ -0,0 +1,7 @@
+-- =================================================================
+-- V6__Add_orders_keyset_index.sql
+-- Migration: Composite index for cursor pagination in GetOrders
+-- =================================================================
+
+-- Keyset pages are read with (created_at, id) < (?, ?) ORDER BY created_at DESC, id DESC
+CREATE INDEX idx_orders_created_at_id ON orders(created_at DESC, id DESC);
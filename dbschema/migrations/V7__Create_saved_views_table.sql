@@ -0,0 +1,25 @@
+-- =================================================================
+-- V7__Create_saved_views_table.sql
+-- Migration: Per-user saved filter sets for the orders list
+-- =================================================================
+
+CREATE TABLE saved_views (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    filters JSONB NOT NULL DEFAULT '{}',
+    sort VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_saved_views_user_name UNIQUE (user_id, name),
+    CONSTRAINT fk_saved_views_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_saved_views_user_id ON saved_views(user_id);
+
+-- Create trigger for updated_at
+CREATE TRIGGER update_saved_views_updated_at
+    BEFORE UPDATE ON saved_views
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
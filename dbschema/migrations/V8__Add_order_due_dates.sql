@@ -0,0 +1,51 @@
+-- =================================================================
+-- V8__Add_order_due_dates.sql
+-- Migration: SLA due dates on orders and a log of sent SLA alerts
+-- =================================================================
+
+ALTER TABLE orders ADD COLUMN promised_by DATE;
+ALTER TABLE orders ADD COLUMN due_at TIMESTAMP WITH TIME ZONE;
+
+-- Open orders are scanned by due date for overdue / at-risk alerts
+CREATE INDEX idx_orders_status_due_at ON orders(status, due_at);
+
+-- Weekday-only business day arithmetic used for the backfill below.
+-- New orders get their dates from the application, which also knows holidays.
+CREATE OR REPLACE FUNCTION add_business_days(start_at TIMESTAMP WITH TIME ZONE, days INTEGER)
+RETURNS TIMESTAMP WITH TIME ZONE AS $$
+DECLARE
+    result TIMESTAMP WITH TIME ZONE := start_at;
+    added INTEGER := 0;
+BEGIN
+    WHILE added < days LOOP
+        result := result + INTERVAL '1 day';
+        IF EXTRACT(ISODOW FROM result) < 6 THEN
+            added := added + 1;
+        END IF;
+    END LOOP;
+    RETURN result;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Backfill existing orders with the default 3 / 5 business day policy
+-- without touching updated_at
+ALTER TABLE orders DISABLE TRIGGER update_orders_updated_at;
+
+UPDATE orders
+SET due_at = add_business_days(created_at, 3),
+    promised_by = add_business_days(created_at, 5)::date
+WHERE due_at IS NULL;
+
+ALTER TABLE orders ENABLE TRIGGER update_orders_updated_at;
+
+CREATE TABLE order_sla_alerts (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    channel VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_order_sla_alerts_kind CHECK (kind IN ('at_risk', 'overdue')),
+    CONSTRAINT uq_order_sla_alerts_order_kind UNIQUE (order_id, kind),
+    CONSTRAINT fk_order_sla_alerts_order_id FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
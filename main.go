@@ -8,6 +8,8 @@ import (
 
 	"customflow/config"
 	"customflow/controllers"
+	"customflow/middleware"
+	"customflow/repository"
 	"customflow/services"
 
 	"github.com/gin-contrib/cors"
@@ -21,9 +23,30 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	// Defaults, config file, environment and flags, in that order
+	cfg, printConfig, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal("Failed to print configuration: ", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration problems:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
 	// Initialize database connection
 	log.Println("Connecting to database...")
-	config.ConnectDatabase()
+	if err := config.ConnectDatabase(cfg.Database); err != nil {
+		log.Fatal("Database connection failed: ", err)
+	}
 
 	// Verify database connectivity (no migrations)
 	if err := verifyDatabaseConnection(); err != nil {
@@ -35,19 +58,52 @@ func main() {
 		log.Fatal("Required database tables not found. Please run Flyway migrations:", err)
 	}
 
-	// Initialize services
+	// Initialize services. The SLA calendar comes first: every service that
+	// creates orders sets their due dates from it.
+	if err := services.InitSLA(cfg.SLA); err != nil {
+		log.Fatalf("Invalid SLA configuration: %v", err)
+	}
+	services.InitUploads(cfg.Uploads)
 	log.Println("Initializing AI service...")
-	services.InitAIService()
+	services.InitAIService(cfg.AI)
+	services.InitOCR(cfg.OCR)
 
-	// Setup Gin router
-	if os.Getenv("GIN_MODE") == "release" {
-		gin.SetMode(gin.ReleaseMode)
+	// Order event notifications and outbound webhooks
+	services.InitNotifications(cfg.Notifications, cfg.WhatsApp)
+	services.InitWebhooks(cfg.Webhooks)
+
+	// Inbound customer messaging
+	services.InitWhatsAppInbound(cfg.WhatsApp)
+	if err := services.InitSMS(cfg.SMS); err != nil {
+		log.Fatalf("Invalid SMS configuration: %v", err)
 	}
+
+	// Call recordings
+	services.InitTranscription(cfg.Transcription, cfg.AI.APIKey)
+
+	// Marketplace order import
+	services.InitMarketplaces(cfg.Marketplace)
+
+	// Overdue alerts
+	services.StartSLAMonitor(services.StaffNotifier(), cfg.SLA)
+
+	// Repositories and the handlers built on them
+	orderRepo := repository.NewGormOrderRepository(config.DB, config.ReplicaDB())
+	imageRepo := repository.NewGormImageRepository(config.DB)
+	userRepo := repository.NewGormUserRepository(config.DB)
+	draftRepo := repository.NewGormDraftRepository(config.DB)
+	viewRepo := repository.NewGormSavedViewRepository(config.DB)
+	budgetRepo := repository.NewGormAIBudgetRepository(config.DB)
+	orderHandler := controllers.NewOrderHandler(orderRepo, imageRepo, draftRepo, viewRepo)
+	budgetHandler := controllers.NewAIBudgetHandler(budgetRepo, userRepo)
+
+	// Setup Gin router
+	gin.SetMode(cfg.Server.Mode)
 	router := gin.Default()
 
 	// CORS middleware
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     cfg.Server.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Session-ID"},
 		ExposeHeaders:    []string{"Content-Length", "X-Session-ID"},
@@ -74,15 +130,28 @@ func main() {
 	router.Use(gin.Recovery())
 
 	// Static files for uploads
-	router.Static("/uploads", "./uploads")
+	router.Static("/uploads", cfg.Uploads.Dir)
 
 	// Ensure uploads directory exists
-	if err := os.MkdirAll("./uploads", 0755); err != nil {
+	if err := os.MkdirAll(cfg.Uploads.Dir, 0755); err != nil {
 		log.Printf("Warning: Could not create uploads directory: %v", err)
 	}
+	// Recordings are private and only downloaded through /calls/:id/recording
+	if err := os.MkdirAll(cfg.Uploads.RecordingsDir, 0700); err != nil {
+		log.Printf("Warning: Could not create recordings directory: %v", err)
+	}
+
+	// Inbound provider webhooks authenticate by signature, not by API session
+	inbound := router.Group("/api/v1/inbound")
+	{
+		inbound.GET("/whatsapp", controllers.VerifyWhatsAppWebhook)
+		inbound.POST("/whatsapp", controllers.ReceiveWhatsAppWebhook)
+		inbound.POST("/sms", controllers.ReceiveSMSWebhook)
+	}
 
 	// API routes
 	api := router.Group("/api/v1")
+	api.Use(middleware.AuthMiddleware())
 	{
 		// Health check
 		api.GET("/health", controllers.HealthCheck)
@@ -90,16 +159,103 @@ func main() {
 		// Order routes
 		orders := api.Group("/orders")
 		{
-			orders.GET("", controllers.GetOrders)
-			orders.GET("/:id", controllers.GetOrder)
-			orders.POST("", controllers.CreateOrder)
-			orders.PUT("/:id", controllers.UpdateOrder)
-			orders.DELETE("/:id", controllers.DeleteOrder)
-			orders.PUT("/:id/status", controllers.UpdateOrderStatus)
+			orders.GET("", orderHandler.GetOrders)
+			orders.GET("/summary", orderHandler.GetOrdersSummary)
+			orders.GET("/:id", orderHandler.GetOrder)
+			orders.POST("", orderHandler.CreateOrder)
+			orders.PUT("/:id", orderHandler.UpdateOrder)
+			orders.DELETE("/:id", orderHandler.DeleteOrder)
+			orders.PUT("/:id/status", orderHandler.UpdateOrderStatus)
+			orders.GET("/:id/messages", controllers.GetOrderMessages)
+			orders.POST("/:id/messages", controllers.SendOrderMessage)
+			orders.POST("/:id/messages/suggest", controllers.SuggestOrderReply)
+		}
+
+		// Saved order list views
+		views := api.Group("/views")
+		{
+			views.GET("", orderHandler.GetSavedViews)
+			views.POST("", orderHandler.CreateSavedView)
+			views.PUT("/:id", orderHandler.UpdateSavedView)
+			views.DELETE("/:id", orderHandler.DeleteSavedView)
+		}
+
+		// Outbound webhooks
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.GET("/subscriptions", controllers.GetWebhookSubscriptions)
+			webhooks.POST("/subscriptions", middleware.RequireRole("admin"), controllers.CreateWebhookSubscription)
+			webhooks.PUT("/subscriptions/:id", middleware.RequireRole("admin"), controllers.UpdateWebhookSubscription)
+			webhooks.DELETE("/subscriptions/:id", middleware.RequireRole("admin"), controllers.DeleteWebhookSubscription)
+			webhooks.GET("/subscriptions/:id/deliveries", controllers.GetWebhookDeliveries)
+			webhooks.GET("/deliveries/:id", controllers.GetWebhookDelivery)
+			webhooks.POST("/deliveries/:id/replay", middleware.RequireRole("admin"), controllers.ReplayWebhookDelivery)
+		}
+
+		// Draft orders from customer messages
+		drafts := api.Group("/drafts")
+		{
+			drafts.GET("", orderHandler.GetDrafts)
+			drafts.GET("/:id", orderHandler.GetDraft)
+			drafts.POST("/:id/convert", orderHandler.ConvertDraft)
+			drafts.POST("/:id/discard", orderHandler.DiscardDraft)
+			drafts.POST("/:id/measurement", orderHandler.AttachDraftMeasurement)
 		}
 
-		// File upload
+		// Phone calls
+		calls := api.Group("/calls")
+		{
+			calls.GET("", controllers.GetCalls)
+			calls.POST("", controllers.CreateCall)
+			calls.GET("/:id", controllers.GetCall)
+			calls.PUT("/:id", controllers.UpdateCall)
+			calls.POST("/:id/transcript", controllers.AddCallTranscript)
+			calls.POST("/:id/recording", controllers.UploadCallRecording)
+			calls.GET("/:id/recording", controllers.DownloadCallRecording)
+		}
+
+		// Marketplace connectors
+		api.GET("/marketplaces", controllers.GetMarketplaces)
+		api.POST("/marketplaces/:name/sync", controllers.SyncMarketplace)
+
+		// AI replies and their feedback, reply library, staff chat, translation,
+		// table measurement from photos, token usage, budgets and prompt templates
+		ai := api.Group("/ai")
+		{
+			ai.POST("/reply", controllers.GenerateReply)
+			ai.POST("/reply/stream", controllers.StreamReply)
+			ai.POST("/chat", controllers.Chat)
+			ai.POST("/chat/stream", controllers.StreamChat)
+			ai.GET("/chat/:session_id", controllers.GetChatSession)
+			ai.GET("/usage", budgetHandler.GetAIUsage)
+			ai.GET("/budgets", budgetHandler.GetAIBudgets)
+			ai.PUT("/budgets/:user_id", middleware.RequireRole("admin"), budgetHandler.SetAIBudget)
+			ai.DELETE("/budgets/:user_id", middleware.RequireRole("admin"), budgetHandler.DeleteAIBudget)
+			ai.GET("/responses", controllers.GetAIResponses)
+			ai.POST("/responses/:id/feedback", controllers.RecordAIFeedback)
+			ai.POST("/responses/:id/promote", controllers.PromoteAIResponse)
+			ai.GET("/library", controllers.GetReplyLibrary)
+			ai.POST("/library", controllers.CreateReplyLibraryEntry)
+			ai.PATCH("/library/:id", controllers.UpdateReplyLibraryEntry)
+			ai.DELETE("/library/:id", controllers.DeleteReplyLibraryEntry)
+			ai.GET("/languages", controllers.GetLanguages)
+			ai.POST("/detect-language", controllers.DetectLanguage)
+			ai.POST("/translate", controllers.Translate)
+			ai.GET("/measurements", controllers.GetMeasurements)
+			ai.POST("/measurements", controllers.EstimateTableSize)
+			ai.GET("/measurements/references", controllers.GetReferenceObjects)
+			ai.GET("/measurements/:id", controllers.GetMeasurement)
+			ai.GET("/prompts", controllers.GetPrompts)
+			ai.GET("/prompts/:id", controllers.GetPrompt)
+			ai.GET("/prompts/compare/:name", controllers.ComparePrompts)
+			ai.POST("/prompts/preview", controllers.PreviewPrompt)
+			ai.POST("/prompts", middleware.RequireRole("admin"), controllers.CreatePrompt)
+			ai.PATCH("/prompts/:id", middleware.RequireRole("admin"), controllers.UpdatePrompt)
+		}
+
+		// File upload and extraction
 		api.POST("/upload", controllers.UploadFiles)
+		api.POST("/ocr", controllers.ExtractOrderText)
 	}
 
 	// 404 handler
@@ -108,19 +264,14 @@ func main() {
 	})
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "7070"
-	}
-
-	log.Printf("🚀 Server starting on port %s", port)
+	log.Printf("🚀 Server starting on port %d", cfg.Server.Port)
 	log.Printf("📊 Database: Connected and verified")
 	log.Printf("🤖 AI Service: Initialized")
-	log.Printf("📁 Static files: ./uploads")
+	log.Printf("📁 Static files: %s", cfg.Uploads.Dir)
 	log.Printf("🌐 API endpoints: /api/v1")
 	log.Printf("⚙️  Schema management: Flyway")
 
-	if err := router.Run(":" + port); err != nil {
+	if err := router.Run(fmt.Sprintf(":%d", cfg.Server.Port)); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
@@ -147,6 +298,23 @@ func verifyRequiredTables() error {
 		"orders",
 		"order_images",
 		"ai_responses",
+		"saved_views",
+		"order_sla_alerts",
+		"webhook_subscriptions",
+		"webhook_deliveries",
+		"webhook_delivery_attempts",
+		"draft_orders",
+		"channel_messages",
+		"marketplace_sync_state",
+		"call_logs",
+		"ocr_cache",
+		"ai_usage",
+		"ai_budgets",
+		"conversation_sessions",
+		"conversation_messages",
+		"prompt_templates",
+		"reply_library",
+		"measurement_estimates",
 	}
 
 	for _, tableName := range requiredTables {
@@ -207,5 +375,5 @@ func runDatabaseDiagnostics() {
 		log.Println("✓ No duplicate order IDs found")
 	}
 
-	log.Println("=== DIAGNOSTICS COMPLETE ===\n")
+	log.Println("=== DIAGNOSTICS COMPLETE ===")
 }
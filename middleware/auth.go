@@ -3,6 +3,7 @@
 package middleware
 
 import (
+	"net/http"
 	"os"
 
 	"github.com/gin-gonic/gin"
@@ -52,3 +53,18 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// RequireRole lets only users with one of the given roles through
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
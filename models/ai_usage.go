@@ -0,0 +1,61 @@
+// models/ai_usage.go - LLM token usage, cost and per-user budgets
+package models
+
+import (
+	"time"
+)
+
+// AIUsage - the tokens and cost of one LLM call
+type AIUsage struct {
+	ID               uint      `json:"id" gorm:"primaryKey;column:id"`
+	Feature          string    `json:"feature" gorm:"column:feature"`
+	Model            string    `json:"model" gorm:"column:model"`
+	RequestedModel   string    `json:"requested_model" gorm:"column:requested_model"` // differs from model when a budget downgraded the call
+	UserID           *uint     `json:"user_id" gorm:"column:user_id"`
+	OrderID          *uint     `json:"order_id" gorm:"column:order_id"`
+	PromptTokens     int       `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" gorm:"column:completion_tokens"`
+	TotalTokens      int       `json:"total_tokens" gorm:"column:total_tokens"`
+	CostUSD          float64   `json:"cost_usd" gorm:"column:cost_usd;type:decimal(12,6)"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// AIBudget - a user's monthly AI spend limit and what happens once it is reached
+type AIBudget struct {
+	UserID          uint      `json:"user_id" gorm:"primaryKey;column:user_id;autoIncrement:false"`
+	MonthlyLimitUSD float64   `json:"monthly_limit_usd" gorm:"column:monthly_limit_usd;type:decimal(10,2)"`
+	Action          string    `json:"action" gorm:"column:action"`
+	DowngradeModel  string    `json:"downgrade_model" gorm:"column:downgrade_model"`
+	CreatedAt       time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// AIUsageFilter narrows the usage report; nil and empty fields match everything
+type AIUsageFilter struct {
+	UserID  *uint
+	OrderID *uint
+	Feature string
+}
+
+// AIUsageRow is one period / feature / model aggregate
+type AIUsageRow struct {
+	Period           time.Time `json:"period"`
+	Feature          string    `json:"feature"`
+	Model            string    `json:"model"`
+	Calls            int       `json:"calls"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+}
+
+// AIBudgetActions lists the valid ai_budgets.action values
+var AIBudgetActions = []string{"block", "downgrade"}
+
+func (AIUsage) TableName() string {
+	return "ai_usage"
+}
+
+func (AIBudget) TableName() string {
+	return "ai_budgets"
+}
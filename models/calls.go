@@ -0,0 +1,33 @@
+// models/calls.go - Phone call log for call-sourced orders
+package models
+
+import (
+	"time"
+)
+
+// CallLog - a phone call with a customer, identified by phone number like channel messages
+type CallLog struct {
+	ID               uint      `json:"id" gorm:"primaryKey;column:id"`
+	PhoneNumber      string    `json:"phone_number" gorm:"column:phone_number"`
+	CustomerName     string    `json:"customer_name" gorm:"column:customer_name"`
+	Direction        string    `json:"direction" gorm:"column:direction"`
+	DurationSeconds  int       `json:"duration_seconds" gorm:"column:duration_seconds"`
+	StartedAt        time.Time `json:"started_at" gorm:"column:started_at"`
+	Notes            string    `json:"notes" gorm:"column:notes;type:text"`
+	RecordingFile    string    `json:"recording_file" gorm:"column:recording_file"`
+	Transcript       string    `json:"transcript" gorm:"column:transcript;type:text"`
+	TranscriptSource string    `json:"transcript_source" gorm:"column:transcript_source"`
+	Extraction       JSONRaw   `json:"extraction" gorm:"column:extraction;type:jsonb"`
+	OrderID          *uint     `json:"order_id" gorm:"column:order_id"`
+	DraftOrderID     *uint     `json:"draft_order_id" gorm:"column:draft_order_id"`
+	CreatedBy        uint      `json:"created_by" gorm:"column:created_by"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// CallDirections lists the valid call_logs.direction values
+var CallDirections = []string{"inbound", "outbound"}
+
+func (CallLog) TableName() string {
+	return "call_logs"
+}
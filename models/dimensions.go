@@ -0,0 +1,15 @@
+package models
+
+// DimensionConversions shows one length in every supported unit
+type DimensionConversions struct {
+	Inches      float64 `json:"in"`
+	Centimeters float64 `json:"cm"`
+	Millimeters float64 `json:"mm"`
+	Feet        float64 `json:"ft"`
+}
+
+// OrderConversions are the conversions of an order's length and width
+type OrderConversions struct {
+	Length DimensionConversions `json:"length"`
+	Width  DimensionConversions `json:"width"`
+}
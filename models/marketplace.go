@@ -0,0 +1,23 @@
+// models/marketplace.go - Marketplace connector sync bookkeeping
+package models
+
+import (
+	"time"
+)
+
+// MarketplaceSyncState - where each marketplace connector left off
+type MarketplaceSyncState struct {
+	Connector     string     `json:"connector" gorm:"primaryKey;column:connector"`
+	Cursor        time.Time  `json:"cursor" gorm:"column:cursor"`
+	LastRunAt     *time.Time `json:"last_run_at" gorm:"column:last_run_at"`
+	LastSuccessAt *time.Time `json:"last_success_at" gorm:"column:last_success_at"`
+	LastError     string     `json:"last_error" gorm:"column:last_error"`
+	OrdersCreated int        `json:"orders_created" gorm:"column:orders_created"`
+	OrdersUpdated int        `json:"orders_updated" gorm:"column:orders_updated"`
+	DraftsCreated int        `json:"drafts_created" gorm:"column:drafts_created"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (MarketplaceSyncState) TableName() string {
+	return "marketplace_sync_state"
+}
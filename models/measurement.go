@@ -0,0 +1,76 @@
+// models/measurement.go - Table sizes estimated from photos with a reference object
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// ImagePoint is a position in a photo as fractions of its width (x, from
+// the left) and height (y, from the top), so it does not depend on the
+// resolution the photo was marked at
+type ImagePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ImageQuad is the four corners of a rectangular object as seen in a photo
+type ImageQuad struct {
+	TopLeft     ImagePoint `json:"top_left"`
+	TopRight    ImagePoint `json:"top_right"`
+	BottomRight ImagePoint `json:"bottom_right"`
+	BottomLeft  ImagePoint `json:"bottom_left"`
+}
+
+// Corners returns the corners in order around the quad, starting top left
+func (q ImageQuad) Corners() [4]ImagePoint {
+	return [4]ImagePoint{q.TopLeft, q.TopRight, q.BottomRight, q.BottomLeft}
+}
+
+func (q ImageQuad) Value() (driver.Value, error) {
+	data, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (q *ImageQuad) Scan(value interface{}) error {
+	data, err := jsonBytes(value)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		*q = ImageQuad{}
+		return nil
+	}
+	return json.Unmarshal(data, q)
+}
+
+// MeasurementEstimate - a table size read from a photo, with the range it
+// is likely to lie in
+type MeasurementEstimate struct {
+	ID               uint       `json:"id" gorm:"primaryKey;column:id"`
+	ImageFile        string     `json:"image_file" gorm:"column:image_file"`
+	Reference        string     `json:"reference" gorm:"column:reference"` // code of the reference object, e.g. a4
+	ReferenceCorners ImageQuad  `json:"reference_corners" gorm:"column:reference_corners;type:jsonb"`
+	TableCorners     ImageQuad  `json:"table_corners" gorm:"column:table_corners;type:jsonb"`
+	ReferenceMarked  bool       `json:"reference_marked" gorm:"column:reference_marked"` // marked by staff rather than detected
+	TableMarked      bool       `json:"table_marked" gorm:"column:table_marked"`
+	Length           float64    `json:"length" gorm:"column:length;type:decimal(10,2)"` // inches
+	Width            float64    `json:"width" gorm:"column:width;type:decimal(10,2)"`
+	LengthMin        float64    `json:"length_min" gorm:"column:length_min;type:decimal(10,2)"`
+	LengthMax        float64    `json:"length_max" gorm:"column:length_max;type:decimal(10,2)"`
+	WidthMin         float64    `json:"width_min" gorm:"column:width_min;type:decimal(10,2)"`
+	WidthMax         float64    `json:"width_max" gorm:"column:width_max;type:decimal(10,2)"`
+	Warnings         StringList `json:"warnings" gorm:"column:warnings;type:jsonb"`
+	Model            string     `json:"model" gorm:"column:model"` // empty when both objects were marked
+	DraftOrderID     *uint      `json:"draft_order_id" gorm:"column:draft_order_id"`
+	CreatedBy        *uint      `json:"created_by" gorm:"column:created_by"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+func (MeasurementEstimate) TableName() string {
+	return "measurement_estimates"
+}
@@ -0,0 +1,55 @@
+// models/messages.go - Customer channel messages and draft orders built from them
+package models
+
+import (
+	"time"
+)
+
+// ChannelMessage - an inbound or outbound WhatsApp/SMS message
+type ChannelMessage struct {
+	ID           uint       `json:"id" gorm:"primaryKey;column:id"`
+	Channel      string     `json:"channel" gorm:"column:channel"`
+	Direction    string     `json:"direction" gorm:"column:direction"`
+	ExternalID   string     `json:"external_id" gorm:"column:external_id"`
+	PhoneNumber  string     `json:"phone_number" gorm:"column:phone_number"`
+	CustomerName string     `json:"customer_name" gorm:"column:customer_name"`
+	Body         string     `json:"body" gorm:"column:body;type:text"`
+	Language     string     `json:"language" gorm:"column:language"` // detected from the body
+	MediaFiles   StringList `json:"media_files" gorm:"column:media_files;type:jsonb"`
+	OrderID      *uint      `json:"order_id" gorm:"column:order_id"`
+	DraftOrderID *uint      `json:"draft_order_id" gorm:"column:draft_order_id"`
+	Status       string     `json:"status" gorm:"column:status"`
+	Error        string     `json:"error,omitempty" gorm:"column:error"`
+	RawPayload   JSONRaw    `json:"-" gorm:"column:raw_payload;type:jsonb"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// DraftOrder - an order proposal built from a customer message, waiting for staff review.
+// Dimensions may be missing, which is why drafts live outside the orders table.
+type DraftOrder struct {
+	ID             uint       `json:"id" gorm:"primaryKey;column:id"`
+	Source         string     `json:"source" gorm:"column:source"`
+	ExternalID     string     `json:"external_id,omitempty" gorm:"column:external_id"`
+	PhoneNumber    string     `json:"phone_number" gorm:"column:phone_number"`
+	CustomerName   string     `json:"customer_name" gorm:"column:customer_name"`
+	Length         *float64   `json:"length" gorm:"column:length;type:decimal(10,2)"`
+	Width          *float64   `json:"width" gorm:"column:width;type:decimal(10,2)"`
+	Thickness      string     `json:"thickness" gorm:"column:thickness"`
+	CornerStyle    string     `json:"corner_style" gorm:"column:corner_style"`
+	Notes          string     `json:"notes" gorm:"column:notes;type:text"`
+	ExtractedText  string     `json:"extracted_text" gorm:"column:extracted_text;type:text"`
+	SuggestedReply string     `json:"suggested_reply" gorm:"column:suggested_reply;type:text"`
+	ImageFiles     StringList `json:"image_files" gorm:"column:image_files;type:jsonb"`
+	Status         string     `json:"status" gorm:"column:status"`
+	OrderID        *uint      `json:"order_id" gorm:"column:order_id"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (ChannelMessage) TableName() string {
+	return "channel_messages"
+}
+
+func (DraftOrder) TableName() string {
+	return "draft_orders"
+}
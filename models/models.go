@@ -5,6 +5,17 @@ import (
 	"time"
 )
 
+// Allowed values - mirror the CHECK constraints in the Flyway migrations
+var (
+	OrderSources      = []string{"amazon", "whatsapp", "sms", "call"}
+	OrderStatuses     = []string{"new", "in-progress", "done"}
+	OrderThicknesses  = []string{"2mm", "3mm", "5mm", "8mm"}
+	OrderCornerStyles = []string{"sharp", "rounded", "custom"}
+
+	// Statuses an order's SLA still applies to
+	OpenOrderStatuses = []string{"new", "in-progress"}
+)
+
 // User model - matches your Flyway migration
 type User struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -25,6 +36,9 @@ type Order struct {
 	PhoneNumber   string        `json:"phone_number" gorm:"column:phone_number"`
 	Length        float64       `json:"length" gorm:"column:length;type:decimal(10,2)"`
 	Width         float64       `json:"width" gorm:"column:width;type:decimal(10,2)"`
+	LengthInput   string        `json:"length_input" gorm:"column:length_input"`
+	WidthInput    string        `json:"width_input" gorm:"column:width_input"`
+	InputUnit     string        `json:"input_unit" gorm:"column:input_unit"`
 	Thickness     string        `json:"thickness" gorm:"column:thickness"`
 	CornerStyle   string        `json:"corner_style" gorm:"column:corner_style"`
 	Notes         string        `json:"notes" gorm:"column:notes;type:text"`
@@ -32,8 +46,17 @@ type Order struct {
 	Status        string        `json:"status" gorm:"column:status"`
 	Images        []OrderImage  `json:"images" gorm:"foreignKey:OrderID"`
 	CreatedBy     uint          `json:"created_by" gorm:"column:created_by"`
+	PromisedBy    *time.Time    `json:"promised_by" gorm:"column:promised_by;type:date"`
+	DueAt         *time.Time    `json:"due_at" gorm:"column:due_at"`
 	CreatedAt     time.Time     `json:"created_at" gorm:"column:created_at"`
 	UpdatedAt     time.Time     `json:"updated_at" gorm:"column:updated_at"`
+
+	// Computed from due_at and status, not stored
+	IsOverdue bool `json:"is_overdue" gorm:"-"`
+	IsAtRisk  bool `json:"is_at_risk" gorm:"-"`
+
+	// Computed from length and width, not stored
+	Conversions *OrderConversions `json:"conversions,omitempty" gorm:"-"`
 }
 
 // OrderImage model - matches your Flyway schema
@@ -47,15 +70,38 @@ type OrderImage struct {
 	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
 }
 
+// OrderSLAAlert records that an SLA alert was sent, so it is only sent once
+type OrderSLAAlert struct {
+	ID        uint      `json:"id" gorm:"primaryKey;column:id"`
+	OrderID   uint      `json:"order_id" gorm:"column:order_id"`
+	Kind      string    `json:"kind" gorm:"column:kind"`
+	DueAt     time.Time `json:"due_at" gorm:"column:due_at"`
+	Channel   string    `json:"channel" gorm:"column:channel"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
 // AIResponse model - matches your Flyway schema
 type AIResponse struct {
-	ID           uint      `json:"id" gorm:"primaryKey;column:id"`
-	UserID       uint      `json:"user_id" gorm:"column:user_id"`
-	InputMessage string    `json:"input_message" gorm:"column:input_message;type:text"`
-	Response     string    `json:"response" gorm:"column:response;type:text"`
-	Tone         string    `json:"tone" gorm:"column:tone"`
-	HasImages    bool      `json:"has_images" gorm:"column:has_images"`
-	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+	ID             uint           `json:"id" gorm:"primaryKey;column:id"`
+	UserID         uint           `json:"user_id" gorm:"column:user_id"`
+	InputMessage   string         `json:"input_message" gorm:"column:input_message;type:text"`
+	Response       string         `json:"response" gorm:"column:response;type:text"`
+	Tone           string         `json:"tone" gorm:"column:tone"`
+	HasImages      bool           `json:"has_images" gorm:"column:has_images"`
+	Feature        string         `json:"feature" gorm:"column:feature"`
+	Model          string         `json:"model" gorm:"column:model"`
+	SessionID      *string        `json:"session_id" gorm:"column:session_id"`
+	OrderID        *uint          `json:"order_id" gorm:"column:order_id"`
+	Status         string         `json:"status" gorm:"column:status"`
+	PromptVersions PromptVersions `json:"prompt_versions" gorm:"column:prompt_versions;type:jsonb"`
+	Language       string         `json:"language" gorm:"column:language"` // detected in the input message
+	ReplyLanguage  string         `json:"reply_language" gorm:"column:reply_language"`
+	FinalText      *string        `json:"final_text" gorm:"column:final_text;type:text"` // what staff actually sent
+	Rating         *int           `json:"rating" gorm:"column:rating"`                   // 1 up, -1 down
+	EditDistance   *int           `json:"edit_distance" gorm:"column:edit_distance"`
+	FeedbackBy     *uint          `json:"feedback_by" gorm:"column:feedback_by"`
+	FeedbackAt     *time.Time     `json:"feedback_at" gorm:"column:feedback_at"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"column:created_at"`
 }
 
 // Conversation models for AI memory
@@ -76,6 +122,18 @@ type ConversationMessage struct {
 	Content    string    `json:"content" gorm:"column:content;type:text"`
 	Timestamp  time.Time `json:"timestamp" gorm:"column:timestamp"`
 	TokenCount int       `json:"token_count" gorm:"column:token_count"`
+	Language   string    `json:"language" gorm:"column:language"`
+}
+
+// SavedView - a named GetOrders filter set stored per user
+type SavedView struct {
+	ID        uint      `json:"id" gorm:"primaryKey;column:id"`
+	UserID    uint      `json:"user_id" gorm:"column:user_id"`
+	Name      string    `json:"name" gorm:"column:name"`
+	Filters   JSONMap   `json:"filters" gorm:"column:filters;type:jsonb"`
+	Sort      string    `json:"sort" gorm:"column:sort"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
 }
 
 // Table name methods to ensure GORM uses correct table names
@@ -91,6 +149,10 @@ func (OrderImage) TableName() string {
 	return "order_images"
 }
 
+func (OrderSLAAlert) TableName() string {
+	return "order_sla_alerts"
+}
+
 func (AIResponse) TableName() string {
 	return "ai_responses"
 }
@@ -101,4 +163,8 @@ func (ConversationSession) TableName() string {
 
 func (ConversationMessage) TableName() string {
 	return "conversation_messages"
-}
\ No newline at end of file
+}
+
+func (SavedView) TableName() string {
+	return "saved_views"
+}
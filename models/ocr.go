@@ -0,0 +1,21 @@
+// models/ocr.go - Cached OCR results
+package models
+
+import (
+	"time"
+)
+
+// OCRCacheEntry - the text read from one image, keyed by the SHA-256 of its
+// bytes and the model that read it
+type OCRCacheEntry struct {
+	ContentHash string    `json:"content_hash" gorm:"primaryKey;column:content_hash"`
+	Model       string    `json:"model" gorm:"primaryKey;column:model"`
+	Text        string    `json:"text" gorm:"column:text;type:text"`
+	Hits        int       `json:"hits" gorm:"column:hits"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	LastUsedAt  time.Time `json:"last_used_at" gorm:"column:last_used_at"`
+}
+
+func (OCRCacheEntry) TableName() string {
+	return "ocr_cache"
+}
@@ -0,0 +1,24 @@
+// models/prompts.go - Versioned AI prompt templates
+package models
+
+import (
+	"time"
+)
+
+// PromptTemplate - one version of a named prompt, a Go text/template
+type PromptTemplate struct {
+	ID          uint      `json:"id" gorm:"primaryKey;column:id"`
+	Name        string    `json:"name" gorm:"column:name"`
+	Version     int       `json:"version" gorm:"column:version"`
+	Body        string    `json:"body" gorm:"column:body;type:text"`
+	Description string    `json:"description" gorm:"column:description"`
+	Active      bool      `json:"active" gorm:"column:active"`
+	Weight      int       `json:"weight" gorm:"column:weight"` // share of calls among the active versions of the name
+	CreatedBy   *uint     `json:"created_by" gorm:"column:created_by"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
@@ -0,0 +1,27 @@
+// models/reply_library.go - Canned customer replies, also used as few-shot examples
+package models
+
+import (
+	"time"
+)
+
+// ReplyLibraryEntry - a reply staff found good enough to reuse
+type ReplyLibraryEntry struct {
+	ID               uint       `json:"id" gorm:"primaryKey;column:id"`
+	Title            string     `json:"title" gorm:"column:title"`
+	InputMessage     string     `json:"input_message" gorm:"column:input_message;type:text"` // the customer message it answers
+	Body             string     `json:"body" gorm:"column:body;type:text"`
+	Tone             string     `json:"tone" gorm:"column:tone"`
+	Language         string     `json:"language" gorm:"column:language"`
+	Tags             StringList `json:"tags" gorm:"column:tags;type:jsonb"`
+	SourceResponseID *uint      `json:"source_response_id" gorm:"column:source_response_id"`
+	Active           bool       `json:"active" gorm:"column:active"`
+	UseCount         int        `json:"use_count" gorm:"column:use_count"`
+	CreatedBy        *uint      `json:"created_by" gorm:"column:created_by"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (ReplyLibraryEntry) TableName() string {
+	return "reply_library"
+}
@@ -0,0 +1,140 @@
+// models/types.go - Column types stored as JSONB
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONMap is a string map stored in a JSONB column
+type JSONMap map[string]string
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *JSONMap) Scan(value interface{}) error {
+	data, err := jsonBytes(value)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		*m = JSONMap{}
+		return nil
+	}
+	return json.Unmarshal(data, m)
+}
+
+func jsonBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported JSON column type %T", value)
+	}
+}
+
+// StringList is a list of strings stored in a JSONB column
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	data, err := jsonBytes(value)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		*l = StringList{}
+		return nil
+	}
+	return json.Unmarshal(data, l)
+}
+
+// Contains reports whether the list holds the given value
+func (l StringList) Contains(value string) bool {
+	for _, v := range l {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONRaw is an arbitrary JSON document stored in a JSONB column
+type JSONRaw json.RawMessage
+
+func (r JSONRaw) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return "null", nil
+	}
+	return string(r), nil
+}
+
+func (r *JSONRaw) Scan(value interface{}) error {
+	data, err := jsonBytes(value)
+	if err != nil {
+		return err
+	}
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+func (r JSONRaw) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+func (r *JSONRaw) UnmarshalJSON(data []byte) error {
+	*r = append((*r)[:0], data...)
+	return nil
+}
+
+// PromptVersions maps prompt template names to the version used, stored in a JSONB column
+type PromptVersions map[string]int
+
+func (v PromptVersions) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (v *PromptVersions) Scan(value interface{}) error {
+	data, err := jsonBytes(value)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		*v = PromptVersions{}
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,64 @@
+// models/validation.go - Order field rules shared by the API and AI extraction
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a single failed rule on an order field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// OrderFields are the order values the CHECK constraints cover
+type OrderFields struct {
+	Source      string
+	Length      float64
+	Width       float64
+	Thickness   string
+	CornerStyle string
+}
+
+// ValidateOrderFields applies the order table rules: known source, thickness
+// and corner style, and positive dimensions
+func ValidateOrderFields(f OrderFields) []FieldError {
+	var errs []FieldError
+
+	if !containsValue(OrderSources, f.Source) {
+		errs = append(errs, FieldError{"source", "Invalid source"})
+	}
+	if f.Length <= 0 {
+		errs = append(errs, FieldError{"length", "Length must be greater than 0"})
+	}
+	if f.Width <= 0 {
+		errs = append(errs, FieldError{"width", "Width must be greater than 0"})
+	}
+	if !containsValue(OrderThicknesses, f.Thickness) {
+		errs = append(errs, FieldError{"thickness", "Invalid thickness"})
+	}
+	if !containsValue(OrderCornerStyles, f.CornerStyle) {
+		errs = append(errs, FieldError{"corner_style", "Invalid corner style"})
+	}
+	return errs
+}
+
+// DescribeOrderRules spells the rules out, for prompts and error responses
+func DescribeOrderRules() string {
+	return fmt.Sprintf("source is one of %s; thickness is one of %s; corner_style is one of %s; length and width are positive numbers in inches",
+		strings.Join(OrderSources, ", "), strings.Join(OrderThicknesses, ", "), strings.Join(OrderCornerStyles, ", "))
+}
+
+func containsValue(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
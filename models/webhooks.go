@@ -0,0 +1,64 @@
+// models/webhooks.go - Outbound webhook subscriptions and delivery log
+package models
+
+import (
+	"time"
+)
+
+// WebhookSubscription - an external endpoint that receives order events
+type WebhookSubscription struct {
+	ID          uint       `json:"id" gorm:"primaryKey;column:id"`
+	URL         string     `json:"url" gorm:"column:url"`
+	Events      StringList `json:"events" gorm:"column:events;type:jsonb"`
+	Secret      string     `json:"-" gorm:"column:secret"`
+	Description string     `json:"description" gorm:"column:description"`
+	Active      bool       `json:"active" gorm:"column:active"`
+	CreatedBy   uint       `json:"created_by" gorm:"column:created_by"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// WebhookDelivery - one event queued for one subscription. Pending rows are the
+// persistent retry queue; finished rows are the delivery log.
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey;column:id"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"column:subscription_id"`
+	EventID        string     `json:"event_id" gorm:"column:event_id"`
+	EventType      string     `json:"event_type" gorm:"column:event_type"`
+	Payload        JSONRaw    `json:"payload" gorm:"column:payload;type:jsonb"`
+	Status         string     `json:"status" gorm:"column:status"`
+	Attempts       int        `json:"attempts" gorm:"column:attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"column:next_attempt_at"`
+	LastError      string     `json:"last_error" gorm:"column:last_error"`
+	ResponseStatus int        `json:"response_status" gorm:"column:response_status"`
+	DeliveredAt    *time.Time `json:"delivered_at" gorm:"column:delivered_at"`
+	ReplayOf       *uint      `json:"replay_of" gorm:"column:replay_of"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"column:updated_at"`
+
+	AttemptLog []WebhookDeliveryAttempt `json:"attempt_log,omitempty" gorm:"foreignKey:DeliveryID"`
+}
+
+// WebhookDeliveryAttempt - a single HTTP attempt for a delivery
+type WebhookDeliveryAttempt struct {
+	ID             uint      `json:"id" gorm:"primaryKey;column:id"`
+	DeliveryID     uint      `json:"delivery_id" gorm:"column:delivery_id"`
+	AttemptNumber  int       `json:"attempt_number" gorm:"column:attempt_number"`
+	ResponseStatus int       `json:"response_status" gorm:"column:response_status"`
+	ResponseBody   string    `json:"response_body" gorm:"column:response_body"`
+	Error          string    `json:"error" gorm:"column:error"`
+	DurationMs     int64     `json:"duration_ms" gorm:"column:duration_ms"`
+	CreatedAt      time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+func (WebhookDeliveryAttempt) TableName() string {
+	return "webhook_delivery_attempts"
+}
@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// Columns CountBy can group orders by
+var orderCountColumns = map[string]bool{"status": true, "source": true, "thickness": true}
+
+type gormOrderRepository struct {
+	db    *gorm.DB
+	reads *gorm.DB // List, Count and CountBy, which may lag behind db
+}
+
+// NewGormOrderRepository stores orders in Postgres through db. The order
+// list and its counts are read through reads, which can be a read replica;
+// pass db again to read everything from the primary.
+func NewGormOrderRepository(db, reads *gorm.DB) OrderRepository {
+	return &gormOrderRepository{db: db, reads: reads}
+}
+
+func (r *gormOrderRepository) Get(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&order).Error
+	return order, notFound(err)
+}
+
+func (r *gormOrderRepository) FindByOrderID(ctx context.Context, orderID string) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).First(&order).Error
+	return order, notFound(err)
+}
+
+func (r *gormOrderRepository) List(ctx context.Context, q OrderQuery) ([]models.Order, error) {
+	query := q.Filter.apply(r.reads.WithContext(ctx).Table("orders"))
+	if q.Cursor != nil {
+		// Served by idx_orders_created_at_id
+		query = query.Where("(created_at, id) < (?, ?)", q.Cursor.CreatedAt, q.Cursor.ID)
+	} else if q.Offset > 0 {
+		query = query.Offset(q.Offset)
+	}
+
+	var orders []models.Order
+	err := query.Order(q.Filter.orderBy()).Limit(q.Limit).Find(&orders).Error
+	return orders, err
+}
+
+func (r *gormOrderRepository) Count(ctx context.Context, filter OrderFilter) (int64, error) {
+	var total int64
+	err := filter.apply(r.reads.WithContext(ctx).Table("orders")).Count(&total).Error
+	return total, err
+}
+
+func (r *gormOrderRepository) CountBy(ctx context.Context, filter OrderFilter, column string) (map[string]int64, error) {
+	if !orderCountColumns[column] {
+		return nil, errors.New("cannot count orders by " + column)
+	}
+	var rows []struct {
+		Key   string
+		Count int64
+	}
+	err := filter.apply(r.reads.WithContext(ctx).Table("orders")).
+		Select(column + " AS key, COUNT(*) AS count").
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Key] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *gormOrderRepository) Create(ctx context.Context, order *models.Order, images []models.OrderImage, outbox Outbox) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			if isDuplicate(err) {
+				return ErrDuplicate
+			}
+			return err
+		}
+		for _, image := range images {
+			image.OrderID = order.ID
+			if err := tx.Create(&image).Error; err != nil {
+				return err
+			}
+		}
+
+		// Reload for the columns the database fills in
+		if err := tx.Where("id = ?", order.ID).First(order).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", order.ID).Order("id").Find(&order.Images).Error; err != nil {
+			return err
+		}
+		return writeOutbox(tx, *order, outbox)
+	})
+}
+
+func (r *gormOrderRepository) Update(ctx context.Context, order *models.Order, update OrderUpdate) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if update.ClearSLAAlerts {
+			if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderSLAAlert{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Images").Save(order).Error; err != nil {
+			if isDuplicate(err) {
+				return ErrDuplicate
+			}
+			return err
+		}
+
+		if update.Images != nil {
+			if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderImage{}).Error; err != nil {
+				return err
+			}
+			for _, image := range update.Images {
+				image.OrderID = order.ID
+				if err := tx.Create(&image).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("order_id = ?", order.ID).Order("id").Find(&order.Images).Error; err != nil {
+				return err
+			}
+		}
+		return writeOutbox(tx, *order, update.Outbox)
+	})
+}
+
+func (r *gormOrderRepository) Delete(ctx context.Context, order models.Order, outbox Outbox) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Images first (foreign key constraint)
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Order{}, order.ID).Error; err != nil {
+			return err
+		}
+		return writeOutbox(tx, order, outbox)
+	})
+}
+
+// writeOutbox stores the outbox's deliveries for order through tx
+func writeOutbox(tx *gorm.DB, order models.Order, outbox Outbox) error {
+	if outbox == nil {
+		return nil
+	}
+	var subscriptions []models.WebhookSubscription
+	if err := tx.Where("active = ?", true).Order("id").Find(&subscriptions).Error; err != nil {
+		return err
+	}
+	deliveries, err := outbox(order, subscriptions)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+	return tx.Create(&deliveries).Error
+}
+
+type gormImageRepository struct {
+	db *gorm.DB
+}
+
+// NewGormImageRepository reads order images from Postgres through db
+func NewGormImageRepository(db *gorm.DB) ImageRepository {
+	return &gormImageRepository{db: db}
+}
+
+func (r *gormImageRepository) ListByOrder(ctx context.Context, orderID uint) ([]models.OrderImage, error) {
+	images := []models.OrderImage{}
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&images).Error
+	return images, err
+}
+
+func (r *gormImageRepository) ListByOrders(ctx context.Context, orderIDs []uint) (map[uint][]models.OrderImage, error) {
+	byOrder := make(map[uint][]models.OrderImage, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return byOrder, nil
+	}
+
+	var images []models.OrderImage
+	if err := r.db.WithContext(ctx).Where("order_id IN ?", orderIDs).Order("id").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		byOrder[image.OrderID] = append(byOrder[image.OrderID], image)
+	}
+	return byOrder, nil
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewGormUserRepository reads users from Postgres through db
+func NewGormUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, notFound(err)
+}
+
+type gormDraftRepository struct {
+	db *gorm.DB
+}
+
+// NewGormDraftRepository stores draft orders in Postgres through db
+func NewGormDraftRepository(db *gorm.DB) DraftRepository {
+	return &gormDraftRepository{db: db}
+}
+
+func (r *gormDraftRepository) Get(ctx context.Context, id uint) (models.DraftOrder, error) {
+	var draft models.DraftOrder
+	err := r.db.WithContext(ctx).First(&draft, id).Error
+	return draft, notFound(err)
+}
+
+func (r *gormDraftRepository) List(ctx context.Context, filter DraftFilter) ([]models.DraftOrder, error) {
+	query := r.db.WithContext(ctx).Order("updated_at DESC, id DESC")
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	drafts := []models.DraftOrder{}
+	err := query.Find(&drafts).Error
+	return drafts, err
+}
+
+func (r *gormDraftRepository) Messages(ctx context.Context, draftID uint) ([]models.ChannelMessage, error) {
+	messages := []models.ChannelMessage{}
+	err := r.db.WithContext(ctx).Where("draft_order_id = ?", draftID).Order("created_at, id").Find(&messages).Error
+	return messages, err
+}
+
+func (r *gormDraftRepository) Save(ctx context.Context, draft *models.DraftOrder) error {
+	return r.db.WithContext(ctx).Save(draft).Error
+}
+
+func (r *gormDraftRepository) SaveConverted(ctx context.Context, draft *models.DraftOrder) error {
+	if draft.OrderID == nil {
+		return errors.New("draft has no order")
+	}
+	orderID := *draft.OrderID
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(draft).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ChannelMessage{}).Where("draft_order_id = ?", draft.ID).
+			Update("order_id", orderID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CallLog{}).Where("draft_order_id = ? AND order_id IS NULL", draft.ID).
+			Update("order_id", orderID).Error
+	})
+}
+
+type gormSavedViewRepository struct {
+	db *gorm.DB
+}
+
+// NewGormSavedViewRepository stores saved views in Postgres through db
+func NewGormSavedViewRepository(db *gorm.DB) SavedViewRepository {
+	return &gormSavedViewRepository{db: db}
+}
+
+func (r *gormSavedViewRepository) List(ctx context.Context, userID uint) ([]models.SavedView, error) {
+	views := []models.SavedView{}
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name").Find(&views).Error
+	return views, err
+}
+
+func (r *gormSavedViewRepository) Get(ctx context.Context, id, userID uint) (models.SavedView, error) {
+	var view models.SavedView
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&view).Error
+	return view, notFound(err)
+}
+
+func (r *gormSavedViewRepository) Create(ctx context.Context, view *models.SavedView) error {
+	if err := r.db.WithContext(ctx).Create(view).Error; err != nil {
+		if isDuplicate(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *gormSavedViewRepository) Save(ctx context.Context, view *models.SavedView) error {
+	if err := r.db.WithContext(ctx).Save(view).Error; err != nil {
+		if isDuplicate(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *gormSavedViewRepository) Delete(ctx context.Context, view models.SavedView) error {
+	return r.db.WithContext(ctx).Delete(&view).Error
+}
+
+type gormAIBudgetRepository struct {
+	db *gorm.DB
+}
+
+// NewGormAIBudgetRepository stores AI budgets and reads AI usage in Postgres through db
+func NewGormAIBudgetRepository(db *gorm.DB) AIBudgetRepository {
+	return &gormAIBudgetRepository{db: db}
+}
+
+func (r *gormAIBudgetRepository) List(ctx context.Context, userID *uint) ([]models.AIBudget, error) {
+	query := r.db.WithContext(ctx).Order("user_id")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	budgets := []models.AIBudget{}
+	err := query.Find(&budgets).Error
+	return budgets, err
+}
+
+func (r *gormAIBudgetRepository) Save(ctx context.Context, budget *models.AIBudget) error {
+	return r.db.WithContext(ctx).Save(budget).Error
+}
+
+func (r *gormAIBudgetRepository) Delete(ctx context.Context, userID uint) error {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.AIBudget{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormAIBudgetRepository) Spent(ctx context.Context, userID uint, since time.Time) (float64, error) {
+	var spent float64
+	err := r.db.WithContext(ctx).Model(&models.AIUsage{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Select("COALESCE(SUM(cost_usd), 0)").
+		Scan(&spent).Error
+	return spent, err
+}
+
+func (r *gormAIBudgetRepository) Usage(ctx context.Context, filter models.AIUsageFilter, period string, since time.Time) ([]models.AIUsageRow, error) {
+	if period != "day" && period != "month" {
+		return nil, fmt.Errorf("invalid period %q", period)
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.AIUsage{}).Where("created_at >= ?", since)
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.OrderID != nil {
+		query = query.Where("order_id = ?", *filter.OrderID)
+	}
+	if filter.Feature != "" {
+		query = query.Where("feature = ?", filter.Feature)
+	}
+
+	rows := []models.AIUsageRow{}
+	err := query.
+		Select("date_trunc('" + period + "', created_at) AS period, feature, model, COUNT(*) AS calls, " +
+			"SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, " +
+			"SUM(total_tokens) AS total_tokens, SUM(cost_usd) AS cost_usd").
+		Group("1, feature, model").
+		Order("1 DESC, feature, model").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func isDuplicate(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "duplicate") || strings.Contains(msg, "unique")
+}
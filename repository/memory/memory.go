@@ -0,0 +1,912 @@
+// Package memory implements the repository interfaces in memory, for running
+// the handlers without Postgres. Filters and sorting follow the SQL in
+// package repository closely but not exactly: full-text search matches whole
+// words rather than going through websearch_to_tsquery.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"customflow/models"
+	"customflow/repository"
+)
+
+// Store holds the rows shared by the repositories built on it
+type Store struct {
+	mu          sync.RWMutex
+	orders      map[uint]models.Order
+	images      map[uint][]models.OrderImage // by order ID
+	users       map[uint]models.User
+	drafts      map[uint]models.DraftOrder
+	messages    []models.ChannelMessage
+	views       map[uint]models.SavedView
+	budgets     map[uint]models.AIBudget // by user ID
+	aiUsage     []models.AIUsage
+	webhooks    []models.WebhookSubscription
+	deliveries  []models.WebhookDelivery
+	nextOrderID uint
+	nextImageID uint
+	nextUserID  uint
+	nextDraftID uint
+	nextViewID  uint
+}
+
+// NewStore returns an empty store
+func NewStore() *Store {
+	return &Store{
+		orders:      map[uint]models.Order{},
+		images:      map[uint][]models.OrderImage{},
+		users:       map[uint]models.User{},
+		drafts:      map[uint]models.DraftOrder{},
+		views:       map[uint]models.SavedView{},
+		budgets:     map[uint]models.AIBudget{},
+		nextOrderID: 1,
+		nextImageID: 1,
+		nextUserID:  1,
+		nextDraftID: 1,
+		nextViewID:  1,
+	}
+}
+
+// AddUser stores a user, assigning an ID when it has none
+func (s *Store) AddUser(user models.User) models.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID == 0 {
+		user.ID = s.nextUserID
+	}
+	if user.ID >= s.nextUserID {
+		s.nextUserID = user.ID + 1
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = user.CreatedAt
+	}
+	s.users[user.ID] = user
+	return user
+}
+
+// AddDraft stores a draft order, assigning an ID when it has none
+func (s *Store) AddDraft(draft models.DraftOrder) models.DraftOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if draft.ID == 0 {
+		draft.ID = s.nextDraftID
+	}
+	if draft.ID >= s.nextDraftID {
+		s.nextDraftID = draft.ID + 1
+	}
+	if draft.CreatedAt.IsZero() {
+		draft.CreatedAt = time.Now()
+		draft.UpdatedAt = draft.CreatedAt
+	}
+	s.drafts[draft.ID] = draft
+	return draft
+}
+
+// AddMessage stores an inbound or outbound message
+func (s *Store) AddMessage(message models.ChannelMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if message.ID == 0 {
+		message.ID = uint(len(s.messages) + 1)
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	s.messages = append(s.messages, message)
+}
+
+// AddAIUsage records an AI call, counting towards its user's spend
+func (s *Store) AddAIUsage(usage models.AIUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	s.aiUsage = append(s.aiUsage, usage)
+}
+
+// AddWebhookSubscription stores a webhook subscription, assigning an ID when it has none
+func (s *Store) AddWebhookSubscription(sub models.WebhookSubscription) models.WebhookSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub.ID == 0 {
+		sub.ID = uint(len(s.webhooks) + 1)
+	}
+	s.webhooks = append(s.webhooks, sub)
+	return sub
+}
+
+// WebhookDeliveries returns the deliveries queued by order changes, oldest first
+func (s *Store) WebhookDeliveries() []models.WebhookDelivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.WebhookDelivery{}, s.deliveries...)
+}
+
+// writeOutbox queues the outbox's deliveries for order. The caller holds the
+// write lock and undoes the change when it fails.
+func (s *Store) writeOutbox(order models.Order, outbox repository.Outbox) error {
+	if outbox == nil {
+		return nil
+	}
+	var active []models.WebhookSubscription
+	for _, sub := range s.webhooks {
+		if sub.Active {
+			active = append(active, sub)
+		}
+	}
+	deliveries, err := outbox(order, active)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		delivery.ID = uint(len(s.deliveries) + 1)
+		delivery.CreatedAt = time.Now()
+		s.deliveries = append(s.deliveries, delivery)
+	}
+	return nil
+}
+
+type orderRepository struct {
+	store *Store
+}
+
+// NewOrderRepository keeps orders and their images in store
+func NewOrderRepository(store *Store) repository.OrderRepository {
+	return &orderRepository{store: store}
+}
+
+func (r *orderRepository) Get(ctx context.Context, id uint) (models.Order, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	order, ok := r.store.orders[id]
+	if !ok {
+		return models.Order{}, repository.ErrNotFound
+	}
+	return order, nil
+}
+
+func (r *orderRepository) FindByOrderID(ctx context.Context, orderID string) (models.Order, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, order := range r.store.orders {
+		if order.OrderID == orderID {
+			return order, nil
+		}
+	}
+	return models.Order{}, repository.ErrNotFound
+}
+
+func (r *orderRepository) List(ctx context.Context, q repository.OrderQuery) ([]models.Order, error) {
+	r.store.mu.RLock()
+	orders := r.store.filter(q.Filter, time.Now())
+	r.store.mu.RUnlock()
+
+	sortOrders(orders, q.Filter.Sort)
+
+	if q.Cursor != nil {
+		start := len(orders)
+		for i, order := range orders {
+			if order.CreatedAt.Before(q.Cursor.CreatedAt) ||
+				(order.CreatedAt.Equal(q.Cursor.CreatedAt) && order.ID < q.Cursor.ID) {
+				start = i
+				break
+			}
+		}
+		orders = orders[start:]
+	} else if q.Offset > 0 {
+		orders = orders[min(q.Offset, len(orders)):]
+	}
+
+	if q.Limit > 0 && len(orders) > q.Limit {
+		orders = orders[:q.Limit]
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) Count(ctx context.Context, filter repository.OrderFilter) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return int64(len(r.store.filter(filter, time.Now()))), nil
+}
+
+func (r *orderRepository) CountBy(ctx context.Context, filter repository.OrderFilter, column string) (map[string]int64, error) {
+	var key func(models.Order) string
+	switch column {
+	case "status":
+		key = func(o models.Order) string { return o.Status }
+	case "source":
+		key = func(o models.Order) string { return o.Source }
+	case "thickness":
+		key = func(o models.Order) string { return o.Thickness }
+	default:
+		return nil, fmt.Errorf("cannot count orders by %s", column)
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	counts := map[string]int64{}
+	for _, order := range r.store.filter(filter, time.Now()) {
+		counts[key(order)]++
+	}
+	return counts, nil
+}
+
+func (r *orderRepository) Create(ctx context.Context, order *models.Order, images []models.OrderImage, outbox repository.Outbox) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.orders {
+		if existing.OrderID == order.OrderID {
+			return repository.ErrDuplicate
+		}
+	}
+
+	now := time.Now()
+	order.ID = s.nextOrderID
+	s.nextOrderID++
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	order.UpdatedAt = now
+	order.Images = s.replaceImages(order.ID, images, now)
+
+	stored := *order
+	stored.Images = nil
+	s.orders[order.ID] = stored
+
+	if err := s.writeOutbox(*order, outbox); err != nil {
+		delete(s.orders, order.ID)
+		delete(s.images, order.ID)
+		return err
+	}
+	return nil
+}
+
+func (r *orderRepository) Update(ctx context.Context, order *models.Order, update repository.OrderUpdate) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	for id, existing := range s.orders {
+		if id != order.ID && existing.OrderID == order.OrderID {
+			return repository.ErrDuplicate
+		}
+	}
+
+	// SLA alerts aren't kept here, so ClearSLAAlerts has nothing to clear
+	previous, previousImages := s.orders[order.ID], s.images[order.ID]
+	now := time.Now()
+	order.UpdatedAt = now
+	if update.Images != nil {
+		order.Images = s.replaceImages(order.ID, update.Images, now)
+	}
+
+	stored := *order
+	stored.Images = nil
+	s.orders[order.ID] = stored
+
+	if err := s.writeOutbox(*order, update.Outbox); err != nil {
+		s.orders[order.ID] = previous
+		if previousImages == nil {
+			delete(s.images, order.ID)
+		} else {
+			s.images[order.ID] = previousImages
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *orderRepository) Delete(ctx context.Context, order models.Order, outbox repository.Outbox) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writeOutbox(order, outbox); err != nil {
+		return err
+	}
+	delete(s.images, order.ID)
+	delete(s.orders, order.ID)
+	return nil
+}
+
+// replaceImages swaps the images of an order for copies of images with IDs
+// assigned. The caller holds the write lock.
+func (s *Store) replaceImages(orderID uint, images []models.OrderImage, now time.Time) []models.OrderImage {
+	stored := make([]models.OrderImage, 0, len(images))
+	for _, image := range images {
+		image.ID = s.nextImageID
+		s.nextImageID++
+		image.OrderID = orderID
+		if image.CreatedAt.IsZero() {
+			image.CreatedAt = now
+		}
+		stored = append(stored, image)
+	}
+
+	if len(stored) == 0 {
+		delete(s.images, orderID)
+	} else {
+		s.images[orderID] = stored
+	}
+	return append([]models.OrderImage{}, stored...)
+}
+
+// filter returns copies of the orders matching f. The caller holds the read lock.
+func (s *Store) filter(f repository.OrderFilter, now time.Time) []models.Order {
+	orders := []models.Order{}
+	for _, order := range s.orders {
+		if s.matches(f, order, now) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+func (s *Store) matches(f repository.OrderFilter, o models.Order, now time.Time) bool {
+	if len(f.Statuses) > 0 && !containsString(f.Statuses, o.Status) {
+		return false
+	}
+	if len(f.Sources) > 0 && !containsString(f.Sources, o.Source) {
+		return false
+	}
+	if len(f.Thicknesses) > 0 && !containsString(f.Thicknesses, o.Thickness) {
+		return false
+	}
+	if len(f.CornerStyles) > 0 && !containsString(f.CornerStyles, o.CornerStyle) {
+		return false
+	}
+	if f.CreatedBy != nil && o.CreatedBy != *f.CreatedBy {
+		return false
+	}
+	if !inRange(o.CreatedAt, f.CreatedFrom, f.CreatedTo) || !inRange(o.UpdatedAt, f.UpdatedFrom, f.UpdatedTo) {
+		return false
+	}
+
+	area := o.Length * o.Width
+	bounds := []struct {
+		value    float64
+		min, max *float64
+	}{
+		{o.Length, f.MinLength, f.MaxLength},
+		{o.Width, f.MinWidth, f.MaxWidth},
+		{area, f.MinArea, f.MaxArea},
+	}
+	for _, b := range bounds {
+		if (b.min != nil && b.value < *b.min) || (b.max != nil && b.value > *b.max) {
+			return false
+		}
+	}
+
+	if f.HasImages != nil && (len(s.images[o.ID]) > 0) != *f.HasImages {
+		return false
+	}
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.Contains(strings.ToLower(o.OrderID), search) &&
+			!strings.Contains(strings.ToLower(o.CustomerName), search) &&
+			!strings.Contains(strings.ToLower(o.PhoneNumber), search) {
+			return false
+		}
+	}
+	if f.Phone != "" && !strings.Contains(digitsOnly(o.PhoneNumber), f.Phone) {
+		return false
+	}
+	if f.FullText != "" && !matchesWords(o.Notes+" "+o.SpecialNotes, f.FullText) {
+		return false
+	}
+
+	open := containsString(models.OpenOrderStatuses, o.Status)
+	if f.SLA != "" {
+		riskCutoff := now.Add(f.AtRiskWindow)
+		if !open {
+			return false
+		}
+		switch f.SLA {
+		case "overdue":
+			if o.DueAt == nil || !o.DueAt.Before(now) {
+				return false
+			}
+		case "at_risk":
+			if o.DueAt == nil || o.DueAt.Before(now) || !o.DueAt.Before(riskCutoff) {
+				return false
+			}
+		case "on_track":
+			if o.DueAt != nil && o.DueAt.Before(riskCutoff) {
+				return false
+			}
+		}
+	}
+	if f.OpenOnly && !open {
+		return false
+	}
+	if (f.DueFrom != nil || f.DueTo != nil) && (o.DueAt == nil || !inRange(*o.DueAt, f.DueFrom, f.DueTo)) {
+		return false
+	}
+	return true
+}
+
+// inRange reports whether from <= t < to, either bound being optional
+func inRange(t time.Time, from, to *time.Time) bool {
+	return (from == nil || !t.Before(*from)) && (to == nil || t.Before(*to))
+}
+
+// matchesWords reports whether every word of query appears as a word of text
+func matchesWords(text, query string) bool {
+	words := map[string]bool{}
+	for _, w := range splitWords(text) {
+		words[w] = true
+	}
+	for _, w := range splitWords(query) {
+		if !words[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// sortOrders orders like OrderFilter.orderBy: the given fields, then id DESC,
+// defaulting to created_at DESC. NULLs sort last ascending and first descending,
+// as in Postgres.
+func sortOrders(orders []models.Order, fields []repository.SortField) {
+	if len(fields) == 0 {
+		fields = []repository.SortField{{Field: "created_at", Desc: true}}
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		for _, field := range fields {
+			cmp := compareOrders(orders[i], orders[j], field.Field)
+			if field.Desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return orders[i].ID > orders[j].ID
+	})
+}
+
+func compareOrders(a, b models.Order, field string) int {
+	switch field {
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	case "order_id":
+		return strings.Compare(a.OrderID, b.OrderID)
+	case "customer_name":
+		return strings.Compare(a.CustomerName, b.CustomerName)
+	case "status":
+		return strings.Compare(a.Status, b.Status)
+	case "source":
+		return strings.Compare(a.Source, b.Source)
+	case "thickness":
+		return strings.Compare(a.Thickness, b.Thickness)
+	case "length":
+		return compareFloats(a.Length, b.Length)
+	case "width":
+		return compareFloats(a.Width, b.Width)
+	case "area":
+		return compareFloats(a.Length*a.Width, b.Length*b.Width)
+	case "due_at":
+		return compareNullableTimes(a.DueAt, b.DueAt)
+	case "promised_by":
+		return compareNullableTimes(a.PromisedBy, b.PromisedBy)
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareNullableTimes treats nil as larger than any time
+func compareNullableTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+type imageRepository struct {
+	store *Store
+}
+
+// NewImageRepository reads order images from store
+func NewImageRepository(store *Store) repository.ImageRepository {
+	return &imageRepository{store: store}
+}
+
+func (r *imageRepository) ListByOrder(ctx context.Context, orderID uint) ([]models.OrderImage, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return append([]models.OrderImage{}, r.store.images[orderID]...), nil
+}
+
+func (r *imageRepository) ListByOrders(ctx context.Context, orderIDs []uint) (map[uint][]models.OrderImage, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	byOrder := make(map[uint][]models.OrderImage, len(orderIDs))
+	for _, id := range orderIDs {
+		if images := r.store.images[id]; len(images) > 0 {
+			byOrder[id] = append([]models.OrderImage{}, images...)
+		}
+	}
+	return byOrder, nil
+}
+
+type userRepository struct {
+	store *Store
+}
+
+// NewUserRepository reads users from store; add them with Store.AddUser
+func NewUserRepository(store *Store) repository.UserRepository {
+	return &userRepository{store: store}
+}
+
+func (r *userRepository) Get(ctx context.Context, id uint) (models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return user, nil
+}
+
+type draftRepository struct {
+	store *Store
+}
+
+// NewDraftRepository keeps drafts in store; add them with Store.AddDraft and
+// their messages with Store.AddMessage. The store has no calls, so converting
+// a draft only moves its messages over to the order.
+func NewDraftRepository(store *Store) repository.DraftRepository {
+	return &draftRepository{store: store}
+}
+
+func (r *draftRepository) Get(ctx context.Context, id uint) (models.DraftOrder, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	draft, ok := r.store.drafts[id]
+	if !ok {
+		return models.DraftOrder{}, repository.ErrNotFound
+	}
+	return draft, nil
+}
+
+func (r *draftRepository) List(ctx context.Context, filter repository.DraftFilter) ([]models.DraftOrder, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	drafts := []models.DraftOrder{}
+	for _, draft := range r.store.drafts {
+		if (filter.Status == "" || draft.Status == filter.Status) && (filter.Source == "" || draft.Source == filter.Source) {
+			drafts = append(drafts, draft)
+		}
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		if !drafts[i].UpdatedAt.Equal(drafts[j].UpdatedAt) {
+			return drafts[i].UpdatedAt.After(drafts[j].UpdatedAt)
+		}
+		return drafts[i].ID > drafts[j].ID
+	})
+	if filter.Limit > 0 && len(drafts) > filter.Limit {
+		drafts = drafts[:filter.Limit]
+	}
+	return drafts, nil
+}
+
+func (r *draftRepository) Messages(ctx context.Context, draftID uint) ([]models.ChannelMessage, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	messages := []models.ChannelMessage{}
+	for _, message := range r.store.messages {
+		if message.DraftOrderID != nil && *message.DraftOrderID == draftID {
+			messages = append(messages, message)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	return messages, nil
+}
+
+func (r *draftRepository) Save(ctx context.Context, draft *models.DraftOrder) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.drafts[draft.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	draft.UpdatedAt = time.Now()
+	r.store.drafts[draft.ID] = *draft
+	return nil
+}
+
+func (r *draftRepository) SaveConverted(ctx context.Context, draft *models.DraftOrder) error {
+	if draft.OrderID == nil {
+		return fmt.Errorf("draft has no order")
+	}
+	if err := r.Save(ctx, draft); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, message := range r.store.messages {
+		if message.DraftOrderID != nil && *message.DraftOrderID == draft.ID {
+			orderID := *draft.OrderID
+			r.store.messages[i].OrderID = &orderID
+		}
+	}
+	return nil
+}
+
+type savedViewRepository struct {
+	store *Store
+}
+
+// NewSavedViewRepository keeps saved views in store
+func NewSavedViewRepository(store *Store) repository.SavedViewRepository {
+	return &savedViewRepository{store: store}
+}
+
+func (r *savedViewRepository) List(ctx context.Context, userID uint) ([]models.SavedView, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	views := []models.SavedView{}
+	for _, view := range r.store.views {
+		if view.UserID == userID {
+			views = append(views, view)
+		}
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return views, nil
+}
+
+func (r *savedViewRepository) Get(ctx context.Context, id, userID uint) (models.SavedView, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	view, ok := r.store.views[id]
+	if !ok || view.UserID != userID {
+		return models.SavedView{}, repository.ErrNotFound
+	}
+	return view, nil
+}
+
+func (r *savedViewRepository) Create(ctx context.Context, view *models.SavedView) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.viewNameTaken(*view) {
+		return repository.ErrDuplicate
+	}
+	view.ID = s.nextViewID
+	s.nextViewID++
+	view.CreatedAt = time.Now()
+	view.UpdatedAt = view.CreatedAt
+	s.views[view.ID] = *view
+	return nil
+}
+
+func (r *savedViewRepository) Save(ctx context.Context, view *models.SavedView) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.views[view.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	if s.viewNameTaken(*view) {
+		return repository.ErrDuplicate
+	}
+	view.UpdatedAt = time.Now()
+	s.views[view.ID] = *view
+	return nil
+}
+
+func (r *savedViewRepository) Delete(ctx context.Context, view models.SavedView) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.views, view.ID)
+	return nil
+}
+
+// viewNameTaken reports whether the user has another view named like view.
+// The caller holds the lock.
+func (s *Store) viewNameTaken(view models.SavedView) bool {
+	for id, existing := range s.views {
+		if id != view.ID && existing.UserID == view.UserID && existing.Name == view.Name {
+			return true
+		}
+	}
+	return false
+}
+
+type aiBudgetRepository struct {
+	store *Store
+}
+
+// NewAIBudgetRepository keeps AI budgets in store and sums the usage added
+// with Store.AddAIUsage
+func NewAIBudgetRepository(store *Store) repository.AIBudgetRepository {
+	return &aiBudgetRepository{store: store}
+}
+
+func (r *aiBudgetRepository) List(ctx context.Context, userID *uint) ([]models.AIBudget, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	budgets := []models.AIBudget{}
+	for _, budget := range r.store.budgets {
+		if userID == nil || budget.UserID == *userID {
+			budgets = append(budgets, budget)
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].UserID < budgets[j].UserID })
+	return budgets, nil
+}
+
+func (r *aiBudgetRepository) Save(ctx context.Context, budget *models.AIBudget) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := r.store.budgets[budget.UserID]; ok {
+		budget.CreatedAt = existing.CreatedAt
+	} else if budget.CreatedAt.IsZero() {
+		budget.CreatedAt = now
+	}
+	budget.UpdatedAt = now
+	r.store.budgets[budget.UserID] = *budget
+	return nil
+}
+
+func (r *aiBudgetRepository) Delete(ctx context.Context, userID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.budgets[userID]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.store.budgets, userID)
+	return nil
+}
+
+func (r *aiBudgetRepository) Spent(ctx context.Context, userID uint, since time.Time) (float64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var spent float64
+	for _, usage := range r.store.aiUsage {
+		if usage.UserID != nil && *usage.UserID == userID && !usage.CreatedAt.Before(since) {
+			spent += usage.CostUSD
+		}
+	}
+	return spent, nil
+}
+
+func (r *aiBudgetRepository) Usage(ctx context.Context, filter models.AIUsageFilter, period string, since time.Time) ([]models.AIUsageRow, error) {
+	if period != "day" && period != "month" {
+		return nil, fmt.Errorf("invalid period %q", period)
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	type key struct {
+		period         time.Time
+		feature, model string
+	}
+	rows := map[key]*models.AIUsageRow{}
+	for _, usage := range r.store.aiUsage {
+		switch {
+		case usage.CreatedAt.Before(since),
+			filter.UserID != nil && (usage.UserID == nil || *usage.UserID != *filter.UserID),
+			filter.OrderID != nil && (usage.OrderID == nil || *usage.OrderID != *filter.OrderID),
+			filter.Feature != "" && usage.Feature != filter.Feature:
+			continue
+		}
+
+		t := usage.CreatedAt
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		if period == "month" {
+			start = start.AddDate(0, 0, 1-t.Day())
+		}
+		k := key{start, usage.Feature, usage.Model}
+		row, ok := rows[k]
+		if !ok {
+			row = &models.AIUsageRow{Period: start, Feature: usage.Feature, Model: usage.Model}
+			rows[k] = row
+		}
+		row.Calls++
+		row.PromptTokens += usage.PromptTokens
+		row.CompletionTokens += usage.CompletionTokens
+		row.TotalTokens += usage.TotalTokens
+		row.CostUSD += usage.CostUSD
+	}
+
+	result := make([]models.AIUsageRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		switch {
+		case !a.Period.Equal(b.Period):
+			return a.Period.After(b.Period)
+		case a.Feature != b.Feature:
+			return a.Feature < b.Feature
+		}
+		return a.Model < b.Model
+	})
+	return result, nil
+}
+
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
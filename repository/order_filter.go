@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// OrderFilter holds every filter the order list understands
+type OrderFilter struct {
+	Statuses     []string
+	Sources      []string
+	Thicknesses  []string
+	CornerStyles []string
+	CreatedBy    *uint
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	UpdatedFrom  *time.Time
+	UpdatedTo    *time.Time
+	MinLength    *float64
+	MaxLength    *float64
+	MinWidth     *float64
+	MaxWidth     *float64
+	MinArea      *float64
+	MaxArea      *float64
+	HasImages    *bool
+	Search       string
+	Phone        string // digits only
+	FullText     string
+	SLA          string        // overdue, at_risk or on_track
+	AtRiskWindow time.Duration // how soon an open order's due date puts it at risk
+	Sort         []SortField
+
+	// Used by the dashboard summary: open orders only, due in [DueFrom, DueTo)
+	OpenOnly bool
+	DueFrom  *time.Time
+	DueTo    *time.Time
+}
+
+// SortField is a single whitelisted ORDER BY column
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// OrderSortColumns maps the sortable fields to their SQL expression
+var OrderSortColumns = map[string]string{
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+	"order_id":      "order_id",
+	"customer_name": "customer_name",
+	"status":        "status",
+	"source":        "source",
+	"thickness":     "thickness",
+	"length":        "length",
+	"width":         "width",
+	"area":          "(length * width)",
+	"due_at":        "due_at",
+	"promised_by":   "promised_by",
+}
+
+// Full-text expression - must match idx_orders_notes_fts in V5__Add_orders_search_indexes.sql
+const orderNotesTSVector = "to_tsvector('simple', coalesce(notes, '') || ' ' || coalesce(special_notes, ''))"
+
+// Phone digits expression - must match idx_orders_phone_digits_trgm in V24__Add_orders_trigram_indexes.sql.
+// The search and phone filters match substrings, served by the trigram indexes there.
+const orderPhoneDigits = "regexp_replace(phone_number, '[^0-9]', '', 'g')"
+
+// apply adds the WHERE clauses for the filter to a query on the orders table
+func (f OrderFilter) apply(query *gorm.DB) *gorm.DB {
+	if len(f.Statuses) > 0 {
+		query = query.Where("status IN ?", f.Statuses)
+	}
+	if len(f.Sources) > 0 {
+		query = query.Where("source IN ?", f.Sources)
+	}
+	if len(f.Thicknesses) > 0 {
+		query = query.Where("thickness IN ?", f.Thicknesses)
+	}
+	if len(f.CornerStyles) > 0 {
+		query = query.Where("corner_style IN ?", f.CornerStyles)
+	}
+	if f.CreatedBy != nil {
+		query = query.Where("created_by = ?", *f.CreatedBy)
+	}
+	if f.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		query = query.Where("created_at < ?", *f.CreatedTo)
+	}
+	if f.UpdatedFrom != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedFrom)
+	}
+	if f.UpdatedTo != nil {
+		query = query.Where("updated_at < ?", *f.UpdatedTo)
+	}
+	if f.MinLength != nil {
+		query = query.Where("length >= ?", *f.MinLength)
+	}
+	if f.MaxLength != nil {
+		query = query.Where("length <= ?", *f.MaxLength)
+	}
+	if f.MinWidth != nil {
+		query = query.Where("width >= ?", *f.MinWidth)
+	}
+	if f.MaxWidth != nil {
+		query = query.Where("width <= ?", *f.MaxWidth)
+	}
+	if f.MinArea != nil {
+		query = query.Where("length * width >= ?", *f.MinArea)
+	}
+	if f.MaxArea != nil {
+		query = query.Where("length * width <= ?", *f.MaxArea)
+	}
+	if f.HasImages != nil {
+		exists := "EXISTS (SELECT 1 FROM order_images WHERE order_images.order_id = orders.id)"
+		if *f.HasImages {
+			query = query.Where(exists)
+		} else {
+			query = query.Where("NOT " + exists)
+		}
+	}
+	if f.Search != "" {
+		like := "%" + f.Search + "%"
+		query = query.Where("order_id ILIKE ? OR customer_name ILIKE ? OR phone_number ILIKE ?", like, like, like)
+	}
+	if f.Phone != "" {
+		query = query.Where(orderPhoneDigits+" LIKE ?", "%"+f.Phone+"%")
+	}
+	if f.FullText != "" {
+		query = query.Where(orderNotesTSVector+" @@ websearch_to_tsquery('simple', ?)", f.FullText)
+	}
+	if f.SLA != "" {
+		now := time.Now()
+		riskCutoff := now.Add(f.AtRiskWindow)
+		query = query.Where("status IN ?", models.OpenOrderStatuses)
+
+		switch f.SLA {
+		case "overdue":
+			query = query.Where("due_at < ?", now)
+		case "at_risk":
+			query = query.Where("due_at >= ? AND due_at < ?", now, riskCutoff)
+		case "on_track":
+			query = query.Where("due_at IS NULL OR due_at >= ?", riskCutoff)
+		}
+	}
+	if f.OpenOnly {
+		query = query.Where("status IN ?", models.OpenOrderStatuses)
+	}
+	if f.DueFrom != nil {
+		query = query.Where("due_at >= ?", *f.DueFrom)
+	}
+	if f.DueTo != nil {
+		query = query.Where("due_at < ?", *f.DueTo)
+	}
+	return query
+}
+
+// orderBy builds the ORDER BY clause, always ending with id so paging is stable
+func (f OrderFilter) orderBy() string {
+	if len(f.Sort) == 0 {
+		return "created_at DESC, id DESC"
+	}
+
+	parts := make([]string, 0, len(f.Sort)+1)
+	for _, s := range f.Sort {
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, OrderSortColumns[s.Field]+" "+direction)
+	}
+	return strings.Join(append(parts, "id DESC"), ", ")
+}
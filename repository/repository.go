@@ -0,0 +1,123 @@
+// Package repository is the storage behind the handlers: interfaces the
+// handlers depend on, with GORM implementations for Postgres. Package memory
+// has in-memory implementations for running handlers without a database.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"customflow/models"
+)
+
+var (
+	// ErrNotFound is returned when no row matches
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a unique value, such as an order ID, is already taken
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+// OrderRepository stores orders and, with them, their image records
+type OrderRepository interface {
+	Get(ctx context.Context, id uint) (models.Order, error)
+	// FindByOrderID looks an order up by its business order ID
+	FindByOrderID(ctx context.Context, orderID string) (models.Order, error)
+	List(ctx context.Context, query OrderQuery) ([]models.Order, error)
+	Count(ctx context.Context, filter OrderFilter) (int64, error)
+	// CountBy groups the filtered orders by status, source or thickness
+	CountBy(ctx context.Context, filter OrderFilter, column string) (map[string]int64, error)
+	// Create stores the order, its images and the outbox's deliveries in one
+	// transaction and reloads the order
+	Create(ctx context.Context, order *models.Order, images []models.OrderImage, outbox Outbox) error
+	Update(ctx context.Context, order *models.Order, update OrderUpdate) error
+	// Delete removes the order with its images and stores the outbox's deliveries
+	Delete(ctx context.Context, order models.Order, outbox Outbox) error
+}
+
+// OrderUpdate says what else changes when an order is saved
+type OrderUpdate struct {
+	Images         []models.OrderImage // replaces the order's images when not nil
+	ClearSLAAlerts bool                // the due dates moved, so alerts may be sent again
+	Outbox         Outbox
+}
+
+// Outbox builds the webhook deliveries for an order change from the order as
+// stored and the active subscriptions. They are written in the change's
+// transaction, so they are queued exactly when the change commits. A nil
+// Outbox queues nothing.
+type Outbox func(order models.Order, subscriptions []models.WebhookSubscription) ([]models.WebhookDelivery, error)
+
+// ImageRepository reads the images attached to orders
+type ImageRepository interface {
+	ListByOrder(ctx context.Context, orderID uint) ([]models.OrderImage, error)
+	// ListByOrders fetches the images of many orders at once, by order
+	ListByOrders(ctx context.Context, orderIDs []uint) (map[uint][]models.OrderImage, error)
+}
+
+// UserRepository reads staff accounts
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (models.User, error)
+}
+
+// DraftRepository stores the draft orders built from inbound messages
+type DraftRepository interface {
+	Get(ctx context.Context, id uint) (models.DraftOrder, error)
+	// List returns the matching drafts, most recently updated first
+	List(ctx context.Context, filter DraftFilter) ([]models.DraftOrder, error)
+	// Messages returns the messages a draft was built from, oldest first
+	Messages(ctx context.Context, draftID uint) ([]models.ChannelMessage, error)
+	Save(ctx context.Context, draft *models.DraftOrder) error
+	// SaveConverted saves a draft that was converted to an order and moves
+	// its messages and calls over to that order
+	SaveConverted(ctx context.Context, draft *models.DraftOrder) error
+}
+
+// DraftFilter narrows the draft list; empty fields match everything
+type DraftFilter struct {
+	Status string
+	Source string
+	Limit  int
+}
+
+// SavedViewRepository stores each user's saved order list views. A view
+// belongs to one user: asking for it with another user ID is ErrNotFound.
+type SavedViewRepository interface {
+	// List returns the user's views by name
+	List(ctx context.Context, userID uint) ([]models.SavedView, error)
+	Get(ctx context.Context, id, userID uint) (models.SavedView, error)
+	// Create and Save return ErrDuplicate when the user has another view by that name
+	Create(ctx context.Context, view *models.SavedView) error
+	Save(ctx context.Context, view *models.SavedView) error
+	Delete(ctx context.Context, view models.SavedView) error
+}
+
+// AIBudgetRepository stores per-user AI budgets and reads what users spent
+type AIBudgetRepository interface {
+	// List returns the budgets by user ID, only userID's when it is set
+	List(ctx context.Context, userID *uint) ([]models.AIBudget, error)
+	// Save creates or replaces the user's budget
+	Save(ctx context.Context, budget *models.AIBudget) error
+	Delete(ctx context.Context, userID uint) error
+	// Spent is the user's AI cost in USD since the given time
+	Spent(ctx context.Context, userID uint, since time.Time) (float64, error)
+	// Usage groups the AI calls since the given time by "day" or "month",
+	// feature and model, newest period first
+	Usage(ctx context.Context, filter models.AIUsageFilter, period string, since time.Time) ([]models.AIUsageRow, error)
+}
+
+// OrderQuery is a page of filtered orders: keyset paging after Cursor when
+// it is set, otherwise Offset paging
+type OrderQuery struct {
+	Filter OrderFilter
+	Cursor *OrderCursor
+	Offset int
+	Limit  int
+}
+
+// OrderCursor is the position of the last order on a page in the default
+// created_at DESC, id DESC order
+type OrderCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uint      `json:"i"`
+}
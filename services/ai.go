@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -11,13 +12,29 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"customflow/config"
 )
 
 type OpenAIRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature"`
-	MaxTokens   int       `json:"max_tokens"`
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+}
+
+type ResponseFormat struct {
+	Type       string      `json:"type"` // "text", "json_object" or "json_schema"
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string                 `json:"name"`
+	Strict bool                   `json:"strict"`
+	Schema map[string]interface{} `json:"schema"`
 }
 
 type Message struct {
@@ -64,6 +81,10 @@ type Usage struct {
 
 var aiService *AIService
 
+// aiHTTPClient is shared by all OpenAI requests so connections are reused;
+// timeouts come from the request context
+var aiHTTPClient = &http.Client{}
+
 type AIService struct {
 	APIKey      string
 	Model       string
@@ -72,73 +93,41 @@ type AIService struct {
 	BaseURL     string
 }
 
-func InitAIService() {
+// InitAIService configures the OpenAI client, the price table and the reply
+// examples from the ai section
+func InitAIService(cfg config.AIConfig) {
 	aiService = &AIService{
-		APIKey:      "",
+		APIKey:      cfg.APIKey,
 		Model:       "gpt-4o", // GPT-4o supports vision
 		Temperature: 0.7,
 		MaxTokens:   1000,
 		BaseURL:     "https://api.openai.com/v1/chat/completions",
 	}
+	initAIClient(cfg)
+	initAIUsage(cfg)
+	replyExampleCount = cfg.ReplyExamples
 
 	if aiService.APIKey == "" {
-		log.Println("WARNING: OPENAI_API_KEY not set. AI features will use fallback responses.")
+		log.Println("WARNING: ai.api_key (OPENAI_API_KEY) not set. AI features will use fallback responses.")
 	} else {
-		log.Println("AI Service initialized with OpenAI API")
+		log.Printf("AI Service initialized with OpenAI API (%v)", aiClientSettings)
 	}
 }
 
-// ExtractTextFromImages - Real OCR using OpenAI Vision API
-func ExtractTextFromImages(images []string) (string, error) {
-	if len(images) == 0 {
-		return "", fmt.Errorf("no images provided")
-	}
-
-	if aiService.APIKey == "" {
-		return "", fmt.Errorf("OpenAI API key not configured")
-	}
-
-	log.Printf("Starting OCR for %d images: %v", len(images), images)
-
-	var extractedTexts []string
-
-	for i, imagePath := range images {
-		log.Printf("Processing image %d/%d: %s", i+1, len(images), imagePath)
-
-		// Convert image to base64
-		base64Image, err := imageToBase64(imagePath)
-		if err != nil {
-			log.Printf("Failed to convert image %s to base64: %v", imagePath, err)
-			continue
-		}
-
-		// Create vision request
-		extractedText, err := performOCRRequest(base64Image)
-		if err != nil {
-			log.Printf("OCR failed for image %s: %v", imagePath, err)
-			continue
-		}
-
-		if strings.TrimSpace(extractedText) != "" {
-			extractedTexts = append(extractedTexts, strings.TrimSpace(extractedText))
-			log.Printf("Successfully extracted text from %s: %d characters", imagePath, len(extractedText))
-		}
-	}
-
-	if len(extractedTexts) == 0 {
-		return "", fmt.Errorf("could not extract text from any of the %d images", len(images))
+// ExtractTextFromImages - Real OCR using OpenAI Vision API. Returns the text of
+// the images that could be read; see RunOCR for per-image results.
+func ExtractTextFromImages(ctx context.Context, images []string) (string, error) {
+	batch, err := RunOCR(ctx, images)
+	if err != nil {
+		return "", err
 	}
-
-	finalText := strings.Join(extractedTexts, "\n\n---NEXT IMAGE---\n\n")
-	log.Printf("OCR completed. Total extracted text: %d characters from %d images", len(finalText), len(extractedTexts))
-
-	return finalText, nil
+	return batch.Text, nil
 }
 
 // Convert image file to base64
 func imageToBase64(imagePath string) (string, error) {
 	// Build full path
-	fullPath := filepath.Join("./uploads", imagePath)
+	fullPath := UploadPath(imagePath)
 
 	// Check if file exists
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
@@ -151,10 +140,15 @@ func imageToBase64(imagePath string) (string, error) {
 		return "", fmt.Errorf("failed to read image file %s: %v", fullPath, err)
 	}
 
-	// Get file extension to determine MIME type
-	ext := strings.ToLower(filepath.Ext(imagePath))
+	dataURL := imageDataURL(imagePath, imageBytes)
+	log.Printf("Converted image %s to base64, size: %d bytes", imagePath, len(imageBytes))
+	return dataURL, nil
+}
+
+// imageDataURL encodes image bytes as a data URL, typed by file extension
+func imageDataURL(imagePath string, imageBytes []byte) string {
 	var mimeType string
-	switch ext {
+	switch strings.ToLower(filepath.Ext(imagePath)) {
 	case ".jpg", ".jpeg":
 		mimeType = "image/jpeg"
 	case ".png":
@@ -167,16 +161,11 @@ func imageToBase64(imagePath string) (string, error) {
 		mimeType = "image/jpeg" // Default fallback
 	}
 
-	// Convert to base64
-	base64String := base64.StdEncoding.EncodeToString(imageBytes)
-	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64String)
-
-	log.Printf("Converted image %s to base64: %s, size: %d bytes", imagePath, mimeType, len(imageBytes))
-	return dataURL, nil
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(imageBytes))
 }
 
 // Perform OCR request to OpenAI Vision API
-func performOCRRequest(base64Image string) (string, error) {
+func performOCRRequest(ctx context.Context, base64Image string) (string, error) {
 	messages := []Message{
 		{
 			Role: "user",
@@ -197,163 +186,116 @@ func performOCRRequest(base64Image string) (string, error) {
 	}
 
 	requestBody := OpenAIRequest{
-		Model:       "gpt-4o",
+		Model:       ocrSettings.Model,
 		Messages:    messages,
 		MaxTokens:   500,
 		Temperature: 0.1, // Low temperature for accurate extraction
 	}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", aiService.BaseURL, bytes.NewBuffer(jsonData))
+	openAIResp, err := doOpenAIRequest(withAIFeature(ctx, AIFeatureOCR), requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+aiService.APIKey)
+	return openAIResp.Choices[0].Message.Content, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+// GenerateAIResponse - Generate response using OpenAI, grounded in the
+// customer's order when there is one and in the customer's language unless
+// another is chosen, looking at any images attached to the message. While
+// the provider is unavailable, or when it failed on every retry, it answers
+// with the fallback response instead.
+func GenerateAIResponse(ctx context.Context, message string, opts ReplyOptions) (string, error) {
+	ctx, facts := replyFacts(ctx, opts.Order)
+	tone := replyTone(opts.Tone)
+	if aiService.APIKey == "" {
+		// Fallback response when no API key is configured
+		return fallbackReply(message, tone, facts), nil
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	images, err := replyImages(opts.Images)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
-	}
-
-	if resp.StatusCode != 200 {
-		log.Printf("OpenAI API error response: %s", string(body))
-		return "", fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	var openAIResp OpenAIResponse
-	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
-	}
-
-	if openAIResp.Error != nil {
-		return "", fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
+		return "", err
 	}
-
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices from OpenAI")
+	openAIResp, err := doOpenAIRequest(withAIFeature(ctx, AIFeatureReply), replyRequest(message, tone, opts.Language, facts, images).request)
+	if aiProviderDown(err) {
+		log.Printf("GenerateAIResponse: %v, using fallback response", err)
+		return fallbackReply(message, tone, facts), nil
 	}
-
-	extractedText := openAIResp.Choices[0].Message.Content
-
-	// Log usage for monitoring
-	if openAIResp.Usage.TotalTokens > 0 {
-		log.Printf("OCR API Usage - Tokens: %d (Prompt: %d, Completion: %d)",
-			openAIResp.Usage.TotalTokens,
-			openAIResp.Usage.PromptTokens,
-			openAIResp.Usage.CompletionTokens)
+	if err != nil {
+		return "", err
 	}
 
-	return extractedText, nil
+	return openAIResp.Choices[0].Message.Content, nil
 }
 
-// GenerateAIResponse - Generate response using OpenAI
-func GenerateAIResponse(message, tone string) (string, error) {
-	if aiService.APIKey == "" {
-		// Fallback response when no API key is configured
-		return generateFallbackResponse(message, tone), nil
-	}
-
-	prompt := createPrompt(message, tone)
-
-	requestBody := OpenAIRequest{
-		Model:       aiService.Model,
-		Temperature: aiService.Temperature,
-		MaxTokens:   aiService.MaxTokens,
-		Messages: []Message{
-			{
-				Role: "system",
-				Content: []ContentItem{
-					{
-						Type: "text",
-						Text: &[]string{createSystemPrompt()}[0],
-					},
-				},
-			},
-			{
-				Role: "user",
-				Content: []ContentItem{
-					{
-						Type: "text",
-						Text: &prompt,
-					},
-				},
-			},
-		},
+// doOpenAIRequest posts a chat completion request and returns a response with
+// at least one choice. Transient failures are retried; see withAIRetries. The
+// model may be swapped by the user's budget, and the usage is recorded.
+func doOpenAIRequest(ctx context.Context, requestBody OpenAIRequest) (*OpenAIResponse, error) {
+	requestedModel := requestBody.Model
+	model, err := applyAIBudget(ctx, requestedModel)
+	if err != nil {
+		return nil, err
 	}
+	requestBody.Model = model
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", aiService.BaseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
+	var openAIResp OpenAIResponse
+	err = withAIRetries(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", aiService.BaseURL, bytes.NewReader(jsonData))
+		if err != nil {
+			return &aiAttemptError{err: fmt.Errorf("failed to create request: %v", err)}
+		}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+aiService.APIKey)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+aiService.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
+		resp, err := aiHTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("OpenAI API error response: %s", string(body))
+			return &aiAttemptError{
+				err:        fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body)),
+				retryable:  retryableStatus(resp.StatusCode),
+				retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+
+		openAIResp = OpenAIResponse{}
+		if err := json.Unmarshal(body, &openAIResp); err != nil {
+			return &aiAttemptError{err: fmt.Errorf("failed to unmarshal response: %v", err)}
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+	if openAIResp.Error != nil {
+		return nil, fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
 	}
 
-	var openAIResp OpenAIResponse
-	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
-	}
+	recordAIUsage(ctx, requestedModel, &openAIResp)
 
 	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices from OpenAI")
+		return nil, fmt.Errorf("no response choices from OpenAI")
 	}
 
-	return openAIResp.Choices[0].Message.Content, nil
-}
-
-func createSystemPrompt() string {
-	return `You are a professional customer service assistant for CustomFlow, a premium custom table cover manufacturing business. 
-
-Your role:
-- Provide helpful, accurate information about custom table covers
-- Maintain a professional yet approachable tone
-- Focus on dimensions, materials, delivery timelines, and customization options
-- Always prioritize customer satisfaction
-- Keep responses concise but informative
-
-Key information about our business:
-- We specialize in custom table covers for dining tables, office tables, conference tables
-- Materials: Various thicknesses (1mm, 1.5mm, 2mm, 3mm) and corner styles (sharp, rounded, custom)
-- Standard delivery: 3-5 business days
-- We serve customers through Amazon, WhatsApp, SMS, and phone orders
-- Premium quality and precise measurements are our specialties
-- We measure in inches
-
-Always be helpful and ensure customers have the information they need to place their order.`
+	return &openAIResp, nil
 }
 
 func generateFallbackResponse(message, tone string) string {
@@ -380,21 +322,6 @@ func generateFallbackResponse(message, tone string) string {
 	return responseList[len(message)%len(responseList)]
 }
 
-func createPrompt(customerMessage, tone string) string {
-	basePrompt := fmt.Sprintf("Customer message: \"%s\"\n\n", customerMessage)
-
-	switch tone {
-	case "formal":
-		basePrompt += `Generate a formal, professional response for business correspondence. Use proper business language while addressing table cover requirements.`
-	case "short":
-		basePrompt += `Generate a brief, concise response under 50 words. Focus on essential information - dimensions, material, and delivery.`
-	default: // friendly
-		basePrompt += `Generate a warm, friendly response while remaining professional. Show enthusiasm for helping with custom table cover needs.`
-	}
-
-	return basePrompt
-}
-
 // GetModelInfo returns information about the current AI model
 func GetModelInfo() map[string]interface{} {
 	return map[string]interface{}{
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+// StreamOptions asks the provider for a final usage chunk on streamed completions
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// AIDeltaFunc receives streamed text as it is generated; an error stops the stream
+type AIDeltaFunc func(text string) error
+
+// ai_responses.status values
+const (
+	AIResponseCompleted = "completed"
+	AIResponseCancelled = "cancelled"
+	AIResponseFailed    = "failed"
+)
+
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// doOpenAIStream posts a streaming chat completion and passes each text delta
+// to onDelta. Failures before the first delta are retried like doOpenAIRequest;
+// a stream cut off midway is not, as the caller has already seen part of it.
+// On error the response holds the text received so far. Usage is recorded for
+// every attempt the provider answered, including ones that failed midway.
+func doOpenAIStream(ctx context.Context, requestBody OpenAIRequest, onDelta AIDeltaFunc) (*OpenAIResponse, error) {
+	requestedModel := requestBody.Model
+	model, err := applyAIBudget(ctx, requestedModel)
+	if err != nil {
+		return nil, err
+	}
+	requestBody.Model = model
+	requestBody.Stream = true
+	requestBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	var openAIResp OpenAIResponse
+	var text strings.Builder
+	var finishReason string
+
+	err = withAIRetries(ctx, func(ctx context.Context) (err error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", aiService.BaseURL, bytes.NewReader(jsonData))
+		if err != nil {
+			return &aiAttemptError{err: fmt.Errorf("failed to create request: %v", err)}
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Authorization", "Bearer "+aiService.APIKey)
+
+		resp, err := aiHTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			log.Printf("OpenAI API error response: %s", string(body))
+			return &aiAttemptError{
+				err:        fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body)),
+				retryable:  retryableStatus(resp.StatusCode),
+				retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+
+		// The provider bills a stream once it has started, so an attempt that
+		// fails from here on records what it used. The usage chunk only comes
+		// at the end; without it the tokens are estimated from the text.
+		defer func() {
+			if err == nil {
+				return
+			}
+			partial := openAIResp
+			if partial.Model == "" {
+				partial.Model = requestBody.Model
+			}
+			if partial.Usage.TotalTokens == 0 {
+				partial.Usage = estimateStreamUsage(requestBody, text.String())
+			}
+			recordAIUsage(ctx, requestedModel, &partial)
+		}()
+
+		started := false
+		midStream := func(err error) error {
+			if started {
+				return &aiAttemptError{err: err}
+			}
+			return err
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				return nil
+			}
+
+			var chunk openAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return midStream(fmt.Errorf("failed to unmarshal stream chunk: %v", err))
+			}
+			if chunk.Error != nil {
+				return midStream(fmt.Errorf("OpenAI API error: %s", chunk.Error.Message))
+			}
+			if chunk.Model != "" {
+				openAIResp.Model = chunk.Model
+			}
+			if chunk.Usage != nil {
+				openAIResp.Usage = *chunk.Usage
+			}
+
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != nil {
+					finishReason = *choice.FinishReason
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				started = true
+				text.WriteString(choice.Delta.Content)
+				if err := onDelta(choice.Delta.Content); err != nil {
+					return &aiAttemptError{err: err}
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return midStream(fmt.Errorf("stream interrupted: %v", err))
+		}
+		return midStream(fmt.Errorf("stream ended without [DONE]"))
+	})
+
+	openAIResp.Choices = []Choice{{
+		Message:      MessageResponse{Role: "assistant", Content: text.String()},
+		FinishReason: finishReason,
+	}}
+	if err != nil {
+		return &openAIResp, err
+	}
+
+	recordAIUsage(ctx, requestedModel, &openAIResp)
+	return &openAIResp, nil
+}
+
+// runCompletion makes a chat completion for a feature, streamed to onDelta if set
+func runCompletion(ctx context.Context, feature string, requestBody OpenAIRequest, onDelta AIDeltaFunc) (*OpenAIResponse, error) {
+	ctx = withAIFeature(ctx, feature)
+	if onDelta != nil {
+		return doOpenAIStream(ctx, requestBody, onDelta)
+	}
+	return doOpenAIRequest(ctx, requestBody)
+}
+
+// ReplyOptions shape a reply to a customer message
+type ReplyOptions struct {
+	Tone     string        // one of ReplyTones, default friendly
+	Order    *models.Order // ground the reply in this order's status and dates
+	Language string        // code from Languages; empty or "auto" replies in the customer's language
+	Images   []string      // photos or screenshots in the uploads directory sent with the message
+}
+
+// ReplyToMessage writes a reply to a customer message for staff and stores it
+// in ai_responses with the detected language. With an order the reply is
+// grounded in its status and dates, and attached images are shown to the
+// model with the message. With onDelta set the reply is streamed to
+// it as it is generated, and a stream the caller cancels is stored as far as
+// it got. Without an API key, while the provider is unavailable or when it
+// failed on every retry, the fallback response is used.
+func ReplyToMessage(ctx context.Context, message string, opts ReplyOptions, onDelta AIDeltaFunc) (models.AIResponse, error) {
+	ctx, facts := replyFacts(ctx, opts.Order)
+	tone := replyTone(opts.Tone)
+	// Images are only read when they will be sent
+	var images []ContentItem
+	if aiService.APIKey != "" {
+		var err error
+		if images, err = replyImages(opts.Images); err != nil {
+			return models.AIResponse{}, err
+		}
+	}
+	reply := replyRequest(message, tone, opts.Language, facts, images)
+	record := models.AIResponse{
+		InputMessage:  message,
+		Tone:          tone,
+		HasImages:     len(images) > 0,
+		Feature:       AIFeatureReply,
+		Status:        AIResponseCompleted,
+		Language:      reply.customer.Code,
+		ReplyLanguage: reply.language.Code,
+	}
+
+	var resp *OpenAIResponse
+	err := ErrAIUnavailable
+	if aiService.APIKey != "" {
+		record.PromptVersions = reply.versions
+		resp, err = runCompletion(ctx, AIFeatureReply, reply.request, onDelta)
+	}
+
+	switch {
+	case aiProviderDown(err):
+		record.Response = fallbackReply(message, tone, facts)
+		record.Model = "fallback"
+		record.ReplyLanguage = LanguageEnglish
+		if onDelta != nil {
+			if err := onDelta(record.Response); err != nil {
+				return record, err
+			}
+		}
+	case err != nil:
+		if resp == nil || resp.Choices[0].Message.Content == "" {
+			return record, err
+		}
+		record.Response, record.Model = resp.Choices[0].Message.Content, resp.Model
+		record.Status = streamStatus(ctx)
+		storeAIResponse(ctx, &record)
+		return record, err
+	default:
+		record.Response, record.Model = resp.Choices[0].Message.Content, resp.Model
+	}
+
+	storeAIResponse(ctx, &record)
+	return record, nil
+}
+
+// streamStatus is the ai_responses status of a stream that ended with an error
+func streamStatus(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return AIResponseCancelled
+	}
+	return AIResponseFailed
+}
+
+// storeAIResponse saves a response for the context's user and order. It runs
+// after the caller may have gone away, so it does not use ctx for the write.
+func storeAIResponse(ctx context.Context, record *models.AIResponse) {
+	scope := aiScopeFrom(ctx)
+	if scope.UserID == nil || config.DB == nil {
+		return
+	}
+	record.UserID = *scope.UserID
+	if record.OrderID == nil {
+		record.OrderID = scope.OrderID
+	}
+	if !containsString(ReplyTones, record.Tone) {
+		record.Tone = "friendly"
+	}
+	record.CreatedAt = time.Now()
+
+	if err := config.DB.Create(record).Error; err != nil {
+		log.Printf("AI: failed to store %s response: %v", record.Feature, err)
+	}
+}
+
+// promptedRequest is a completion request and the template versions its prompts came from
+type promptedRequest struct {
+	request  OpenAIRequest
+	versions models.PromptVersions
+	customer LanguageDetection // language of the customer's message
+	language Language          // language asked for
+}
+
+// replyRequest builds the reply prompts. With an order its facts follow the
+// system prompt, so the reply can give the real status and delivery date;
+// when the customer wrote in or the reply is wanted in another language than
+// English, the language prompt says which language to answer in. Replies
+// from the library to similar messages go before the message as examples,
+// and images the customer attached are sent along with it.
+func replyRequest(message, tone, language string, facts *OrderFacts, images []ContentItem) promptedRequest {
+	customer := DetectLanguage(message)
+	vars := promptVars()
+	vars.Message, vars.Tone, vars.Order, vars.Images = message, tone, facts, len(images)
+	vars.CustomerLanguage, vars.Language = customer.Language, replyLanguage(language, customer)
+	system := RenderPrompt(PromptSystem, vars)
+	prompt := RenderPrompt(PromptReply, vars)
+
+	reply := promptedRequest{
+		request: OpenAIRequest{
+			Model:       aiService.Model,
+			Temperature: aiService.Temperature,
+			MaxTokens:   aiService.MaxTokens,
+			Messages:    []Message{systemMessage(system.Text)},
+		},
+		versions: models.PromptVersions{system.Name: system.Version, prompt.Name: prompt.Version},
+		customer: customer,
+		language: vars.Language,
+	}
+	extra := []string{}
+	if facts != nil {
+		extra = append(extra, PromptOrder)
+	}
+	if vars.Language.Code != LanguageEnglish || (customer.Code != LanguageEnglish && customer.Code != LanguageUndetermined) {
+		extra = append(extra, PromptLanguage)
+	}
+	if len(images) > 0 {
+		extra = append(extra, PromptImages)
+	}
+	for _, name := range extra {
+		rendered := RenderPrompt(name, vars)
+		reply.request.Messages = append(reply.request.Messages, systemMessage(rendered.Text))
+		reply.versions[rendered.Name] = rendered.Version
+	}
+
+	// Library replies to similar messages, as earlier turns of the conversation
+	for _, example := range replyExamples(message, tone, vars.Language.Code) {
+		exampleVars := vars
+		exampleVars.Message = example.InputMessage
+		question := RenderPrompt(PromptReply, exampleVars).Text
+		answer := example.Body
+		reply.request.Messages = append(reply.request.Messages,
+			Message{Role: "user", Content: []ContentItem{{Type: "text", Text: &question}}},
+			Message{Role: "assistant", Content: []ContentItem{{Type: "text", Text: &answer}}})
+	}
+
+	content := append([]ContentItem{{Type: "text", Text: &prompt.Text}}, images...)
+	reply.request.Messages = append(reply.request.Messages, Message{Role: "user", Content: content})
+	return reply
+}
+
+// replyImages loads the images a customer attached as content items, in high
+// detail so that sizes on a tape measure or in a screenshot can be read
+func replyImages(images []string) ([]ContentItem, error) {
+	var content []ContentItem
+	for _, image := range images {
+		dataURL, err := imageToBase64(image)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, ContentItem{
+			Type:     "image_url",
+			ImageURL: &ImageURL{URL: dataURL, Detail: "high"},
+		})
+	}
+	return content, nil
+}
+
+// MaxReplyImages caps the images sent with one reply request
+const MaxReplyImages = 4
+
+// replyImageFiles picks the images to send with a reply from a message's
+// attachments: the last MaxReplyImages files in a format the provider accepts
+func replyImageFiles(files []string) []string {
+	var images []string
+	for _, file := range files {
+		if IsReplyImage(file) {
+			images = append(images, file)
+		}
+	}
+	if len(images) > MaxReplyImages {
+		images = images[len(images)-MaxReplyImages:]
+	}
+	return images
+}
+
+// IsReplyImage reports whether a file in the uploads directory is an image format the
+// provider accepts with a reply
+func IsReplyImage(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+func systemMessage(text string) Message {
+	return Message{Role: "system", Content: []ContentItem{{Type: "text", Text: &text}}}
+}
+
+func replyTone(tone string) string {
+	if containsString(ReplyTones, tone) {
+		return tone
+	}
+	return "friendly"
+}
+
+// replyFacts scopes ctx to the order a reply is about and returns its facts
+func replyFacts(ctx context.Context, order *models.Order) (context.Context, *OrderFacts) {
+	if order == nil {
+		return ctx, nil
+	}
+	facts := orderFacts(*order, time.Now())
+	return WithAIOrder(ctx, order.ID), &facts
+}
+
+// fallbackReply is the reply used without the provider
+func fallbackReply(message, tone string, facts *OrderFacts) string {
+	if facts != nil {
+		return orderFallbackResponse(*facts, tone)
+	}
+	return generateFallbackResponse(message, tone)
+}
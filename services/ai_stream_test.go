@@ -0,0 +1,17 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplyImageFiles(t *testing.T) {
+	files := []string{"a.jpg", "note.pdf", "b.png", "c.webp", "voice.ogg", "d.jpeg", "e.gif"}
+	want := []string{"b.png", "c.webp", "d.jpeg", "e.gif"}
+	if got := replyImageFiles(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("replyImageFiles = %v, want %v", got, want)
+	}
+	if got := replyImageFiles([]string{"scan.pdf"}); got != nil {
+		t.Errorf("replyImageFiles without images = %v, want nil", got)
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// AI features usage is accounted to
+const (
+	AIFeatureOCR         = "ocr"
+	AIFeatureReply       = "reply"
+	AIFeatureChat        = "chat"
+	AIFeatureExtraction  = "extraction"
+	AIFeatureTranslate   = "translate"
+	AIFeatureMeasurement = "measurement"
+)
+
+// ErrAIBudgetExceeded is returned without calling the provider once a user
+// with a blocking budget has spent their monthly limit
+var ErrAIBudgetExceeded = errors.New("monthly AI budget exceeded")
+
+// AIPrice is the cost of a model in USD per million tokens
+type AIPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+var (
+	aiPrices = map[string]AIPrice{
+		"gpt-4o":      {Input: 2.50, Output: 10.00},
+		"gpt-4o-mini": {Input: 0.15, Output: 0.60},
+	}
+	aiDowngradeModel = "gpt-4o-mini"
+)
+
+// initAIUsage merges the configured prices over the defaults and sets the
+// model "downgrade" budgets fall back to
+func initAIUsage(cfg config.AIConfig) {
+	for model, price := range cfg.Prices {
+		aiPrices[model] = AIPrice{Input: price.Input, Output: price.Output}
+	}
+	aiDowngradeModel = cfg.DowngradeModel
+}
+
+// AIPrices returns the price table
+func AIPrices() map[string]AIPrice {
+	return aiPrices
+}
+
+// AICost prices a call. Dated model names such as gpt-4o-2024-08-06 use the
+// longest matching entry; unknown models cost 0.
+func AICost(model string, promptTokens, completionTokens int) float64 {
+	best := ""
+	for name := range aiPrices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return 0
+	}
+	price := aiPrices[best]
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+}
+
+// aiScope is who and what an LLM call is accounted to, carried on the context
+type aiScope struct {
+	Feature string
+	UserID  *uint
+	OrderID *uint
+}
+
+type aiScopeKey struct{}
+
+func aiScopeFrom(ctx context.Context) aiScope {
+	scope, _ := ctx.Value(aiScopeKey{}).(aiScope)
+	return scope
+}
+
+// WithAIUser accounts the LLM calls made with ctx to a user, and applies their budget
+func WithAIUser(ctx context.Context, userID uint) context.Context {
+	scope := aiScopeFrom(ctx)
+	scope.UserID = &userID
+	return context.WithValue(ctx, aiScopeKey{}, scope)
+}
+
+// WithAIOrder accounts the LLM calls made with ctx to an order
+func WithAIOrder(ctx context.Context, orderID uint) context.Context {
+	scope := aiScopeFrom(ctx)
+	scope.OrderID = &orderID
+	return context.WithValue(ctx, aiScopeKey{}, scope)
+}
+
+func withAIFeature(ctx context.Context, feature string) context.Context {
+	scope := aiScopeFrom(ctx)
+	scope.Feature = feature
+	return context.WithValue(ctx, aiScopeKey{}, scope)
+}
+
+// applyAIBudget returns the model to call for the context's user: the
+// requested one, the downgrade model once a "downgrade" budget is spent, or
+// ErrAIBudgetExceeded once a "block" budget is
+func applyAIBudget(ctx context.Context, model string) (string, error) {
+	scope := aiScopeFrom(ctx)
+	if scope.UserID == nil || config.DB == nil {
+		return model, nil
+	}
+
+	var budget models.AIBudget
+	if err := config.DB.Where("user_id = ?", *scope.UserID).First(&budget).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("AI: budget lookup failed for user %d: %v", *scope.UserID, err)
+		}
+		return model, nil
+	}
+
+	spent, err := monthlyAISpend(*scope.UserID, time.Now())
+	if err != nil {
+		log.Printf("AI: spend lookup failed for user %d: %v", *scope.UserID, err)
+		return model, nil
+	}
+	if spent < budget.MonthlyLimitUSD {
+		return model, nil
+	}
+
+	if budget.Action == "downgrade" {
+		downgrade := budget.DowngradeModel
+		if downgrade == "" {
+			downgrade = aiDowngradeModel
+		}
+		return downgrade, nil
+	}
+	return model, fmt.Errorf("%w: spent $%.2f of $%.2f", ErrAIBudgetExceeded, spent, budget.MonthlyLimitUSD)
+}
+
+func monthlyAISpend(userID uint, now time.Time) (float64, error) {
+	var spent float64
+	err := config.DB.Model(&models.AIUsage{}).
+		Where("user_id = ? AND created_at >= ?", userID, monthStart(now)).
+		Select("COALESCE(SUM(cost_usd), 0)").
+		Scan(&spent).Error
+	return spent, err
+}
+
+// Token estimates for streams cut off before the provider reported usage:
+// about four characters of English per token, and the cost of one high
+// detail 1024px image
+const (
+	estimatedCharsPerToken = 4
+	estimatedImageTokens   = 765
+)
+
+// estimateStreamUsage approximates the usage of a request whose stream ended
+// after producing completion
+func estimateStreamUsage(req OpenAIRequest, completion string) Usage {
+	var usage Usage
+	for _, message := range req.Messages {
+		for _, item := range message.Content {
+			if item.Text != nil {
+				usage.PromptTokens += estimateTokens(*item.Text)
+			}
+			if item.ImageURL != nil {
+				usage.PromptTokens += estimatedImageTokens
+			}
+		}
+	}
+	usage.CompletionTokens = estimateTokens(completion)
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+func estimateTokens(text string) int {
+	return (len([]rune(text)) + estimatedCharsPerToken - 1) / estimatedCharsPerToken
+}
+
+// recordAIUsage stores the tokens and cost of a finished call
+func recordAIUsage(ctx context.Context, requestedModel string, resp *OpenAIResponse) {
+	scope := aiScopeFrom(ctx)
+	model := resp.Model
+	if model == "" {
+		model = requestedModel
+	}
+	feature := scope.Feature
+	if feature == "" {
+		feature = AIFeatureReply
+	}
+
+	usage := models.AIUsage{
+		Feature:          feature,
+		Model:            model,
+		RequestedModel:   requestedModel,
+		UserID:           scope.UserID,
+		OrderID:          scope.OrderID,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		CostUSD:          AICost(model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+		CreatedAt:        time.Now(),
+	}
+
+	log.Printf("AI usage - %s %s: %d tokens (prompt %d, completion %d), $%.6f",
+		usage.Feature, usage.Model, usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens, usage.CostUSD)
+
+	if config.DB == nil {
+		return
+	}
+	if err := config.DB.Create(&usage).Error; err != nil {
+		log.Printf("AI: failed to record usage: %v", err)
+	}
+}
+
+// AIBudgetStatus is a budget with this month's spend
+type AIBudgetStatus struct {
+	models.AIBudget
+	SpentUSD     float64 `json:"spent_usd"`
+	RemainingUSD float64 `json:"remaining_usd"`
+	Exceeded     bool    `json:"exceeded"`
+}
+
+// NewAIBudgetStatus is budget with spent USD spent this month
+func NewAIBudgetStatus(budget models.AIBudget, spent float64) AIBudgetStatus {
+	remaining := budget.MonthlyLimitUSD - spent
+	if remaining < 0 {
+		remaining = 0
+	}
+	return AIBudgetStatus{
+		AIBudget:     budget,
+		SpentUSD:     round2(spent),
+		RemainingUSD: round2(remaining),
+		Exceeded:     spent >= budget.MonthlyLimitUSD,
+	}
+}
+
+// AIBudgetPeriodStart is when the budget month containing t began
+func AIBudgetPeriodStart(t time.Time) time.Time {
+	return monthStart(t)
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
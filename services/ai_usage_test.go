@@ -0,0 +1,21 @@
+package services
+
+import "testing"
+
+func TestEstimateStreamUsage(t *testing.T) {
+	system := "You are a helpful assistant."        // 28 characters, 7 tokens
+	prompt := "Is my 48 x 30 in cover shipped yet?" // 35 characters, 9 tokens
+	req := OpenAIRequest{Messages: []Message{
+		systemMessage(system),
+		{Role: "user", Content: []ContentItem{
+			{Type: "text", Text: &prompt},
+			{Type: "image_url", ImageURL: &ImageURL{URL: "data:image/png;base64,AAAA", Detail: "high"}},
+		}},
+	}}
+
+	got := estimateStreamUsage(req, "It left on Mon") // 14 characters, 4 tokens
+	want := Usage{PromptTokens: 7 + 9 + estimatedImageTokens, CompletionTokens: 4, TotalTokens: 7 + 9 + estimatedImageTokens + 4}
+	if got != want {
+		t.Errorf("estimateStreamUsage = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"customflow/config"
+)
+
+// ErrAIUnavailable is returned without calling the provider while the circuit breaker is open
+var ErrAIUnavailable = errors.New("AI provider unavailable, circuit breaker open")
+
+// ErrAIRetriesExhausted wraps the last error when every attempt at a call
+// failed in a way worth retrying, such as 429, 5xx or a network error
+var ErrAIRetriesExhausted = errors.New("AI provider failed on every attempt")
+
+// aiProviderDown reports whether err means the provider could not be reached
+// or kept failing, rather than that the request or the budget was refused.
+// Replies fall back to a canned response in that case.
+func aiProviderDown(err error) bool {
+	return errors.Is(err, ErrAIUnavailable) || errors.Is(err, ErrAIRetriesExhausted)
+}
+
+type AIClientSettings struct {
+	CallTimeout     time.Duration // per attempt
+	MaxRetries      int
+	RetryBase       time.Duration
+	MaxRetryWait    time.Duration // cap on backoff and on Retry-After
+	BreakerFailures int           // consecutive failures that open the breaker
+	BreakerCooldown time.Duration // how long it stays open before one trial call
+}
+
+var (
+	aiClientSettings = AIClientSettings{
+		CallTimeout:     60 * time.Second,
+		MaxRetries:      2,
+		RetryBase:       500 * time.Millisecond,
+		MaxRetryWait:    30 * time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+	}
+	aiBreaker = &circuitBreaker{}
+)
+
+// initAIClient sets the timeout, retry and circuit breaker settings; the
+// backoff is doubled per retry with jitter, Retry-After included in the cap
+func initAIClient(cfg config.AIConfig) {
+	aiClientSettings = AIClientSettings{
+		CallTimeout:     cfg.Timeout,
+		MaxRetries:      cfg.MaxRetries,
+		RetryBase:       cfg.RetryBase,
+		MaxRetryWait:    cfg.MaxRetryWait,
+		BreakerFailures: cfg.BreakerFailures,
+		BreakerCooldown: cfg.BreakerCooldown,
+	}
+	aiBreaker = &circuitBreaker{threshold: aiClientSettings.BreakerFailures, cooldown: aiClientSettings.BreakerCooldown}
+}
+
+// aiAttemptError is a failed attempt, with whether it is worth retrying and
+// how long the provider asked us to wait
+type aiAttemptError struct {
+	err        error
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e *aiAttemptError) Error() string { return e.err.Error() }
+func (e *aiAttemptError) Unwrap() error { return e.err }
+
+// withAIRetries runs attempt under the circuit breaker, with a timeout per
+// attempt, retrying retryable failures with jittered exponential backoff or
+// the provider's Retry-After. Cancelling ctx stops it at once. When the
+// retries run out the error wraps ErrAIRetriesExhausted.
+func withAIRetries(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if !aiBreaker.Allow() {
+		return ErrAIUnavailable
+	}
+
+	var err error
+	for try := 0; ; try++ {
+		callCtx, cancel := context.WithTimeout(ctx, aiClientSettings.CallTimeout)
+		err = attempt(callCtx)
+		cancel()
+
+		if err == nil {
+			aiBreaker.Success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider
+			aiBreaker.Release()
+			return ctx.Err()
+		}
+
+		var attemptErr *aiAttemptError
+		retryable := !errors.As(err, &attemptErr) || attemptErr.retryable
+		if !retryable {
+			// The request was rejected; the provider itself is fine
+			aiBreaker.Success()
+			return err
+		}
+		if try >= aiClientSettings.MaxRetries {
+			break
+		}
+
+		wait := retryBackoff(try)
+		if attemptErr != nil && attemptErr.retryAfter > 0 {
+			wait = attemptErr.retryAfter
+		}
+		if wait > aiClientSettings.MaxRetryWait {
+			wait = aiClientSettings.MaxRetryWait
+		}
+		log.Printf("AI: attempt %d failed, retrying in %v: %v", try+1, wait, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			aiBreaker.Release()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	aiBreaker.Failure()
+	return fmt.Errorf("%w (%d attempts): %w", ErrAIRetriesExhausted, aiClientSettings.MaxRetries+1, err)
+}
+
+// retryBackoff is base * 2^try with "full jitter"
+func retryBackoff(try int) time.Duration {
+	backoff := aiClientSettings.RetryBase << uint(try)
+	if backoff <= 0 || backoff > aiClientSettings.MaxRetryWait {
+		backoff = aiClientSettings.MaxRetryWait
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// retryableStatus reports whether a provider response is worth retrying
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// circuitBreaker opens after threshold consecutive failed calls and stays open
+// for cooldown. Then one trial call is let through: success closes it, failure
+// opens it again. A zero threshold disables it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	trial     bool // a half-open trial call is in flight
+}
+
+// BreakerStatus is the breaker state reported by HealthCheck
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed && b.state != "" {
+		log.Println("AI: provider healthy again, circuit breaker closed")
+	}
+	b.state, b.failures, b.trial = BreakerClosed, 0, false
+}
+
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.threshold > 0 && (b.trial || b.failures >= b.threshold) {
+		if b.state != BreakerOpen {
+			log.Printf("AI: %d consecutive failures, circuit breaker open for %v", b.failures, b.cooldown)
+		}
+		b.state, b.openedAt = BreakerOpen, time.Now()
+	}
+	b.trial = false
+}
+
+// Release ends a call that neither succeeded nor failed, such as a cancelled one
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// currentState moves an open breaker to half-open once the cooldown has passed; callers hold mu
+func (b *circuitBreaker) currentState() string {
+	if b.state == "" {
+		b.state = BreakerClosed
+	}
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *circuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: b.currentState(), ConsecutiveFailures: b.failures}
+	if status.State != BreakerClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+	return status
+}
+
+// AIHealth reports whether AI features can reach the provider
+func AIHealth() map[string]interface{} {
+	breaker := aiBreaker.Status()
+
+	status := "available"
+	switch {
+	case aiService == nil || aiService.APIKey == "":
+		status = "not_configured"
+	case breaker.State == BreakerOpen:
+		status = "unavailable"
+	case breaker.State == BreakerHalfOpen || breaker.ConsecutiveFailures > 0:
+		status = "degraded"
+	}
+
+	return map[string]interface{}{
+		"status":  status,
+		"breaker": breaker,
+	}
+}
+
+func (s AIClientSettings) String() string {
+	return fmt.Sprintf("timeout %v, %d retries, breaker after %d failures for %v",
+		s.CallTimeout, s.MaxRetries, s.BreakerFailures, s.BreakerCooldown)
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{threshold: 3, cooldown: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("call %d refused before the threshold", i+1)
+		}
+		b.Failure()
+	}
+	if state := b.Status().State; state != BreakerClosed {
+		t.Fatalf("after 2 failures state = %s, want %s", state, BreakerClosed)
+	}
+
+	// The third consecutive failure opens it
+	b.Allow()
+	b.Failure()
+	if state := b.Status().State; state != BreakerOpen {
+		t.Fatalf("after 3 failures state = %s, want %s", state, BreakerOpen)
+	}
+	if b.Allow() {
+		t.Fatal("open breaker allowed a call")
+	}
+
+	// After the cooldown exactly one trial call is let through
+	b.openedAt = time.Now().Add(-time.Minute)
+	if state := b.Status().State; state != BreakerHalfOpen {
+		t.Fatalf("after cooldown state = %s, want %s", state, BreakerHalfOpen)
+	}
+	if !b.Allow() {
+		t.Fatal("half-open breaker refused the trial call")
+	}
+	if b.Allow() {
+		t.Fatal("half-open breaker allowed a second call during the trial")
+	}
+
+	// A failed trial opens it again at once
+	b.Failure()
+	if state := b.Status().State; state != BreakerOpen {
+		t.Fatalf("after failed trial state = %s, want %s", state, BreakerOpen)
+	}
+
+	// A cancelled trial frees the slot without deciding anything
+	b.openedAt = time.Now().Add(-time.Minute)
+	b.Allow()
+	b.Release()
+	if state := b.Status().State; state != BreakerHalfOpen {
+		t.Fatalf("after released trial state = %s, want %s", state, BreakerHalfOpen)
+	}
+
+	// A successful trial closes it and resets the count
+	if !b.Allow() {
+		t.Fatal("half-open breaker refused a trial after release")
+	}
+	b.Success()
+	status := b.Status()
+	if status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Fatalf("after successful trial status = %+v, want closed with no failures", status)
+	}
+	if !b.Allow() {
+		t.Fatal("closed breaker refused a call")
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := &circuitBreaker{}
+	for i := 0; i < 20; i++ {
+		if !b.Allow() {
+			t.Fatalf("disabled breaker refused call %d", i+1)
+		}
+		b.Failure()
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		min, max time.Duration
+	}{
+		{"empty", "", 0, 0},
+		{"seconds", "7", 7 * time.Second, 7 * time.Second},
+		{"zero seconds", "0", 0, 0},
+		{"negative seconds", "-3", 0, 0},
+		{"http date", time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), 85 * time.Second, 90 * time.Second},
+		{"past http date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+		{"garbage", "soon", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("%s: parseRetryAfter(%q) = %s, want between %s and %s", tt.name, tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+// withTestAIProvider points the AI client at handler with fast retries and a
+// fresh breaker, restoring the previous settings when the test ends
+func withTestAIProvider(t *testing.T, handler http.HandlerFunc) {
+	provider := httptest.NewServer(handler)
+
+	service, settings, breaker := aiService, aiClientSettings, aiBreaker
+	t.Cleanup(func() {
+		provider.Close()
+		aiService, aiClientSettings, aiBreaker = service, settings, breaker
+	})
+
+	aiService = &AIService{APIKey: "sk-test", Model: "gpt-4o", MaxTokens: 100, BaseURL: provider.URL}
+	aiClientSettings = AIClientSettings{
+		CallTimeout:     time.Second,
+		MaxRetries:      2,
+		RetryBase:       time.Millisecond,
+		MaxRetryWait:    5 * time.Millisecond,
+		BreakerFailures: 5,
+		BreakerCooldown: time.Minute,
+	}
+	aiBreaker = &circuitBreaker{threshold: aiClientSettings.BreakerFailures, cooldown: aiClientSettings.BreakerCooldown}
+}
+
+func TestWithAIRetriesExhausted(t *testing.T) {
+	var calls atomic.Int32
+	withTestAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "1") // capped at MaxRetryWait
+		http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusTooManyRequests)
+	})
+
+	_, err := doOpenAIRequest(context.Background(), OpenAIRequest{Model: "gpt-4o"})
+	if !errors.Is(err, ErrAIRetriesExhausted) {
+		t.Fatalf("err = %v, want ErrAIRetriesExhausted", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("provider called %d times, want 3", got)
+	}
+	if got := aiBreaker.Status().ConsecutiveFailures; got != 1 {
+		t.Errorf("breaker counted %d failures, want 1 per exhausted call", got)
+	}
+}
+
+func TestGenerateAIResponseFallback(t *testing.T) {
+	var calls atomic.Int32
+	withTestAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	message := "Do you make round covers?"
+	want := fallbackReply(message, "friendly", nil)
+
+	// Retries exhausted
+	reply, err := GenerateAIResponse(context.Background(), message, ReplyOptions{})
+	if err != nil || reply != want {
+		t.Fatalf("GenerateAIResponse after exhausted retries = %q, %v; want the fallback response", reply, err)
+	}
+
+	// Breaker open: the provider is not called at all
+	aiBreaker.state, aiBreaker.openedAt = BreakerOpen, time.Now()
+	before := calls.Load()
+	reply, err = GenerateAIResponse(context.Background(), message, ReplyOptions{})
+	if err != nil || reply != want {
+		t.Fatalf("GenerateAIResponse with breaker open = %q, %v; want the fallback response", reply, err)
+	}
+	if calls.Load() != before {
+		t.Error("provider called while the breaker was open")
+	}
+}
+
+func TestGenerateAIResponseRejected(t *testing.T) {
+	var calls atomic.Int32
+	withTestAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+	})
+
+	// A rejected request is the caller's problem, not an outage: no retry, no fallback
+	if reply, err := GenerateAIResponse(context.Background(), "hello", ReplyOptions{}); err == nil {
+		t.Fatalf("GenerateAIResponse = %q, want an error", reply)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"customflow/config"
+)
+
+// AmazonConnector pulls orders from the Amazon Selling Partner API (Orders v0)
+type AmazonConnector struct {
+	Endpoint        string // e.g. https://sellingpartnerapi-na.amazon.com
+	TokenURL        string // Login with Amazon token endpoint
+	ClientID        string
+	ClientSecret    string
+	RefreshToken    string
+	MarketplaceIDs  []string
+	OrderStatuses   []string
+	RequestInterval time.Duration // pause between orderItems calls to stay under the rate limit
+	Client          *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// SP-API response shapes, trimmed to the fields we map
+type spapiOrdersResponse struct {
+	Payload struct {
+		Orders            []spapiOrder `json:"Orders"`
+		NextToken         string       `json:"NextToken"`
+		LastUpdatedBefore string       `json:"LastUpdatedBefore"`
+	} `json:"payload"`
+	Errors []spapiError `json:"errors"`
+}
+
+type spapiOrder struct {
+	AmazonOrderID  string `json:"AmazonOrderId"`
+	PurchaseDate   string `json:"PurchaseDate"`
+	LastUpdateDate string `json:"LastUpdateDate"`
+	OrderStatus    string `json:"OrderStatus"`
+	MarketplaceID  string `json:"MarketplaceId"`
+	BuyerInfo      *struct {
+		BuyerName  string `json:"BuyerName"`
+		BuyerEmail string `json:"BuyerEmail"`
+	} `json:"BuyerInfo"`
+	ShippingAddress *struct {
+		Name  string `json:"Name"`
+		Phone string `json:"Phone"`
+	} `json:"ShippingAddress"`
+}
+
+type spapiOrderItemsResponse struct {
+	Payload struct {
+		OrderItems []spapiOrderItem `json:"OrderItems"`
+		NextToken  string           `json:"NextToken"`
+	} `json:"payload"`
+	Errors []spapiError `json:"errors"`
+}
+
+type spapiOrderItem struct {
+	OrderItemID     string `json:"OrderItemId"`
+	SellerSKU       string `json:"SellerSKU"`
+	Title           string `json:"Title"`
+	QuantityOrdered int    `json:"QuantityOrdered"`
+	BuyerInfo       *struct {
+		BuyerCustomizedInfo *struct {
+			CustomizedURL string `json:"CustomizedURL"`
+		} `json:"BuyerCustomizedInfo"`
+	} `json:"BuyerInfo"`
+}
+
+type spapiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// InitAmazonConnector builds the Amazon connector from marketplace.amazon, or
+// returns nil when it has no refresh token
+func InitAmazonConnector(cfg config.AmazonConfig) *AmazonConnector {
+	if cfg.RefreshToken == "" {
+		return nil
+	}
+
+	return &AmazonConnector{
+		Endpoint:        strings.TrimRight(cfg.Endpoint, "/"),
+		TokenURL:        cfg.TokenURL,
+		ClientID:        cfg.ClientID,
+		ClientSecret:    cfg.ClientSecret,
+		RefreshToken:    cfg.RefreshToken,
+		MarketplaceIDs:  cfg.MarketplaceIDs,
+		OrderStatuses:   cfg.OrderStatuses,
+		RequestInterval: cfg.RequestInterval,
+		Client:          &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (a *AmazonConnector) Name() string {
+	return "amazon"
+}
+
+// FetchOrders pages through orders updated after since and resolves each
+// order's items and buyer customization
+func (a *AmazonConnector) FetchOrders(ctx context.Context, since time.Time) ([]MarketplaceOrder, time.Time, error) {
+	var orders []MarketplaceOrder
+	var cursor time.Time
+
+	params := url.Values{}
+	params.Set("MarketplaceIds", strings.Join(a.MarketplaceIDs, ","))
+	params.Set("LastUpdatedAfter", since.UTC().Format(time.RFC3339))
+	if len(a.OrderStatuses) > 0 {
+		params.Set("OrderStatuses", strings.Join(a.OrderStatuses, ","))
+	}
+
+	for {
+		var page spapiOrdersResponse
+		if err := a.get(ctx, "/orders/v0/orders", params, &page); err != nil {
+			// Imports are idempotent, so resuming from the old cursor is safe
+			return orders, since, err
+		}
+
+		for _, o := range page.Payload.Orders {
+			mo, err := a.mapOrder(ctx, o)
+			if err != nil {
+				return orders, since, fmt.Errorf("order %s: %v", o.AmazonOrderID, err)
+			}
+			orders = append(orders, mo)
+			if mo.UpdatedAt.After(cursor) {
+				cursor = mo.UpdatedAt
+			}
+		}
+
+		if page.Payload.NextToken == "" {
+			if t, err := time.Parse(time.RFC3339, page.Payload.LastUpdatedBefore); err == nil {
+				cursor = t
+			}
+			break
+		}
+		params = url.Values{}
+		params.Set("MarketplaceIds", strings.Join(a.MarketplaceIDs, ","))
+		params.Set("NextToken", page.Payload.NextToken)
+	}
+
+	return orders, cursor, nil
+}
+
+func (a *AmazonConnector) mapOrder(ctx context.Context, o spapiOrder) (MarketplaceOrder, error) {
+	mo := MarketplaceOrder{ExternalID: o.AmazonOrderID}
+	mo.PurchasedAt, _ = time.Parse(time.RFC3339, o.PurchaseDate)
+	mo.UpdatedAt, _ = time.Parse(time.RFC3339, o.LastUpdateDate)
+
+	if o.BuyerInfo != nil {
+		mo.CustomerName = o.BuyerInfo.BuyerName
+	}
+	if o.ShippingAddress != nil {
+		if mo.CustomerName == "" {
+			mo.CustomerName = o.ShippingAddress.Name
+		}
+		mo.PhoneNumber = o.ShippingAddress.Phone
+	}
+
+	if a.RequestInterval > 0 {
+		select {
+		case <-time.After(a.RequestInterval):
+		case <-ctx.Done():
+			return mo, ctx.Err()
+		}
+	}
+
+	var items spapiOrderItemsResponse
+	if err := a.get(ctx, "/orders/v0/orders/"+url.PathEscape(o.AmazonOrderID)+"/orderItems", nil, &items); err != nil {
+		return mo, err
+	}
+
+	var notes, customization []string
+	for _, item := range items.Payload.OrderItems {
+		notes = append(notes, fmt.Sprintf("%dx %s (SKU %s)", item.QuantityOrdered, item.Title, item.SellerSKU))
+
+		if item.BuyerInfo == nil || item.BuyerInfo.BuyerCustomizedInfo == nil || item.BuyerInfo.BuyerCustomizedInfo.CustomizedURL == "" {
+			continue
+		}
+		text, err := a.fetchCustomization(ctx, item.BuyerInfo.BuyerCustomizedInfo.CustomizedURL)
+		if err != nil {
+			log.Printf("Amazon: customization for %s item %s unavailable: %v", o.AmazonOrderID, item.OrderItemID, err)
+			continue
+		}
+		customization = append(customization, text)
+	}
+
+	mo.Notes = "Amazon order " + o.AmazonOrderID + "\n" + strings.Join(notes, "\n")
+	mo.Customization = strings.Join(customization, "\n")
+	return mo, nil
+}
+
+// fetchCustomization downloads the buyer customization, a zip holding a JSON
+// document (or the JSON itself), and flattens it to "label: value" lines
+func (a *AmazonConnector) fetchCustomization(ctx context.Context, customizedURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", customizedURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("customization download failed (status %d)", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.HasPrefix(body, []byte("PK")) {
+		if body, err = jsonFromZip(body); err != nil {
+			return "", err
+		}
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("invalid customization JSON: %v", err)
+	}
+
+	var lines []string
+	flattenCustomization(doc, &lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+func jsonFromZip(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid customization zip: %v", err)
+	}
+	for _, f := range archive.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, 10<<20))
+	}
+	return nil, fmt.Errorf("customization zip has no JSON document")
+}
+
+// Keys that hold the buyer's answer in customization JSON
+var customizationValueKeys = []string{"inputValue", "optionValue", "text", "value"}
+
+func flattenCustomization(node interface{}, lines *[]string) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if label, ok := v["label"].(string); ok {
+			for _, key := range customizationValueKeys {
+				if value, ok := v[key].(string); ok && strings.TrimSpace(value) != "" {
+					*lines = append(*lines, label+": "+strings.TrimSpace(value))
+					break
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			flattenCustomization(v[key], lines)
+		}
+	case []interface{}:
+		for _, child := range v {
+			flattenCustomization(child, lines)
+		}
+	}
+}
+
+func (a *AmazonConnector) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	token, err := a.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoint := a.Endpoint + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-amz-access-token", token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "CustomFlow/1.0 (Language=Go)")
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SP-API error (status %d) for %s: %s", resp.StatusCode, path, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// token exchanges the refresh token for an LWA access token, cached until shortly before expiry
+func (a *AmazonConnector) token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.accessToken != "" && time.Now().Before(a.tokenExpiry) {
+		return a.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", a.RefreshToken)
+	form.Set("client_id", a.ClientID)
+	form.Set("client_secret", a.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request LWA token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("LWA token error (status %d): %s", resp.StatusCode, result.ErrorDescription)
+	}
+
+	a.accessToken = result.AccessToken
+	a.tokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return a.accessToken, nil
+}
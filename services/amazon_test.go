@@ -0,0 +1,173 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// spapiFixtureServer serves the recorded SP-API responses in testdata/amazon
+// the way cmd/spapi-standin does; files whose path ends in one of missing
+// answer 404
+func spapiFixtureServer(t *testing.T, missing ...string) (*httptest.Server, *atomic.Int32) {
+	dir := filepath.Join("..", "testdata", "amazon")
+	var tokens atomic.Int32
+	var server *httptest.Server
+
+	serveFile := func(w http.ResponseWriter, path string) {
+		for _, m := range missing {
+			if strings.HasSuffix(path, m) {
+				http.Error(w, `{"errors":[{"code":"NotFound"}]}`, http.StatusNotFound)
+				return
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, `{"errors":[{"code":"NotFound"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bytes.ReplaceAll(data, []byte("{{BASE_URL}}"), []byte(server.URL)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/o2/token", func(w http.ResponseWriter, r *http.Request) {
+		tokens.Add(1)
+		if r.FormValue("refresh_token") != "refresh" {
+			http.Error(w, `{"error_description":"bad refresh token"}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"Atza|test","expires_in":3600}`))
+	})
+	mux.HandleFunc("GET /orders/v0/orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-amz-access-token") != "Atza|test" {
+			http.Error(w, `{"errors":[{"code":"Unauthorized"}]}`, http.StatusForbidden)
+			return
+		}
+		page := "orders.json"
+		if token := r.URL.Query().Get("NextToken"); token != "" {
+			page = "orders_" + filepath.Base(token) + ".json"
+		}
+		serveFile(w, filepath.Join(dir, page))
+	})
+	mux.HandleFunc("GET /orders/v0/orders/{id}/orderItems", func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, filepath.Join(dir, "orderItems", filepath.Base(r.PathValue("id"))+".json"))
+	})
+	mux.HandleFunc("GET /customizations/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.PathValue("name"))
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		data, err := os.ReadFile(filepath.Join(dir, "customizations", base+".json"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(name, ".zip") {
+			var buf bytes.Buffer
+			archive := zip.NewWriter(&buf)
+			f, _ := archive.Create(base + ".json")
+			f.Write(data)
+			archive.Close()
+			data = buf.Bytes()
+		}
+		w.Write(data)
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &tokens
+}
+
+func newTestAmazonConnector(server *httptest.Server) *AmazonConnector {
+	return &AmazonConnector{
+		Endpoint:       server.URL,
+		TokenURL:       server.URL + "/auth/o2/token",
+		ClientID:       "client",
+		ClientSecret:   "secret",
+		RefreshToken:   "refresh",
+		MarketplaceIDs: []string{"ATVPDKIKX0DER"},
+		Client:         server.Client(),
+	}
+}
+
+func TestAmazonFetchOrders(t *testing.T) {
+	server, tokens := spapiFixtureServer(t)
+	amazon := newTestAmazonConnector(server)
+
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	orders, cursor, err := amazon.FetchOrders(context.Background(), since)
+	if err != nil {
+		t.Fatalf("FetchOrders: %v", err)
+	}
+	if want := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC); !cursor.Equal(want) {
+		t.Errorf("cursor = %s, want the last page's LastUpdatedBefore %s", cursor, want)
+	}
+	if got := tokens.Load(); got != 1 {
+		t.Errorf("requested %d LWA tokens, want 1 reused across calls", got)
+	}
+
+	tests := []struct {
+		id, customer, phone string
+		complete            bool // customization has size, thickness and corners
+		customization       string
+	}{
+		{"113-4829371-2203415", "Daniel Okafor", "+1 206-555-0143", true, "Table Length (inches): 60"},
+		{"113-7716402-9981432", "Mei Lin", "", false, "Thickness: 5mm"},
+		{"114-0093817-5562091", "Rosa Alvarez", "+1 512-555-0178", true, "Size: 72 x 42 inches"},
+	}
+	if len(orders) != len(tests) {
+		t.Fatalf("got %d orders over two pages, want %d", len(orders), len(tests))
+	}
+	for i, tt := range tests {
+		mo := orders[i]
+		if mo.ExternalID != tt.id || mo.CustomerName != tt.customer || mo.PhoneNumber != tt.phone {
+			t.Errorf("order %d = %s %q %q, want %s %q %q", i, mo.ExternalID, mo.CustomerName, mo.PhoneNumber, tt.id, tt.customer, tt.phone)
+		}
+		if !strings.Contains(mo.Customization, tt.customization) {
+			t.Errorf("%s customization = %q, want it to contain %q", tt.id, mo.Customization, tt.customization)
+		}
+		if !strings.HasPrefix(mo.Notes, "Amazon order "+tt.id+"\n") {
+			t.Errorf("%s notes = %q", tt.id, mo.Notes)
+		}
+
+		hints := ParseOrderHints(mo.Customization)
+		complete := hints.Length != nil && hints.Width != nil && hints.Thickness != "" && hints.CornerStyle != ""
+		if complete != tt.complete {
+			t.Errorf("%s customization complete = %v, want %v (hints %+v)", tt.id, complete, tt.complete, hints)
+		}
+	}
+}
+
+func TestAmazonFetchOrdersPartialFailure(t *testing.T) {
+	server, _ := spapiFixtureServer(t, "114-0093817-5562091.json")
+	amazon := newTestAmazonConnector(server)
+
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	orders, cursor, err := amazon.FetchOrders(context.Background(), since)
+	if err == nil || !strings.Contains(err.Error(), "114-0093817-5562091") {
+		t.Fatalf("FetchOrders error = %v, want the failed order named", err)
+	}
+	if len(orders) != 2 {
+		t.Errorf("got %d orders, want the 2 from the first page", len(orders))
+	}
+	if !cursor.Equal(since) {
+		t.Errorf("cursor = %s, want the previous cursor %s kept", cursor, since)
+	}
+}
+
+func TestAmazonFetchOrdersBadToken(t *testing.T) {
+	server, _ := spapiFixtureServer(t)
+	amazon := newTestAmazonConnector(server)
+	amazon.RefreshToken = "revoked"
+
+	if _, _, err := amazon.FetchOrders(context.Background(), time.Now()); err == nil || !strings.Contains(err.Error(), "bad refresh token") {
+		t.Fatalf("FetchOrders error = %v, want the LWA error", err)
+	}
+}
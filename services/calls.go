@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+// Where a call transcript came from
+const (
+	TranscriptPasted      = "pasted"
+	TranscriptTranscribed = "transcribed"
+)
+
+// ApplyCallTranscript stores a transcript on the call, extracts order details
+// from it and writes them into the call's draft order, creating it if needed
+func ApplyCallTranscript(ctx context.Context, call *models.CallLog, transcript, source string) (*models.DraftOrder, StructuredExtraction, error) {
+	transcript = strings.TrimSpace(transcript)
+	if transcript == "" {
+		return nil, StructuredExtraction{}, fmt.Errorf("transcript is empty")
+	}
+
+	if call.OrderID != nil {
+		ctx = WithAIOrder(ctx, *call.OrderID)
+	}
+	result, err := ExtractOrderDetails(ctx, transcript, "call")
+	if err != nil {
+		return nil, result, err
+	}
+	extraction := result.Order
+
+	draft := &models.DraftOrder{
+		Source:     "call",
+		Status:     DraftPending,
+		ImageFiles: models.StringList{},
+	}
+	if call.DraftOrderID != nil {
+		var existing models.DraftOrder
+		if err := config.DB.First(&existing, *call.DraftOrderID).Error; err != nil {
+			return nil, result, fmt.Errorf("failed to load draft order: %v", err)
+		}
+		// A converted or discarded draft is history; start a fresh one
+		if existing.Status == DraftPending {
+			draft = &existing
+		}
+	}
+
+	draft.PhoneNumber = call.PhoneNumber
+	draft.CustomerName = call.CustomerName
+	if extraction.CustomerName != "" && draft.CustomerName == "" {
+		draft.CustomerName = extraction.CustomerName
+	}
+	// Only take values that passed validation
+	invalid := map[string]bool{}
+	for _, fe := range result.Errors {
+		invalid[fe.Field] = true
+	}
+	if extraction.Length != nil && extraction.Width != nil && !invalid["length"] && !invalid["width"] {
+		draft.Length, draft.Width = extraction.Length, extraction.Width
+	}
+	if extraction.Thickness != "" && !invalid["thickness"] {
+		draft.Thickness = extraction.Thickness
+	}
+	if extraction.CornerStyle != "" && !invalid["corner_style"] {
+		draft.CornerStyle = extraction.CornerStyle
+	}
+	draft.Notes = strings.TrimSpace(strings.Join([]string{call.Notes, extraction.Notes}, "\n"))
+	draft.ExtractedText = transcript
+
+	if err := config.DB.Save(draft).Error; err != nil {
+		return nil, result, fmt.Errorf("failed to save draft order: %v", err)
+	}
+
+	raw, _ := json.Marshal(result)
+	call.Transcript = transcript
+	call.TranscriptSource = source
+	call.Extraction = models.JSONRaw(raw)
+	call.DraftOrderID = &draft.ID
+	if call.CustomerName == "" {
+		call.CustomerName = draft.CustomerName
+	}
+
+	if err := config.DB.Save(call).Error; err != nil {
+		return draft, result, fmt.Errorf("failed to update call log: %v", err)
+	}
+	return draft, result, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"github.com/twinj/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrChatSessionNotFound is returned for unknown, expired or other users' sessions
+var ErrChatSessionNotFound = errors.New("chat session not found or expired")
+
+const (
+	chatSessionTTL   = 24 * time.Hour
+	chatHistoryLimit = 20 // earlier messages sent back to the model with each turn
+)
+
+// ChatResult is one assistant turn of a staff chat
+type ChatResult struct {
+	SessionID string            `json:"session_id"`
+	Response  models.AIResponse `json:"response"`
+}
+
+// OpenChatSession returns the user's active session with the given ID, or a
+// new session when sessionID is empty
+func OpenChatSession(userID uint, sessionID string) (*models.ConversationSession, error) {
+	now := time.Now()
+	if sessionID == "" {
+		session := &models.ConversationSession{
+			SessionID: strings.ReplaceAll(uuid.NewV4().String(), "-", ""),
+			UserID:    userID,
+			Active:    true,
+			ExpiresAt: now.Add(chatSessionTTL),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := config.DB.Create(session).Error; err != nil {
+			return nil, fmt.Errorf("failed to create chat session: %v", err)
+		}
+		return session, nil
+	}
+
+	var session models.ConversationSession
+	err := config.DB.Where("session_id = ? AND user_id = ? AND active AND expires_at > ?", sessionID, userID, now).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrChatSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ChatHistory returns a session's messages, oldest first
+func ChatHistory(sessionID string) ([]models.ConversationMessage, error) {
+	var messages []models.ConversationMessage
+	err := config.DB.Where("session_id = ?", sessionID).Order("timestamp, id").Find(&messages).Error
+	return messages, err
+}
+
+// Chat sends a staff message with the session's recent history and stores
+// both turns. With onDelta set the answer is streamed to it; an answer the
+// caller cancels is stored as far as it got.
+func Chat(ctx context.Context, session *models.ConversationSession, message string, onDelta AIDeltaFunc) (ChatResult, error) {
+	result := ChatResult{SessionID: session.SessionID}
+	if aiService.APIKey == "" {
+		return result, fmt.Errorf("%w: OpenAI API key not configured", ErrAIUnavailable)
+	}
+
+	var history []models.ConversationMessage
+	err := config.DB.Where("session_id = ?", session.SessionID).
+		Order("timestamp DESC, id DESC").Limit(chatHistoryLimit).Find(&history).Error
+	if err != nil {
+		return result, fmt.Errorf("failed to load chat history: %v", err)
+	}
+
+	system, versions := chatSystemPrompt()
+	messages := []Message{{Role: "system", Content: []ContentItem{{Type: "text", Text: &system}}}}
+	for i := len(history) - 1; i >= 0; i-- {
+		content := history[i].Content
+		messages = append(messages, Message{Role: history[i].Role, Content: []ContentItem{{Type: "text", Text: &content}}})
+	}
+	messages = append(messages, Message{Role: "user", Content: []ContentItem{{Type: "text", Text: &message}}})
+
+	resp, err := runCompletion(ctx, AIFeatureChat, OpenAIRequest{
+		Model:       aiService.Model,
+		Temperature: aiService.Temperature,
+		MaxTokens:   aiService.MaxTokens,
+		Messages:    messages,
+	}, onDelta)
+
+	record := models.AIResponse{
+		InputMessage:   message,
+		Tone:           "friendly",
+		Feature:        AIFeatureChat,
+		SessionID:      &session.SessionID,
+		Status:         AIResponseCompleted,
+		PromptVersions: versions,
+		Language:       DetectLanguage(message).Code,
+	}
+	if err != nil {
+		if resp == nil || resp.Choices[0].Message.Content == "" {
+			return result, err
+		}
+		record.Status = streamStatus(ctx)
+	}
+	record.Response, record.Model = resp.Choices[0].Message.Content, resp.Model
+	record.ReplyLanguage = DetectLanguage(record.Response).Code
+
+	saveChatTurn(session, message, record.Response, resp.Usage)
+	storeAIResponse(ctx, &record)
+	result.Response = record
+	return result, err
+}
+
+// saveChatTurn stores the staff message and the answer with their detected
+// languages, and keeps the session alive
+func saveChatTurn(session *models.ConversationSession, message, answer string, usage Usage) {
+	now := time.Now()
+	turn := []models.ConversationMessage{
+		{SessionID: session.SessionID, Role: "user", Content: message, Timestamp: now, TokenCount: usage.PromptTokens,
+			Language: DetectLanguage(message).Code},
+		{SessionID: session.SessionID, Role: "assistant", Content: answer, Timestamp: now.Add(time.Microsecond), TokenCount: usage.CompletionTokens,
+			Language: DetectLanguage(answer).Code},
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&turn).Error; err != nil {
+			return err
+		}
+		return tx.Model(session).Updates(map[string]interface{}{"expires_at": now.Add(chatSessionTTL)}).Error
+	})
+	if err != nil {
+		log.Printf("Chat: failed to save turn for session %s: %v", session.SessionID, err)
+	}
+}
+
+// chatSystemPrompt renders the staff chat prompt around the system prompt
+func chatSystemPrompt() (string, models.PromptVersions) {
+	vars := promptVars()
+	system := RenderPrompt(PromptSystem, vars)
+	vars.System = system.Text
+	chat := RenderPrompt(PromptChat, vars)
+	return chat.Text, models.PromptVersions{system.Name: system.Version, chat.Name: chat.Version}
+}
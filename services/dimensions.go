@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"customflow/models"
+)
+
+// Dimension units. Orders are stored in inches; the unit the customer used is kept alongside.
+const (
+	UnitInches      = "in"
+	UnitCentimeters = "cm"
+	UnitMillimeters = "mm"
+	UnitMeters      = "m"
+	UnitFeet        = "ft"
+	UnitFeetInches  = "ft-in"
+	UnitMixed       = "mixed"
+)
+
+// DimensionUnits lists the units accepted as a default unit for bare numbers
+var DimensionUnits = []string{UnitInches, UnitCentimeters, UnitMillimeters, UnitMeters, UnitFeet}
+
+var inchesPerUnit = map[string]float64{
+	UnitInches:      1,
+	UnitCentimeters: 1 / 2.54,
+	UnitMillimeters: 1 / 25.4,
+	UnitMeters:      1 / 0.0254,
+	UnitFeet:        12,
+}
+
+// Dimension is one measurement as the customer wrote it and in inches
+type Dimension struct {
+	Inches float64 `json:"inches"`
+	Input  string  `json:"input"`
+	Unit   string  `json:"unit"`
+}
+
+const (
+	unitPattern   = `(?:cm|mm|m|inches|inch|in|"|”|″|''|feet|foot|ft|'|’|′)`
+	numberPattern = `(?:\d+(?:\.\d+)?(?:[\s-]+\d+/\d+)?|\d+/\d+)`
+	// 4'6", 4 ft 6 in, 4ft 6 1/2"
+	feetInchesPattern = `\d+(?:\.\d+)?\s*(?:feet|foot|ft|'|’|′)\s*` + numberPattern + `\s*(?:inches|inch|in|"|”|″|'')?`
+	dimensionPattern  = `(?:` + feetInchesPattern + `|` + numberPattern + `(?:\s*` + unitPattern + `)?)`
+)
+
+var (
+	feetInchesRegexp = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(?:feet|foot|ft|'|’|′)\s*(` + numberPattern + `)\s*(?:inches|inch|in|"|”|″|'')?$`)
+	dimensionRegexp  = regexp.MustCompile(`(?i)^(` + numberPattern + `)\s*(` + unitPattern + `)?$`)
+	fractionRegexp   = regexp.MustCompile(`^(?:(\d+(?:\.\d+)?)[\s-]+)?(\d+)/(\d+)$`)
+
+	// "4ft x 2.5ft", "120 x 60 cm", "48 1/2 x 30 inches"; the trailing unit applies to unitless sides
+	dimensionPairRegexp = regexp.MustCompile(`(?i)(` + dimensionPattern + `)\s*(?:x|×|\*|by)\s*(` + dimensionPattern + `)(?:\s*(` + unitPattern + `))?(?:[^a-z]|$)`)
+)
+
+// ParseDimension reads one measurement such as "48", "48 1/2 in", "120cm", "4ft",
+// "4' 6\"" or "1.2 m". Bare numbers are taken in defaultUnit (inches if empty),
+// and Input then names the unit.
+func ParseDimension(input, defaultUnit string) (Dimension, error) {
+	input = strings.TrimSpace(input)
+	d := Dimension{Input: input}
+	if input == "" {
+		return d, fmt.Errorf("empty dimension")
+	}
+
+	if m := feetInchesRegexp.FindStringSubmatch(input); m != nil {
+		feet, _ := strconv.ParseFloat(m[1], 64)
+		inches, err := parseNumber(m[2])
+		if err != nil {
+			return d, err
+		}
+		d.Inches = round2(feet*12 + inches)
+		d.Unit = UnitFeetInches
+		return d, validDimension(d)
+	}
+
+	m := dimensionRegexp.FindStringSubmatch(input)
+	if m == nil {
+		return d, fmt.Errorf("cannot read dimension %q", input)
+	}
+
+	value, err := parseNumber(m[1])
+	if err != nil {
+		return d, err
+	}
+
+	d.Unit = normalizeUnit(m[2])
+	if d.Unit == "" {
+		// Keep the input self-describing: "120" in cm is recorded as "120 cm"
+		if d.Unit = normalizeUnit(defaultUnit); d.Unit != "" {
+			d.Input += " " + d.Unit
+		}
+	}
+	if d.Unit == "" {
+		d.Unit = UnitInches
+	}
+	factor, ok := inchesPerUnit[d.Unit]
+	if !ok {
+		return d, fmt.Errorf("unknown unit %q", defaultUnit)
+	}
+
+	d.Inches = round2(value * factor)
+	return d, validDimension(d)
+}
+
+// ParseDimensionPair reads "length x width" text such as "4ft x 2.5ft" or "120 x 60 cm"
+func ParseDimensionPair(input, defaultUnit string) (Dimension, Dimension, error) {
+	m := dimensionPairRegexp.FindStringSubmatch(strings.TrimSpace(input))
+	if m == nil {
+		return Dimension{}, Dimension{}, fmt.Errorf("cannot read dimensions %q, expected e.g. 48 x 30 in", input)
+	}
+	return parseDimensionPairMatch(m, defaultUnit)
+}
+
+// Bare "N x M" in free text is only read as a table size in inches when both
+// sides fall in this range, so "5x7 photo" or "2 x 3 pack" are not dimensions
+const (
+	minBareTableInches = 12
+	maxBareTableInches = 180
+)
+
+// FindDimensionPair looks for the first "length x width" in free text such as
+// OCR output. A pair needs a unit on either side or after it, or a plausible
+// table size in inches.
+func FindDimensionPair(text string) (Dimension, Dimension, bool) {
+	for _, m := range dimensionPairRegexp.FindAllStringSubmatch(text, -1) {
+		length, width, err := parseDimensionPairMatch(m, "")
+		if err != nil {
+			continue
+		}
+		if m[3] != "" || hasUnit(m[1]) || hasUnit(m[2]) || (plausibleTableSide(length) && plausibleTableSide(width)) {
+			return length, width, true
+		}
+	}
+	return Dimension{}, Dimension{}, false
+}
+
+func plausibleTableSide(d Dimension) bool {
+	return d.Inches >= minBareTableInches && d.Inches <= maxBareTableInches
+}
+
+func parseDimensionPairMatch(m []string, defaultUnit string) (Dimension, Dimension, error) {
+	unit := defaultUnit
+	if m[3] != "" {
+		unit = m[3]
+	}
+	length, err := ParseDimension(m[1], unit)
+	if err != nil {
+		return length, Dimension{}, err
+	}
+	width, err := ParseDimension(m[2], unit)
+	if err != nil {
+		return length, width, err
+	}
+	if m[3] != "" {
+		return length, width, nil
+	}
+
+	switch {
+	// "4 x 2.5ft" means 4 feet
+	case dimensionRegexp.MatchString(m[1]) && !hasUnit(m[1]) && hasUnit(m[2]):
+		length, err = ParseDimension(m[1], width.Unit)
+	// "120cm x 60" means 60 cm, but "4ft x 30" and "4'6\" x 30" mean 30 inches
+	case hasUnit(m[1]) && dimensionRegexp.MatchString(m[2]) && !hasUnit(m[2]):
+		carried := length.Unit
+		if carried == UnitFeet || carried == UnitFeetInches {
+			carried = UnitInches
+		}
+		if carried != width.Unit {
+			width, err = ParseDimension(m[2], carried)
+		}
+	}
+	return length, width, err
+}
+
+// CombinedUnit describes the units of a pair: the shared unit, or "mixed"
+func CombinedUnit(a, b Dimension) string {
+	if a.Unit == b.Unit {
+		return a.Unit
+	}
+	return UnitMixed
+}
+
+// ConvertInches returns the conversions for a length in inches
+func ConvertInches(inches float64) models.DimensionConversions {
+	return models.DimensionConversions{
+		Inches:      round2(inches),
+		Centimeters: round2(inches * 2.54),
+		Millimeters: round2(inches * 25.4),
+		Feet:        round2(inches / 12),
+	}
+}
+
+// AnnotateDimensions fills the computed unit conversions on an order
+func AnnotateDimensions(order *models.Order) {
+	order.Conversions = &models.OrderConversions{
+		Length: ConvertInches(order.Length),
+		Width:  ConvertInches(order.Width),
+	}
+}
+
+func parseNumber(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if m := fractionRegexp.FindStringSubmatch(s); m != nil {
+		whole := 0.0
+		if m[1] != "" {
+			whole, _ = strconv.ParseFloat(m[1], 64)
+		}
+		num, _ := strconv.ParseFloat(m[2], 64)
+		den, _ := strconv.ParseFloat(m[3], 64)
+		if den == 0 {
+			return 0, fmt.Errorf("invalid fraction %q", s)
+		}
+		return whole + num/den, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func normalizeUnit(unit string) string {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "in", "inch", "inches", `"`, "”", "″", "''":
+		return UnitInches
+	case "cm":
+		return UnitCentimeters
+	case "mm":
+		return UnitMillimeters
+	case "m":
+		return UnitMeters
+	case "ft", "feet", "foot", "'", "’", "′":
+		return UnitFeet
+	}
+	return ""
+}
+
+func hasUnit(s string) bool {
+	m := dimensionRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	return m == nil || m[2] != ""
+}
+
+func validDimension(d Dimension) error {
+	if d.Inches <= 0 || math.IsInf(d.Inches, 0) || math.IsNaN(d.Inches) {
+		return fmt.Errorf("dimension %q must be greater than 0", d.Input)
+	}
+	return nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,65 @@
+package services
+
+import "testing"
+
+func TestParseDimensionPair(t *testing.T) {
+	tests := []struct {
+		input       string
+		defaultUnit string
+		length      float64
+		width       float64
+		unit        string
+	}{
+		{"48 x 30", "", 48, 30, UnitInches},
+		{"48 x 30 in", "", 48, 30, UnitInches},
+		{"120 x 60 cm", "", 47.24, 23.62, UnitCentimeters},
+		{"120 x 60", "cm", 47.24, 23.62, UnitCentimeters},
+		{"120cm x 60", "", 47.24, 23.62, UnitCentimeters},
+		{"1200mm x 600", "", 47.24, 23.62, UnitMillimeters},
+		{"1.2m x 0.6", "", 47.24, 23.62, UnitMeters},
+		{"48in x 30", "cm", 48, 30, UnitInches},
+		{"4ft x 30", "", 48, 30, UnitMixed},
+		{"4ft x 30", "cm", 48, 30, UnitMixed},
+		{`4'6" x 30`, "", 54, 30, UnitMixed},
+		{"4 x 2.5ft", "", 48, 30, UnitFeet},
+		{"120cm x 24in", "", 47.24, 24, UnitMixed},
+		{"48 1/2 by 30", "", 48.5, 30, UnitInches},
+	}
+
+	for _, tt := range tests {
+		length, width, err := ParseDimensionPair(tt.input, tt.defaultUnit)
+		if err != nil {
+			t.Errorf("ParseDimensionPair(%q, %q) error: %v", tt.input, tt.defaultUnit, err)
+			continue
+		}
+		if length.Inches != tt.length || width.Inches != tt.width || CombinedUnit(length, width) != tt.unit {
+			t.Errorf("ParseDimensionPair(%q, %q) = %v x %v %s, want %v x %v %s", tt.input, tt.defaultUnit,
+				length.Inches, width.Inches, CombinedUnit(length, width), tt.length, tt.width, tt.unit)
+		}
+	}
+}
+
+func TestFindDimensionPair(t *testing.T) {
+	tests := []struct {
+		text   string
+		found  bool
+		length float64
+		width  float64
+	}{
+		{"Hi, I need a cover for my 48 x 30 dining table", true, 48, 30},
+		{"table is 120cm x 60, 2mm please", true, 47.24, 23.62},
+		{"sending a 5x7 photo of the table", false, 0, 0},
+		{"5x7 photo, table is 4ft x 30", true, 48, 30},
+		{"2 x 3 pack of coasters", false, 0, 0},
+		{"small side table 10 x 10 in", true, 10, 10},
+		{"no numbers here", false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		length, width, found := FindDimensionPair(tt.text)
+		if found != tt.found || length.Inches != tt.length || width.Inches != tt.width {
+			t.Errorf("FindDimensionPair(%q) = %v x %v, %v; want %v x %v, %v", tt.text,
+				length.Inches, width.Inches, found, tt.length, tt.width, tt.found)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// Draft order states
+const (
+	DraftPending   = "pending"
+	DraftConverted = "converted"
+	DraftDiscarded = "discarded"
+)
+
+// Messages from the same customer within this window extend the same draft
+const draftMergeWindow = 24 * time.Hour
+
+// DraftInput is one customer message feeding a draft order
+type DraftInput struct {
+	Source       string
+	PhoneNumber  string
+	CustomerName string
+	Text         string
+	ImageFiles   []string // filenames in the uploads directory
+}
+
+// UpdateDraftFromMessage adds a customer message to the customer's open draft
+// (or starts a new one), runs OCR on the attached images, fills in any order
+// fields it can recognise and refreshes the suggested reply.
+//
+// OCR and the suggested reply are provider calls, so they run first, on a
+// copy of the draft merged without the lock. Messages from the same customer are
+// then merged one at a time: a transaction holds an advisory lock on the
+// source and phone number while the draft is reloaded, merged and saved, so
+// messages arriving together extend a single draft. The reply saved is the
+// one generated last; it may miss a message merged while it was generated.
+func UpdateDraftFromMessage(ctx context.Context, in DraftInput) (*models.DraftOrder, error) {
+	parts := draftMessageParts(ctx, in)
+
+	current, err := findOpenDraft(config.DB.WithContext(ctx), in.Source, in.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		current = newDraft(in)
+	}
+	entry := draftEntry(in.Source, parts)
+	mergeDraftMessage(current, in, entry)
+	reply := suggestDraftReply(ctx, current, in, parts)
+
+	var draft *models.DraftOrder
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if in.PhoneNumber != "" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "draft_orders:"+in.Source+":"+in.PhoneNumber).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		draft, err = findOpenDraft(tx, in.Source, in.PhoneNumber)
+		if err != nil {
+			return err
+		}
+		if draft == nil {
+			draft = newDraft(in)
+		}
+		mergeDraftMessage(draft, in, entry)
+		if reply != "" {
+			draft.SuggestedReply = reply
+		}
+
+		if err := tx.Save(draft).Error; err != nil {
+			return fmt.Errorf("failed to save draft order: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Drafts: updated draft %d for %s (%s)", draft.ID, draft.PhoneNumber, draft.Source)
+	return draft, nil
+}
+
+func newDraft(in DraftInput) *models.DraftOrder {
+	return &models.DraftOrder{
+		Source:      in.Source,
+		PhoneNumber: in.PhoneNumber,
+		Status:      DraftPending,
+		ImageFiles:  models.StringList{},
+	}
+}
+
+// draftMessageParts is the message text followed by the OCR output of its images
+func draftMessageParts(ctx context.Context, in DraftInput) []string {
+	var parts []string
+	if text := strings.TrimSpace(in.Text); text != "" {
+		parts = append(parts, text)
+	}
+
+	if len(in.ImageFiles) > 0 {
+		ocrText, err := ExtractTextFromImages(ctx, in.ImageFiles)
+		if err != nil {
+			log.Printf("Drafts: OCR failed for %v: %v", in.ImageFiles, err)
+		} else {
+			parts = append(parts, ocrText)
+		}
+	}
+	return parts
+}
+
+// draftEntry is the conversation entry for one message, "" when it has no text
+func draftEntry(source string, parts []string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[%s %s]\n%s", source, time.Now().Format("2006-01-02 15:04"), strings.Join(parts, "\n\n"))
+}
+
+// mergeDraftMessage appends the message entry and images to the draft and
+// refreshes the recognised fields
+func mergeDraftMessage(draft *models.DraftOrder, in DraftInput, entry string) {
+	if in.CustomerName != "" {
+		draft.CustomerName = in.CustomerName
+	}
+	draft.ImageFiles = append(draft.ImageFiles, in.ImageFiles...)
+	if entry != "" {
+		draft.ExtractedText = strings.TrimSpace(draft.ExtractedText + "\n\n" + entry)
+	}
+	applyOrderHints(draft, ParseOrderHints(draft.ExtractedText))
+}
+
+// suggestDraftReply generates the reply to the merged draft, "" when there is
+// nothing to reply to or generation failed
+func suggestDraftReply(ctx context.Context, draft *models.DraftOrder, in DraftInput, parts []string) string {
+	if draft.ExtractedText == "" {
+		return ""
+	}
+
+	// The prompt adds English notes, so the language comes from the customer's own words
+	opts := ReplyOptions{
+		Language: DetectLanguage(strings.Join(parts, "\n")).Code,
+		Images:   replyImageFiles(in.ImageFiles),
+	}
+	reply, err := GenerateAIResponse(ctx, draftReplyPrompt(draft), opts)
+	if err != nil {
+		log.Printf("Drafts: failed to generate suggested reply: %v", err)
+		return ""
+	}
+	return reply
+}
+
+func findOpenDraft(tx *gorm.DB, source, phone string) (*models.DraftOrder, error) {
+	if phone == "" {
+		return nil, nil
+	}
+
+	var draft models.DraftOrder
+	err := tx.
+		Where("source = ? AND phone_number = ? AND status = ?", source, phone, DraftPending).
+		Where("updated_at > ?", time.Now().Add(-draftMergeWindow)).
+		Order("updated_at DESC").
+		First(&draft).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+func applyOrderHints(draft *models.DraftOrder, hints OrderHints) {
+	if hints.Length != nil && hints.Width != nil {
+		draft.Length, draft.Width = hints.Length, hints.Width
+	}
+	if hints.Thickness != "" {
+		draft.Thickness = hints.Thickness
+	}
+	if hints.CornerStyle != "" {
+		draft.CornerStyle = hints.CornerStyle
+	}
+}
+
+// DraftMissingFields lists the order details a draft still lacks
+func DraftMissingFields(draft *models.DraftOrder) []string {
+	var missing []string
+	if draft.Length == nil || draft.Width == nil {
+		missing = append(missing, "table length and width (inches, cm or feet)")
+	}
+	if draft.Thickness == "" {
+		missing = append(missing, "thickness")
+	}
+	if draft.CornerStyle == "" {
+		missing = append(missing, "corner style")
+	}
+	return missing
+}
+
+// draftReplyPrompt gives the reply model the conversation plus what is still missing
+func draftReplyPrompt(draft *models.DraftOrder) string {
+	prompt := draft.ExtractedText
+	if missing := DraftMissingFields(draft); len(missing) > 0 {
+		prompt += "\n\n(Still needed from the customer: " + strings.Join(missing, ", ") + ")"
+	}
+	return prompt
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"customflow/models"
+)
+
+// Order event types
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderUpdated       = "order.updated"
+	EventOrderStatusChanged = "order.status_changed"
+	EventOrderDeleted       = "order.deleted"
+)
+
+// Event is published after an order change has been committed
+type Event struct {
+	Type       string       `json:"type"`
+	Order      models.Order `json:"order"`
+	OldStatus  string       `json:"old_status,omitempty"`
+	UserID     uint         `json:"user_id"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// EventHandler receives published events. Handlers run in their own goroutine.
+type EventHandler func(ctx context.Context, e Event)
+
+// EventBus is an in-process publish/subscribe hub for order events
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+	timeout  time.Duration
+}
+
+// Subscribe to all event types with this wildcard
+const AllEvents = "*"
+
+var eventBus = NewEventBus()
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: map[string][]EventHandler{},
+		timeout:  2 * time.Minute,
+	}
+}
+
+// Subscribe registers a handler for an event type, or AllEvents
+func (b *EventBus) Subscribe(eventType string, handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish hands the event to every matching handler without blocking the caller
+func (b *EventBus) Publish(e Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := append(append([]EventHandler{}, b.handlers[e.Type]...), b.handlers[AllEvents]...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		go b.run(handler, e)
+	}
+}
+
+func (b *EventBus) run(handler EventHandler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("EventBus: handler for %s panicked: %v", e.Type, r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	handler(ctx, e)
+}
+
+// PublishEvent publishes on the application event bus
+func PublishEvent(e Event) {
+	log.Printf("Event: %s for order %s", e.Type, e.Order.OrderID)
+	eventBus.Publish(e)
+}
+
+// SubscribeEvent subscribes to the application event bus
+func SubscribeEvent(eventType string, handler EventHandler) {
+	eventBus.Subscribe(eventType, handler)
+}
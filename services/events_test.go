@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"customflow/models"
+)
+
+// collect records the event types a handler saw
+type collect struct {
+	mu    sync.Mutex
+	wg    sync.WaitGroup
+	seen  map[string][]string
+	times []time.Time
+}
+
+func (c *collect) handler(name string) EventHandler {
+	return func(ctx context.Context, e Event) {
+		defer c.wg.Done()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.seen[name] = append(c.seen[name], e.Type)
+		c.times = append(c.times, e.OccurredAt)
+	}
+}
+
+func waitFor(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handlers did not run")
+	}
+}
+
+func TestEventBusFanOut(t *testing.T) {
+	bus := NewEventBus()
+	c := &collect{seen: map[string][]string{}}
+	bus.Subscribe(EventOrderCreated, c.handler("created"))
+	bus.Subscribe(EventOrderCreated, c.handler("created2"))
+	bus.Subscribe(EventOrderDeleted, c.handler("deleted"))
+	bus.Subscribe(AllEvents, c.handler("all"))
+
+	// created: both created handlers and the wildcard; status_changed: the wildcard only
+	c.wg.Add(4)
+	bus.Publish(Event{Type: EventOrderCreated, Order: models.Order{OrderID: "CF-1"}})
+	bus.Publish(Event{Type: EventOrderStatusChanged, Order: models.Order{OrderID: "CF-1"}})
+	waitFor(t, &c.wg)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if got := c.seen["created"]; len(got) != 1 || got[0] != EventOrderCreated {
+		t.Errorf("created handler saw %v", got)
+	}
+	if got := c.seen["created2"]; len(got) != 1 {
+		t.Errorf("second created handler saw %v", got)
+	}
+	if got := c.seen["deleted"]; len(got) != 0 {
+		t.Errorf("deleted handler saw %v", got)
+	}
+	if got := c.seen["all"]; len(got) != 2 {
+		t.Errorf("wildcard handler saw %v, want both events", got)
+	}
+	for _, at := range c.times {
+		if at.IsZero() {
+			t.Error("Publish did not set OccurredAt")
+		}
+	}
+}
+
+func TestEventBusRecoversHandlerPanic(t *testing.T) {
+	bus := NewEventBus()
+	c := &collect{seen: map[string][]string{}}
+	panicked := make(chan struct{}, 2)
+	bus.Subscribe(EventOrderUpdated, func(ctx context.Context, e Event) {
+		panicked <- struct{}{}
+		panic("boom")
+	})
+	bus.Subscribe(EventOrderUpdated, c.handler("ok"))
+
+	// The panicking handler must neither stop the other handler nor later events
+	c.wg.Add(2)
+	bus.Publish(Event{Type: EventOrderUpdated})
+	bus.Publish(Event{Type: EventOrderUpdated})
+	waitFor(t, &c.wg)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-panicked:
+		case <-time.After(5 * time.Second):
+			t.Fatal("panicking handler did not run for every event")
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if got := c.seen["ok"]; len(got) != 2 {
+		t.Errorf("healthy handler saw %v, want both events", got)
+	}
+}
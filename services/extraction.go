@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"customflow/models"
+)
+
+// OrderExtraction is the typed order the model found in text or images
+type OrderExtraction struct {
+	OrderID      string   `json:"order_id"`
+	CustomerName string   `json:"customer_name"`
+	PhoneNumber  string   `json:"phone_number"`
+	Source       string   `json:"source"`
+	Length       *float64 `json:"length"` // inches
+	Width        *float64 `json:"width"`  // inches
+	LengthInput  string   `json:"length_input"`
+	WidthInput   string   `json:"width_input"`
+	Unit         string   `json:"unit"`
+	Thickness    string   `json:"thickness"`
+	CornerStyle  string   `json:"corner_style"`
+	Notes        string   `json:"notes"`
+}
+
+// ExtractionInput is what to extract from: text, images in the uploads directory, or both
+type ExtractionInput struct {
+	Text   string
+	Images []string
+	Source string // order source to assume when the content does not say
+}
+
+// StructuredExtraction is the result of a structured extraction, with the raw
+// model output kept for audit
+type StructuredExtraction struct {
+	Order        OrderExtraction     `json:"order"`
+	RawText      string              `json:"raw_text"`
+	Valid        bool                `json:"valid"`
+	Errors       []models.FieldError `json:"errors,omitempty"`
+	Missing      []string            `json:"missing,omitempty"`
+	Attempts     int                 `json:"attempts"`
+	Method       string              `json:"method"` // "llm" or "pattern"
+	RawResponses []string            `json:"raw_responses,omitempty"`
+}
+
+// How many times the model may answer before the last answer is returned as is
+const maxExtractionAttempts = 3
+
+// ExtractOrderDetails extracts an order from free text such as a call transcript.
+// Without an API key, or if the model cannot be reached, it falls back to ParseOrderHints.
+func ExtractOrderDetails(ctx context.Context, text, source string) (StructuredExtraction, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return StructuredExtraction{}, fmt.Errorf("no text to extract from")
+	}
+
+	if aiService.APIKey != "" {
+		result, err := ExtractStructuredOrder(ctx, ExtractionInput{Text: text, Source: source})
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("Extraction: LLM extraction failed, using pattern matching: %v", err)
+	}
+
+	hints := ParseOrderHints(text)
+	result := StructuredExtraction{
+		Order: OrderExtraction{
+			Source:      source,
+			Length:      hints.Length,
+			Width:       hints.Width,
+			LengthInput: hints.LengthInput,
+			WidthInput:  hints.WidthInput,
+			Unit:        hints.Unit,
+			Thickness:   hints.Thickness,
+			CornerStyle: hints.CornerStyle,
+		},
+		RawText: text,
+		Method:  "pattern",
+	}
+	result.Errors, result.Missing = validateExtraction(result.Order)
+	result.Valid = len(result.Errors) == 0 && len(result.Missing) == 0
+	return result, nil
+}
+
+// ExtractStructuredOrder asks the model for JSON matching the order schema and
+// checks it against the order rules. Invalid answers are sent back with the
+// validation errors, up to maxExtractionAttempts times. Fields the content
+// does not mention are reported as missing and are not retried.
+func ExtractStructuredOrder(ctx context.Context, in ExtractionInput) (StructuredExtraction, error) {
+	var result StructuredExtraction
+	result.Method = "llm"
+
+	if aiService.APIKey == "" {
+		return result, fmt.Errorf("OpenAI API key not configured")
+	}
+	if strings.TrimSpace(in.Text) == "" && len(in.Images) == 0 {
+		return result, fmt.Errorf("no text or images provided")
+	}
+
+	content, err := extractionContent(in)
+	if err != nil {
+		return result, err
+	}
+
+	system := orderExtractionPrompt(in.Source)
+	messages := []Message{
+		{Role: "system", Content: []ContentItem{{Type: "text", Text: &system}}},
+		{Role: "user", Content: content},
+	}
+
+	for result.Attempts < maxExtractionAttempts {
+		result.Attempts++
+
+		resp, err := doOpenAIRequest(withAIFeature(ctx, AIFeatureExtraction), OpenAIRequest{
+			Model:       aiService.Model,
+			Temperature: 0.1,
+			MaxTokens:   1500,
+			Messages:    messages,
+			ResponseFormat: &ResponseFormat{
+				Type:       "json_schema",
+				JSONSchema: &JSONSchema{Name: "order_extraction", Strict: true, Schema: orderExtractionSchema()},
+			},
+		})
+		if err != nil {
+			return result, err
+		}
+
+		answer := resp.Choices[0].Message.Content
+		result.RawResponses = append(result.RawResponses, answer)
+
+		var feedback string
+		var parsed struct {
+			OrderExtraction
+			RawText string `json:"raw_text"`
+		}
+		if err := json.Unmarshal([]byte(answer), &parsed); err != nil {
+			feedback = "Your answer was not valid JSON for the schema: " + err.Error()
+		} else {
+			if parsed.Source == "" {
+				parsed.Source = in.Source
+			}
+			resolveExtractionDimensions(&parsed.OrderExtraction)
+			result.Order = parsed.OrderExtraction
+			result.RawText = parsed.RawText
+			result.Errors, result.Missing = validateExtraction(result.Order)
+			result.Valid = len(result.Errors) == 0 && len(result.Missing) == 0
+			if len(result.Errors) == 0 {
+				break
+			}
+			feedback = "Your answer failed validation: " + joinFieldErrors(result.Errors) +
+				". Rules: " + models.DescribeOrderRules() +
+				". Correct these fields; use null or \"\" if the value really is not stated."
+		}
+
+		log.Printf("Extraction: attempt %d rejected: %s", result.Attempts, feedback)
+		messages = append(messages,
+			Message{Role: "assistant", Content: []ContentItem{{Type: "text", Text: &answer}}},
+			Message{Role: "user", Content: []ContentItem{{Type: "text", Text: &feedback}}},
+		)
+	}
+
+	return result, nil
+}
+
+// validateExtraction runs the order rules on the fields the model filled in;
+// empty fields are returned as missing instead
+func validateExtraction(o OrderExtraction) ([]models.FieldError, []string) {
+	fields := models.OrderFields{Source: o.Source, Thickness: o.Thickness, CornerStyle: o.CornerStyle}
+	present := map[string]bool{
+		"source":       o.Source != "",
+		"length":       o.Length != nil || o.LengthInput != "",
+		"width":        o.Width != nil || o.WidthInput != "",
+		"thickness":    o.Thickness != "",
+		"corner_style": o.CornerStyle != "",
+	}
+	if o.Length != nil {
+		fields.Length = *o.Length
+	}
+	if o.Width != nil {
+		fields.Width = *o.Width
+	}
+
+	// Dimensions that were written down but could not be read
+	unreadable := map[string]bool{}
+	var errs []models.FieldError
+	for _, d := range []struct {
+		field, input string
+		inches       *float64
+	}{{"length", o.LengthInput, o.Length}, {"width", o.WidthInput, o.Width}} {
+		if d.inches == nil && d.input != "" {
+			unreadable[d.field] = true
+			errs = append(errs, models.FieldError{
+				Field:   d.field,
+				Message: fmt.Sprintf("Cannot read %s %q, expected a number with a unit such as 48 in, 120 cm or 4' 6\"", d.field, d.input),
+			})
+		}
+	}
+
+	for _, fe := range models.ValidateOrderFields(fields) {
+		if present[fe.Field] && !unreadable[fe.Field] {
+			errs = append(errs, fe)
+		}
+	}
+
+	var missing []string
+	for _, field := range []string{"source", "length", "width", "thickness", "corner_style"} {
+		if !present[field] {
+			missing = append(missing, field)
+		}
+	}
+	return errs, missing
+}
+
+// resolveExtractionDimensions converts the dimensions the model copied from
+// the content to inches. A bare number on one side takes the other side's
+// unit, and inches when neither has one.
+func resolveExtractionDimensions(o *OrderExtraction) {
+	o.Length, o.Width, o.Unit = nil, nil, ""
+	o.LengthInput, o.WidthInput = strings.TrimSpace(o.LengthInput), strings.TrimSpace(o.WidthInput)
+
+	if o.LengthInput != "" && o.WidthInput != "" {
+		if length, width, err := ParseDimensionPair(o.LengthInput+" x "+o.WidthInput, ""); err == nil {
+			o.Length, o.Width = &length.Inches, &width.Inches
+			o.Unit = CombinedUnit(length, width)
+			return
+		}
+	}
+	if length, err := ParseDimension(o.LengthInput, ""); err == nil {
+		o.Length, o.Unit = &length.Inches, length.Unit
+	}
+	if width, err := ParseDimension(o.WidthInput, ""); err == nil {
+		o.Width, o.Unit = &width.Inches, width.Unit
+	}
+}
+
+func extractionContent(in ExtractionInput) ([]ContentItem, error) {
+	intro := "Extract the order from the following content."
+	if text := strings.TrimSpace(in.Text); text != "" {
+		intro += "\n\n" + text
+	}
+	content := []ContentItem{{Type: "text", Text: &intro}}
+
+	for _, image := range in.Images {
+		dataURL, err := imageToBase64(image)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, ContentItem{
+			Type:     "image_url",
+			ImageURL: &ImageURL{URL: dataURL, Detail: "high"},
+		})
+	}
+	return content, nil
+}
+
+func orderExtractionPrompt(source string) string {
+	prompt := `You extract custom table cover orders from customer messages, screenshots and call transcripts.
+Answer with JSON matching the schema.
+- raw_text: every piece of text you can read in the content, verbatim, images separated by blank lines
+- length_input and width_input: the table size exactly as written, with its unit (e.g. "48 1/2 in", "120cm", "4' 6\""); if the unit is only given once, as in "120 x 60 cm", repeat it on both; "" if not stated
+- thickness, corner_style, source: "" if not stated
+- notes: anything else the workshop should know
+Do not guess values that are not in the content.
+Rules: ` + models.DescribeOrderRules() + "."
+	if source != "" {
+		prompt += "\nThe content came in through the " + source + " channel."
+	}
+	return prompt
+}
+
+func orderExtractionSchema() map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	enum := func(values []string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "enum": append([]string{""}, values...)}
+	}
+
+	properties := map[string]interface{}{
+		"raw_text":      str,
+		"order_id":      str,
+		"customer_name": str,
+		"phone_number":  str,
+		"source":        enum(models.OrderSources),
+		"length_input":  str,
+		"width_input":   str,
+		"thickness":     enum(models.OrderThicknesses),
+		"corner_style":  enum(models.OrderCornerStyles),
+		"notes":         str,
+	}
+	required := make([]string, 0, len(properties))
+	for key := range properties {
+		required = append(required, key)
+	}
+	sort.Strings(required)
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func joinFieldErrors(errs []models.FieldError) string {
+	parts := make([]string, len(errs))
+	for i, fe := range errs {
+		parts[i] = fe.Error()
+	}
+	return strings.Join(parts, "; ")
+}
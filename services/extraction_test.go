@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// extractionProvider answers each extraction request with the next of
+// answers, repeating the last, and records the messages it was sent
+func extractionProvider(t *testing.T, answers ...string) *[][]Message {
+	var requests [][]Message
+	withTestAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("provider got an invalid request: %v", err)
+		}
+		answer := answers[min(len(requests), len(answers)-1)]
+		requests = append(requests, req.Messages)
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []Choice{{Message: MessageResponse{Content: answer}}}})
+	})
+	return &requests
+}
+
+func messageText(m Message) string {
+	var parts []string
+	for _, item := range m.Content {
+		if item.Text != nil {
+			parts = append(parts, *item.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func TestExtractStructuredOrderRetriesWithFieldErrors(t *testing.T) {
+	invalid := `{"raw_text":"table 48 x 30 in, 4mm, oval","order_id":"","customer_name":"Ann","phone_number":"","source":"sms","length_input":"48 in","width_input":"thirty","thickness":"4mm","corner_style":"oval","notes":""}`
+	valid := `{"raw_text":"table 48 x 30 in, 3mm, rounded","order_id":"","customer_name":"Ann","phone_number":"","source":"sms","length_input":"48 in","width_input":"30 in","thickness":"3mm","corner_style":"rounded","notes":""}`
+	requests := extractionProvider(t, invalid, valid)
+
+	result, err := ExtractStructuredOrder(context.Background(), ExtractionInput{Text: "table 48 x 30 in", Source: "sms"})
+	if err != nil {
+		t.Fatalf("ExtractStructuredOrder: %v", err)
+	}
+	if result.Attempts != 2 || len(*requests) != 2 {
+		t.Fatalf("attempts = %d with %d requests, want 2", result.Attempts, len(*requests))
+	}
+	if !result.Valid || len(result.Errors) != 0 {
+		t.Errorf("result valid = %v, errors %v; want the corrected answer", result.Valid, result.Errors)
+	}
+	if result.Order.Thickness != "3mm" || result.Order.Width == nil || *result.Order.Width != 30 {
+		t.Errorf("order = %+v, want the second answer", result.Order)
+	}
+
+	// The retry repeats the rejected answer and names every failed field
+	retry := (*requests)[1]
+	if len(retry) != 4 || retry[2].Role != "assistant" || messageText(retry[2]) != invalid {
+		t.Fatalf("retry messages = %d, want system, user, the rejected answer and the feedback", len(retry))
+	}
+	feedback := messageText(retry[3])
+	for _, want := range []string{"width: Cannot read width \"thirty\"", "thickness: Invalid thickness", "corner_style: Invalid corner style"} {
+		if !strings.Contains(feedback, want) {
+			t.Errorf("feedback %q does not mention %q", feedback, want)
+		}
+	}
+}
+
+func TestExtractStructuredOrderStopsAfterMaxAttempts(t *testing.T) {
+	requests := extractionProvider(t,
+		"not json",
+		`{"raw_text":"","order_id":"","customer_name":"","phone_number":"","source":"fax","length_input":"","width_input":"","thickness":"","corner_style":"","notes":""}`,
+	)
+
+	result, err := ExtractStructuredOrder(context.Background(), ExtractionInput{Text: "hello", Source: "sms"})
+	if err != nil {
+		t.Fatalf("ExtractStructuredOrder: %v", err)
+	}
+	if result.Attempts != maxExtractionAttempts || len(*requests) != maxExtractionAttempts {
+		t.Fatalf("attempts = %d with %d requests, want %d", result.Attempts, len(*requests), maxExtractionAttempts)
+	}
+	if result.Valid || len(result.Errors) != 1 || result.Errors[0].Field != "source" {
+		t.Errorf("result valid = %v, errors %v; want the last answer's source error", result.Valid, result.Errors)
+	}
+	if len(result.RawResponses) != maxExtractionAttempts {
+		t.Errorf("kept %d raw responses, want %d", len(result.RawResponses), maxExtractionAttempts)
+	}
+	if feedback := messageText((*requests)[1][3]); !strings.Contains(feedback, "not valid JSON") {
+		t.Errorf("feedback after unparseable answer = %q", feedback)
+	}
+}
+
+func TestExtractStructuredOrderMissingFieldsNotRetried(t *testing.T) {
+	requests := extractionProvider(t,
+		`{"raw_text":"hi","order_id":"","customer_name":"","phone_number":"","source":"","length_input":"","width_input":"","thickness":"","corner_style":"","notes":""}`,
+	)
+
+	result, err := ExtractStructuredOrder(context.Background(), ExtractionInput{Text: "hi", Source: "whatsapp"})
+	if err != nil {
+		t.Fatalf("ExtractStructuredOrder: %v", err)
+	}
+	if result.Attempts != 1 || len(*requests) != 1 {
+		t.Errorf("attempts = %d, want 1 when fields are only missing", result.Attempts)
+	}
+	if result.Valid || len(result.Missing) != 4 || result.Order.Source != "whatsapp" {
+		t.Errorf("result = %+v, want source defaulted and 4 fields missing", result)
+	}
+}
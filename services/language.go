@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Language is a language and the script it is written in
+type Language struct {
+	Code      string `json:"code"` // e.g. "ta", or "ta-Latn" for Tamil typed in English letters
+	Name      string `json:"name"`
+	Romanized bool   `json:"romanized"`
+}
+
+// LanguageDetection is the detected language of a text
+type LanguageDetection struct {
+	Language
+	Mixed      bool    `json:"mixed"` // also has a substantial amount of English
+	Confidence float64 `json:"confidence"`
+}
+
+// LanguageEnglish is assumed for replies unless the customer writes in another language
+const LanguageEnglish = "en"
+
+// LanguageUndetermined is stored for texts without letters, e.g. only numbers or emoji
+const LanguageUndetermined = "und"
+
+// Languages lists the languages replies and translations can be written in
+var Languages = []Language{
+	{Code: "en", Name: "English"},
+	{Code: "ta", Name: "Tamil"},
+	{Code: "ta-Latn", Name: "Tamil written in English letters (Tanglish)", Romanized: true},
+	{Code: "hi", Name: "Hindi"},
+	{Code: "hi-Latn", Name: "Hindi written in English letters (Hinglish)", Romanized: true},
+	{Code: "te", Name: "Telugu"},
+	{Code: "kn", Name: "Kannada"},
+	{Code: "ml", Name: "Malayalam"},
+}
+
+// LookupLanguage returns a supported language by code
+func LookupLanguage(code string) (Language, bool) {
+	for _, lang := range Languages {
+		if strings.EqualFold(lang.Code, code) {
+			return lang, true
+		}
+	}
+	return Language{}, false
+}
+
+func languageByCode(code string) Language {
+	lang, _ := LookupLanguage(code)
+	return lang
+}
+
+// Native scripts of the supported languages
+var languageScripts = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"ta", unicode.Tamil},
+	{"hi", unicode.Devanagari},
+	{"te", unicode.Telugu},
+	{"kn", unicode.Kannada},
+	{"ml", unicode.Malayalam},
+}
+
+// Common words of Tamil and Hindi as customers type them in English letters.
+// Words that are also common in English ("to", "me", "na") are left out.
+var romanizedWords = map[string]map[string]bool{
+	"ta-Latn": wordSet("enna epdi eppadi evlo evvalavu vanakkam nandri sollunga solunga venum vendum illa illai " +
+		"irukku iruku irukka enga engaluku unga ungaluku naan neenga romba seri sari anna akka thambi pannunga " +
+		"panna pannanum panren vanthu varum varuma varumaa kodunga anuppunga anupunga eppo ippo innum konjam " +
+		"machan thaan dhaan aama illaya ethana yenna yeppo kedaikuma kidaikuma mudiyuma aachu achu pochu"),
+	"hi-Latn": wordSet("hai hain kya kab nahi nahin mera meri mere mujhe aap aapka aapki bhai kitna kitne kitni " +
+		"chahiye karo kijiye kaise kyun kyon abhi aur yeh woh hoga hogi aayega ayega aayegi bhejo batao bataiye " +
+		"paisa ji haan theek thik accha acha liye wala wali raha rahi gaya gayi dijiye kaha kahan hum humara " +
+		"jaldi bhejiye milega milegi kripya dhanyavad shukriya"),
+}
+
+var englishWords = wordSet("the is are my your our when where what how will would can could please thanks thank " +
+	"order table cover size delivery deliver status send sent received yet still today tomorrow need want " +
+	"with for and this that it have has did does not any update")
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// DetectLanguage guesses the language of a customer message or OCR text.
+// Native scripts are recognised from their letters; Tamil and Hindi typed in
+// English letters from common words. Everything else in Latin letters is
+// taken to be English.
+func DetectLanguage(text string) LanguageDetection {
+	scriptLetters := map[string]int{}
+	latin, letters := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Mc, r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, script := range languageScripts {
+			if unicode.Is(script.table, r) {
+				scriptLetters[script.code]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return LanguageDetection{Language: Language{Code: LanguageUndetermined, Name: "Undetermined"}}
+	}
+
+	best, bestCount := "", 0
+	for _, script := range languageScripts {
+		if n := scriptLetters[script.code]; n > bestCount {
+			best, bestCount = script.code, n
+		}
+	}
+	if bestCount > 0 && float64(bestCount) >= 0.2*float64(letters) {
+		return LanguageDetection{
+			Language:   languageByCode(best),
+			Mixed:      float64(latin) >= 0.2*float64(letters),
+			Confidence: round2(float64(bestCount) / float64(letters-latin)),
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	english := 0
+	hits := map[string]int{}
+	for _, w := range words {
+		if englishWords[w] {
+			english++
+		}
+		for code, set := range romanizedWords {
+			if set[w] {
+				hits[code]++
+			}
+		}
+	}
+
+	best, bestCount = "", 0
+	for code, n := range hits {
+		if n > bestCount || (n == bestCount && code < best) {
+			best, bestCount = code, n
+		}
+	}
+	if bestCount >= 2 || (bestCount == 1 && len(words) <= 4) {
+		return LanguageDetection{
+			Language:   languageByCode(best),
+			Mixed:      english > 0 && english*3 >= len(words),
+			Confidence: round2(min(1, float64(bestCount)/float64(max(1, len(words)-english)))),
+		}
+	}
+
+	confidence := 0.5
+	if len(words) > 0 {
+		confidence = round2(min(1, 0.5+float64(english)/float64(len(words))))
+	}
+	return LanguageDetection{Language: languageByCode(LanguageEnglish), Confidence: confidence}
+}
+
+// replyLanguage is the language to reply in: the chosen one, or the
+// customer's when code is empty or "auto"
+func replyLanguage(code string, customer LanguageDetection) Language {
+	if lang, ok := LookupLanguage(code); ok {
+		return lang
+	}
+	if customer.Code == LanguageUndetermined {
+		return languageByCode(LanguageEnglish)
+	}
+	return customer.Language
+}
+
+// Translation is a customer's text translated for staff
+type Translation struct {
+	Source        LanguageDetection `json:"source"`
+	Target        Language          `json:"target"`
+	Text          string            `json:"text"`
+	Model         string            `json:"model,omitempty"` // empty when the text was already in the target language
+	PromptVersion int               `json:"prompt_version"`
+}
+
+// Translate translates text into a supported language with the AI provider
+func Translate(ctx context.Context, text, target string) (Translation, error) {
+	lang, ok := LookupLanguage(target)
+	if !ok {
+		return Translation{}, fmt.Errorf("unsupported language %q", target)
+	}
+	result := Translation{Source: DetectLanguage(text), Target: lang, Text: text}
+	if (result.Source.Code == lang.Code && !result.Source.Mixed) || result.Source.Code == LanguageUndetermined {
+		return result, nil
+	}
+	if aiService.APIKey == "" {
+		return result, fmt.Errorf("%w: OpenAI API key not configured", ErrAIUnavailable)
+	}
+
+	vars := promptVars()
+	vars.Message, vars.CustomerLanguage, vars.Language = text, result.Source.Language, lang
+	prompt := RenderPrompt(PromptTranslate, vars)
+	resp, err := doOpenAIRequest(withAIFeature(ctx, AIFeatureTranslate), OpenAIRequest{
+		Model:       aiService.Model,
+		Temperature: 0,
+		MaxTokens:   aiService.MaxTokens,
+		Messages: []Message{
+			systemMessage(prompt.Text),
+			{Role: "user", Content: []ContentItem{{Type: "text", Text: &text}}},
+		},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Text = strings.TrimSpace(resp.Choices[0].Message.Content)
+	result.Model = resp.Model
+	result.PromptVersion = prompt.Version
+	return result, nil
+}
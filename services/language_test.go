@@ -0,0 +1,47 @@
+package services
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantCode  string
+		wantMixed bool
+	}{
+		{"tamil script", "எனக்கு ஒரு மேஜை உறை வேண்டும்", "ta", false},
+		{"devanagari", "मुझे टेबल कवर चाहिए", "hi", false},
+		{"tamil script with english", "My order எப்போது வரும்? please update the delivery status", "ta", true},
+		{"tanglish", "Enna size venum anna", "ta-Latn", false},
+		{"tanglish greeting", "Vanakkam!", "ta-Latn", false},
+		{"hinglish", "Mera order kab aayega bhai", "hi-Latn", false},
+		{"tanglish mixed with english", "order status enna, when will it come? delivery eppo", "ta-Latn", true},
+		{"english", "When will my table cover be delivered?", "en", false},
+		{"english with a hindi-looking word", "Please send the cover to my office, thanks ji and have a good day", "en", false},
+		{"digits only", "9876543210", "und", false},
+		{"digits and emoji", "👍 100 / 200", "und", false},
+		{"empty", "", "und", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectLanguage(tt.text)
+			if got.Code != tt.wantCode || got.Mixed != tt.wantMixed {
+				t.Errorf("DetectLanguage(%q) = %s (mixed %v), want %s (mixed %v)",
+					tt.text, got.Code, got.Mixed, tt.wantCode, tt.wantMixed)
+			}
+		})
+	}
+}
+
+func TestReplyLanguage(t *testing.T) {
+	tamil := DetectLanguage("எனக்கு ஒரு மேஜை உறை வேண்டும்")
+	if got := replyLanguage("", tamil); got.Code != "ta" {
+		t.Errorf("auto reply language = %s, want ta", got.Code)
+	}
+	if got := replyLanguage("en", tamil); got.Code != "en" {
+		t.Errorf("chosen reply language = %s, want en", got.Code)
+	}
+	if got := replyLanguage("auto", DetectLanguage("12345")); got.Code != LanguageEnglish {
+		t.Errorf("reply language for undetermined text = %s, want en", got.Code)
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MarketplaceOrder is an order as reported by a marketplace, before mapping
+type MarketplaceOrder struct {
+	ExternalID    string
+	CustomerName  string
+	PhoneNumber   string
+	PurchasedAt   time.Time
+	UpdatedAt     time.Time
+	Customization string // buyer customization text, parsed with ParseOrderHints
+	Notes         string
+}
+
+// MarketplaceConnector pulls orders from one marketplace
+type MarketplaceConnector interface {
+	// Name is the connector key and the order source it creates orders for
+	Name() string
+	// FetchOrders returns orders changed since the cursor and the cursor to resume from.
+	// On a partial failure it returns what it has, with a cursor covering only those.
+	FetchOrders(ctx context.Context, since time.Time) ([]MarketplaceOrder, time.Time, error)
+}
+
+// MarketplaceSyncResult counts what one sync run did
+type MarketplaceSyncResult struct {
+	Connector     string `json:"connector"`
+	Fetched       int    `json:"fetched"`
+	OrdersCreated int    `json:"orders_created"`
+	OrdersUpdated int    `json:"orders_updated"`
+	DraftsCreated int    `json:"drafts_created"`
+	Skipped       int    `json:"skipped"`
+}
+
+var (
+	marketplaceConnectors = map[string]MarketplaceConnector{}
+	marketplaceSyncMu     sync.Mutex
+	marketplaceSettings   struct {
+		Interval        time.Duration
+		InitialLookback time.Duration
+		UserID          uint
+	}
+)
+
+// RegisterMarketplaceConnector makes a connector available for syncing
+func RegisterMarketplaceConnector(c MarketplaceConnector) {
+	marketplaceConnectors[c.Name()] = c
+}
+
+// MarketplaceConnectorNames lists the registered connectors
+func MarketplaceConnectorNames() []string {
+	names := make([]string, 0, len(marketplaceConnectors))
+	for name := range marketplaceConnectors {
+		names = append(names, name)
+	}
+	return names
+}
+
+// InitMarketplaces registers the configured connectors and starts syncing
+// every marketplace.sync_interval, unless that is 0. Imported orders are
+// created by marketplace.sync_user_id.
+func InitMarketplaces(cfg config.MarketplaceConfig) {
+	marketplaceSettings.Interval = cfg.SyncInterval
+	marketplaceSettings.InitialLookback = cfg.Lookback
+	marketplaceSettings.UserID = uint(cfg.SyncUserID)
+
+	if amazon := InitAmazonConnector(cfg.Amazon); amazon != nil {
+		RegisterMarketplaceConnector(amazon)
+	}
+
+	if len(marketplaceConnectors) == 0 {
+		log.Println("Marketplace sync: no connectors configured")
+		return
+	}
+	if marketplaceSettings.Interval <= 0 {
+		log.Println("Marketplace sync scheduler disabled (marketplace.sync_interval is 0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(marketplaceSettings.Interval)
+		defer ticker.Stop()
+
+		for {
+			for _, name := range MarketplaceConnectorNames() {
+				if _, err := SyncMarketplace(context.Background(), name); err != nil {
+					log.Printf("Marketplace sync: %s failed: %v", name, err)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+
+	log.Printf("Marketplace sync started for %v (every %s)", MarketplaceConnectorNames(), marketplaceSettings.Interval)
+}
+
+// SyncMarketplace runs one sync for a connector. Runs are serialised so the
+// scheduler and a manual trigger never import the same page twice.
+func SyncMarketplace(ctx context.Context, name string) (MarketplaceSyncResult, error) {
+	result := MarketplaceSyncResult{Connector: name}
+
+	connector, ok := marketplaceConnectors[name]
+	if !ok {
+		return result, fmt.Errorf("unknown marketplace connector: %s", name)
+	}
+
+	marketplaceSyncMu.Lock()
+	defer marketplaceSyncMu.Unlock()
+
+	state := models.MarketplaceSyncState{Connector: name}
+	if err := config.DB.First(&state, "connector = ?", name).Error; err == gorm.ErrRecordNotFound {
+		state.Cursor = time.Now().Add(-marketplaceSettings.InitialLookback)
+	} else if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	state.LastRunAt = &now
+
+	orders, cursor, fetchErr := connector.FetchOrders(ctx, state.Cursor)
+	result.Fetched = len(orders)
+
+	// The cursor only moves past orders that were stored: it stops just
+	// before the earliest failed one, so the next run fetches it again
+	for _, mo := range orders {
+		if err := importMarketplaceOrder(name, mo, &result); err != nil {
+			log.Printf("Marketplace sync: %s order %s: %v", name, mo.ExternalID, err)
+			result.Skipped++
+			cursor = cursorBefore(cursor, mo, state.Cursor)
+		}
+	}
+
+	if !cursor.IsZero() && cursor.After(state.Cursor) {
+		state.Cursor = cursor
+	}
+	state.OrdersCreated += result.OrdersCreated
+	state.OrdersUpdated += result.OrdersUpdated
+	state.DraftsCreated += result.DraftsCreated
+	state.LastError = ""
+	if fetchErr != nil {
+		state.LastError = fetchErr.Error()
+	} else if result.Skipped > 0 {
+		state.LastError = fmt.Sprintf("%d orders failed to import, retrying from %s", result.Skipped, state.Cursor.Format(time.RFC3339))
+	} else {
+		state.LastSuccessAt = &now
+	}
+
+	if err := config.DB.Save(&state).Error; err != nil {
+		log.Printf("Marketplace sync: failed to save %s sync state: %v", name, err)
+	}
+
+	log.Printf("Marketplace sync: %s fetched %d, created %d, updated %d, drafts %d, skipped %d",
+		name, result.Fetched, result.OrdersCreated, result.OrdersUpdated, result.DraftsCreated, result.Skipped)
+	return result, fetchErr
+}
+
+// cursorBefore returns the sync cursor to resume from when mo failed to import:
+// just before its last update, or the previous cursor if it has no timestamp
+func cursorBefore(cursor time.Time, mo MarketplaceOrder, previous time.Time) time.Time {
+	failedAt := mo.UpdatedAt
+	if failedAt.IsZero() {
+		failedAt = mo.PurchasedAt
+	}
+	if failedAt.IsZero() {
+		return previous
+	}
+	if retry := failedAt.Add(-time.Second); retry.Before(cursor) {
+		return retry
+	}
+	return cursor
+}
+
+// importMarketplaceOrder upserts by order_id. Orders with complete dimensions,
+// thickness and corner style become orders; the rest become drafts listing
+// what is missing, for staff to complete with the customer. Existing orders
+// only get their customer details refreshed, so staff edits are kept.
+func importMarketplaceOrder(source string, mo MarketplaceOrder, result *MarketplaceSyncResult) error {
+	if mo.ExternalID == "" {
+		return fmt.Errorf("missing order ID")
+	}
+
+	var existing models.Order
+	err := config.DB.Where("order_id = ?", mo.ExternalID).First(&existing).Error
+	if err == nil {
+		updates := map[string]interface{}{}
+		if mo.CustomerName != "" && mo.CustomerName != existing.CustomerName {
+			updates["customer_name"] = mo.CustomerName
+		}
+		if mo.PhoneNumber != "" && mo.PhoneNumber != existing.PhoneNumber {
+			updates["phone_number"] = mo.PhoneNumber
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		event := Event{Type: EventOrderUpdated, UserID: marketplaceSettings.UserID, OccurredAt: time.Now()}
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+				return err
+			}
+			event.Order = existing
+			return queueWebhookDeliveries(tx, event)
+		})
+		if err != nil {
+			return err
+		}
+		result.OrdersUpdated++
+		PublishEvent(event)
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	hints := ParseOrderHints(mo.Customization)
+	if hints.Length == nil || hints.Width == nil || hints.Thickness == "" || hints.CornerStyle == "" {
+		return importMarketplaceDraft(source, mo, hints, result)
+	}
+
+	order := models.Order{
+		OrderID:      mo.ExternalID,
+		CustomerName: mo.CustomerName,
+		Source:       source,
+		PhoneNumber:  mo.PhoneNumber,
+		Length:       *hints.Length,
+		Width:        *hints.Width,
+		LengthInput:  hints.LengthInput,
+		WidthInput:   hints.WidthInput,
+		InputUnit:    hints.Unit,
+		Thickness:    hints.Thickness,
+		CornerStyle:  hints.CornerStyle,
+		Notes:        strings.TrimSpace(mo.Notes + "\n\n" + mo.Customization),
+		Status:       "new",
+		CreatedBy:    marketplaceSettings.UserID,
+		CreatedAt:    mo.PurchasedAt,
+	}
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = time.Now()
+	}
+	ApplyDueDates(&order)
+
+	// A concurrent insert of the same order_id is not an error, just not ours
+	created := false
+	event := Event{Type: EventOrderCreated, UserID: marketplaceSettings.UserID, OccurredAt: time.Now()}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "order_id"}}, DoNothing: true}).Create(&order)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		created = true
+		event.Order = order
+		return queueWebhookDeliveries(tx, event)
+	})
+	if err != nil || !created {
+		return err
+	}
+
+	result.OrdersCreated++
+	AnnotateSLA(&event.Order, event.OccurredAt)
+	AnnotateDimensions(&event.Order)
+	PublishEvent(event)
+	return nil
+}
+
+func importMarketplaceDraft(source string, mo MarketplaceOrder, hints OrderHints, result *MarketplaceSyncResult) error {
+	draft := models.DraftOrder{
+		Source:        source,
+		ExternalID:    mo.ExternalID,
+		PhoneNumber:   mo.PhoneNumber,
+		CustomerName:  mo.CustomerName,
+		Thickness:     hints.Thickness,
+		CornerStyle:   hints.CornerStyle,
+		Notes:         mo.Notes,
+		ExtractedText: mo.Customization,
+		ImageFiles:    models.StringList{},
+		Status:        DraftPending,
+	}
+	applyOrderHints(&draft, hints)
+	if missing := DraftMissingFields(&draft); len(missing) > 0 {
+		draft.Notes = strings.TrimSpace(draft.Notes + "\n\nMissing from the marketplace order: " + strings.Join(missing, ", "))
+	}
+
+	res := config.DB.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "source"}, {Name: "external_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "external_id <> ''"}}},
+		DoNothing:   true,
+	}).Create(&draft)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		result.DraftsCreated++
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorBefore(t *testing.T) {
+	previous := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	fetched := time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC)
+	at := func(hour int) time.Time { return time.Date(2026, 10, 2, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		cursor time.Time
+		order  MarketplaceOrder
+		want   time.Time
+	}{
+		{"before failed update", fetched, MarketplaceOrder{UpdatedAt: at(9)}, at(9).Add(-time.Second)},
+		{"purchase time without update", fetched, MarketplaceOrder{PurchasedAt: at(8)}, at(8).Add(-time.Second)},
+		{"earlier failure wins", at(5), MarketplaceOrder{UpdatedAt: at(9)}, at(5)},
+		{"no timestamp keeps previous", fetched, MarketplaceOrder{}, previous},
+	}
+	for _, tt := range tests {
+		if got := cursorBefore(tt.cursor, tt.order, previous); !got.Equal(tt.want) {
+			t.Errorf("%s: cursorBefore = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
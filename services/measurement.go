@@ -0,0 +1,646 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// ReferenceObject is an object of known size photographed lying on the table
+type ReferenceObject struct {
+	Code     string  `json:"code"`
+	Name     string  `json:"name"`
+	LengthMM float64 `json:"length_mm"` // long side
+	WidthMM  float64 `json:"width_mm"`
+}
+
+// ReferenceObjects lists the objects a table can be measured against
+var ReferenceObjects = []ReferenceObject{
+	{Code: "a4", Name: "A4 sheet of paper", LengthMM: 297, WidthMM: 210},
+	{Code: "a3", Name: "A3 sheet of paper", LengthMM: 420, WidthMM: 297},
+	{Code: "letter", Name: "US Letter sheet of paper", LengthMM: 279.4, WidthMM: 215.9},
+	{Code: "credit-card", Name: "credit or debit card", LengthMM: 85.6, WidthMM: 53.98},
+}
+
+// LookupReferenceObject returns a reference object by code
+func LookupReferenceObject(code string) (ReferenceObject, bool) {
+	for _, ref := range ReferenceObjects {
+		if ref.Code == code {
+			return ref, true
+		}
+	}
+	return ReferenceObject{}, false
+}
+
+var (
+	// ErrReferenceNotFound is returned when the vision model cannot find the reference object
+	ErrReferenceNotFound = errors.New("reference object not found in the photo; mark its corners")
+	// ErrTableNotFound is returned when the vision model cannot find the table top
+	ErrTableNotFound = errors.New("table top not found in the photo; mark its corners")
+	// ErrMeasurementGeometry is returned for corners that do not outline a rectangle seen in perspective
+	ErrMeasurementGeometry = errors.New("corners do not outline a rectangle in the photo")
+)
+
+// Corner placement error, as a fraction of the image height, for corners
+// marked by staff and for corners found by the vision model
+const (
+	markedCornerError   = 0.004
+	detectedCornerError = 0.015
+)
+
+const mmPerInch = 25.4
+
+// MeasurementInput is a photo of a table with a reference object lying flat
+// on it. Corners that are not marked are found by the vision model.
+type MeasurementInput struct {
+	Image            string // filename in the uploads directory
+	Reference        string // code from ReferenceObjects
+	ReferenceCorners *models.ImageQuad
+	TableCorners     *models.ImageQuad
+}
+
+// EstimateTableSize measures the table top in a photo against the reference
+// object on it. The reference fixes the perspective of the table's plane, so
+// the photo need not be taken straight from above. The range is about two
+// standard deviations of the corner placement error either side of the
+// estimate, widened when opposite edges of the table disagree.
+func EstimateTableSize(ctx context.Context, in MeasurementInput) (models.MeasurementEstimate, error) {
+	estimate := models.MeasurementEstimate{
+		ImageFile:       in.Image,
+		Reference:       in.Reference,
+		ReferenceMarked: in.ReferenceCorners != nil,
+		TableMarked:     in.TableCorners != nil,
+		Warnings:        models.StringList{},
+	}
+	ref, ok := LookupReferenceObject(in.Reference)
+	if !ok {
+		return estimate, fmt.Errorf("unknown reference object %q", in.Reference)
+	}
+
+	if in.ReferenceCorners == nil || in.TableCorners == nil {
+		detection, model, err := detectMeasurementCorners(ctx, in.Image, ref)
+		if err != nil {
+			return estimate, err
+		}
+		estimate.Model = model
+		if in.ReferenceCorners == nil {
+			if !detection.ReferenceFound {
+				return estimate, ErrReferenceNotFound
+			}
+			in.ReferenceCorners = &detection.Reference
+		}
+		if in.TableCorners == nil {
+			if !detection.TableFound {
+				return estimate, ErrTableNotFound
+			}
+			in.TableCorners = &detection.Table
+		}
+		if notes := strings.TrimSpace(detection.Notes); notes != "" {
+			estimate.Warnings = append(estimate.Warnings, notes)
+		}
+	}
+	estimate.ReferenceCorners, estimate.TableCorners = *in.ReferenceCorners, *in.TableCorners
+
+	refError, tableError := detectedCornerError, detectedCornerError
+	if estimate.ReferenceMarked {
+		refError = markedCornerError
+	}
+	if estimate.TableMarked {
+		tableError = markedCornerError
+	}
+	size, err := measureTable(ref, estimate.ReferenceCorners, estimate.TableCorners, imageAspect(in.Image), refError, tableError)
+	if err != nil {
+		return estimate, err
+	}
+
+	estimate.Length, estimate.LengthMin, estimate.LengthMax = size.length.inches()
+	estimate.Width, estimate.WidthMin, estimate.WidthMax = size.width.inches()
+	if size.edgeMismatch > 0.1 {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"Opposite edges of the table differ by %.0f%%: the corners may be misplaced or the reference may not lie flat on the table",
+			size.edgeMismatch*100))
+	}
+	if size.orientationGuessed {
+		estimate.Warnings = append(estimate.Warnings,
+			"The photo was taken straight along the table, so which way the reference lies was assumed: check the estimate with the customer, or use a photo taken from nearer a corner")
+	}
+	if size.refShape > 1.25 || size.refShape < 0.8 {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"The reference does not have the proportions of a %s: check that it is the right object, lies flat and is fully visible", ref.Name))
+	}
+	if size.length.sigma > 0.05*size.length.value || size.width.sigma > 0.05*size.width.value {
+		estimate.Warnings = append(estimate.Warnings,
+			"The estimate is rough: a larger reference object, or a photo taken from higher above the table, would narrow it")
+	}
+	return estimate, nil
+}
+
+// sideEstimate is a measured side in millimetres with its standard deviation
+type sideEstimate struct {
+	value, sigma float64
+}
+
+// inches returns the side and its two-sigma range in inches
+func (s sideEstimate) inches() (value, lowest, highest float64) {
+	value = s.value / mmPerInch
+	spread := 2 * s.sigma / mmPerInch
+	return round2(value), round2(math.Max(0, value-spread)), round2(value + spread)
+}
+
+type tableSize struct {
+	length, width sideEstimate
+	edgeMismatch  float64 // relative difference between opposite edges, the larger pair
+	refShape      float64 // the reference's proportions in the photo over its real ones, 1 if unknown
+	// which way the reference lies was judged from a typical camera, not from the photo
+	orientationGuessed bool
+}
+
+// measureTable maps the table corners onto the plane of the reference
+// object and measures its sides there. The uncertainty is propagated to
+// first order from the corner placement errors, given as fractions of the
+// image height, and the mismatch between opposite edges is added to it.
+// aspect is the image width over its height.
+func measureTable(ref ReferenceObject, refQuad, tableQuad models.ImageQuad, aspect, refError, tableError float64) (tableSize, error) {
+	coords := append(quadCoords(refQuad), quadCoords(tableQuad)...)
+	for i := 0; i < len(coords); i += 2 {
+		coords[i] *= aspect
+	}
+	// Which way round the reference lies is decided once, so that the
+	// perturbed corners below cannot flip it
+	orientation := referenceOrientation(ref, coords, aspect)
+	longAcross := orientation.longAcross
+	sides := func(c []float64) (top, right, bottom, left float64, err error) {
+		h, err := referenceHomography(ref, c[:8], longAcross)
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		var plane [4][2]float64
+		for i := 0; i < 4; i++ {
+			u, v, ok := h.apply(c[8+2*i], c[9+2*i])
+			if !ok {
+				return 0, 0, 0, 0, ErrMeasurementGeometry
+			}
+			plane[i] = [2]float64{u, v}
+		}
+		if !convexQuad(plane) {
+			return 0, 0, 0, 0, ErrMeasurementGeometry
+		}
+		d := func(a, b int) float64 {
+			return math.Hypot(plane[a][0]-plane[b][0], plane[a][1]-plane[b][1])
+		}
+		return d(0, 1), d(1, 2), d(2, 3), d(3, 0), nil
+	}
+
+	top, right, bottom, left, err := sides(coords)
+	if err != nil {
+		return tableSize{}, err
+	}
+	across, down := (top+bottom)/2, (left+right)/2
+
+	// First order error propagation by finite differences
+	const step = 1e-5
+	var acrossVar, downVar float64
+	for i := range coords {
+		sigma := tableError
+		if i < 8 {
+			sigma = refError
+		}
+		perturbed := append([]float64(nil), coords...)
+		perturbed[i] += step
+		t, r, b, l, err := sides(perturbed)
+		if err != nil {
+			continue
+		}
+		acrossVar += math.Pow(((t+b)/2-across)/step*sigma, 2)
+		downVar += math.Pow(((l+r)/2-down)/step*sigma, 2)
+	}
+	acrossVar += math.Pow((top-bottom)/2, 2)
+	downVar += math.Pow((left-right)/2, 2)
+
+	size := tableSize{
+		length:             sideEstimate{value: across, sigma: math.Sqrt(acrossVar)},
+		width:              sideEstimate{value: down, sigma: math.Sqrt(downVar)},
+		edgeMismatch:       math.Max(math.Abs(top-bottom)/across, math.Abs(left-right)/down),
+		refShape:           orientation.shape,
+		orientationGuessed: orientation.guessed,
+	}
+	if size.width.value > size.length.value {
+		size.length, size.width = size.width, size.length
+	}
+	return size, nil
+}
+
+// Focal length of a typical phone camera (26mm equivalent) as a fraction of the
+// photo's long side, used when a photo does not reveal its own
+const typicalFocalLength = 0.72
+
+// A table that maps this much closer to a rectangle one way round (in the
+// cosine of its worst corner angle) settles the reference's orientation
+const rectangularityMargin = 0.1
+
+type orientationChoice struct {
+	longAcross bool    // the reference's long side runs along the top edge of its quad
+	shape      float64 // as tableSize.refShape
+	guessed    bool
+}
+
+// referenceOrientation decides which way round the reference lies, from
+// coords holding the reference quad and then the table quad. Both ways are
+// tried: the one that maps the table closer to a rectangle wins when the
+// table and reference are not square to each other. Otherwise the camera
+// geometry recovered from the reference decides. When the photo is taken
+// straight along the table that geometry is undefined, and the orientation
+// whose implied focal length is nearer a typical phone camera's is taken.
+func referenceOrientation(ref ReferenceObject, coords []float64, aspect float64) orientationChoice {
+	center := [2]float64{aspect / 2, 0.5}
+	proj := projectRectangle(quadPoints(coords[:8]), center)
+	choice := orientationChoice{shape: 1}
+
+	f2 := proj.focalFromRightAngle()
+	recovered := f2 > 0 && !math.IsNaN(f2) && !math.IsInf(f2, 0)
+	if recovered {
+		refAspect := proj.aspect(f2)
+		choice.shape = math.Max(refAspect, 1/refAspect) / (ref.LengthMM / ref.WidthMM)
+		choice.longAcross = refAspect >= 1
+	}
+
+	across, alongOK := tableRectangularity(ref, coords, true)
+	down, downOK := tableRectangularity(ref, coords, false)
+	switch {
+	case alongOK && !downOK:
+		choice.longAcross = true
+		return choice
+	case downOK && !alongOK:
+		choice.longAcross = false
+		return choice
+	case alongOK && downOK && math.Abs(across-down) > rectangularityMargin:
+		choice.longAcross = across < down
+		return choice
+	case recovered:
+		return choice
+	}
+
+	// Neither the table nor the camera settles it
+	choice.guessed = true
+	typical := math.Log(typicalFocalLength * math.Max(aspect, 1))
+	best := math.Inf(1)
+	for _, longAcross := range []bool{true, false} {
+		r := ref.LengthMM / ref.WidthMM
+		if !longAcross {
+			r = 1 / r
+		}
+		f2 := proj.focalForAspect(r)
+		if f2 <= 0 || math.IsNaN(f2) || math.IsInf(f2, 0) {
+			continue
+		}
+		if distance := math.Abs(math.Log(math.Sqrt(f2)) - typical); distance < best {
+			best, choice.longAcross = distance, longAcross
+		}
+	}
+	if math.IsInf(best, 1) {
+		// Seen square on, the photo shows the proportions as they are
+		choice.longAcross = proj.flatAspect() >= 1
+	}
+	return choice
+}
+
+// tableRectangularity maps the table corners onto the reference's plane and
+// returns the cosine of the corner angle furthest from a right angle
+func tableRectangularity(ref ReferenceObject, coords []float64, longAcross bool) (float64, bool) {
+	h, err := referenceHomography(ref, coords[:8], longAcross)
+	if err != nil {
+		return 0, false
+	}
+	var plane [4][2]float64
+	for i := 0; i < 4; i++ {
+		u, v, ok := h.apply(coords[8+2*i], coords[9+2*i])
+		if !ok {
+			return 0, false
+		}
+		plane[i] = [2]float64{u, v}
+	}
+	if !convexQuad(plane) {
+		return 0, false
+	}
+
+	worst := 0.0
+	for i := 0; i < 4; i++ {
+		a, b, c := plane[(i+3)%4], plane[i], plane[(i+1)%4]
+		ux, uy, vx, vy := a[0]-b[0], a[1]-b[1], c[0]-b[0], c[1]-b[1]
+		cos := (ux*vx + uy*vy) / (math.Hypot(ux, uy) * math.Hypot(vx, vy))
+		worst = math.Max(worst, math.Abs(cos))
+	}
+	return worst, true
+}
+
+// imageAspect is the width of an image in the uploads directory over its height, or 1
+// when its format cannot be read
+func imageAspect(filename string) float64 {
+	file, err := os.Open(UploadPath(filename))
+	if err != nil {
+		log.Printf("Measurement: cannot open %s: %v", filename, err)
+		return 1
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil || config.Width == 0 || config.Height == 0 {
+		log.Printf("Measurement: cannot read the size of %s, assuming a square image: %v", filename, err)
+		return 1
+	}
+	return float64(config.Width) / float64(config.Height)
+}
+
+func quadCoords(q models.ImageQuad) []float64 {
+	coords := make([]float64, 0, 8)
+	for _, p := range q.Corners() {
+		coords = append(coords, p.X, p.Y)
+	}
+	return coords
+}
+
+// homography maps image coordinates onto a plane
+type homography [8]float64
+
+func (h homography) apply(x, y float64) (u, v float64, ok bool) {
+	w := h[6]*x + h[7]*y + 1
+	if w <= 1e-9 {
+		// The point is on or beyond the plane's horizon
+		return 0, 0, false
+	}
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w, true
+}
+
+// referenceHomography maps the photo onto the reference object's plane in
+// millimetres, with the object's long side across when longAcross is set
+func referenceHomography(ref ReferenceObject, c []float64, longAcross bool) (homography, error) {
+	image := quadPoints(c)
+	if !convexQuad(image) {
+		return homography{}, ErrMeasurementGeometry
+	}
+	across, down := ref.LengthMM, ref.WidthMM
+	if !longAcross {
+		across, down = down, across
+	}
+	plane := [4][2]float64{{0, 0}, {across, 0}, {across, down}, {0, down}}
+	return solveHomography(image, plane)
+}
+
+func quadPoints(c []float64) [4][2]float64 {
+	var points [4][2]float64
+	for i := range points {
+		points[i] = [2]float64{c[2*i], c[2*i+1]}
+	}
+	return points
+}
+
+// projectedRectangle holds the edge directions n2 (along the top edge) and
+// n3 (along the left edge) of a rectangle seen in a photo, in camera
+// coordinates up to the unknown focal length (Zhang and He, "Whiteboard
+// scanning and image enhancement"). The camera is taken to have square
+// pixels and its principal point at the centre of the photo.
+type projectedRectangle struct {
+	n2, n3 [3]float64
+}
+
+func projectRectangle(p [4][2]float64, center [2]float64) projectedRectangle {
+	point := func(i int) [3]float64 {
+		return [3]float64{p[i][0] - center[0], p[i][1] - center[1], 1}
+	}
+	cross := func(a, b [3]float64) [3]float64 {
+		return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+	}
+	dot := func(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+
+	// m1 top left, m2 top right, m3 bottom left, m4 bottom right
+	m1, m2, m3, m4 := point(0), point(1), point(3), point(2)
+	k2 := dot(cross(m1, m4), m3) / dot(cross(m2, m4), m3)
+	k3 := dot(cross(m1, m4), m2) / dot(cross(m3, m4), m2)
+	var proj projectedRectangle
+	for i := range proj.n2 {
+		proj.n2[i], proj.n3[i] = k2*m2[i]-m1[i], k3*m3[i]-m1[i]
+	}
+	return proj
+}
+
+// focalFromRightAngle is the squared focal length at which the edges meet
+// at a right angle on the table; 0 when they are parallel in the photo
+func (r projectedRectangle) focalFromRightAngle() float64 {
+	if math.Abs(r.n2[2]*r.n3[2]) < 1e-9 {
+		return 0
+	}
+	return -(r.n2[0]*r.n3[0] + r.n2[1]*r.n3[1]) / (r.n2[2] * r.n3[2])
+}
+
+// aspect is the rectangle's width over its height for a squared focal length
+func (r projectedRectangle) aspect(f2 float64) float64 {
+	return math.Sqrt((r.n2[0]*r.n2[0]/f2 + r.n2[1]*r.n2[1]/f2 + r.n2[2]*r.n2[2]) /
+		(r.n3[0]*r.n3[0]/f2 + r.n3[1]*r.n3[1]/f2 + r.n3[2]*r.n3[2]))
+}
+
+// flatAspect is the rectangle's width over its height in the photo, which is
+// right when it is seen square on
+func (r projectedRectangle) flatAspect() float64 {
+	return math.Sqrt((r.n2[0]*r.n2[0] + r.n2[1]*r.n2[1]) / (r.n3[0]*r.n3[0] + r.n3[1]*r.n3[1]))
+}
+
+// focalForAspect is the squared focal length at which the rectangle would
+// have the given width over height, or a non-positive number if none does
+func (r projectedRectangle) focalForAspect(aspect float64) float64 {
+	a2 := r.n2[0]*r.n2[0] + r.n2[1]*r.n2[1]
+	a3 := r.n3[0]*r.n3[0] + r.n3[1]*r.n3[1]
+	b2, b3 := r.n2[2]*r.n2[2], r.n3[2]*r.n3[2]
+	denominator := b2 - aspect*aspect*b3
+	if math.Abs(denominator) < 1e-12 {
+		return 0
+	}
+	return (aspect*aspect*a3 - a2) / denominator
+}
+
+// solveHomography finds the homography taking each src point to its dst point
+func solveHomography(src, dst [4][2]float64) (homography, error) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y, u, v := src[i][0], src[i][1], dst[i][0], dst[i][1]
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return homography{}, ErrMeasurementGeometry
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var h homography
+	for i := range h {
+		h[i] = a[i][8] / a[i][i]
+	}
+	return h, nil
+}
+
+// convexQuad reports whether the corners, in order, turn the same way at
+// every corner with a non-zero area
+func convexQuad(p [4][2]float64) bool {
+	sign := 0.0
+	for i := 0; i < 4; i++ {
+		a, b, c := p[i], p[(i+1)%4], p[(i+2)%4]
+		cross := (b[0]-a[0])*(c[1]-b[1]) - (b[1]-a[1])*(c[0]-b[0])
+		if math.Abs(cross) < 1e-12 {
+			return false
+		}
+		if sign == 0 {
+			sign = cross
+		} else if (cross > 0) != (sign > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// AttachMeasurementToDraft gives a draft the estimated size and notes the
+// range, so staff confirm it with the customer before converting the draft.
+// The photo is added to the draft's images if it is not there yet.
+func AttachMeasurementToDraft(draft *models.DraftOrder, estimate *models.MeasurementEstimate) error {
+	ref, _ := LookupReferenceObject(estimate.Reference)
+	note := fmt.Sprintf("Size estimated from photo %s against a %s: %s x %s in (length %s-%s, width %s-%s in); confirm with the customer",
+		estimate.ImageFile, ref.Name, formatInches(estimate.Length), formatInches(estimate.Width),
+		formatInches(estimate.LengthMin), formatInches(estimate.LengthMax),
+		formatInches(estimate.WidthMin), formatInches(estimate.WidthMax))
+
+	length, width := estimate.Length, estimate.Width
+	draft.Length, draft.Width = &length, &width
+	draft.Notes = strings.TrimSpace(draft.Notes + "\n" + note)
+	if !draft.ImageFiles.Contains(estimate.ImageFile) {
+		draft.ImageFiles = append(draft.ImageFiles, estimate.ImageFile)
+	}
+	estimate.DraftOrderID = &draft.ID
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(draft).Error; err != nil {
+			return err
+		}
+		return tx.Model(estimate).Update("draft_order_id", draft.ID).Error
+	})
+}
+
+// measurementDetection is the vision model's answer
+type measurementDetection struct {
+	ReferenceFound bool             `json:"reference_found"`
+	Reference      models.ImageQuad `json:"reference"`
+	TableFound     bool             `json:"table_found"`
+	Table          models.ImageQuad `json:"table"`
+	Notes          string           `json:"notes"`
+}
+
+// detectMeasurementCorners asks the vision model for the corners of the
+// reference object and the table top
+func detectMeasurementCorners(ctx context.Context, photo string, ref ReferenceObject) (measurementDetection, string, error) {
+	var detection measurementDetection
+	if aiService.APIKey == "" {
+		return detection, "", fmt.Errorf("%w: OpenAI API key not configured; mark the corners instead", ErrAIUnavailable)
+	}
+	images, err := replyImages([]string{photo})
+	if err != nil {
+		return detection, "", err
+	}
+
+	system := measurementPrompt(ref)
+	resp, err := doOpenAIRequest(withAIFeature(ctx, AIFeatureMeasurement), OpenAIRequest{
+		Model:       aiService.Model,
+		Temperature: 0,
+		MaxTokens:   500,
+		Messages: []Message{
+			systemMessage(system),
+			{Role: "user", Content: images},
+		},
+		ResponseFormat: &ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &JSONSchema{Name: "measurement_corners", Strict: true, Schema: measurementSchema()},
+		},
+	})
+	if err != nil {
+		return detection, "", err
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &detection); err != nil {
+		return detection, resp.Model, fmt.Errorf("failed to parse corner detection: %v", err)
+	}
+	return detection, resp.Model, nil
+}
+
+func measurementPrompt(ref ReferenceObject) string {
+	return fmt.Sprintf(`You locate objects in a customer's photo of a table so that the table can be measured.
+A %s (%.0f x %.0f mm) should be lying flat on the table top.
+Give the four corners of the %[1]s and of the table top as they appear in the photo: top_left,
+top_right, bottom_right and bottom_left. Each corner is x, the fraction of the image width from the
+left edge, and y, the fraction of the image height from the top edge, both from 0 to 1.
+For a table top with rounded corners give the point where its straight edges would meet.
+Set reference_found or table_found to false when the object is not fully visible; do not guess.
+Use notes for anything that makes the measurement unreliable, such as a folded sheet or a
+reference object that is not on the table top; otherwise leave it empty.`, ref.Name, ref.LengthMM, ref.WidthMM)
+}
+
+func measurementSchema() map[string]interface{} {
+	object := func(properties map[string]interface{}) map[string]interface{} {
+		required := make([]string, 0, len(properties))
+		for key := range properties {
+			required = append(required, key)
+		}
+		sort.Strings(required)
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	number := map[string]interface{}{"type": "number"}
+	point := object(map[string]interface{}{"x": number, "y": number})
+	quad := object(map[string]interface{}{
+		"top_left":     point,
+		"top_right":    point,
+		"bottom_right": point,
+		"bottom_left":  point,
+	})
+	boolean := map[string]interface{}{"type": "boolean"}
+
+	return object(map[string]interface{}{
+		"reference_found": boolean,
+		"reference":       quad,
+		"table_found":     boolean,
+		"table":           quad,
+		"notes":           map[string]interface{}{"type": "string"},
+	})
+}
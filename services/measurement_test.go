@@ -0,0 +1,150 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"customflow/models"
+)
+
+// syntheticCamera is a pinhole camera over the table plane (z = 0, in mm),
+// with square pixels and its principal point at the centre of the photo.
+// focal is in units of the image height.
+type syntheticCamera struct {
+	position, target [3]float64
+	focal, aspect    float64
+}
+
+// project returns the photo position of a point on the table as fractions
+// of the image width and height
+func (cam syntheticCamera) project(x, y float64) models.ImagePoint {
+	sub := func(a, b [3]float64) [3]float64 { return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+	cross := func(a, b [3]float64) [3]float64 {
+		return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+	}
+	dot := func(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+	unit := func(a [3]float64) [3]float64 {
+		n := math.Sqrt(dot(a, a))
+		return [3]float64{a[0] / n, a[1] / n, a[2] / n}
+	}
+
+	forward := unit(sub(cam.target, cam.position))
+	right := unit(cross(forward, [3]float64{0, 0, 1}))
+	down := cross(forward, right)
+
+	p := sub([3]float64{x, y, 0}, cam.position)
+	depth := dot(p, forward)
+	u := cam.aspect/2 + cam.focal*dot(p, right)/depth
+	v := 0.5 + cam.focal*dot(p, down)/depth
+	return models.ImagePoint{X: u / cam.aspect, Y: v}
+}
+
+// photograph returns the quad of an axis-aligned rectangle on the table,
+// seen from a camera in front of it (at negative y)
+func (cam syntheticCamera) photograph(x0, y0, x1, y1 float64) models.ImageQuad {
+	return models.ImageQuad{
+		TopLeft:     cam.project(x0, y1),
+		TopRight:    cam.project(x1, y1),
+		BottomRight: cam.project(x1, y0),
+		BottomLeft:  cam.project(x0, y0),
+	}
+}
+
+// photographRotated returns the quad of a w x h rectangle centred at (cx, cy)
+// and turned by angle radians, starting from the corner furthest back on the left
+func (cam syntheticCamera) photographRotated(cx, cy, w, h, angle float64) models.ImageQuad {
+	var corners [4]models.ImagePoint
+	offsets := [4][2]float64{{-w / 2, h / 2}, {w / 2, h / 2}, {w / 2, -h / 2}, {-w / 2, -h / 2}}
+	for i, o := range offsets {
+		x := cx + o[0]*math.Cos(angle) - o[1]*math.Sin(angle)
+		y := cy + o[0]*math.Sin(angle) + o[1]*math.Cos(angle)
+		corners[i] = cam.project(x, y)
+	}
+	return models.ImageQuad{TopLeft: corners[0], TopRight: corners[1], BottomRight: corners[2], BottomLeft: corners[3]}
+}
+
+func TestMeasureTableSyntheticPhotos(t *testing.T) {
+	a4, _ := LookupReferenceObject("a4")
+	card, _ := LookupReferenceObject("credit-card")
+
+	// A 900 x 600 mm (35.43 x 23.62 in) table with its long side across the photo
+	const tableLength, tableWidth = 900.0, 600.0
+	table := func(cam syntheticCamera) models.ImageQuad { return cam.photograph(0, 0, tableLength, tableWidth) }
+
+	tests := []struct {
+		name    string
+		cam     syntheticCamera
+		ref     ReferenceObject
+		refQuad func(cam syntheticCamera) models.ImageQuad
+		guessed bool // the orientation can only be assumed from a typical camera
+	}{
+		{
+			name:    "centreline, A4 lengthwise away from the camera",
+			cam:     syntheticCamera{position: [3]float64{450, -800, 900}, target: [3]float64{450, 300, 0}, focal: 1.0, aspect: 4.0 / 3},
+			ref:     a4,
+			refQuad: func(cam syntheticCamera) models.ImageQuad { return cam.photograph(345, 150, 555, 447) },
+			guessed: true,
+		},
+		{
+			name:    "centreline, A4 lengthwise across",
+			cam:     syntheticCamera{position: [3]float64{450, -800, 900}, target: [3]float64{450, 300, 0}, focal: 1.0, aspect: 4.0 / 3},
+			ref:     a4,
+			refQuad: func(cam syntheticCamera) models.ImageQuad { return cam.photograph(300, 200, 597, 410) },
+			guessed: true,
+		},
+		{
+			name:    "centreline, telephoto, A4 lengthwise away",
+			cam:     syntheticCamera{position: [3]float64{450, -1600, 1400}, target: [3]float64{450, 300, 0}, focal: 2.2, aspect: 4.0 / 3},
+			ref:     a4,
+			refQuad: func(cam syntheticCamera) models.ImageQuad { return cam.photograph(345, 150, 555, 447) },
+			guessed: true,
+		},
+		{
+			name:    "portrait photo from the corner, A4 lengthwise away",
+			cam:     syntheticCamera{position: [3]float64{-300, -700, 1000}, target: [3]float64{450, 300, 0}, focal: 1.3, aspect: 3.0 / 4},
+			ref:     a4,
+			refQuad: func(cam syntheticCamera) models.ImageQuad { return cam.photograph(345, 150, 555, 447) },
+			guessed: false,
+		},
+		{
+			name:    "from the side, card turned on the table",
+			cam:     syntheticCamera{position: [3]float64{1300, -500, 800}, target: [3]float64{450, 300, 0}, focal: 1.1, aspect: 4.0 / 3},
+			ref:     card,
+			refQuad: func(cam syntheticCamera) models.ImageQuad { return cam.photographRotated(450, 300, 53.98, 85.6, 0.5) },
+			guessed: false,
+		},
+		{
+			name:    "centreline, card turned on the table",
+			cam:     syntheticCamera{position: [3]float64{450, -800, 900}, target: [3]float64{450, 300, 0}, focal: 1.0, aspect: 4.0 / 3},
+			ref:     card,
+			refQuad: func(cam syntheticCamera) models.ImageQuad { return cam.photographRotated(450, 300, 53.98, 85.6, 0.6) },
+			guessed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := measureTable(tt.ref, tt.refQuad(tt.cam), table(tt.cam), tt.cam.aspect, markedCornerError, markedCornerError)
+			if err != nil {
+				t.Fatalf("measureTable: %v", err)
+			}
+
+			length, lengthMin, lengthMax := size.length.inches()
+			width, widthMin, widthMax := size.width.inches()
+			wantLength, wantWidth := tableLength/mmPerInch, tableWidth/mmPerInch
+			if lengthMin > wantLength || lengthMax < wantLength || widthMin > wantWidth || widthMax < wantWidth {
+				t.Errorf("estimate %.2f (%.2f-%.2f) x %.2f (%.2f-%.2f) in excludes %.2f x %.2f in",
+					length, lengthMin, lengthMax, width, widthMin, widthMax, wantLength, wantWidth)
+			}
+			if math.Abs(length-wantLength) > 0.03*wantLength || math.Abs(width-wantWidth) > 0.03*wantWidth {
+				t.Errorf("estimate %.2f x %.2f in, want %.2f x %.2f in", length, width, wantLength, wantWidth)
+			}
+			if size.refShape > 1.25 || size.refShape < 0.8 {
+				t.Errorf("reference proportions %.2f of the real ones, want about 1", size.refShape)
+			}
+			if size.orientationGuessed != tt.guessed {
+				t.Errorf("orientation guessed = %v, want %v", size.orientationGuessed, tt.guessed)
+			}
+		})
+	}
+}
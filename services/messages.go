@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Channel message states
+const (
+	MessageReceived  = "received"
+	MessageProcessed = "processed"
+	MessageFailed    = "failed"
+	MessageSent      = "sent"
+)
+
+// Reply tones understood by GenerateAIResponse
+var ReplyTones = []string{"friendly", "formal", "short"}
+
+// Phone numbers are matched on their last 10 digits, so "+91 98123 45678",
+// "098123-45678" and "9812345678" all thread together
+const phoneKeyDigits = 10
+
+// PhoneKey returns the digits a phone number is threaded on
+func PhoneKey(phone string) string {
+	digits := digitsOnly(phone)
+	if len(digits) > phoneKeyDigits {
+		digits = digits[len(digits)-phoneKeyDigits:]
+	}
+	return digits
+}
+
+// E.164 allows at most 15 digits after the "+"; anything under 8 is a short code or a typo
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+// NormalizePhone returns phone in E.164 form. Numbers written with "+" or an
+// "00" international prefix keep their country code; national numbers lose
+// their trunk zeros and get SMS_DEFAULT_COUNTRY_CODE. It reports false when
+// no valid E.164 number can be made, e.g. for a national number without a
+// default country code.
+func NormalizePhone(phone string) (string, bool) {
+	phone = strings.TrimSpace(phone)
+	digits := digitsOnly(phone)
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	default:
+		national := strings.TrimLeft(digits, "0")
+		code := smsDefaultCountryCode
+		if code == "" || national == "" {
+			return "", false
+		}
+		// Numbers that already carry the country code are longer than a national number
+		if !(strings.HasPrefix(national, code) && len(national) > phoneKeyDigits) {
+			national = code + national
+		}
+		digits = national
+	}
+
+	if len(digits) < minE164Digits || len(digits) > maxE164Digits || digits[0] == '0' {
+		return "", false
+	}
+	return "+" + digits, true
+}
+
+// PhoneKeySQL is the SQL equivalent of PhoneKey for a column (see V11 indexes)
+func PhoneKeySQL(column string) string {
+	return fmt.Sprintf("right(regexp_replace(%s, '[^0-9]', '', 'g'), %d)", column, phoneKeyDigits)
+}
+
+// insertInboundMessage stores a message, with its detected language, unless
+// the provider already delivered it. It reports whether a new row was created.
+func insertInboundMessage(message *models.ChannelMessage) (bool, error) {
+	message.Language = DetectLanguage(message.Body).Code
+	result := config.DB.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "channel"}, {Name: "external_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "external_id <> ''"}}},
+		DoNothing:   true,
+	}).Create(message)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to store %s message %s: %v", message.Channel, message.ExternalID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindOrderByPhone returns the customer's most recent order, preferring open ones
+func FindOrderByPhone(phone string) (*models.Order, error) {
+	return findOrderByPhone(phone, false)
+}
+
+// FindOpenOrderByPhone returns the customer's most recent open order. A
+// customer whose orders are all done has none: a new message from them may
+// be a new order.
+func FindOpenOrderByPhone(phone string) (*models.Order, error) {
+	return findOrderByPhone(phone, true)
+}
+
+func findOrderByPhone(phone string, openOnly bool) (*models.Order, error) {
+	key := PhoneKey(phone)
+	if key == "" {
+		return nil, nil
+	}
+
+	query := config.DB.Where(PhoneKeySQL("phone_number")+" = ?", key)
+	if openOnly {
+		query = query.Where("status IN ?", models.OpenOrderStatuses)
+	}
+
+	var order models.Order
+	err := query.
+		Order(clause.Expr{SQL: "status IN ? DESC, created_at DESC", Vars: []interface{}{models.OpenOrderStatuses}}).
+		First(&order).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// HandleInboundSMS stores an inbound SMS and threads it onto the customer's open
+// order. Texts from numbers without one, including customers whose orders are
+// all done, start or extend a draft order instead.
+// It reports whether the message was new.
+func HandleInboundSMS(in InboundSMS) (models.ChannelMessage, bool, error) {
+	raw, _ := json.Marshal(in)
+	// Keep the sender as the gateway sent it when it cannot be made E.164
+	phone, ok := NormalizePhone(in.From)
+	if !ok {
+		phone = strings.TrimSpace(in.From)
+	}
+	message := models.ChannelMessage{
+		Channel:     "sms",
+		Direction:   "inbound",
+		ExternalID:  in.ExternalID,
+		PhoneNumber: phone,
+		Body:        in.Body,
+		MediaFiles:  models.StringList{},
+		Status:      MessageReceived,
+		RawPayload:  models.JSONRaw(raw),
+	}
+
+	order, err := FindOpenOrderByPhone(in.From)
+	if err != nil {
+		return message, false, err
+	}
+	if order != nil {
+		message.OrderID = &order.ID
+		message.CustomerName = order.CustomerName
+		message.Status = MessageProcessed
+	}
+
+	created, err := insertInboundMessage(&message)
+	if err != nil || !created {
+		return message, created, err
+	}
+
+	if order != nil {
+		log.Printf("SMS: message %d from %s threaded onto order %s", message.ID, message.PhoneNumber, order.OrderID)
+		return message, true, nil
+	}
+
+	go func(message models.ChannelMessage) {
+		draft, err := UpdateDraftFromMessage(context.Background(), DraftInput{
+			Source:      "sms",
+			PhoneNumber: message.PhoneNumber,
+			Text:        message.Body,
+		})
+		if err != nil {
+			message.Status = MessageFailed
+			message.Error = err.Error()
+		} else {
+			message.Status = MessageProcessed
+			message.DraftOrderID = &draft.ID
+		}
+		if err := config.DB.Save(&message).Error; err != nil {
+			log.Printf("SMS: failed to update message %d: %v", message.ID, err)
+		}
+	}(message)
+
+	return message, true, nil
+}
+
+// OrderConversation returns the messages exchanged with an order's customer,
+// oldest first: everything linked to the order plus anything from the same phone
+func OrderConversation(order models.Order) ([]models.ChannelMessage, error) {
+	query := config.DB.Where("order_id = ?", order.ID)
+	if key := PhoneKey(order.PhoneNumber); key != "" {
+		query = query.Or(PhoneKeySQL("phone_number")+" = ?", key)
+	}
+
+	var messages []models.ChannelMessage
+	err := query.Order("created_at, id").Find(&messages).Error
+	return messages, err
+}
+
+// SuggestOrderReply drafts a reply to the customer's latest message in the
+// given tone, in the language of that message unless another is chosen, with
+// any photos it came with. The reply is stored in ai_responses so staff
+// feedback can be recorded against it.
+func SuggestOrderReply(ctx context.Context, order models.Order, tone, language string) (models.AIResponse, error) {
+	// Without a phone key only messages linked to the order count, as in OrderConversation
+	thread := config.DB.Where("order_id = ?", order.ID)
+	if key := PhoneKey(order.PhoneNumber); key != "" {
+		thread = thread.Or(PhoneKeySQL("phone_number")+" = ?", key)
+	}
+
+	var last models.ChannelMessage
+	err := config.DB.
+		Where("direction = ?", "inbound").
+		Where(thread).
+		Order("created_at DESC, id DESC").
+		First(&last).Error
+	if err == gorm.ErrRecordNotFound {
+		return models.AIResponse{}, fmt.Errorf("no customer message to reply to")
+	}
+	if err != nil {
+		return models.AIResponse{}, err
+	}
+
+	opts := ReplyOptions{Tone: tone, Order: &order, Language: language, Images: replyImageFiles(last.MediaFiles)}
+	return ReplyToMessage(ctx, last.Body, opts, nil)
+}
+
+// SendOrderSMS texts the order's customer and records the outbound message
+func SendOrderSMS(ctx context.Context, order models.Order, body string) (models.ChannelMessage, error) {
+	if smsProvider == nil {
+		return models.ChannelMessage{}, ErrSMSDisabled
+	}
+	phone, ok := NormalizePhone(order.PhoneNumber)
+	if !ok {
+		return models.ChannelMessage{}, fmt.Errorf("%w: %q", ErrInvalidPhone, order.PhoneNumber)
+	}
+
+	message := models.ChannelMessage{
+		Channel:      "sms",
+		Direction:    "outbound",
+		PhoneNumber:  phone,
+		CustomerName: order.CustomerName,
+		Body:         strings.TrimSpace(body),
+		MediaFiles:   models.StringList{},
+		OrderID:      &order.ID,
+		Status:       MessageSent,
+	}
+	message.Language = DetectLanguage(message.Body).Code
+
+	externalID, err := smsProvider.Send(ctx, message.PhoneNumber, message.Body)
+	if err != nil {
+		message.Status = MessageFailed
+		message.Error = err.Error()
+	}
+	message.ExternalID = externalID
+
+	if dbErr := config.DB.Create(&message).Error; dbErr != nil {
+		log.Printf("SMS: failed to record outbound message to %s: %v", message.PhoneNumber, dbErr)
+	}
+	return message, err
+}
@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone       string
+		countryCode string
+		want        string
+		ok          bool
+	}{
+		{"+91 98123 45678", "", "+919812345678", true},
+		{"+1 (555) 010-0199", "91", "+15550100199", true},
+		{"0044 20 7946 0958", "", "+442079460958", true},
+		{"098123-45678", "91", "+919812345678", true},
+		{"9812345678", "91", "+919812345678", true},
+		{"919812345678", "91", "+919812345678", true},
+		{"9812345678", "", "", false}, // national number without a default country
+		{"12345", "", "", false},      // short code
+		{"+1234567890123456", "", "", false},
+		{"", "91", "", false},
+	}
+
+	for _, tt := range tests {
+		smsDefaultCountryCode = tt.countryCode
+		got, ok := NormalizePhone(tt.phone)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizePhone(%q) with country %q = %q, %v; want %q, %v", tt.phone, tt.countryCode, got, ok, tt.want, tt.ok)
+		}
+	}
+	smsDefaultCountryCode = ""
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notification is a single message to deliver through a Notifier
+type Notification struct {
+	Event     string `json:"event"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	OrderID   uint   `json:"order_id,omitempty"`
+
+	// Pre-approved WhatsApp template and its body parameters, used by the WhatsApp channel
+	Template       string   `json:"template,omitempty"`
+	TemplateParams []string `json:"template_params,omitempty"`
+}
+
+// Notifier delivers notifications over one channel
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// LogNotifier only writes notifications to the log
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (LogNotifier) Name() string {
+	return "log"
+}
+
+func (LogNotifier) Send(ctx context.Context, n Notification) error {
+	log.Printf("Notification [%s] to %q: %s - %s", n.Event, n.Recipient, n.Subject, n.Body)
+	return nil
+}
+
+// SMTPNotifier sends plain-text email
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (SMTPNotifier) Name() string {
+	return "email"
+}
+
+func (s SMTPNotifier) Send(ctx context.Context, n Notification) error {
+	if n.Recipient == "" {
+		return fmt.Errorf("email notification has no recipient")
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.Recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(n.Body)
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	recipients := splitList(n.Recipient)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, recipients, []byte(msg.String()))
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SMSGatewayNotifier posts messages to an HTTP SMS gateway as
+// {"to": ..., "from": ..., "message": ...} with a bearer API key
+type SMSGatewayNotifier struct {
+	URL    string
+	APIKey string
+	Sender string
+	Client *http.Client
+}
+
+func (SMSGatewayNotifier) Name() string {
+	return "sms"
+}
+
+func (s SMSGatewayNotifier) Send(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(map[string]string{
+		"to":      n.Recipient,
+		"from":    s.Sender,
+		"message": n.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	return doNotificationRequest(s.Client, req)
+}
+
+// WhatsAppTemplateNotifier sends pre-approved template messages through the WhatsApp Cloud API
+type WhatsAppTemplateNotifier struct {
+	APIURL        string // e.g. https://graph.facebook.com/v20.0
+	PhoneNumberID string
+	AccessToken   string
+	Language      string
+	Client        *http.Client
+}
+
+func (WhatsAppTemplateNotifier) Name() string {
+	return "whatsapp"
+}
+
+func (w WhatsAppTemplateNotifier) Send(ctx context.Context, n Notification) error {
+	if n.Template == "" {
+		return fmt.Errorf("whatsapp notification for %s has no template", n.Event)
+	}
+
+	params := make([]map[string]string, 0, len(n.TemplateParams))
+	for _, p := range n.TemplateParams {
+		params = append(params, map[string]string{"type": "text", "text": p})
+	}
+
+	template := map[string]interface{}{
+		"name":     n.Template,
+		"language": map[string]string{"code": w.Language},
+	}
+	if len(params) > 0 {
+		template["components"] = []map[string]interface{}{
+			{"type": "body", "parameters": params},
+		}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                digitsOnly(n.Recipient),
+		"type":              "template",
+		"template":          template,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/%s/messages", strings.TrimRight(w.APIURL, "/"), w.PhoneNumberID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+w.AccessToken)
+
+	return doNotificationRequest(w.Client, req)
+}
+
+func doNotificationRequest(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("notification API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OCR result statuses per image
+const (
+	OCRStatusOK     = "ok"
+	OCRStatusCached = "cached"
+	OCRStatusFailed = "failed"
+)
+
+// OCRImageResult is the outcome of reading one image
+type OCRImageResult struct {
+	Image      string `json:"image"`
+	Status     string `json:"status"`
+	Text       string `json:"text,omitempty"`
+	Language   string `json:"language,omitempty"` // detected in the text
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// OCRBatchResult holds the per-image results in request order and the text of
+// the images that could be read, joined with an image separator
+type OCRBatchResult struct {
+	Text      string            `json:"text"`
+	Language  LanguageDetection `json:"language"`
+	Images    []OCRImageResult  `json:"images"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Cached    int               `json:"cached"`
+}
+
+const ocrImageSeparator = "\n\n---NEXT IMAGE---\n\n"
+
+type OCRSettings struct {
+	Model         string
+	Workers       int
+	RatePerMinute int // OCR requests started per minute, across all requests
+	Burst         int
+	CacheEnabled  bool
+}
+
+var (
+	ocrSettings = OCRSettings{Model: "gpt-4o", Workers: 4, RatePerMinute: 60, Burst: 4, CacheEnabled: true}
+	ocrLimiter  = newTokenBucket(ocrSettings.RatePerMinute, ocrSettings.Burst)
+)
+
+// InitOCR applies the ocr section. The burst, requests allowed at once before
+// the rate applies, defaults to the number of workers.
+func InitOCR(cfg config.OCRConfig) {
+	ocrSettings.Model = cfg.Model
+	ocrSettings.Workers = max(cfg.Workers, 1)
+	ocrSettings.RatePerMinute = cfg.RatePerMinute
+	ocrSettings.Burst = cfg.Burst
+	if ocrSettings.Burst == 0 {
+		ocrSettings.Burst = ocrSettings.Workers
+	}
+	ocrSettings.CacheEnabled = cfg.Cache
+	ocrLimiter = newTokenBucket(ocrSettings.RatePerMinute, ocrSettings.Burst)
+
+	log.Printf("OCR: %s, %d workers, %d requests/minute (burst %d), cache %v",
+		ocrSettings.Model, ocrSettings.Workers, ocrSettings.RatePerMinute, ocrSettings.Burst, ocrSettings.CacheEnabled)
+}
+
+// RunOCR reads images from the uploads directory in parallel, at most ocr.workers at a
+// time and within the provider rate limit. Images read before are answered
+// from the cache. A failed image does not fail the batch; it is reported in
+// its result. The error is only set when no image could be read at all.
+func RunOCR(ctx context.Context, images []string) (OCRBatchResult, error) {
+	var batch OCRBatchResult
+	if len(images) == 0 {
+		return batch, fmt.Errorf("no images provided")
+	}
+
+	batch.Images = make([]OCRImageResult, len(images))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := ocrSettings.Workers
+	if workers > len(images) {
+		workers = len(images)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				batch.Images[i] = ocrImage(ctx, images[i])
+			}
+		}()
+	}
+	for i := range images {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var texts []string
+	for i, r := range batch.Images {
+		switch r.Status {
+		case OCRStatusFailed:
+			batch.Failed++
+			continue
+		case OCRStatusCached:
+			batch.Cached++
+		}
+		batch.Succeeded++
+		if r.Text != "" {
+			batch.Images[i].Language = DetectLanguage(r.Text).Code
+			texts = append(texts, r.Text)
+		}
+	}
+	batch.Text = strings.Join(texts, ocrImageSeparator)
+	batch.Language = DetectLanguage(batch.Text)
+
+	log.Printf("OCR: %d images, %d read (%d from cache), %d failed, %d characters",
+		len(images), batch.Succeeded, batch.Cached, batch.Failed, len(batch.Text))
+
+	if batch.Succeeded == 0 {
+		return batch, fmt.Errorf("could not extract text from any of the %d images", len(images))
+	}
+	return batch, nil
+}
+
+func ocrImage(ctx context.Context, image string) (result OCRImageResult) {
+	result.Image = image
+	start := time.Now()
+	defer func() { result.DurationMS = time.Since(start).Milliseconds() }()
+
+	fail := func(err error) OCRImageResult {
+		log.Printf("OCR failed for image %s: %v", image, err)
+		result.Status, result.Error = OCRStatusFailed, err.Error()
+		return result
+	}
+
+	data, err := os.ReadFile(UploadPath(image))
+	if err != nil {
+		return fail(fmt.Errorf("failed to read image: %v", err))
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if text, ok := cachedOCR(hash); ok {
+		result.Status, result.Text = OCRStatusCached, text
+		return result
+	}
+
+	if aiService.APIKey == "" {
+		return fail(fmt.Errorf("OpenAI API key not configured"))
+	}
+	if err := ocrLimiter.Wait(ctx); err != nil {
+		return fail(err)
+	}
+
+	text, err := performOCRRequest(ctx, imageDataURL(image, data))
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Status, result.Text = OCRStatusOK, strings.TrimSpace(text)
+	storeOCR(hash, result.Text)
+	return result
+}
+
+func cachedOCR(hash string) (string, bool) {
+	if !ocrSettings.CacheEnabled || config.DB == nil {
+		return "", false
+	}
+
+	var entry models.OCRCacheEntry
+	if err := config.DB.Where("content_hash = ? AND model = ?", hash, ocrSettings.Model).First(&entry).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("OCR: cache lookup failed: %v", err)
+		}
+		return "", false
+	}
+
+	config.DB.Model(&entry).Where("content_hash = ? AND model = ?", hash, ocrSettings.Model).
+		Updates(map[string]interface{}{"hits": gorm.Expr("hits + 1"), "last_used_at": time.Now()})
+	return entry.Text, true
+}
+
+func storeOCR(hash, text string) {
+	if !ocrSettings.CacheEnabled || config.DB == nil {
+		return
+	}
+
+	now := time.Now()
+	entry := models.OCRCacheEntry{ContentHash: hash, Model: ocrSettings.Model, Text: text, CreatedAt: now, LastUsedAt: now}
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		log.Printf("OCR: failed to cache result: %v", err)
+	}
+}
+
+// tokenBucket allows burst requests at once and then ratePerMinute on average
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	burst    float64
+	perToken time.Duration
+	last     time.Time
+}
+
+func newTokenBucket(ratePerMinute, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	b := &tokenBucket{tokens: float64(burst), burst: float64(burst), last: time.Now()}
+	if ratePerMinute > 0 {
+		b.perToken = time.Minute / time.Duration(ratePerMinute)
+	}
+	return b
+}
+
+// Wait blocks until a token is available or ctx is done. A zero rate means no limit.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if b.perToken == 0 {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += float64(now.Sub(b.last)) / float64(b.perToken)
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) * float64(b.perToken))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurstAndLimit(t *testing.T) {
+	b := newTokenBucket(60, 3) // one token a second
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("burst call %d: %v", i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("burst of 3 took %s, want no wait", elapsed)
+	}
+
+	// The bucket is empty; the next token is a second away
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait on an empty bucket = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	b := newTokenBucket(60, 2)
+	b.tokens = 0
+
+	// Half a token's time has passed: still nothing to take
+	b.last = time.Now().Add(-500 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); err == nil {
+		t.Fatal("Wait succeeded after half a refill period")
+	}
+
+	// An idle hour refills only up to the burst
+	b.last = time.Now().Add(-time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("refilled call %d: %v", i+1, err)
+		}
+	}
+	if b.tokens >= 1 {
+		t.Fatalf("after an idle hour and 2 calls %.2f tokens left, want the burst of 2 capped", b.tokens)
+	}
+
+	// A short rate waits for the next token instead of failing
+	fast := newTokenBucket(6000, 1) // one token every 10ms
+	fast.Wait(context.Background())
+	start := time.Now()
+	if err := fast.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait for refill: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("second call waited %s, want about 10ms", elapsed)
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	b := newTokenBucket(0, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 100; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatalf("unlimited bucket call %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestRunOCRKeepsInputOrder(t *testing.T) {
+	dir := t.TempDir()
+	previousUploads, previousOCR, previousLimiter := uploads, ocrSettings, ocrLimiter
+	t.Cleanup(func() { uploads, ocrSettings, ocrLimiter = previousUploads, previousOCR, previousLimiter })
+	uploads.Dir = dir
+	ocrSettings = OCRSettings{Model: "gpt-4o", Workers: 4}
+	ocrLimiter = newTokenBucket(0, 1)
+
+	// Each image holds its own text; later images are answered first, so
+	// workers finish out of order
+	const count = 8
+	var images []string
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("page%d.png", i)
+		content := fmt.Sprintf("page %d", i)
+		if i == 5 {
+			content = "unreadable"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, name)
+	}
+	images = append(images, "missing.png")
+
+	var inFlight, maxInFlight atomic.Int32
+	withTestAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		dataURL := req.Messages[0].Content[1].ImageURL.URL
+		data, _ := base64.StdEncoding.DecodeString(dataURL[strings.Index(dataURL, ",")+1:])
+		text := string(data)
+		if text == "unreadable" {
+			http.Error(w, `{"error":{"message":"invalid image"}}`, http.StatusBadRequest)
+			return
+		}
+
+		var page int
+		fmt.Sscanf(text, "page %d", &page)
+		time.Sleep(time.Duration(count-page) * 5 * time.Millisecond)
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []Choice{{Message: MessageResponse{Content: text}}}})
+	})
+
+	batch, err := RunOCR(context.Background(), images)
+	if err != nil {
+		t.Fatalf("RunOCR: %v", err)
+	}
+
+	if len(batch.Images) != len(images) {
+		t.Fatalf("got %d results for %d images", len(batch.Images), len(images))
+	}
+	var want []string
+	for i, r := range batch.Images {
+		if r.Image != images[i] {
+			t.Errorf("result %d is for %s, want %s", i, r.Image, images[i])
+		}
+		switch {
+		case i == 5 || i == count:
+			if r.Status != OCRStatusFailed {
+				t.Errorf("%s status = %s, want %s", r.Image, r.Status, OCRStatusFailed)
+			}
+		case r.Status != OCRStatusOK || r.Text != fmt.Sprintf("page %d", i):
+			t.Errorf("%s = %s %q, want ok %q", r.Image, r.Status, r.Text, fmt.Sprintf("page %d", i))
+		default:
+			want = append(want, r.Text)
+		}
+	}
+	if batch.Text != strings.Join(want, ocrImageSeparator) {
+		t.Errorf("batch text = %q, want the readable pages in input order", batch.Text)
+	}
+	if batch.Succeeded != count-1 || batch.Failed != 2 {
+		t.Errorf("succeeded %d, failed %d; want %d and 2", batch.Succeeded, batch.Failed, count-1)
+	}
+	if got := maxInFlight.Load(); got > int32(ocrSettings.Workers) {
+		t.Errorf("%d OCR requests in flight at once, want at most %d workers", got, ocrSettings.Workers)
+	}
+}
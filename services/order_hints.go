@@ -0,0 +1,105 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+
+	"customflow/models"
+)
+
+// OrderHints are order fields spotted in free text such as a chat message or OCR output
+type OrderHints struct {
+	Length      *float64 `json:"length,omitempty"`
+	Width       *float64 `json:"width,omitempty"`
+	Thickness   string   `json:"thickness,omitempty"`
+	CornerStyle string   `json:"corner_style,omitempty"`
+	LengthInput string   `json:"length_input,omitempty"` // as written, e.g. "4ft"
+	WidthInput  string   `json:"width_input,omitempty"`
+	Unit        string   `json:"unit,omitempty"` // unit the dimensions were written in
+}
+
+var (
+	thicknessPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*mm\b`)
+
+	// Labelled fields, as in marketplace customization forms ("Length (inches): 48")
+	lengthFieldPattern = regexp.MustCompile(`(?im)^\s*(?:table\s+)?length\b([^:\n]*):\s*(.+)$`)
+	widthFieldPattern  = regexp.MustCompile(`(?im)^\s*(?:table\s+)?width\b([^:\n]*):\s*(.+)$`)
+	labelUnitPattern   = regexp.MustCompile(`(?i)\b(inches|inch|in|cm|mm|feet|ft|m)\b`)
+
+	// Corner styles, most explicit first: a labelled field ("Corner style:
+	// Sharp"), a phrase ("rounded corners", "custom shape"), then a bare word.
+	// Whole words only, so "around" and "background" say nothing.
+	cornerPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?im)^\s*corners?(?:\s+style)?\s*:\s*(rounded|round|sharp|square|custom)\b`),
+		regexp.MustCompile(`(?i)\b(rounded|round|sharp|square|custom)(?:[\s-]+edged?)?\s+(?:corners?|shape)\b`),
+		regexp.MustCompile(`(?i)\b(rounded|round|sharp)\b`),
+	}
+	cornerStyleWords = map[string]string{
+		"rounded": "rounded",
+		"round":   "rounded",
+		"sharp":   "sharp",
+		"square":  "sharp",
+		"custom":  "custom",
+	}
+)
+
+// ParseOrderHints looks for "L x W" (or labelled length/width) dimensions,
+// a thickness and a corner style in text
+func ParseOrderHints(text string) OrderHints {
+	var hints OrderHints
+
+	length, width, ok := FindDimensionPair(text)
+	if !ok {
+		length, width, ok = labelledDimensions(text)
+	}
+	if ok {
+		hints.Length, hints.Width = &length.Inches, &width.Inches
+		hints.LengthInput, hints.WidthInput = length.Input, width.Input
+		hints.Unit = CombinedUnit(length, width)
+	}
+
+	for _, m := range thicknessPattern.FindAllStringSubmatch(text, -1) {
+		if candidate := m[1] + "mm"; containsString(models.OrderThicknesses, candidate) {
+			hints.Thickness = candidate
+			break
+		}
+	}
+
+	for _, pattern := range cornerPatterns {
+		if m := pattern.FindStringSubmatch(text); m != nil {
+			hints.CornerStyle = cornerStyleWords[strings.ToLower(m[1])]
+			break
+		}
+	}
+
+	return hints
+}
+
+// labelledDimensions reads "Length (cm): 120" / "Width (cm): 60" lines
+func labelledDimensions(text string) (Dimension, Dimension, bool) {
+	lm, wm := lengthFieldPattern.FindStringSubmatch(text), widthFieldPattern.FindStringSubmatch(text)
+	if lm == nil || wm == nil {
+		return Dimension{}, Dimension{}, false
+	}
+
+	parse := func(m []string) (Dimension, error) {
+		unit := ""
+		if um := labelUnitPattern.FindStringSubmatch(m[1]); um != nil {
+			unit = um[1]
+		}
+		return ParseDimension(m[2], unit)
+	}
+
+	length, errL := parse(lm)
+	width, errW := parse(wm)
+	return length, width, errL == nil && errW == nil
+}
+
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
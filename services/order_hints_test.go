@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestParseOrderHintsCornerStyle(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"around 48 x 30 inches", ""},
+		{"white background, sharp corners", "sharp"},
+		{"Photo has a round plate in the background. Square corners please", "sharp"},
+		{"48 x 30 inches, 2mm, rounded corners please", "rounded"},
+		{"round-edged corners", "rounded"},
+		{"a custom shape to fit the bay window", "custom"},
+		{"Size: 72 x 42 inches\nCorner style: Sharp", "sharp"},
+		{"Corners: Rounded\nThickness: 2mm", "rounded"},
+		{"it's a round table", "rounded"},
+		{"square table, 36 x 36", ""},
+		{"the edges are sharp", "sharp"},
+		{"sharpen the photo", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseOrderHints(tt.text).CornerStyle; got != tt.want {
+			t.Errorf("ParseOrderHints(%q).CornerStyle = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"customflow/config"
+)
+
+// MessageTemplate is the text for one audience/event/status/source combination.
+// Subject, Body and WhatsAppParams are Go text/templates over orderMessageData.
+type MessageTemplate struct {
+	Subject          string   `json:"subject"`
+	Body             string   `json:"body"`
+	WhatsAppTemplate string   `json:"whatsapp_template,omitempty"`
+	WhatsAppParams   []string `json:"whatsapp_params,omitempty"`
+	Disabled         bool     `json:"disabled,omitempty"`
+}
+
+// Default templates, keyed "<audience>.<event>[.<status>][.<source>]".
+// Lookups fall back from the most to the least specific key.
+var defaultMessageTemplates = map[string]MessageTemplate{
+	"customer.order.created": {
+		Subject:          "We received your order {{.OrderID}}",
+		Body:             "Hi {{.CustomerName}}, thank you for your order {{.OrderID}} ({{.Dimensions}}, {{.Thickness}}). We will deliver by {{.PromisedBy}}.",
+		WhatsAppTemplate: "order_received",
+		WhatsAppParams:   []string{"{{.CustomerName}}", "{{.OrderID}}", "{{.PromisedBy}}"},
+	},
+	"customer.order.status_changed.new": {Disabled: true},
+	"customer.order.status_changed.in-progress": {
+		Subject:          "Your order {{.OrderID}} is in production",
+		Body:             "Hi {{.CustomerName}}, good news! Your table cover {{.OrderID}} is now being made.",
+		WhatsAppTemplate: "order_in_progress",
+		WhatsAppParams:   []string{"{{.CustomerName}}", "{{.OrderID}}"},
+	},
+	"customer.order.status_changed.done": {
+		Subject:          "Your order {{.OrderID}} is ready",
+		Body:             "Hi {{.CustomerName}}, your table cover {{.OrderID}} ({{.Dimensions}}) is ready and on its way. Thank you for choosing CustomFlow!",
+		WhatsAppTemplate: "order_ready",
+		WhatsAppParams:   []string{"{{.CustomerName}}", "{{.OrderID}}"},
+	},
+	"customer.order.deleted": {Disabled: true},
+	"staff.order.created": {
+		Subject: "New {{.Source}} order {{.OrderID}}",
+		Body:    "New {{.Source}} order {{.OrderID}} for {{.CustomerName}}: {{.Dimensions}}, {{.Thickness}}, {{.CornerStyle}} corners. Due {{.DueAt}}.",
+	},
+	"staff.order.status_changed": {
+		Subject: "Order {{.OrderID}} is now {{.Status}}",
+		Body:    "Order {{.OrderID}} ({{.Source}}) moved from '{{.OldStatus}}' to '{{.Status}}'.",
+	},
+	"staff.order.deleted": {
+		Subject: "Order {{.OrderID}} was deleted",
+		Body:    "Order {{.OrderID}} ({{.Source}}) for {{.CustomerName}} was deleted.",
+	},
+}
+
+// Customer channel per order source; amazon buyers are reached through Amazon, not by us
+var customerChannelBySource = map[string]string{
+	"whatsapp": "whatsapp",
+	"sms":      "sms",
+	"call":     "sms",
+}
+
+type orderMessageData struct {
+	OrderID      string
+	CustomerName string
+	Source       string
+	Status       string
+	OldStatus    string
+	Dimensions   string
+	Thickness    string
+	CornerStyle  string
+	PromisedBy   string
+	DueAt        string
+}
+
+var (
+	notifiers        = map[string]Notifier{}
+	messageTemplates = map[string]MessageTemplate{}
+	staffRecipients  string
+)
+
+// InitNotifications configures the channel drivers from the notifications
+// section and subscribes the customer/staff notifier to order events. A
+// channel without its host, gateway or WhatsApp number and token logs instead
+// of sending, and so does every channel with the log driver.
+func InitNotifications(cfg config.NotificationConfig, whatsApp config.WhatsAppConfig) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	logOnly := cfg.Driver == "log"
+
+	notifiers["email"] = NewLogNotifier()
+	if cfg.SMTP.Host != "" && !logOnly {
+		notifiers["email"] = SMTPNotifier{
+			Host:     cfg.SMTP.Host,
+			Port:     strconv.Itoa(cfg.SMTP.Port),
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+		}
+	}
+
+	notifiers["sms"] = NewLogNotifier()
+	if cfg.SMSGateway.URL != "" && !logOnly {
+		notifiers["sms"] = SMSGatewayNotifier{
+			URL:    cfg.SMSGateway.URL,
+			APIKey: cfg.SMSGateway.APIKey,
+			Sender: cfg.SMSGateway.Sender,
+			Client: client,
+		}
+	}
+
+	notifiers["whatsapp"] = NewLogNotifier()
+	if whatsApp.AccessToken != "" && whatsApp.PhoneNumberID != "" && !logOnly {
+		notifiers["whatsapp"] = WhatsAppTemplateNotifier{
+			APIURL:        whatsApp.APIURL,
+			PhoneNumberID: whatsApp.PhoneNumberID,
+			AccessToken:   whatsApp.AccessToken,
+			Language:      whatsApp.TemplateLanguage,
+			Client:        client,
+		}
+	}
+
+	staffRecipients = strings.Join(cfg.StaffEmails, ",")
+
+	for key, tmpl := range defaultMessageTemplates {
+		messageTemplates[key] = tmpl
+	}
+	if path := cfg.TemplatesFile; path != "" {
+		if err := loadMessageTemplates(path); err != nil {
+			log.Printf("WARNING: Failed to load notification templates from %s: %v", path, err)
+		}
+	}
+
+	SubscribeEvent(AllEvents, notifyOrderEvent)
+
+	for _, channel := range []string{"email", "sms", "whatsapp"} {
+		log.Printf("Notifications: %s channel uses %T", channel, notifiers[channel])
+	}
+}
+
+// StaffNotifier returns the channel used for staff alerts
+func StaffNotifier() Notifier {
+	return notifiers["email"]
+}
+
+// StaffRecipients returns the configured staff addresses
+func StaffRecipients() string {
+	return staffRecipients
+}
+
+func loadMessageTemplates(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var overrides map[string]MessageTemplate
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return err
+	}
+
+	for key, tmpl := range overrides {
+		messageTemplates[key] = tmpl
+	}
+	log.Printf("Notifications: loaded %d template overrides from %s", len(overrides), path)
+	return nil
+}
+
+// notifyOrderEvent tells the customer (over their source channel) and staff about an order event
+func notifyOrderEvent(ctx context.Context, e Event) {
+	data := newOrderMessageData(e)
+
+	if channel, ok := customerChannelBySource[e.Order.Source]; ok && e.Order.PhoneNumber != "" {
+		sendTemplated(ctx, "customer", channel, e, data, e.Order.PhoneNumber)
+	}
+
+	if staffRecipients != "" {
+		sendTemplated(ctx, "staff", "email", e, data, staffRecipients)
+	}
+}
+
+func sendTemplated(ctx context.Context, audience, channel string, e Event, data orderMessageData, recipient string) {
+	tmpl, ok := lookupMessageTemplate(audience, e.Type, e.Order.Status, e.Order.Source)
+	if !ok || tmpl.Disabled {
+		return
+	}
+
+	n, err := renderNotification(tmpl, data)
+	if err != nil {
+		log.Printf("Notifications: failed to render %s %s template: %v", audience, e.Type, err)
+		return
+	}
+	n.Event = e.Type
+	n.Recipient = recipient
+	n.OrderID = e.Order.ID
+
+	notifier := notifiers[channel]
+	if err := notifier.Send(ctx, n); err != nil {
+		log.Printf("Notifications: failed to send %s %s notification for order %s: %v",
+			channel, e.Type, e.Order.OrderID, err)
+		return
+	}
+	log.Printf("Notifications: sent %s %s notification for order %s", channel, e.Type, e.Order.OrderID)
+}
+
+func lookupMessageTemplate(audience, eventType, status, source string) (MessageTemplate, bool) {
+	base := audience + "." + eventType
+	for _, key := range []string{
+		base + "." + status + "." + source,
+		base + "." + status,
+		base + "." + source,
+		base,
+	} {
+		if tmpl, ok := messageTemplates[key]; ok {
+			return tmpl, true
+		}
+	}
+	return MessageTemplate{}, false
+}
+
+func renderNotification(tmpl MessageTemplate, data orderMessageData) (Notification, error) {
+	var n Notification
+	var err error
+
+	if n.Subject, err = renderText(tmpl.Subject, data); err != nil {
+		return n, err
+	}
+	if n.Body, err = renderText(tmpl.Body, data); err != nil {
+		return n, err
+	}
+
+	n.Template = tmpl.WhatsAppTemplate
+	for _, param := range tmpl.WhatsAppParams {
+		value, err := renderText(param, data)
+		if err != nil {
+			return n, err
+		}
+		n.TemplateParams = append(n.TemplateParams, value)
+	}
+	return n, nil
+}
+
+func renderText(text string, data interface{}) (string, error) {
+	t, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func newOrderMessageData(e Event) orderMessageData {
+	o := e.Order
+	data := orderMessageData{
+		OrderID:      o.OrderID,
+		CustomerName: o.CustomerName,
+		Source:       o.Source,
+		Status:       o.Status,
+		OldStatus:    e.OldStatus,
+		Dimensions:   fmt.Sprintf("%.2f x %.2f in", o.Length, o.Width),
+		Thickness:    o.Thickness,
+		CornerStyle:  o.CornerStyle,
+	}
+	if data.CustomerName == "" {
+		data.CustomerName = "there"
+	}
+	if o.PromisedBy != nil {
+		data.PromisedBy = o.PromisedBy.Format("2 Jan 2006")
+	}
+	if o.DueAt != nil {
+		data.DueAt = o.DueAt.Format("2006-01-02 15:04")
+	}
+	return data
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+// recordingNotifier keeps every notification instead of delivering it
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Send(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+// initLogNotifications runs InitNotifications with the log driver and swaps
+// every channel for a recorder, restoring the package state afterwards
+func initLogNotifications(t *testing.T, cfg config.NotificationConfig) map[string]*recordingNotifier {
+	t.Helper()
+	savedNotifiers, savedTemplates, savedStaff := notifiers, messageTemplates, staffRecipients
+	notifiers, messageTemplates = map[string]Notifier{}, map[string]MessageTemplate{}
+	t.Cleanup(func() {
+		notifiers, messageTemplates, staffRecipients = savedNotifiers, savedTemplates, savedStaff
+	})
+
+	cfg.Driver = "log"
+	InitNotifications(cfg, config.WhatsAppConfig{AccessToken: "token", PhoneNumberID: "123"})
+
+	recorders := map[string]*recordingNotifier{}
+	for _, channel := range []string{"email", "sms", "whatsapp"} {
+		if _, ok := notifiers[channel].(*LogNotifier); !ok {
+			t.Fatalf("%s channel uses %T with the log driver", channel, notifiers[channel])
+		}
+		recorders[channel] = &recordingNotifier{}
+		notifiers[channel] = recorders[channel]
+	}
+	return recorders
+}
+
+func TestLogDriverOverridesConfiguredChannels(t *testing.T) {
+	initLogNotifications(t, config.NotificationConfig{
+		SMTP:       config.SMTPConfig{Host: "smtp.example.com", Port: 587},
+		SMSGateway: config.SMSGatewayConfig{URL: "https://sms.example.com"},
+	})
+}
+
+func TestNotifyOrderEventChannels(t *testing.T) {
+	promised := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	order := func(source, status, phone string) models.Order {
+		return models.Order{ID: 7, OrderID: "CF-7", Source: source, Status: status, PhoneNumber: phone,
+			Length: 48, Width: 30, Thickness: "2mm", PromisedBy: &promised}
+	}
+
+	tests := []struct {
+		name      string
+		staff     []string
+		event     Event
+		wantEmail int
+		wantSMS   int
+		wantWA    int
+	}{
+		{"whatsapp order to customer and staff", []string{"shop@example.com"},
+			Event{Type: EventOrderCreated, Order: order("whatsapp", "new", "+919876543210")}, 1, 0, 1},
+		{"call order goes out by sms", nil,
+			Event{Type: EventOrderCreated, Order: order("call", "new", "+919876543210")}, 0, 1, 0},
+		{"amazon buyer is not contacted", []string{"shop@example.com"},
+			Event{Type: EventOrderCreated, Order: order("amazon", "new", "+919876543210")}, 1, 0, 0},
+		{"no phone, no customer message", nil,
+			Event{Type: EventOrderCreated, Order: order("sms", "new", "")}, 0, 0, 0},
+		{"disabled customer template", nil,
+			Event{Type: EventOrderStatusChanged, OldStatus: "done", Order: order("sms", "new", "+919876543210")}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := initLogNotifications(t, config.NotificationConfig{StaffEmails: tt.staff})
+			notifyOrderEvent(context.Background(), tt.event)
+
+			if got := len(rec["email"].sent); got != tt.wantEmail {
+				t.Errorf("email sent %d, want %d", got, tt.wantEmail)
+			}
+			if got := len(rec["sms"].sent); got != tt.wantSMS {
+				t.Errorf("sms sent %d, want %d", got, tt.wantSMS)
+			}
+			if got := len(rec["whatsapp"].sent); got != tt.wantWA {
+				t.Errorf("whatsapp sent %d, want %d", got, tt.wantWA)
+			}
+		})
+	}
+}
+
+func TestNotifyOrderEventRendersTemplates(t *testing.T) {
+	rec := initLogNotifications(t, config.NotificationConfig{StaffEmails: []string{"a@example.com", "b@example.com"}})
+	promised := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	notifyOrderEvent(context.Background(), Event{Type: EventOrderCreated, Order: models.Order{
+		ID: 7, OrderID: "CF-7", Source: "whatsapp", Status: "new", PhoneNumber: "+919876543210",
+		Length: 48, Width: 30, Thickness: "2mm", CornerStyle: "rounded", PromisedBy: &promised,
+	}})
+
+	wa := rec["whatsapp"].sent[0]
+	if wa.Recipient != "+919876543210" || wa.Event != EventOrderCreated || wa.OrderID != 7 {
+		t.Errorf("whatsapp notification = %+v", wa)
+	}
+	if wa.Template != "order_received" {
+		t.Errorf("whatsapp template = %q", wa.Template)
+	}
+	wantParams := []string{"there", "CF-7", "5 Mar 2026"}
+	if len(wa.TemplateParams) != len(wantParams) {
+		t.Fatalf("whatsapp params = %q, want %q", wa.TemplateParams, wantParams)
+	}
+	for i, want := range wantParams {
+		if wa.TemplateParams[i] != want {
+			t.Errorf("whatsapp param %d = %q, want %q", i, wa.TemplateParams[i], want)
+		}
+	}
+
+	staff := rec["email"].sent[0]
+	if staff.Recipient != "a@example.com,b@example.com" {
+		t.Errorf("staff recipient = %q", staff.Recipient)
+	}
+	if want := "New whatsapp order CF-7"; staff.Subject != want {
+		t.Errorf("staff subject = %q, want %q", staff.Subject, want)
+	}
+	if want := "New whatsapp order CF-7 for there: 48.00 x 30.00 in, 2mm, rounded corners. Due ."; staff.Body != want {
+		t.Errorf("staff body = %q, want %q", staff.Body, want)
+	}
+}
+
+func TestMessageTemplateOverridesAndFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	overrides := `{
+		"customer.order.status_changed.done.sms": {"subject": "Ready", "body": "{{.OrderID}} is ready for pickup"},
+		"customer.order.status_changed.in-progress": {"disabled": true}
+	}`
+	if err := os.WriteFile(path, []byte(overrides), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := initLogNotifications(t, config.NotificationConfig{TemplatesFile: path})
+
+	// The status+source override wins for sms; whatsapp falls back to the status template
+	notifyOrderEvent(context.Background(), Event{Type: EventOrderStatusChanged, OldStatus: "in-progress",
+		Order: models.Order{OrderID: "CF-8", Source: "sms", Status: "done", PhoneNumber: "555"}})
+	notifyOrderEvent(context.Background(), Event{Type: EventOrderStatusChanged, OldStatus: "in-progress",
+		Order: models.Order{OrderID: "CF-9", Source: "whatsapp", Status: "done", PhoneNumber: "555"}})
+	notifyOrderEvent(context.Background(), Event{Type: EventOrderStatusChanged, OldStatus: "new",
+		Order: models.Order{OrderID: "CF-10", Source: "sms", Status: "in-progress", PhoneNumber: "555"}})
+
+	if len(rec["sms"].sent) != 1 || rec["sms"].sent[0].Body != "CF-8 is ready for pickup" {
+		t.Errorf("sms sent %+v, want only the override", rec["sms"].sent)
+	}
+	if len(rec["whatsapp"].sent) != 1 || rec["whatsapp"].sent[0].Template != "order_ready" {
+		t.Errorf("whatsapp sent %+v, want the default done template", rec["whatsapp"].sent)
+	}
+
+	if _, ok := lookupMessageTemplate("staff", EventOrderUpdated, "new", "sms"); ok {
+		t.Error("found a staff template for order.updated, which has none")
+	}
+}
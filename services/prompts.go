@@ -0,0 +1,450 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// Prompt template names
+const (
+	PromptSystem    = "system"    // shared system prompt
+	PromptReply     = "reply"     // customer reply instructions; .Message and .Tone
+	PromptChat      = "chat"      // staff chat system prompt; .System is the rendered system prompt
+	PromptOrder     = "order"     // the customer's order for grounded replies; .Order
+	PromptLanguage  = "language"  // the language to reply in; .Language and .CustomerLanguage
+	PromptTranslate = "translate" // translation for staff; .CustomerLanguage into .Language
+	PromptImages    = "images"    // photos or screenshots sent with the message; .Images is how many
+)
+
+// PromptNames lists the templates the AI features render
+var PromptNames = []string{PromptSystem, PromptReply, PromptChat, PromptOrder, PromptLanguage, PromptTranslate, PromptImages}
+
+// ErrPromptTemplateNotFound is returned for unknown template IDs
+var ErrPromptTemplateNotFound = errors.New("prompt template not found")
+
+// promptCacheTTL bounds how long another instance's template edits take to apply here
+const promptCacheTTL = time.Minute
+
+// PromptVars are the values a template can use. The business details come
+// from the running configuration, so prompts never disagree with validation.
+type PromptVars struct {
+	Thicknesses         []string
+	CornerStyles        []string
+	Sources             []string
+	DueDays             int
+	PromiseDays         int
+	PromiseDaysBySource map[string]int
+
+	Message          string
+	Tone             string
+	System           string
+	Order            *OrderFacts
+	Language         Language
+	CustomerLanguage Language
+	Images           int
+}
+
+// RenderedPrompt is a template's output and the version that produced it;
+// version 0 is the built-in default, used while the table has no active version
+type RenderedPrompt struct {
+	Name    string
+	Version int
+	Text    string
+}
+
+// defaultPrompts are used when prompt_templates has no active version of a name
+var defaultPrompts = map[string]string{
+	PromptSystem: `You are a professional customer service assistant for CustomFlow, a premium custom table cover manufacturing business.
+
+Your role:
+- Provide helpful, accurate information about custom table covers
+- Maintain a professional yet approachable tone
+- Focus on dimensions, materials, delivery timelines, and customization options
+- Always prioritize customer satisfaction
+- Keep responses concise but informative
+
+Key information about our business:
+- We specialize in custom table covers for dining tables, office tables, conference tables
+- Materials: thicknesses of {{join .Thicknesses ", "}} and corner styles {{join .CornerStyles ", "}}; we do not make other thicknesses
+- Standard delivery: {{.PromiseDays}} business days{{range $source, $days := .PromiseDaysBySource}}{{if ne $days $.PromiseDays}} ({{$days}} for {{$source}} orders){{end}}{{end}}
+- We serve customers through Amazon, WhatsApp, SMS, and phone orders
+- Premium quality and precise measurements are our specialties
+- We work in inches but accept sizes in cm, mm or feet and inches
+
+Always be helpful and ensure customers have the information they need to place their order.`,
+
+	PromptReply: `Customer message: "{{.Message}}"
+
+{{if eq .Tone "formal"}}Generate a formal, professional response for business correspondence. Use proper business language while addressing table cover requirements.{{else if eq .Tone "short"}}Generate a brief, concise response under 50 words. Focus on essential information - dimensions, material, and delivery.{{else}}Generate a warm, friendly response while remaining professional. Show enthusiasm for helping with custom table cover needs.{{end}}`,
+
+	PromptChat: `{{.System}}
+
+You are now talking with a CustomFlow staff member, not a customer. Help them with
+order questions, drafting customer messages and working out table cover specifications.
+Keep answers short and practical.`,
+
+	PromptOrder: `The customer has this order with us:
+- Order: {{.Order.Reference}} for {{.Order.CustomerName}}, placed via {{.Order.Source}} on {{.Order.OrderedOn}}
+- Status: {{.Order.StatusText}}
+- Size: {{.Order.Size}}, {{.Order.Thickness}} thick, {{.Order.CornerStyle}} corners
+{{- if .Order.PromisedBy}}
+- Promised delivery by: {{.Order.PromisedBy}}
+{{- end}}
+{{- if .Order.IsOverdue}}
+- The order is behind schedule: apologise for the delay and say the team is prioritising it
+{{- end}}
+
+Answer questions about the order from these facts only. Do not invent tracking numbers, carriers
+or dates that are not listed; if the customer asks for something not listed, say a team member
+will follow up.`,
+
+	PromptLanguage: `{{if ne .CustomerLanguage.Code "und"}}The customer wrote in {{.CustomerLanguage.Name}}. {{end}}Write the whole reply in {{.Language.Name}}.
+Keep order references, sizes, thicknesses and dates exactly as written above.`,
+
+	PromptTranslate: `Translate the customer's message{{if ne .CustomerLanguage.Code "und"}} from {{.CustomerLanguage.Name}}{{end}} into {{.Language.Name}}
+for CustomFlow staff. Keep numbers, sizes, units, names and order references exactly as written.
+Reply with the translation only.`,
+
+	PromptImages: `The customer attached {{if eq .Images 1}}a photo or screenshot{{else}}{{.Images}} photos or screenshots{{end}} to their message.
+Use what you can see in {{if eq .Images 1}}it{{else}}them{{end}} to answer. If a photo shows a table or surface with a tape measure
+or ruler, read the measurement and confirm the length and width you can see, with the unit, and ask
+the customer to check any reading that is unclear.{{if .Order}} Say whether it matches the order size above.{{end}}
+Do not guess at anything you cannot see.`,
+}
+
+var promptFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// parsedDefaultPrompts are the defaults, parsed once at startup
+var parsedDefaultPrompts = func() map[string]*template.Template {
+	parsed := map[string]*template.Template{}
+	for name, body := range defaultPrompts {
+		parsed[name] = template.Must(template.New(name).Funcs(promptFuncs).Parse(body))
+	}
+	return parsed
+}()
+
+type promptChoice struct {
+	template models.PromptTemplate
+	parsed   *template.Template
+}
+
+var promptCache struct {
+	sync.Mutex
+	loadedAt time.Time
+	byName   map[string][]promptChoice
+}
+
+// promptVars returns the configuration part of the template variables
+func promptVars() PromptVars {
+	vars := PromptVars{
+		Thicknesses:         models.OrderThicknesses,
+		CornerStyles:        models.OrderCornerStyles,
+		Sources:             models.OrderSources,
+		DueDays:             3,
+		PromiseDays:         5,
+		PromiseDaysBySource: map[string]int{},
+	}
+	if slaConfig != nil {
+		vars.DueDays = slaConfig.Default.DueDays
+		vars.PromiseDays = slaConfig.Default.PromiseDays
+		for source, policy := range slaConfig.BySource {
+			vars.PromiseDaysBySource[source] = policy.PromiseDays
+		}
+	}
+	return vars
+}
+
+// RenderPrompt renders one of the active versions of a template, picked in
+// proportion to their weights. A version that fails to render falls back to
+// the built-in default, so a bad edit cannot stop replies.
+func RenderPrompt(name string, vars PromptVars) RenderedPrompt {
+	if choice, ok := pickPrompt(name); ok {
+		var text strings.Builder
+		err := choice.parsed.Execute(&text, vars)
+		if err == nil {
+			return RenderedPrompt{Name: name, Version: choice.template.Version, Text: text.String()}
+		}
+		log.Printf("Prompts: %s v%d failed to render, using default: %v", name, choice.template.Version, err)
+	}
+
+	var text strings.Builder
+	if err := parsedDefaultPrompts[name].Execute(&text, vars); err != nil {
+		log.Printf("Prompts: default %s failed to render: %v", name, err)
+	}
+	return RenderedPrompt{Name: name, Text: text.String()}
+}
+
+func pickPrompt(name string) (promptChoice, bool) {
+	choices := activePrompts()[name]
+	total := 0
+	for _, choice := range choices {
+		total += choice.template.Weight
+	}
+	if total == 0 {
+		return promptChoice{}, false
+	}
+
+	n := rand.Intn(total)
+	for _, choice := range choices {
+		if n < choice.template.Weight {
+			return choice, true
+		}
+		n -= choice.template.Weight
+	}
+	return promptChoice{}, false
+}
+
+// activePrompts returns the parsed active templates by name, reloading them
+// once promptCacheTTL has passed
+func activePrompts() map[string][]promptChoice {
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	if config.DB == nil || time.Since(promptCache.loadedAt) < promptCacheTTL {
+		return promptCache.byName
+	}
+
+	var templates []models.PromptTemplate
+	if err := config.DB.Where("active").Order("name, version").Find(&templates).Error; err != nil {
+		log.Printf("Prompts: failed to load templates: %v", err)
+		return promptCache.byName
+	}
+
+	byName := map[string][]promptChoice{}
+	for _, t := range templates {
+		parsed, err := parsePrompt(t.Name, t.Body)
+		if err != nil {
+			log.Printf("Prompts: skipping %s v%d: %v", t.Name, t.Version, err)
+			continue
+		}
+		byName[t.Name] = append(byName[t.Name], promptChoice{template: t, parsed: parsed})
+	}
+	promptCache.byName = byName
+	promptCache.loadedAt = time.Now()
+	return byName
+}
+
+// invalidatePrompts makes the next render reload the templates
+func invalidatePrompts() {
+	promptCache.Lock()
+	promptCache.loadedAt = time.Time{}
+	promptCache.Unlock()
+}
+
+func parsePrompt(name, body string) (*template.Template, error) {
+	return template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(body)
+}
+
+// ValidatePrompt parses a template body and renders it with sample values,
+// so that mistyped variables are caught before the version is saved
+func ValidatePrompt(name, body string) error {
+	if !containsString(PromptNames, name) {
+		return fmt.Errorf("unknown prompt name %q, expected one of %s", name, strings.Join(PromptNames, ", "))
+	}
+	if strings.TrimSpace(body) == "" {
+		return errors.New("prompt body is empty")
+	}
+	parsed, err := parsePrompt(name, body)
+	if err != nil {
+		return err
+	}
+
+	vars := promptVars()
+	vars.Message, vars.Tone, vars.System = "Do you make covers for a 60 x 36 inch table?", "friendly", "System prompt"
+	vars.Order = &sampleOrderFacts
+	vars.Language, vars.CustomerLanguage = languageByCode("ta"), languageByCode("ta-Latn")
+	vars.Images = 1
+	var text strings.Builder
+	return parsed.Execute(&text, vars)
+}
+
+// sampleOrderFacts stand in for a customer's order when validating and previewing templates
+var sampleOrderFacts = OrderFacts{
+	Reference:    "ORD-SAMPLE",
+	CustomerName: "Sample Customer",
+	Source:       "whatsapp",
+	Status:       "in-progress",
+	StatusText:   orderStatusText["in-progress"],
+	Size:         "60 x 36 in",
+	Thickness:    "3mm",
+	CornerStyle:  "rounded",
+	OrderedOn:    "Monday 5 January 2026",
+	PromisedBy:   "Monday 12 January 2026",
+}
+
+// PreviewPrompt renders a template body, or the version picked for the name
+// when body is empty, with the given message and tone. The order is the
+// customer's order, or sample facts when nil; the language is the one
+// detected in the message and the reply language the one chosen. The
+// message is taken to have one image attached.
+func PreviewPrompt(name, body, message, tone, language string, order *models.Order) (RenderedPrompt, error) {
+	vars := promptVars()
+	vars.Message, vars.Tone, vars.Images = message, tone, 1
+	customer := DetectLanguage(message)
+	vars.CustomerLanguage, vars.Language = customer.Language, replyLanguage(language, customer)
+	vars.Order = &sampleOrderFacts
+	if order != nil {
+		facts := orderFacts(*order, time.Now())
+		vars.Order = &facts
+	}
+	if name == PromptChat {
+		vars.System = RenderPrompt(PromptSystem, vars).Text
+	}
+	if body == "" {
+		return RenderPrompt(name, vars), nil
+	}
+
+	if err := ValidatePrompt(name, body); err != nil {
+		return RenderedPrompt{}, err
+	}
+	parsed, _ := parsePrompt(name, body)
+	var text strings.Builder
+	if err := parsed.Execute(&text, vars); err != nil {
+		return RenderedPrompt{}, err
+	}
+	return RenderedPrompt{Name: name, Text: text.String()}, nil
+}
+
+// CreatePromptVersion saves t as the next version of its name. An active
+// version replaces the active ones unless abTest is set, in which case it is
+// served alongside them in proportion to its weight.
+func CreatePromptVersion(t *models.PromptTemplate, abTest bool) error {
+	if err := ValidatePrompt(t.Name, t.Body); err != nil {
+		return err
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Serializes concurrent edits of a name so versions stay unique
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "prompt_templates:"+t.Name).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PromptTemplate{}).Where("name = ?", t.Name).
+			Select("COALESCE(MAX(version), 0) + 1").Scan(&t.Version).Error; err != nil {
+			return err
+		}
+		if t.Active && !abTest {
+			if err := tx.Model(&models.PromptTemplate{}).Where("name = ? AND active", t.Name).
+				Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		t.CreatedAt = time.Now()
+		t.UpdatedAt = t.CreatedAt
+		return tx.Create(t).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidatePrompts()
+	return nil
+}
+
+// PromptTemplateUpdate changes whether and how often a version is served
+type PromptTemplateUpdate struct {
+	Active      *bool
+	Weight      *int
+	Description *string
+}
+
+// UpdatePromptTemplate applies an update to a version; a version's body never changes
+func UpdatePromptTemplate(id uint, update PromptTemplateUpdate) (*models.PromptTemplate, error) {
+	var t models.PromptTemplate
+	if err := config.DB.First(&t, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrPromptTemplateNotFound
+		}
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	if update.Active != nil {
+		changes["active"] = *update.Active
+	}
+	if update.Weight != nil {
+		changes["weight"] = *update.Weight
+	}
+	if update.Description != nil {
+		changes["description"] = *update.Description
+	}
+	if len(changes) == 0 {
+		return &t, nil
+	}
+
+	if err := config.DB.Model(&t).Updates(changes).Error; err != nil {
+		return nil, err
+	}
+	invalidatePrompts()
+	return &t, nil
+}
+
+// PromptVersionStats summarises the responses one template version produced
+// and what staff thought of them
+type PromptVersionStats struct {
+	Version         int        `json:"version"`
+	Responses       int        `json:"responses"`
+	Completed       int        `json:"completed"`
+	Cancelled       int        `json:"cancelled"`
+	Failed          int        `json:"failed"`
+	CancelRate      float64    `json:"cancel_rate"`
+	AvgLength       float64    `json:"avg_length"` // characters
+	RatedUp         int        `json:"rated_up"`
+	RatedDown       int        `json:"rated_down"`
+	ApprovalRate    *float64   `json:"approval_rate"`     // share of rated responses rated up
+	AvgEditDistance *float64   `json:"avg_edit_distance"` // of responses with a recorded final text
+	FirstUsed       *time.Time `json:"first_used"`
+	LastUsed        *time.Time `json:"last_used"`
+}
+
+// ComparePromptVersions aggregates the ai_responses of each version of a
+// template since a time, for comparing the versions of an A/B test
+func ComparePromptVersions(name string, since time.Time) ([]PromptVersionStats, error) {
+	stats := []PromptVersionStats{}
+	err := config.DB.Model(&models.AIResponse{}).
+		Select("(prompt_versions->>?)::int AS version, COUNT(*) AS responses, "+
+			"COUNT(*) FILTER (WHERE status = 'completed') AS completed, "+
+			"COUNT(*) FILTER (WHERE status = 'cancelled') AS cancelled, "+
+			"COUNT(*) FILTER (WHERE status = 'failed') AS failed, "+
+			"COALESCE(AVG(LENGTH(response)), 0) AS avg_length, "+
+			"COUNT(*) FILTER (WHERE rating = 1) AS rated_up, "+
+			"COUNT(*) FILTER (WHERE rating = -1) AS rated_down, "+
+			"AVG(edit_distance) AS avg_edit_distance, "+
+			"MIN(created_at) AS first_used, MAX(created_at) AS last_used", name).
+		Where("prompt_versions->>? IS NOT NULL AND created_at >= ?", name, since).
+		Group("1").
+		Order("1").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stats {
+		if stats[i].Responses > 0 {
+			stats[i].CancelRate = round2(float64(stats[i].Cancelled) / float64(stats[i].Responses))
+		}
+		if rated := stats[i].RatedUp + stats[i].RatedDown; rated > 0 {
+			approval := round2(float64(stats[i].RatedUp) / float64(rated))
+			stats[i].ApprovalRate = &approval
+		}
+		if stats[i].AvgEditDistance != nil {
+			*stats[i].AvgEditDistance = round2(*stats[i].AvgEditDistance)
+		}
+		stats[i].AvgLength = round2(stats[i].AvgLength)
+	}
+	return stats, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"customflow/models"
+)
+
+// withActivePrompts serves the given versions without a database
+func withActivePrompts(t *testing.T, versions ...models.PromptTemplate) {
+	t.Helper()
+	byName := map[string][]promptChoice{}
+	for _, v := range versions {
+		parsed, err := parsePrompt(v.Name, v.Body)
+		if err != nil {
+			t.Fatalf("parse %s v%d: %v", v.Name, v.Version, err)
+		}
+		byName[v.Name] = append(byName[v.Name], promptChoice{template: v, parsed: parsed})
+	}
+
+	promptCache.Lock()
+	saved := promptCache.byName
+	promptCache.byName = byName
+	promptCache.loadedAt = time.Now()
+	promptCache.Unlock()
+	t.Cleanup(func() {
+		promptCache.Lock()
+		promptCache.byName = saved
+		promptCache.Unlock()
+	})
+}
+
+func TestPickPromptFollowsWeights(t *testing.T) {
+	withActivePrompts(t,
+		models.PromptTemplate{Name: PromptReply, Version: 1, Body: "A", Active: true, Weight: 3},
+		models.PromptTemplate{Name: PromptReply, Version: 2, Body: "B", Active: true, Weight: 1},
+		models.PromptTemplate{Name: PromptReply, Version: 3, Body: "C", Active: true, Weight: 0},
+	)
+
+	const picks = 4000
+	counts := map[int]int{}
+	for i := 0; i < picks; i++ {
+		choice, ok := pickPrompt(PromptReply)
+		if !ok {
+			t.Fatal("pickPrompt found no version")
+		}
+		counts[choice.template.Version]++
+	}
+	if counts[3] != 0 {
+		t.Errorf("weight 0 version was picked %d times", counts[3])
+	}
+	if share := float64(counts[1]) / picks; share < 0.7 || share > 0.8 {
+		t.Errorf("weight 3 of 4 version got %.2f of the picks, want about 0.75", share)
+	}
+
+	if _, ok := pickPrompt(PromptChat); ok {
+		t.Error("pickPrompt found a version of a name without active versions")
+	}
+}
+
+func TestRenderPromptFallsBackToDefault(t *testing.T) {
+	// Parses, but fails to render while the reply has no order
+	withActivePrompts(t, models.PromptTemplate{Name: PromptReply, Version: 4, Body: "{{.Message}} about {{.Order.Reference}}", Active: true, Weight: 1})
+
+	vars := promptVars()
+	vars.Message, vars.Tone = "hello", "short"
+	got := RenderPrompt(PromptReply, vars)
+	if got.Version != 0 || !strings.Contains(got.Text, `Customer message: "hello"`) {
+		t.Errorf("RenderPrompt = %+v, want the default reply prompt", got)
+	}
+
+	vars.Order = &sampleOrderFacts
+	got = RenderPrompt(PromptReply, vars)
+	if got.Version != 4 || got.Text != "hello about ORD-SAMPLE" {
+		t.Errorf("RenderPrompt = %+v, want v4 with the order reference", got)
+	}
+}
+
+func TestValidatePrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{PromptReply, `Reply to "{{.Message}}" in a {{.Tone}} tone`, false},
+		{PromptSystem, `Thicknesses: {{join .Thicknesses ", "}}`, false},
+		{PromptOrder, `{{.Order.Reference}} is {{.Order.StatusText}}`, false},
+		{PromptReply, `Hi {{.CustomerName}}`, true}, // not a prompt variable
+		{PromptOrder, `{{.Order.Tracking}}`, true},
+		{PromptReply, `{{if .Message}}unclosed`, true},
+		{PromptReply, "  \n", true},
+		{"greeting", `Hello`, true},
+	}
+	for _, tt := range tests {
+		err := ValidatePrompt(tt.name, tt.body)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePrompt(%q, %q) error = %v, want error %v", tt.name, tt.body, err, tt.wantErr)
+		}
+	}
+
+	// An invalid body is rejected before anything is written
+	err := CreatePromptVersion(&models.PromptTemplate{Name: PromptReply, Body: "{{.Customer}}", Active: true, Weight: 1}, false)
+	if err == nil {
+		t.Error("CreatePromptVersion accepted a body with an unknown variable")
+	}
+}
+
+func TestDefaultPromptsRender(t *testing.T) {
+	vars := promptVars()
+	vars.Message, vars.Tone, vars.System = "hello", "friendly", "system"
+	vars.Order = &sampleOrderFacts
+	vars.Language, vars.CustomerLanguage = languageByCode("en"), languageByCode("und")
+	vars.Images = 2
+	for _, name := range PromptNames {
+		if got := RenderPrompt(name, vars); got.Version != 0 || strings.TrimSpace(got.Text) == "" {
+			t.Errorf("default %s rendered %+v", name, got)
+		}
+	}
+}
+
+// The system prompt must offer exactly the thicknesses the orders table accepts
+func TestSystemPromptListsThicknessConstraint(t *testing.T) {
+	sql, err := os.ReadFile(filepath.Join("..", "dbschema", "migrations", "V1__Create_users_table.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`chk_orders_thickness CHECK \(thickness IN \(([^)]*)\)\)`).FindSubmatch(sql)
+	if m == nil {
+		t.Fatal("chk_orders_thickness not found in the schema")
+	}
+	var allowed []string
+	for _, v := range strings.Split(string(m[1]), ",") {
+		allowed = append(allowed, strings.Trim(strings.TrimSpace(v), "'"))
+	}
+
+	text := RenderPrompt(PromptSystem, promptVars()).Text
+	if want := "thicknesses of " + strings.Join(allowed, ", ") + " and"; !strings.Contains(text, want) {
+		t.Errorf("system prompt does not contain %q:\n%s", want, text)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"customflow/models"
+)
+
+// OrderFacts is what a reply may tell the customer about their order,
+// rendered into the "order" prompt so answers are grounded in the real order
+type OrderFacts struct {
+	Reference    string
+	CustomerName string
+	Source       string
+	Status       string
+	StatusText   string
+	Size         string
+	Thickness    string
+	CornerStyle  string
+	OrderedOn    string
+	PromisedBy   string // empty when the order has no promised date
+	IsOverdue    bool
+	IsAtRisk     bool
+}
+
+var orderStatusText = map[string]string{
+	"new":         "received and waiting to go into production",
+	"in-progress": "in production",
+	"done":        "completed",
+}
+
+const factDateLayout = "Monday 2 January 2006"
+
+// orderFacts describes an order for the reply prompt as of now
+func orderFacts(order models.Order, now time.Time) OrderFacts {
+	if slaConfig != nil {
+		AnnotateSLA(&order, now)
+	}
+
+	facts := OrderFacts{
+		Reference:    order.OrderID,
+		CustomerName: order.CustomerName,
+		Source:       order.Source,
+		Status:       order.Status,
+		StatusText:   orderStatusText[order.Status],
+		Size:         orderSize(order),
+		Thickness:    order.Thickness,
+		CornerStyle:  order.CornerStyle,
+		OrderedOn:    order.CreatedAt.Format(factDateLayout),
+		IsOverdue:    order.IsOverdue,
+		IsAtRisk:     order.IsAtRisk,
+	}
+	if facts.StatusText == "" {
+		facts.StatusText = order.Status
+	}
+	if facts.Reference == "" {
+		facts.Reference = "#" + strconv.FormatUint(uint64(order.ID), 10)
+	}
+	if order.PromisedBy != nil {
+		facts.PromisedBy = order.PromisedBy.Format(factDateLayout)
+	}
+	return facts
+}
+
+// orderSize is the size in inches, led by what the customer typed when that was another unit
+func orderSize(order models.Order) string {
+	inches := fmt.Sprintf("%s x %s in", formatInches(order.Length), formatInches(order.Width))
+	if order.InputUnit == "" || order.InputUnit == "in" || order.LengthInput == "" || order.WidthInput == "" {
+		return inches
+	}
+	return fmt.Sprintf("%s x %s (%s)", order.LengthInput, order.WidthInput, inches)
+}
+
+func formatInches(v float64) string {
+	return strconv.FormatFloat(round2(v), 'f', -1, 64)
+}
+
+// orderFallbackResponse answers with the order's status when the provider is unavailable
+func orderFallbackResponse(facts OrderFacts, tone string) string {
+	delivery := ""
+	if facts.PromisedBy != "" && facts.Status != "done" {
+		delivery = ", due for delivery by " + facts.PromisedBy
+	}
+
+	switch tone {
+	case "formal":
+		return fmt.Sprintf("Thank you for your message. Your order %s is currently %s%s. We will contact you should anything change.",
+			facts.Reference, facts.StatusText, delivery)
+	case "short":
+		return fmt.Sprintf("Order %s: %s%s.", facts.Reference, facts.StatusText, delivery)
+	default:
+		return fmt.Sprintf("Thanks for getting in touch! Your order %s is %s%s. We'll let you know if anything changes.",
+			facts.Reference, facts.StatusText, delivery)
+	}
+}
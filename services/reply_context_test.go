@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+func TestOrderFacts(t *testing.T) {
+	if err := InitSLA(config.Defaults().SLA); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 14, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(30*24*time.Hour)
+	promised := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		order       models.Order
+		wantStatus  string
+		wantOverdue bool
+		wantRef     string
+	}{
+		{"new", models.Order{OrderID: "ORD-1", Status: "new", DueAt: &future}, "received and waiting to go into production", false, "ORD-1"},
+		{"in production and late", models.Order{OrderID: "ORD-2", Status: "in-progress", DueAt: &past}, "in production", true, "ORD-2"},
+		{"done is never late", models.Order{OrderID: "ORD-3", Status: "done", DueAt: &past}, "completed", false, "ORD-3"},
+		{"unknown status is shown as is", models.Order{ID: 42, Status: "cancelled"}, "cancelled", false, "#42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.order.PromisedBy = &promised
+			facts := orderFacts(tt.order, now)
+			if facts.StatusText != tt.wantStatus {
+				t.Errorf("StatusText = %q, want %q", facts.StatusText, tt.wantStatus)
+			}
+			if facts.IsOverdue != tt.wantOverdue {
+				t.Errorf("IsOverdue = %v, want %v", facts.IsOverdue, tt.wantOverdue)
+			}
+			if facts.Reference != tt.wantRef {
+				t.Errorf("Reference = %q, want %q", facts.Reference, tt.wantRef)
+			}
+			if facts.PromisedBy != "Monday 12 January 2026" {
+				t.Errorf("PromisedBy = %q", facts.PromisedBy)
+			}
+		})
+	}
+}
+
+func TestOrderSize(t *testing.T) {
+	tests := []struct {
+		order models.Order
+		want  string
+	}{
+		{models.Order{Length: 48, Width: 30}, "48 x 30 in"},
+		{models.Order{Length: 48.5, Width: 30.25, InputUnit: "in", LengthInput: "48.5", WidthInput: "30.25"}, "48.5 x 30.25 in"},
+		{models.Order{Length: 47.244, Width: 29.528, InputUnit: "cm", LengthInput: "120 cm", WidthInput: "75 cm"}, "120 cm x 75 cm (47.24 x 29.53 in)"},
+		{models.Order{Length: 60, Width: 36, InputUnit: "ft", LengthInput: "5 ft", WidthInput: ""}, "60 x 36 in"}, // partial input falls back to inches
+	}
+	for _, tt := range tests {
+		if got := orderSize(tt.order); got != tt.want {
+			t.Errorf("orderSize(%+v) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestOrderFallbackResponse(t *testing.T) {
+	facts := OrderFacts{Reference: "ORD-7", Status: "in-progress", StatusText: "in production", PromisedBy: "Monday 12 January 2026"}
+	for _, tone := range []string{"formal", "short", "friendly"} {
+		got := orderFallbackResponse(facts, tone)
+		if !strings.Contains(got, "ORD-7") || !strings.Contains(got, "in production, due for delivery by Monday 12 January 2026") {
+			t.Errorf("%s fallback = %q", tone, got)
+		}
+	}
+
+	facts.Status, facts.StatusText = "done", "completed"
+	if got := orderFallbackResponse(facts, "short"); got != "Order ORD-7: completed." {
+		t.Errorf("done fallback = %q, want no promised date", got)
+	}
+}
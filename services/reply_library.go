@@ -0,0 +1,288 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAIResponseNotFound is returned for unknown ai_responses IDs
+	ErrAIResponseNotFound = errors.New("AI response not found")
+	// ErrNotPromotable is returned when promoting a response that is not a
+	// customer reply, or that staff rated down
+	ErrNotPromotable = errors.New("only customer replies that were not rated down can be added to the library")
+	// ErrAlreadyPromoted is returned when a response is already in the library
+	ErrAlreadyPromoted = errors.New("response is already in the reply library")
+)
+
+// Ratings staff can give an AI response
+const (
+	RatingUp   = 1
+	RatingDown = -1
+)
+
+// replyExampleCount is how many library replies are sent as few-shot
+// examples with each reply (ai.reply_examples, 0 disables)
+var replyExampleCount = 3
+
+// AIFeedback is staff's verdict on a response; nil fields are left unchanged
+type AIFeedback struct {
+	FinalText *string
+	Rating    *int // RatingUp, RatingDown, or 0 to clear
+}
+
+// RecordAIFeedback stores the text staff sent and their rating for a
+// response. The edit distance is measured from the generated response.
+func RecordAIFeedback(id, userID uint, feedback AIFeedback) (*models.AIResponse, error) {
+	var response models.AIResponse
+	if err := config.DB.First(&response, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAIResponseNotFound
+		}
+		return nil, err
+	}
+
+	changes := feedbackChanges(response, userID, feedback, time.Now())
+	if err := config.DB.Model(&response).Updates(changes).Error; err != nil {
+		return nil, err
+	}
+	if err := config.DB.First(&response, id).Error; err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// feedbackChanges are the ai_responses columns feedback sets
+func feedbackChanges(response models.AIResponse, userID uint, feedback AIFeedback, now time.Time) map[string]interface{} {
+	changes := map[string]interface{}{"feedback_by": userID, "feedback_at": now}
+	if feedback.FinalText != nil {
+		changes["final_text"] = *feedback.FinalText
+		changes["edit_distance"] = EditDistance(response.Response, *feedback.FinalText)
+	}
+	if feedback.Rating != nil {
+		if *feedback.Rating == 0 {
+			changes["rating"] = nil
+		} else {
+			changes["rating"] = *feedback.Rating
+		}
+	}
+	return changes
+}
+
+// EditDistance is the Levenshtein distance between two texts in characters.
+// It takes time proportional to the product of their lengths, so the
+// feedback endpoint caps the final text.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// PromoteAIResponse adds a reply to the library: the text staff sent if they
+// recorded one, otherwise the generated text
+func PromoteAIResponse(id, userID uint, title string, tags []string) (*models.ReplyLibraryEntry, error) {
+	var response models.AIResponse
+	if err := config.DB.First(&response, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrAIResponseNotFound
+		}
+		return nil, err
+	}
+
+	entry, err := libraryEntry(response, userID, title, tags)
+	if err != nil {
+		return nil, err
+	}
+	result := config.DB.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "source_response_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "source_response_id IS NOT NULL"}}},
+		DoNothing:   true,
+	}).Create(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrAlreadyPromoted
+	}
+	return &entry, nil
+}
+
+// libraryEntry is the library entry for a response. Only completed customer
+// replies that staff did not rate down can be promoted.
+func libraryEntry(response models.AIResponse, userID uint, title string, tags []string) (models.ReplyLibraryEntry, error) {
+	if response.Feature != AIFeatureReply || response.Status != AIResponseCompleted ||
+		(response.Rating != nil && *response.Rating == RatingDown) {
+		return models.ReplyLibraryEntry{}, ErrNotPromotable
+	}
+
+	body := response.Response
+	if response.FinalText != nil && strings.TrimSpace(*response.FinalText) != "" {
+		body = *response.FinalText
+	}
+	language := response.ReplyLanguage
+	if language == "" {
+		language = DetectLanguage(body).Code
+	}
+	if title == "" {
+		title = libraryTitle(response.InputMessage)
+	}
+
+	return models.ReplyLibraryEntry{
+		Title:            title,
+		InputMessage:     response.InputMessage,
+		Body:             body,
+		Tone:             replyTone(response.Tone),
+		Language:         language,
+		Tags:             NormalizeTags(tags),
+		SourceResponseID: &response.ID,
+		Active:           true,
+		CreatedBy:        &userID,
+	}, nil
+}
+
+// libraryTitle is a default title: the start of the customer message
+func libraryTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if runes := []rune(title); len(runes) > 80 {
+		title = string(runes[:80]) + "..."
+	}
+	if title == "" {
+		title = "Untitled reply"
+	}
+	return title
+}
+
+// NormalizeTags lowercases and de-duplicates library tags
+func NormalizeTags(tags []string) models.StringList {
+	normalized := models.StringList{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !normalized.Contains(tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// ReplyLibraryFilter narrows a library search; empty fields match everything
+type ReplyLibraryFilter struct {
+	Query           string // web search syntax: words, "quoted phrases", or, -excluded
+	Tone            string
+	Language        string
+	Tag             string
+	IncludeInactive bool
+}
+
+// SearchReplyLibrary returns the best matching replies, most relevant first
+func SearchReplyLibrary(filter ReplyLibraryFilter, limit int) ([]models.ReplyLibraryEntry, error) {
+	query := config.DB.Model(&models.ReplyLibraryEntry{})
+	if !filter.IncludeInactive {
+		query = query.Where("active")
+	}
+	if filter.Tone != "" {
+		query = query.Where("tone = ?", filter.Tone)
+	}
+	if filter.Language != "" {
+		query = query.Where("language = ?", filter.Language)
+	}
+	if filter.Tag != "" {
+		tag, _ := json.Marshal([]string{strings.ToLower(filter.Tag)})
+		query = query.Where("tags @> ?", string(tag))
+	}
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		query = query.Where("search_vector @@ websearch_to_tsquery('simple', ?)", q).
+			Order(clause.Expr{SQL: "ts_rank(search_vector, websearch_to_tsquery('simple', ?)) DESC", Vars: []interface{}{q}})
+	}
+
+	entries := []models.ReplyLibraryEntry{}
+	err := query.Order("use_count DESC, id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// replyExamples picks library replies to similar messages as few-shot
+// examples, preferring the reply language and then the tone
+func replyExamples(message, tone, language string) []models.ReplyLibraryEntry {
+	if replyExampleCount == 0 || config.DB == nil {
+		return nil
+	}
+	q := libraryMatchQuery(message)
+	if q == "" {
+		return nil
+	}
+
+	var examples []models.ReplyLibraryEntry
+	err := config.DB.
+		Where("active AND input_message <> '' AND search_vector @@ websearch_to_tsquery('simple', ?)", q).
+		Order(clause.Expr{
+			SQL:  "(language = ?) DESC, ts_rank(search_vector, websearch_to_tsquery('simple', ?)) DESC, (tone = ?) DESC, use_count DESC",
+			Vars: []interface{}{language, q, tone},
+		}).
+		Limit(replyExampleCount).
+		Find(&examples).Error
+	if err != nil {
+		log.Printf("AI: reply library lookup failed: %v", err)
+		return nil
+	}
+
+	if len(examples) > 0 {
+		ids := make([]uint, len(examples))
+		for i, example := range examples {
+			ids[i] = example.ID
+		}
+		if err := config.DB.Model(&models.ReplyLibraryEntry{}).Where("id IN ?", ids).
+			UpdateColumn("use_count", gorm.Expr("use_count + 1")).Error; err != nil {
+			log.Printf("AI: failed to count reply library use: %v", err)
+		}
+	}
+	return examples
+}
+
+// libraryMatchQuery turns a message into a web search query matching any of
+// its words, so a library reply only needs to share some words with it
+func libraryMatchQuery(message string) string {
+	seen := map[string]bool{}
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	}) {
+		if len([]rune(word)) < 3 || seen[word] || englishStopWords[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+		if len(words) == 20 {
+			break
+		}
+	}
+	return strings.Join(words, " or ")
+}
+
+// Words too common to say two messages are about the same thing
+var englishStopWords = wordSet("the and for you your are was were have has had this that with from what when where " +
+	"which who will would can could please thanks thank hello dear sir madam")
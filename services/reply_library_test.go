@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"customflow/models"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "hello", 5},
+		{"hello", "", 5},
+		{"kitten", "sitting", 3},
+		{"48 x 30", "48 x 36", 1},
+		{"नमस्ते", "नमस्ते जी", 3}, // counted in characters, not bytes
+		{"வணக்கம்", "வணக்கம்", 0},
+	}
+	for _, tt := range tests {
+		if got := EditDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFeedbackChanges(t *testing.T) {
+	response := models.AIResponse{Response: "Your cover ships Friday."}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	final := "Your cover ships Monday."
+	up, clear := RatingUp, 0
+
+	changes := feedbackChanges(response, 7, AIFeedback{FinalText: &final, Rating: &up}, now)
+	if changes["final_text"] != final || changes["edit_distance"] != 3 || changes["rating"] != RatingUp ||
+		changes["feedback_by"] != uint(7) || changes["feedback_at"] != now {
+		t.Errorf("changes = %v, want the final text, its distance 3, rating up and who and when", changes)
+	}
+
+	changes = feedbackChanges(response, 7, AIFeedback{Rating: &clear}, now)
+	if v, ok := changes["rating"]; !ok || v != nil {
+		t.Errorf("rating none: changes[rating] = %v (set %v), want it cleared", v, ok)
+	}
+	if _, ok := changes["final_text"]; ok {
+		t.Error("rating only: final_text changed")
+	}
+}
+
+func TestLibraryEntry(t *testing.T) {
+	down, up := RatingDown, RatingUp
+	final, blank := "Thanks! Your 48 x 30 cover ships Monday.", "  "
+	reply := func(change func(r *models.AIResponse)) models.AIResponse {
+		r := models.AIResponse{
+			ID:           4,
+			Feature:      AIFeatureReply,
+			Status:       AIResponseCompleted,
+			InputMessage: "When will my table cover arrive?",
+			Response:     "Your cover ships Friday.",
+			Tone:         "formal",
+		}
+		if change != nil {
+			change(&r)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		response models.AIResponse
+		wantErr  error
+		wantBody string
+	}{
+		{"generated reply", reply(nil), nil, "Your cover ships Friday."},
+		{"rated up with final text", reply(func(r *models.AIResponse) { r.Rating, r.FinalText = &up, &final }), nil, final},
+		{"blank final text", reply(func(r *models.AIResponse) { r.FinalText = &blank }), nil, "Your cover ships Friday."},
+		{"rated down", reply(func(r *models.AIResponse) { r.Rating = &down }), ErrNotPromotable, ""},
+		{"chat answer", reply(func(r *models.AIResponse) { r.Feature = AIFeatureChat }), ErrNotPromotable, ""},
+		{"translation", reply(func(r *models.AIResponse) { r.Feature = AIFeatureTranslate }), ErrNotPromotable, ""},
+		{"failed stream", reply(func(r *models.AIResponse) { r.Status = AIResponseFailed }), ErrNotPromotable, ""},
+	}
+	for _, tt := range tests {
+		entry, err := libraryEntry(tt.response, 3, "", []string{" Shipping ", "shipping", ""})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if entry.Body != tt.wantBody || entry.Title != tt.response.InputMessage || entry.Tone != "formal" || entry.Language != "en" {
+			t.Errorf("%s: entry = %q %q (%s, %s), want body %q titled by the message", tt.name, entry.Title, entry.Body, entry.Tone, entry.Language, tt.wantBody)
+		}
+		if strings.Join(entry.Tags, ",") != "shipping" || entry.SourceResponseID == nil || *entry.SourceResponseID != 4 || !entry.Active {
+			t.Errorf("%s: tags %v, source %v, active %v", tt.name, entry.Tags, entry.SourceResponseID, entry.Active)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+// SLAPolicy is measured in business days from order creation
+type SLAPolicy struct {
+	DueDays     int `json:"due_days"`     // internal dispatch deadline (orders.due_at)
+	PromiseDays int `json:"promise_days"` // delivery date promised to the customer (orders.promised_by)
+}
+
+// BusinessCalendar knows which days count towards an SLA
+type BusinessCalendar struct {
+	Location *time.Location
+	Weekend  map[time.Weekday]bool
+	Holidays map[string]bool // keyed by YYYY-MM-DD in Location
+}
+
+type SLAConfig struct {
+	Calendar     BusinessCalendar
+	Default      SLAPolicy
+	BySource     map[string]SLAPolicy
+	AtRiskWindow time.Duration
+}
+
+var slaConfig *SLAConfig
+
+// InitSLA builds the SLA policies and business calendar from the sla section.
+// Sources without an override use the default due and promise days. Time
+// zones, weekdays and dates that do not parse are an error, and so is a
+// weekend that covers all seven days.
+func InitSLA(settings config.SLAConfig) error {
+	cfg := &SLAConfig{
+		Default: SLAPolicy{
+			DueDays:     settings.DueDays,
+			PromiseDays: settings.PromiseDays,
+		},
+		BySource:     map[string]SLAPolicy{},
+		AtRiskWindow: settings.AtRisk,
+		Calendar: BusinessCalendar{
+			Location: time.Local,
+			Weekend:  map[time.Weekday]bool{},
+			Holidays: map[string]bool{},
+		},
+	}
+
+	if settings.Timezone != "" {
+		loc, err := time.LoadLocation(settings.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid SLA time zone %q: %v", settings.Timezone, err)
+		}
+		cfg.Calendar.Location = loc
+	}
+
+	for _, source := range models.OrderSources {
+		policy := cfg.Default
+		if override, ok := settings.Sources[source]; ok {
+			if override.DueDays != nil {
+				policy.DueDays = *override.DueDays
+			}
+			if override.PromiseDays != nil {
+				policy.PromiseDays = *override.PromiseDays
+			}
+		}
+		cfg.BySource[source] = policy
+	}
+
+	for _, day := range settings.Weekend {
+		wd, ok := config.ParseWeekday(day)
+		if !ok {
+			return fmt.Errorf("unknown SLA weekend day %q", day)
+		}
+		cfg.Calendar.Weekend[wd] = true
+	}
+
+	for _, day := range settings.Holidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return fmt.Errorf("invalid SLA holiday %q, expected YYYY-MM-DD", day)
+		}
+		cfg.Calendar.Holidays[day] = true
+	}
+
+	if len(cfg.Calendar.Weekend) == 7 {
+		return fmt.Errorf("SLA weekend %q leaves no business days in the week", strings.Join(settings.Weekend, ","))
+	}
+
+	slaConfig = cfg
+	log.Printf("SLA initialized: %d/%d business days (due/promise), %d holidays",
+		cfg.Default.DueDays, cfg.Default.PromiseDays, len(cfg.Calendar.Holidays))
+	return nil
+}
+
+// IsBusinessDay reports whether t falls on a working day
+func (cal BusinessCalendar) IsBusinessDay(t time.Time) bool {
+	t = t.In(cal.Location)
+	return !cal.Weekend[t.Weekday()] && !cal.Holidays[t.Format("2006-01-02")]
+}
+
+// maxCalendarDays bounds the business day search so a calendar without
+// working days cannot hang the caller
+const maxCalendarDays = 10 * 366
+
+// AddBusinessDays moves t forward by n working days, keeping the time of day.
+// It gives up after maxCalendarDays calendar days.
+func (cal BusinessCalendar) AddBusinessDays(t time.Time, n int) time.Time {
+	t = t.In(cal.Location)
+	for added, days := 0, 0; added < n && days < maxCalendarDays; days++ {
+		t = t.AddDate(0, 0, 1)
+		if cal.IsBusinessDay(t) {
+			added++
+		}
+	}
+	return t
+}
+
+// PolicyFor returns the SLA policy for an order source
+func PolicyFor(source string) SLAPolicy {
+	if policy, ok := slaConfig.BySource[source]; ok {
+		return policy
+	}
+	return slaConfig.Default
+}
+
+// ComputeDueDates returns due_at and promised_by for an order created at createdAt
+func ComputeDueDates(source string, createdAt time.Time) (dueAt time.Time, promisedBy time.Time) {
+	policy := PolicyFor(source)
+	cal := slaConfig.Calendar
+
+	dueAt = cal.AddBusinessDays(createdAt, policy.DueDays)
+	promised := cal.AddBusinessDays(createdAt, policy.PromiseDays)
+	promisedBy = time.Date(promised.Year(), promised.Month(), promised.Day(), 0, 0, 0, 0, time.UTC)
+	return dueAt, promisedBy
+}
+
+// ApplyDueDates sets the SLA dates on an order from its source and creation time
+func ApplyDueDates(order *models.Order) {
+	createdAt := order.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	dueAt, promisedBy := ComputeDueDates(order.Source, createdAt)
+	order.DueAt = &dueAt
+	order.PromisedBy = &promisedBy
+}
+
+// AnnotateSLA fills the computed IsOverdue/IsAtRisk flags on an order
+func AnnotateSLA(order *models.Order, now time.Time) {
+	order.IsOverdue, order.IsAtRisk = false, false
+	if order.DueAt == nil || !isOpenStatus(order.Status) {
+		return
+	}
+	if now.After(*order.DueAt) {
+		order.IsOverdue = true
+	} else if now.Add(slaConfig.AtRiskWindow).After(*order.DueAt) {
+		order.IsAtRisk = true
+	}
+}
+
+// AtRiskWindow is how long before due_at an open order is flagged at risk
+func AtRiskWindow() time.Duration {
+	return slaConfig.AtRiskWindow
+}
+
+// SLASummary describes the active SLA for the API and prompts
+func SLASummary() map[string]interface{} {
+	return map[string]interface{}{
+		"default":        slaConfig.Default,
+		"by_source":      slaConfig.BySource,
+		"at_risk_hours":  slaConfig.AtRiskWindow.Hours(),
+		"holidays_count": len(slaConfig.Calendar.Holidays),
+		"timezone":       slaConfig.Calendar.Location.String(),
+	}
+}
+
+func isOpenStatus(status string) bool {
+	return containsString(models.OpenOrderStatuses, status)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p SLAPolicy) String() string {
+	return fmt.Sprintf("%d-%d business days", p.DueDays, p.PromiseDays)
+}
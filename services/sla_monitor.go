@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+// SLA alert kinds, recorded once per order in order_sla_alerts
+const (
+	SLAAlertAtRisk  = "at_risk"
+	SLAAlertOverdue = "overdue"
+)
+
+// StartSLAMonitor checks open orders every sla.check_interval and sends one
+// alert per order when it becomes at risk and when it goes overdue. Alerts go
+// to sla.alert_recipient, or else the staff addresses, through the given notifier.
+func StartSLAMonitor(notifier Notifier, cfg config.SLAConfig) {
+	interval := cfg.CheckInterval
+	if interval <= 0 {
+		log.Println("SLA monitor disabled (sla.check_interval is 0)")
+		return
+	}
+
+	recipient := cfg.AlertRecipient
+	if recipient == "" {
+		recipient = StaffRecipients()
+	}
+	if recipient == "" {
+		recipient = "staff"
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			checkSLAs(notifier, recipient)
+			<-ticker.C
+		}
+	}()
+
+	log.Printf("SLA monitor started (every %s, notifier: %s)", interval, notifier.Name())
+}
+
+func checkSLAs(notifier Notifier, recipient string) {
+	for _, w := range slaAlertWindows(time.Now()) {
+		if err := alertOrders(notifier, recipient, w); err != nil {
+			log.Printf("SLA monitor: %s check failed: %v", w.kind, err)
+		}
+	}
+}
+
+// slaWindow selects the open orders one alert kind applies to: those due in
+// [from, to), with no lower bound when from is nil
+type slaWindow struct {
+	kind string
+	from *time.Time
+	to   time.Time
+}
+
+func (w slaWindow) contains(due time.Time) bool {
+	return (w.from == nil || !due.Before(*w.from)) && due.Before(w.to)
+}
+
+// slaAlertWindows splits due dates the way the orders list's SLA filter does,
+// so an order that is already overdue is not also due soon
+func slaAlertWindows(now time.Time) []slaWindow {
+	return []slaWindow{
+		{kind: SLAAlertOverdue, to: now},
+		{kind: SLAAlertAtRisk, from: &now, to: now.Add(AtRiskWindow())},
+	}
+}
+
+// alertOrders notifies about open orders due in the window that have not had its alert yet
+func alertOrders(notifier Notifier, recipient string, w slaWindow) error {
+	kind := w.kind
+	query := config.DB.
+		Where("status IN ?", models.OpenOrderStatuses).
+		Where("due_at IS NOT NULL AND due_at < ?", w.to)
+	if w.from != nil {
+		query = query.Where("due_at >= ?", *w.from)
+	}
+
+	var orders []models.Order
+	err := query.
+		Where("NOT EXISTS (SELECT 1 FROM order_sla_alerts a WHERE a.order_id = orders.id AND a.kind = ?)", kind).
+		Order("due_at").
+		Limit(200).
+		Find(&orders).Error
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := notifier.Send(ctx, slaNotification(order, kind, recipient))
+		cancel()
+		if err != nil {
+			log.Printf("SLA monitor: failed to send %s alert for order %s: %v", kind, order.OrderID, err)
+			continue
+		}
+
+		alert := models.OrderSLAAlert{OrderID: order.ID, Kind: kind, DueAt: *order.DueAt, Channel: notifier.Name()}
+		if err := config.DB.Create(&alert).Error; err != nil {
+			log.Printf("SLA monitor: failed to record %s alert for order %s: %v", kind, order.OrderID, err)
+		}
+	}
+
+	if len(orders) > 0 {
+		log.Printf("SLA monitor: sent %d %s alerts", len(orders), kind)
+	}
+	return nil
+}
+
+func slaNotification(order models.Order, kind, recipient string) Notification {
+	due := order.DueAt.In(slaConfig.Calendar.Location).Format("2006-01-02 15:04")
+
+	subject := fmt.Sprintf("Order %s is overdue", order.OrderID)
+	body := "Order %s (%s, %.2f x %.2f in) for %s is '%s' and was due %s."
+	if kind == SLAAlertAtRisk {
+		subject = fmt.Sprintf("Order %s is due soon", order.OrderID)
+		body = "Order %s (%s, %.2f x %.2f in) for %s is '%s' and is due %s."
+	}
+
+	return Notification{
+		Event:     "order.sla_" + kind,
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      fmt.Sprintf(body, order.OrderID, order.Source, order.Length, order.Width, order.CustomerName, order.Status, due),
+		OrderID:   order.ID,
+	}
+}
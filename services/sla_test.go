@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"customflow/config"
+)
+
+func TestInitSLARejectsFullWeekend(t *testing.T) {
+	cfg := config.Defaults().SLA
+	cfg.Weekend = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+	if err := InitSLA(cfg); err == nil {
+		t.Fatal("InitSLA accepted a weekend with no business days")
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	cal := BusinessCalendar{
+		Location: time.UTC,
+		Weekend:  map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		Holidays: map[string]bool{"2026-12-25": true},
+	}
+	thu := time.Date(2026, 12, 24, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "2026-12-24"},
+		{1, "2026-12-28"}, // skips the holiday and the weekend
+		{3, "2026-12-30"},
+	}
+	for _, tt := range tests {
+		got := cal.AddBusinessDays(thu, tt.n).Format("2006-01-02")
+		if got != tt.want {
+			t.Errorf("AddBusinessDays(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAddBusinessDaysWithoutWorkingDaysStops(t *testing.T) {
+	cal := BusinessCalendar{Location: time.UTC, Weekend: map[time.Weekday]bool{}, Holidays: map[string]bool{}}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		cal.Weekend[d] = true
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	done := make(chan time.Time, 1)
+	go func() { done <- cal.AddBusinessDays(start, 1) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddBusinessDays did not return for a calendar without business days")
+	}
+}
+
+func TestSLAAlertWindows(t *testing.T) {
+	if err := InitSLA(config.Defaults().SLA); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		due  time.Time
+		want string // the only alert kind the order gets, "" for none
+	}{
+		{"overdue by days", now.AddDate(0, 0, -3), SLAAlertOverdue},
+		{"overdue by a second", now.Add(-time.Second), SLAAlertOverdue},
+		{"due now", now, SLAAlertAtRisk},
+		{"due soon", now.Add(AtRiskWindow() / 2), SLAAlertAtRisk},
+		{"due after the window", now.Add(AtRiskWindow()), ""},
+	}
+	for _, tt := range tests {
+		var kinds []string
+		for _, w := range slaAlertWindows(now) {
+			if w.contains(tt.due) {
+				kinds = append(kinds, w.kind)
+			}
+		}
+		if (tt.want == "" && len(kinds) != 0) || (tt.want != "" && (len(kinds) != 1 || kinds[0] != tt.want)) {
+			t.Errorf("%s: alerts %v, want only %q", tt.name, kinds, tt.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"customflow/config"
+)
+
+// InboundSMS is a provider-neutral inbound text message
+type InboundSMS struct {
+	ExternalID string
+	From       string
+	To         string
+	Body       string
+	MediaURLs  []string
+}
+
+// SMSProvider receives and sends two-way SMS through a gateway
+type SMSProvider interface {
+	Name() string
+	// ParseInbound authenticates and decodes an inbound webhook request
+	ParseInbound(r *http.Request) (InboundSMS, error)
+	// Send delivers a text and returns the provider's message ID
+	Send(ctx context.Context, to, body string) (string, error)
+	// InboundResponse is written back to the gateway after an inbound message
+	InboundResponse() (contentType string, body string)
+}
+
+// TwilioSMSProvider talks to Twilio or any gateway with the same API shape:
+// form-encoded inbound webhooks signed with X-Twilio-Signature and a REST send API
+type TwilioSMSProvider struct {
+	APIURL     string // e.g. https://api.twilio.com
+	AccountSID string
+	AuthToken  string
+	From       string
+	WebhookURL string // public URL of the inbound endpoint, as configured at the provider
+	Client     *http.Client
+}
+
+func (TwilioSMSProvider) Name() string {
+	return "twilio"
+}
+
+func (t TwilioSMSProvider) ParseInbound(r *http.Request) (InboundSMS, error) {
+	if err := r.ParseForm(); err != nil {
+		return InboundSMS{}, fmt.Errorf("invalid form body: %v", err)
+	}
+
+	webhookURL := t.WebhookURL
+	if webhookURL == "" {
+		scheme := "https"
+		if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+			scheme = "http"
+		}
+		webhookURL = scheme + "://" + r.Host + r.URL.RequestURI()
+	}
+
+	expected := TwilioSignature(t.AuthToken, webhookURL, r.PostForm)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Twilio-Signature"))) {
+		return InboundSMS{}, fmt.Errorf("invalid signature")
+	}
+
+	return inboundSMSFromForm(r.PostForm), nil
+}
+
+func (t TwilioSMSProvider) Send(ctx context.Context, to, body string) (string, error) {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", t.From)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(t.APIURL, "/"), t.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		SID     string `json:"sid"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("SMS API error (status %d): %s", resp.StatusCode, result.Message)
+	}
+	return result.SID, nil
+}
+
+func (TwilioSMSProvider) InboundResponse() (string, string) {
+	return "text/xml", `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+}
+
+// TwilioSignature is base64 HMAC-SHA1 over the URL followed by the sorted form keys and values
+func TwilioSignature(authToken, webhookURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(webhookURL)
+	for _, key := range keys {
+		for _, value := range form[key] {
+			b.WriteString(key)
+			b.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FakeSMSProvider accepts unsigned Twilio-style webhooks and keeps sent messages
+// in memory, for local development without a gateway account
+type FakeSMSProvider struct {
+	mu     sync.Mutex
+	outbox []FakeSMS
+}
+
+type FakeSMS struct {
+	ID     string    `json:"id"`
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+func NewFakeSMSProvider() *FakeSMSProvider {
+	return &FakeSMSProvider{}
+}
+
+func (*FakeSMSProvider) Name() string {
+	return "fake"
+}
+
+func (*FakeSMSProvider) ParseInbound(r *http.Request) (InboundSMS, error) {
+	if err := r.ParseForm(); err != nil {
+		return InboundSMS{}, fmt.Errorf("invalid form body: %v", err)
+	}
+	in := inboundSMSFromForm(r.PostForm)
+	if in.ExternalID == "" {
+		in.ExternalID = "fake_in_" + randomHex(8)
+	}
+	return in, nil
+}
+
+func (f *FakeSMSProvider) Send(ctx context.Context, to, body string) (string, error) {
+	msg := FakeSMS{ID: "fake_" + randomHex(8), To: to, Body: body, SentAt: time.Now()}
+
+	f.mu.Lock()
+	f.outbox = append(f.outbox, msg)
+	f.mu.Unlock()
+
+	log.Printf("[sms:fake] to=%s: %s", to, body)
+	return msg.ID, nil
+}
+
+func (*FakeSMSProvider) InboundResponse() (string, string) {
+	return "text/xml", `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+}
+
+// Outbox returns the messages sent so far
+func (f *FakeSMSProvider) Outbox() []FakeSMS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeSMS{}, f.outbox...)
+}
+
+func inboundSMSFromForm(form url.Values) InboundSMS {
+	in := InboundSMS{
+		ExternalID: form.Get("MessageSid"),
+		From:       form.Get("From"),
+		To:         form.Get("To"),
+		Body:       form.Get("Body"),
+	}
+	for i := 0; ; i++ {
+		mediaURL := form.Get(fmt.Sprintf("MediaUrl%d", i))
+		if mediaURL == "" {
+			break
+		}
+		in.MediaURLs = append(in.MediaURLs, mediaURL)
+	}
+	return in
+}
+
+var smsProvider SMSProvider
+
+var (
+	// ErrSMSDisabled is returned when no SMS provider is configured
+	ErrSMSDisabled = errors.New("SMS is not configured")
+	// ErrInvalidPhone is returned for numbers that cannot be written in E.164 form
+	ErrInvalidPhone = errors.New("phone number is not a valid international number")
+)
+
+// smsDefaultCountryCode is prefixed to national numbers (see NormalizePhone)
+var smsDefaultCountryCode string
+
+// InitSMS selects the two-way SMS provider from the sms section: twilio,
+// implied by a Twilio account SID, or fake; otherwise SMS is disabled.
+// The fake provider accepts unsigned webhooks and never delivers, so it is
+// only used when asked for by name. An unknown provider or incomplete Twilio
+// credentials are an error.
+func InitSMS(cfg config.SMSConfig) error {
+	smsProvider = nil
+	smsDefaultCountryCode = digitsOnly(cfg.DefaultCountryCode)
+
+	switch provider := cfg.ProviderName(); provider {
+	case "":
+		log.Println("WARNING: sms.provider (SMS_PROVIDER) not set. SMS is disabled.")
+		return nil
+	case "twilio":
+		twilio := TwilioSMSProvider{
+			APIURL:     cfg.Twilio.APIURL,
+			AccountSID: cfg.Twilio.AccountSID,
+			AuthToken:  cfg.Twilio.AuthToken,
+			From:       cfg.Twilio.FromNumber,
+			WebhookURL: cfg.WebhookURL,
+			Client:     &http.Client{Timeout: 30 * time.Second},
+		}
+		if twilio.AccountSID == "" || twilio.AuthToken == "" || twilio.From == "" {
+			return fmt.Errorf("SMS provider twilio needs an account SID, auth token and from number")
+		}
+		smsProvider = twilio
+	case "fake":
+		log.Println("WARNING: Using the fake SMS provider. Inbound webhooks are not authenticated and nothing is delivered.")
+		smsProvider = NewFakeSMSProvider()
+	default:
+		return fmt.Errorf("unknown SMS provider %q (want twilio or fake)", provider)
+	}
+
+	log.Printf("SMS provider: %s", smsProvider.Name())
+	return nil
+}
+
+// SMS returns the configured two-way SMS provider, or nil when SMS is disabled
+func SMS() SMSProvider {
+	return smsProvider
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"customflow/config"
+)
+
+func TestInitSMS(t *testing.T) {
+	twilio := config.TwilioConfig{AccountSID: "AC123", AuthToken: "token", FromNumber: "+15550100"}
+
+	tests := []struct {
+		name    string
+		cfg     config.SMSConfig
+		want    string // provider name, "" when SMS is disabled
+		wantErr bool
+	}{
+		{"unset", config.SMSConfig{}, "", false},
+		{"fake", config.SMSConfig{Provider: "fake"}, "fake", false},
+		{"unknown", config.SMSConfig{Provider: "nexmo"}, "", true},
+		{"twilio without credentials", config.SMSConfig{Provider: "twilio"}, "", true},
+		{"twilio without token", config.SMSConfig{Twilio: config.TwilioConfig{AccountSID: "AC123", FromNumber: "+15550100"}}, "", true},
+		{"twilio implied by account", config.SMSConfig{Twilio: twilio}, "twilio", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := InitSMS(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitSMS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := ""
+			if SMS() != nil {
+				got = SMS().Name()
+			}
+			if got != tt.want {
+				t.Errorf("provider = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"customflow/config"
+)
+
+// Transcriber turns a call recording in the uploads directory into text
+type Transcriber interface {
+	Name() string
+	Transcribe(ctx context.Context, filename string) (string, error)
+}
+
+// OpenAITranscriber uses the OpenAI audio transcription endpoint
+type OpenAITranscriber struct {
+	APIURL string // e.g. https://api.openai.com/v1/audio/transcriptions
+	APIKey string
+	Model  string
+	Client *http.Client
+}
+
+func (OpenAITranscriber) Name() string {
+	return "openai"
+}
+
+func (t OpenAITranscriber) Transcribe(ctx context.Context, filename string) (string, error) {
+	file, err := os.Open(RecordingPath(filename))
+	if err != nil {
+		return "", fmt.Errorf("recording not found: %v", err)
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", t.Model)
+	form.WriteField("response_format", "json")
+	part, err := form.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return "", fmt.Errorf("failed to read recording: %v", err)
+	}
+	form.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.APIURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+t.APIKey)
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// StubTranscriber is for local development: it returns the contents of a
+// "<recording>.txt" file next to the recording, or a fixed sample transcript.
+// It is only used when asked for by name, since the sample reads like a real order.
+type StubTranscriber struct{}
+
+const stubTranscript = "Customer called to order a clear table cover, 48 by 30 inches, 2mm, rounded corners."
+
+func (StubTranscriber) Name() string {
+	return "stub"
+}
+
+func (StubTranscriber) Transcribe(ctx context.Context, filename string) (string, error) {
+	path := RecordingPath(filename)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("recording not found: %v", err)
+	}
+	if text, err := os.ReadFile(path + ".txt"); err == nil {
+		return strings.TrimSpace(string(text)), nil
+	}
+	return stubTranscript, nil
+}
+
+var transcriber Transcriber
+
+// ErrTranscriptionDisabled is returned when no transcriber is configured
+var ErrTranscriptionDisabled = errors.New("call transcription is not configured")
+
+// InitTranscription selects the call recording transcriber: openai, implied
+// by an OpenAI API key, or stub; otherwise recording uploads are rejected
+func InitTranscription(cfg config.TranscriptionConfig, apiKey string) {
+	transcriber = nil
+	name := cfg.Provider
+	if name == "" && apiKey != "" {
+		name = "openai"
+	}
+
+	switch name {
+	case "":
+		log.Println("WARNING: transcription.provider (TRANSCRIBER) not set and no OpenAI API key. Call recording uploads are disabled.")
+		return
+	case "openai":
+		transcriber = OpenAITranscriber{
+			APIURL: cfg.APIURL,
+			APIKey: apiKey,
+			Model:  cfg.Model,
+			Client: &http.Client{Timeout: 5 * time.Minute},
+		}
+	case "stub":
+		log.Println("WARNING: Using the stub transcriber. Recordings without a .txt transcript get a sample order.")
+		transcriber = StubTranscriber{}
+	}
+
+	log.Printf("Transcription: using %s transcriber", transcriber.Name())
+}
+
+// TranscriptionEnabled reports whether call recordings can be transcribed
+func TranscriptionEnabled() bool {
+	return transcriber != nil
+}
+
+// TranscribeRecording transcribes an uploaded call recording
+func TranscribeRecording(ctx context.Context, filename string) (string, error) {
+	if transcriber == nil {
+		return "", ErrTranscriptionDisabled
+	}
+	return transcriber.Transcribe(ctx, filename)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"customflow/config"
+)
+
+func TestInitTranscription(t *testing.T) {
+	t.Cleanup(func() { transcriber = nil })
+
+	tests := []struct {
+		name   string
+		cfg    config.TranscriptionConfig
+		apiKey string
+		want   string // transcriber name, "" when recordings are rejected
+	}{
+		{"unset", config.TranscriptionConfig{}, "", ""},
+		{"openai implied by key", config.TranscriptionConfig{}, "sk-test", "openai"},
+		{"stub", config.TranscriptionConfig{Provider: "stub"}, "", "stub"},
+		{"stub with key", config.TranscriptionConfig{Provider: "stub"}, "sk-test", "stub"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InitTranscription(tt.cfg, tt.apiKey)
+			got := ""
+			if transcriber != nil {
+				got = transcriber.Name()
+			}
+			if got != tt.want || TranscriptionEnabled() != (tt.want != "") {
+				t.Fatalf("transcriber = %q (enabled %v), want %q", got, TranscriptionEnabled(), tt.want)
+			}
+			if tt.want == "" {
+				if _, err := TranscribeRecording(context.Background(), "call_1.mp3"); !errors.Is(err, ErrTranscriptionDisabled) {
+					t.Errorf("TranscribeRecording() error = %v, want ErrTranscriptionDisabled", err)
+				}
+			}
+		})
+	}
+}
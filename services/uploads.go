@@ -0,0 +1,31 @@
+package services
+
+import (
+	"path/filepath"
+
+	"customflow/config"
+)
+
+// Upload directory and size limits, from the server configuration
+var uploads = config.Defaults().Uploads
+
+// InitUploads sets where uploaded files live and how large they may be
+func InitUploads(cfg config.UploadConfig) {
+	uploads = cfg
+}
+
+// UploadSettings is the upload directory and limits in use
+func UploadSettings() config.UploadConfig {
+	return uploads
+}
+
+// UploadPath is where an uploaded file with the given name is stored
+func UploadPath(filename string) string {
+	return filepath.Join(uploads.Dir, filename)
+}
+
+// RecordingPath is where a call recording with the given name is stored.
+// Recordings are kept apart from uploads, which are served without authentication.
+func RecordingPath(filename string) string {
+	return filepath.Join(uploads.RecordingsDir, filename)
+}
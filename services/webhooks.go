@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+
+	"gorm.io/gorm"
+)
+
+// Webhook delivery states
+const (
+	WebhookPending   = "pending"
+	WebhookSucceeded = "succeeded"
+	WebhookFailed    = "failed"
+)
+
+// Event types a webhook subscription can ask for; AllEvents subscribes to everything
+var WebhookEventTypes = []string{
+	EventOrderCreated,
+	EventOrderUpdated,
+	EventOrderStatusChanged,
+	EventOrderDeleted,
+	AllEvents,
+}
+
+type webhookSettings struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	Lease        time.Duration
+}
+
+var (
+	webhookConfig webhookSettings
+	webhookClient *http.Client
+)
+
+// WebhookPayload is the JSON body posted to subscribers
+type WebhookPayload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       WebhookData `json:"data"`
+}
+
+type WebhookData struct {
+	Order     models.Order `json:"order"`
+	OldStatus string       `json:"old_status,omitempty"`
+}
+
+// InitWebhooks starts the delivery worker, polling every webhooks.poll_interval.
+// Deliveries are queued by the order writes themselves; see WebhookOutbox.
+func InitWebhooks(cfg config.WebhookConfig) {
+	webhookConfig = webhookSettings{
+		PollInterval: cfg.PollInterval,
+		BatchSize:    20,
+		MaxAttempts:  cfg.MaxAttempts,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		Timeout:      cfg.Timeout,
+		Lease:        2 * time.Minute,
+	}
+	webhookClient = &http.Client{Timeout: webhookConfig.Timeout}
+
+	if webhookConfig.PollInterval <= 0 {
+		log.Println("Webhook worker disabled (webhooks.poll_interval is 0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(webhookConfig.PollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			processWebhookQueue()
+		}
+	}()
+
+	log.Printf("Webhook worker started (every %s, max %d attempts)", webhookConfig.PollInterval, webhookConfig.MaxAttempts)
+}
+
+// NewWebhookSecret generates a random signing secret for a subscription
+func NewWebhookSecret() string {
+	return "whsec_" + randomHex(24)
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers verify the X-CustomFlow-Signature header with the same computation.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookOutbox builds the deliveries for e: one pending delivery per active
+// subscription to its type, carrying the order as stored. Order writes save
+// them in the same transaction as the change (an outbox), so a committed
+// change always has its deliveries queued and a rolled back one has none.
+func WebhookOutbox(e Event) func(order models.Order, subscriptions []models.WebhookSubscription) ([]models.WebhookDelivery, error) {
+	return func(order models.Order, subscriptions []models.WebhookSubscription) ([]models.WebhookDelivery, error) {
+		if e.OccurredAt.IsZero() {
+			e.OccurredAt = time.Now()
+		}
+		AnnotateSLA(&order, e.OccurredAt)
+		AnnotateDimensions(&order)
+
+		eventID := "evt_" + randomHex(12)
+		payload, err := json.Marshal(WebhookPayload{
+			ID:         eventID,
+			Type:       e.Type,
+			OccurredAt: e.OccurredAt,
+			Data:       WebhookData{Order: order, OldStatus: e.OldStatus},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s webhook payload: %v", e.Type, err)
+		}
+
+		var deliveries []models.WebhookDelivery
+		for _, sub := range subscriptions {
+			if !sub.Active || (!sub.Events.Contains(e.Type) && !sub.Events.Contains(AllEvents)) {
+				continue
+			}
+			deliveries = append(deliveries, models.WebhookDelivery{
+				SubscriptionID: sub.ID,
+				EventID:        eventID,
+				EventType:      e.Type,
+				Payload:        models.JSONRaw(payload),
+				Status:         WebhookPending,
+				NextAttemptAt:  e.OccurredAt,
+			})
+		}
+		return deliveries, nil
+	}
+}
+
+// queueWebhookDeliveries writes the deliveries for e through tx, the
+// transaction that changed the order
+func queueWebhookDeliveries(tx *gorm.DB, e Event) error {
+	var subscriptions []models.WebhookSubscription
+	if err := tx.Where("active = ?", true).Find(&subscriptions).Error; err != nil {
+		return fmt.Errorf("failed to load webhook subscriptions: %v", err)
+	}
+	deliveries, err := WebhookOutbox(e)(e.Order, subscriptions)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to queue %s webhook deliveries: %v", e.Type, err)
+	}
+	return nil
+}
+
+// ReplayWebhookDelivery queues a fresh copy of an earlier delivery
+func ReplayWebhookDelivery(original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	replay := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         WebhookPending,
+		NextAttemptAt:  time.Now(),
+		ReplayOf:       &original.ID,
+	}
+	err := config.DB.Create(&replay).Error
+	return replay, err
+}
+
+// processWebhookQueue claims due deliveries with a lease, so several
+// instances can share the queue and a crashed worker's rows are retried
+func processWebhookQueue() {
+	var deliveries []models.WebhookDelivery
+	err := config.DB.Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		time.Now().Add(webhookConfig.Lease), WebhookPending, time.Now(), webhookConfig.BatchSize,
+	).Scan(&deliveries).Error
+	if err != nil {
+		log.Printf("Webhooks: failed to claim deliveries: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		deliverWebhook(&deliveries[i])
+	}
+}
+
+func deliverWebhook(d *models.WebhookDelivery) {
+	var sub models.WebhookSubscription
+	if err := config.DB.First(&sub, d.SubscriptionID).Error; err != nil || !sub.Active {
+		d.Status = WebhookFailed
+		d.LastError = "subscription missing or inactive"
+		config.DB.Save(d)
+		return
+	}
+
+	attempt := models.WebhookDeliveryAttempt{DeliveryID: d.ID, AttemptNumber: d.Attempts + 1}
+	started := time.Now()
+	status, body, err := postWebhook(sub, d)
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	attempt.ResponseStatus = status
+	attempt.ResponseBody = body
+
+	d.Attempts++
+	d.ResponseStatus = status
+
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		now := time.Now()
+		d.Status = WebhookSucceeded
+		d.DeliveredAt = &now
+		d.LastError = ""
+	default:
+		if err != nil {
+			d.LastError = err.Error()
+		} else {
+			d.LastError = fmt.Sprintf("unexpected status %d", status)
+		}
+		attempt.Error = d.LastError
+
+		if d.Attempts >= webhookConfig.MaxAttempts {
+			d.Status = WebhookFailed
+		} else {
+			d.NextAttemptAt = time.Now().Add(webhookBackoff(d.Attempts))
+		}
+	}
+
+	if err := config.DB.Create(&attempt).Error; err != nil {
+		log.Printf("Webhooks: failed to log attempt for delivery %d: %v", d.ID, err)
+	}
+	if err := config.DB.Save(d).Error; err != nil {
+		log.Printf("Webhooks: failed to update delivery %d: %v", d.ID, err)
+	}
+
+	log.Printf("Webhooks: delivery %d (%s) to %s attempt %d -> %s",
+		d.ID, d.EventType, sub.URL, d.Attempts, d.Status)
+}
+
+func postWebhook(sub models.WebhookSubscription, d *models.WebhookDelivery) (int, string, error) {
+	body := []byte(d.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CustomFlow-Webhooks/1.0")
+	req.Header.Set("X-CustomFlow-Event", d.EventType)
+	req.Header.Set("X-CustomFlow-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-CustomFlow-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-CustomFlow-Signature", "sha256="+SignWebhookPayload(sub.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return resp.StatusCode, string(respBody), nil
+}
+
+// webhookBackoff is exponential from BaseBackoff, capped at MaxBackoff, with +/-20% jitter
+func webhookBackoff(attempts int) time.Duration {
+	backoff := float64(webhookConfig.BaseBackoff) * math.Pow(2, float64(attempts-1))
+	if backoff > float64(webhookConfig.MaxBackoff) {
+		backoff = float64(webhookConfig.MaxBackoff)
+	}
+	jitter := 0.8 + mathrand.Float64()*0.4
+	return time.Duration(backoff * jitter)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
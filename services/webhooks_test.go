@@ -0,0 +1,129 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"customflow/models"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+
+	// Reference values from HMAC-SHA256 over "<timestamp>.<body>", as a receiver computes them
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		want      string
+	}{
+		{"payload", "whsec_test", 1700000000, body, "c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925"},
+		{"empty body", "whsec_test", 1700000000, nil, "5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+		{"other secret", "whsec_other", 1700000000, body, "d8d091c76b586cff4dbd317fc47ebddff4b86de3ce18d3c03753c3c1901d475a"},
+		{"other timestamp", "whsec_test", 1700000001, body, "a6b8e4670849f25456dbcceec15faae9edf44ea78d5607a06ebcb96ce7583658"},
+	}
+	for _, tt := range tests {
+		if got := SignWebhookPayload(tt.secret, tt.timestamp, tt.body); got != tt.want {
+			t.Errorf("%s: SignWebhookPayload = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	previous := webhookConfig
+	defer func() { webhookConfig = previous }()
+	webhookConfig.BaseBackoff = 30 * time.Second
+	webhookConfig.MaxBackoff = 6 * time.Hour
+
+	tests := []struct {
+		attempts int
+		base     time.Duration // before the +/-20% jitter
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{10, 15360 * time.Second},
+		{11, 6 * time.Hour}, // 30s * 2^10 is past the cap
+		{30, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		low, high := time.Duration(float64(tt.base)*0.8), time.Duration(float64(tt.base)*1.2)
+		for i := 0; i < 200; i++ {
+			if got := webhookBackoff(tt.attempts); got < low || got > high {
+				t.Fatalf("webhookBackoff(%d) = %s, want between %s and %s", tt.attempts, got, low, high)
+			}
+		}
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, strings.Repeat("x", 3000))
+	}))
+	defer receiver.Close()
+
+	previous := webhookClient
+	defer func() { webhookClient = previous }()
+	webhookClient = receiver.Client()
+
+	sub := models.WebhookSubscription{URL: receiver.URL, Secret: "whsec_test"}
+	delivery := models.WebhookDelivery{ID: 42, EventType: EventOrderCreated, Payload: models.JSONRaw(`{"id":"evt_1"}`)}
+
+	before := time.Now().Unix()
+	status, body, err := postWebhook(sub, &delivery)
+	if err != nil {
+		t.Fatalf("postWebhook: %v", err)
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", status, http.StatusAccepted)
+	}
+	if len(body) != 2048 {
+		t.Errorf("response body kept %d bytes, want 2048", len(body))
+	}
+
+	if got.Method != http.MethodPost || string(gotBody) != `{"id":"evt_1"}` {
+		t.Errorf("receiver got %s %q", got.Method, gotBody)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":          "application/json",
+		"X-CustomFlow-Event":    EventOrderCreated,
+		"X-CustomFlow-Delivery": "42",
+	} {
+		if value := got.Header.Get(header); value != want {
+			t.Errorf("%s = %q, want %q", header, value, want)
+		}
+	}
+
+	timestamp, err := strconv.ParseInt(got.Header.Get("X-CustomFlow-Timestamp"), 10, 64)
+	if err != nil || timestamp < before || timestamp > time.Now().Unix() {
+		t.Fatalf("X-CustomFlow-Timestamp = %q", got.Header.Get("X-CustomFlow-Timestamp"))
+	}
+	if want := "sha256=" + SignWebhookPayload("whsec_test", timestamp, gotBody); got.Header.Get("X-CustomFlow-Signature") != want {
+		t.Errorf("X-CustomFlow-Signature = %q, want %q", got.Header.Get("X-CustomFlow-Signature"), want)
+	}
+}
+
+func TestPostWebhookUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+
+	previous := webhookClient
+	defer func() { webhookClient = previous }()
+	webhookClient = &http.Client{Timeout: time.Second}
+
+	sub := models.WebhookSubscription{URL: receiver.URL, Secret: "whsec_test"}
+	if status, _, err := postWebhook(sub, &models.WebhookDelivery{Payload: models.JSONRaw(`{}`)}); err == nil || status != 0 {
+		t.Errorf("postWebhook to a closed server = %d, %v; want an error", status, err)
+	}
+}
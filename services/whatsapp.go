@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"customflow/config"
+	"customflow/models"
+)
+
+// WhatsAppWebhook is the envelope the Cloud API posts for message events
+type WhatsAppWebhook struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string        `json:"field"`
+			Value WhatsAppValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type WhatsAppValue struct {
+	MessagingProduct string `json:"messaging_product"`
+	Metadata         struct {
+		DisplayPhoneNumber string `json:"display_phone_number"`
+		PhoneNumberID      string `json:"phone_number_id"`
+	} `json:"metadata"`
+	Contacts []struct {
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+		WaID string `json:"wa_id"`
+	} `json:"contacts"`
+	Messages []WhatsAppMessage `json:"messages"`
+}
+
+type WhatsAppMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      *struct {
+		Body string `json:"body"`
+	} `json:"text,omitempty"`
+	Image    *WhatsAppMedia `json:"image,omitempty"`
+	Document *WhatsAppMedia `json:"document,omitempty"`
+}
+
+type WhatsAppMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+}
+
+type whatsAppInboundSettings struct {
+	AppSecret   string
+	VerifyToken string
+	AccessToken string
+	APIURL      string
+	Client      *http.Client
+}
+
+var whatsAppInbound whatsAppInboundSettings
+
+// InitWhatsAppInbound configures the inbound WhatsApp webhook. Without an app
+// secret to check X-Hub-Signature-256 every webhook is rejected; without an
+// access token, shared with outbound templates, media is not downloaded.
+func InitWhatsAppInbound(cfg config.WhatsAppConfig) {
+	whatsAppInbound = whatsAppInboundSettings{
+		AppSecret:   cfg.AppSecret,
+		VerifyToken: cfg.VerifyToken,
+		AccessToken: cfg.AccessToken,
+		APIURL:      strings.TrimRight(cfg.APIURL, "/"),
+		Client:      &http.Client{Timeout: 60 * time.Second},
+	}
+
+	if whatsAppInbound.AppSecret == "" {
+		log.Println("WARNING: whatsapp.app_secret (WHATSAPP_APP_SECRET) not set. Inbound WhatsApp webhooks will be rejected.")
+	}
+	if whatsAppInbound.AccessToken == "" {
+		log.Println("WARNING: whatsapp.access_token (WHATSAPP_ACCESS_TOKEN) not set. WhatsApp media will not be downloaded.")
+	}
+}
+
+// VerifyWhatsAppChallenge checks the hub.mode/hub.verify_token pair from the subscription handshake
+func VerifyWhatsAppChallenge(mode, token string) bool {
+	return mode == "subscribe" && whatsAppInbound.VerifyToken != "" &&
+		hmac.Equal([]byte(token), []byte(whatsAppInbound.VerifyToken))
+}
+
+// VerifyWhatsAppSignature checks the X-Hub-Signature-256 header against the raw body.
+// Nothing verifies without an app secret.
+func VerifyWhatsAppSignature(body []byte, header string) bool {
+	if whatsAppInbound.AppSecret == "" {
+		return false
+	}
+
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(whatsAppInbound.AppSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// HandleWhatsAppWebhook stores every new message in the payload and processes
+// them in the background. Messages already seen (webhook retries) are skipped.
+// It returns the number of new messages.
+func HandleWhatsAppWebhook(body []byte) (int, error) {
+	var payload WhatsAppWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return 0, fmt.Errorf("invalid WhatsApp payload: %v", err)
+	}
+
+	stored := 0
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+
+			names := map[string]string{}
+			for _, contact := range change.Value.Contacts {
+				names[contact.WaID] = contact.Profile.Name
+			}
+
+			for _, msg := range change.Value.Messages {
+				raw, _ := json.Marshal(msg)
+				message := models.ChannelMessage{
+					Channel:      "whatsapp",
+					Direction:    "inbound",
+					ExternalID:   msg.ID,
+					PhoneNumber:  "+" + digitsOnly(msg.From),
+					CustomerName: names[msg.From],
+					Body:         whatsAppMessageText(msg),
+					MediaFiles:   models.StringList{},
+					Status:       MessageReceived,
+					RawPayload:   models.JSONRaw(raw),
+				}
+
+				created, err := insertInboundMessage(&message)
+				if err != nil {
+					return stored, err
+				}
+				if !created {
+					log.Printf("WhatsApp: message %s already received, skipping", msg.ID)
+					continue
+				}
+
+				stored++
+				go processWhatsAppMessage(message, msg)
+			}
+		}
+	}
+
+	return stored, nil
+}
+
+// processWhatsAppMessage downloads the media and feeds the message into a draft order
+func processWhatsAppMessage(message models.ChannelMessage, msg WhatsAppMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("WhatsApp: processing message %s panicked: %v", msg.ID, r)
+		}
+	}()
+
+	var problems []string
+	for _, media := range []*WhatsAppMedia{msg.Image, msg.Document} {
+		if media == nil || !strings.HasPrefix(media.MimeType, "image/") {
+			continue
+		}
+		filename, err := downloadWhatsAppMedia(media)
+		if err != nil {
+			log.Printf("WhatsApp: failed to download media %s: %v", media.ID, err)
+			problems = append(problems, err.Error())
+			continue
+		}
+		message.MediaFiles = append(message.MediaFiles, filename)
+	}
+
+	draft, err := UpdateDraftFromMessage(context.Background(), DraftInput{
+		Source:       "whatsapp",
+		PhoneNumber:  message.PhoneNumber,
+		CustomerName: message.CustomerName,
+		Text:         message.Body,
+		ImageFiles:   message.MediaFiles,
+	})
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		message.DraftOrderID = &draft.ID
+	}
+
+	message.Status = MessageProcessed
+	if draft == nil {
+		message.Status = MessageFailed
+	}
+	message.Error = strings.Join(problems, "; ")
+
+	if err := config.DB.Save(&message).Error; err != nil {
+		log.Printf("WhatsApp: failed to update message %d: %v", message.ID, err)
+	}
+}
+
+// downloadWhatsAppMedia resolves a media ID to its URL and saves the file into the uploads directory
+func downloadWhatsAppMedia(media *WhatsAppMedia) (string, error) {
+	if whatsAppInbound.AccessToken == "" {
+		return "", fmt.Errorf("WHATSAPP_ACCESS_TOKEN not configured")
+	}
+
+	var info struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+	}
+	resp, err := whatsAppGet(whatsAppInbound.APIURL + "/" + media.ID)
+	if err != nil {
+		return "", err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if err != nil || info.URL == "" {
+		return "", fmt.Errorf("invalid media lookup response for %s", media.ID)
+	}
+
+	resp, err = whatsAppGet(info.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	ext := ".jpg"
+	if exts, _ := mime.ExtensionsByType(media.MimeType); len(exts) > 0 {
+		ext = exts[len(exts)-1]
+	}
+	filename := fmt.Sprintf("wa_%s_%d%s", randomHex(8), time.Now().Unix(), ext)
+
+	if err := os.MkdirAll(UploadSettings().Dir, 0755); err != nil {
+		return "", err
+	}
+	file, err := os.Create(UploadPath(filename))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, io.LimitReader(resp.Body, 16<<20)); err != nil {
+		return "", fmt.Errorf("failed to save media %s: %v", media.ID, err)
+	}
+	return filename, nil
+}
+
+func whatsAppGet(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+whatsAppInbound.AccessToken)
+
+	resp, err := whatsAppInbound.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("WhatsApp API error (status %d) for %s", resp.StatusCode, url)
+	}
+	return resp, nil
+}
+
+func whatsAppMessageText(msg WhatsAppMessage) string {
+	switch {
+	case msg.Text != nil:
+		return msg.Text.Body
+	case msg.Image != nil:
+		return msg.Image.Caption
+	case msg.Document != nil:
+		return msg.Document.Caption
+	}
+	return ""
+}